@@ -0,0 +1,91 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"com.MixieMelts.cart/internal/database"
+	"com.MixieMelts.cart/internal/handlers"
+	"com.MixieMelts.cart/internal/inventory"
+	"com.MixieMelts.cart/internal/products"
+	"com.MixieMelts.users/jwtverify"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	// Load .env file if present
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found or failed to load; falling back to environment variables")
+	}
+
+	// Create database connection
+	dbURL := os.Getenv("DATABASE_URL")
+	if dbURL == "" {
+		log.Fatal("DATABASE_URL must be set")
+	}
+
+	db, err := database.New(dbURL)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	productsURL := os.Getenv("PRODUCTS_URL")
+	if productsURL == "" {
+		productsURL = "http://products:8082"
+	}
+
+	// Carts hold stock through inventory's internal routes, which need the
+	// shared INTERNAL_API_TOKEN
+	inventoryURL := os.Getenv("INVENTORY_URL")
+	if inventoryURL == "" {
+		inventoryURL = "http://inventory:8083"
+	}
+
+	// Tokens are verified against the users service's published keys
+	verifier, err := jwtverify.Configure(os.Getenv("JWKS_URL"), os.Getenv("JWT_SECRET_KEY"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Initialize handlers with the db and the products and inventory clients
+	h := handlers.New(db, products.New(productsURL), inventory.New(inventoryURL, os.Getenv("INTERNAL_API_TOKEN")), verifier)
+
+	// Router setup
+	r := chi.NewRouter()
+	r.Use(middleware.Logger)
+	r.Use(middleware.Recoverer)
+	r.Use(middleware.Timeout(60 * time.Second))
+
+	// Cart routes - keyed by the JWT subject, or by X-Cart-ID for guests
+	r.Group(func(r chi.Router) {
+		r.Use(h.OwnerMiddleware)
+		r.Get("/cart", h.GetCart)
+		r.Delete("/cart", h.ClearCart)
+		r.Post("/cart/items", h.AddItem)
+		r.Put("/cart/items/{productID}", h.UpdateItem)
+		r.Delete("/cart/items/{productID}", h.RemoveItem)
+		r.Post("/cart/merge", h.MergeCart)
+	})
+
+	// Health check
+	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	})
+
+	// Start server
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8084"
+	}
+
+	log.Printf("Starting cart service on port %s", port)
+	if err := http.ListenAndServe(":"+port, r); err != nil {
+		log.Fatalf("Failed to start server: %v", err)
+	}
+}
@@ -0,0 +1,271 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"com.MixieMelts.cart/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+func (db *DB) createCartsTable(ctx context.Context) error {
+	query := `CREATE TABLE IF NOT EXISTS carts (
+		id SERIAL PRIMARY KEY,
+		user_id TEXT UNIQUE,
+		anonymous_id TEXT UNIQUE,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		CHECK (user_id IS NOT NULL OR anonymous_id IS NOT NULL)
+	);`
+	if _, err := db.Exec(ctx, query); err != nil {
+		return err
+	}
+	_, err := db.Exec(ctx, `ALTER TABLE carts ADD COLUMN IF NOT EXISTS hold_reference TEXT`)
+	return err
+}
+
+func (db *DB) createCartItemsTable(ctx context.Context) error {
+	query := `CREATE TABLE IF NOT EXISTS cart_items (
+		id SERIAL PRIMARY KEY,
+		cart_id BIGINT NOT NULL REFERENCES carts(id) ON DELETE CASCADE,
+		product_id BIGINT NOT NULL,
+		name TEXT NOT NULL,
+		unit_price NUMERIC(10, 2) NOT NULL,
+		quantity INTEGER NOT NULL CHECK (quantity > 0),
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (cart_id, product_id)
+	);`
+	_, err := db.Exec(ctx, query)
+	return err
+}
+
+// ownerColumn returns the carts column and value that identify owner.
+func ownerColumn(owner models.CartOwner) (string, string) {
+	if owner.IsAnonymous() {
+		return "anonymous_id", owner.AnonymousID
+	}
+	return "user_id", owner.UserID
+}
+
+// ensureCart returns the id of owner's cart, creating it if needed.
+func ensureCart(ctx context.Context, tx pgx.Tx, owner models.CartOwner) (int64, error) {
+	col, val := ownerColumn(owner)
+	_, err := tx.Exec(ctx, fmt.Sprintf(`INSERT INTO carts (%s) VALUES ($1) ON CONFLICT DO NOTHING`, col), val)
+	if err != nil {
+		return 0, fmt.Errorf("ensureCart insert: %w", err)
+	}
+	var id int64
+	if err := tx.QueryRow(ctx, fmt.Sprintf(`SELECT id FROM carts WHERE %s = $1 FOR UPDATE`, col), val).Scan(&id); err != nil {
+		return 0, fmt.Errorf("ensureCart select: %w", err)
+	}
+	return id, nil
+}
+
+// GetCart returns owner's cart. A cart that has never been written to is
+// returned empty rather than as an error.
+func (db *DB) GetCart(ctx context.Context, owner models.CartOwner) (*models.Cart, error) {
+	col, val := ownerColumn(owner)
+	cart := &models.Cart{Items: []models.CartItem{}}
+	var userID, anonID, hold *string
+	err := db.QueryRow(ctx, fmt.Sprintf(`SELECT id, user_id, anonymous_id, hold_reference, created_at, updated_at FROM carts WHERE %s = $1`, col), val).
+		Scan(&cart.ID, &userID, &anonID, &hold, &cart.CreatedAt, &cart.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			cart.UserID = owner.UserID
+			cart.AnonymousID = owner.AnonymousID
+			return cart, nil
+		}
+		return nil, fmt.Errorf("GetCart: %w", err)
+	}
+	if userID != nil {
+		cart.UserID = *userID
+	}
+	if anonID != nil {
+		cart.AnonymousID = *anonID
+	}
+	if hold != nil {
+		cart.HoldReference = *hold
+	}
+
+	rows, err := db.Query(ctx, `SELECT product_id, name, unit_price, quantity, created_at, updated_at FROM cart_items WHERE cart_id = $1 ORDER BY id`, cart.ID)
+	if err != nil {
+		return nil, fmt.Errorf("GetCart items: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var it models.CartItem
+		if err := rows.Scan(&it.ProductID, &it.Name, &it.UnitPrice, &it.Quantity, &it.CreatedAt, &it.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("GetCart items scan: %w", err)
+		}
+		cart.Items = append(cart.Items, it)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("GetCart items rows: %w", err)
+	}
+	cart.ComputeSubtotal()
+	return cart, nil
+}
+
+// AddItem adds item to owner's cart, incrementing the quantity if the product
+// is already present. The stored name and price are refreshed from item.
+func (db *DB) AddItem(ctx context.Context, owner models.CartOwner, item models.CartItem) (*models.Cart, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("AddItem begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	cartID, err := ensureCart(ctx, tx, owner)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(ctx, `INSERT INTO cart_items (cart_id, product_id, name, unit_price, quantity) VALUES ($1,$2,$3,$4,$5)
+		ON CONFLICT (cart_id, product_id) DO UPDATE
+		SET quantity = cart_items.quantity + EXCLUDED.quantity, name = EXCLUDED.name, unit_price = EXCLUDED.unit_price, updated_at = NOW()`,
+		cartID, item.ProductID, item.Name, item.UnitPrice, item.Quantity)
+	if err != nil {
+		return nil, fmt.Errorf("AddItem upsert: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `UPDATE carts SET updated_at = NOW() WHERE id = $1`, cartID); err != nil {
+		return nil, fmt.Errorf("AddItem touch cart: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("AddItem commit: %w", err)
+	}
+	return db.GetCart(ctx, owner)
+}
+
+// SetItem sets the quantity (and refreshed name/price) of a product line in
+// owner's cart. A quantity of zero or less removes the line.
+func (db *DB) SetItem(ctx context.Context, owner models.CartOwner, item models.CartItem) (*models.Cart, error) {
+	if item.Quantity <= 0 {
+		return db.RemoveItem(ctx, owner, item.ProductID)
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("SetItem begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	cartID, err := ensureCart(ctx, tx, owner)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(ctx, `INSERT INTO cart_items (cart_id, product_id, name, unit_price, quantity) VALUES ($1,$2,$3,$4,$5)
+		ON CONFLICT (cart_id, product_id) DO UPDATE
+		SET quantity = EXCLUDED.quantity, name = EXCLUDED.name, unit_price = EXCLUDED.unit_price, updated_at = NOW()`,
+		cartID, item.ProductID, item.Name, item.UnitPrice, item.Quantity)
+	if err != nil {
+		return nil, fmt.Errorf("SetItem upsert: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `UPDATE carts SET updated_at = NOW() WHERE id = $1`, cartID); err != nil {
+		return nil, fmt.Errorf("SetItem touch cart: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("SetItem commit: %w", err)
+	}
+	return db.GetCart(ctx, owner)
+}
+
+// RemoveItem deletes a product line from owner's cart.
+func (db *DB) RemoveItem(ctx context.Context, owner models.CartOwner, productID int64) (*models.Cart, error) {
+	col, val := ownerColumn(owner)
+	_, err := db.Exec(ctx, fmt.Sprintf(`DELETE FROM cart_items WHERE product_id = $1 AND cart_id = (SELECT id FROM carts WHERE %s = $2)`, col), productID, val)
+	if err != nil {
+		return nil, fmt.Errorf("RemoveItem: %w", err)
+	}
+	return db.GetCart(ctx, owner)
+}
+
+// SetHold records reference as the inventory hold for owner's cart, creating
+// the cart if needed, and returns the hold it replaces. An empty reference
+// clears it.
+func (db *DB) SetHold(ctx context.Context, owner models.CartOwner, reference string) (string, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return "", fmt.Errorf("SetHold begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	cartID, err := ensureCart(ctx, tx, owner)
+	if err != nil {
+		return "", err
+	}
+	var previous *string
+	if err := tx.QueryRow(ctx, `SELECT hold_reference FROM carts WHERE id = $1`, cartID).Scan(&previous); err != nil {
+		return "", fmt.Errorf("SetHold select: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `UPDATE carts SET hold_reference = NULLIF($1, '') WHERE id = $2`, reference, cartID); err != nil {
+		return "", fmt.Errorf("SetHold update: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return "", fmt.Errorf("SetHold commit: %w", err)
+	}
+	if previous == nil {
+		return "", nil
+	}
+	return *previous, nil
+}
+
+// ClearCart deletes owner's cart and all of its items.
+func (db *DB) ClearCart(ctx context.Context, owner models.CartOwner) error {
+	col, val := ownerColumn(owner)
+	if _, err := db.Exec(ctx, fmt.Sprintf(`DELETE FROM carts WHERE %s = $1`, col), val); err != nil {
+		return fmt.Errorf("ClearCart: %w", err)
+	}
+	return nil
+}
+
+// MergeCarts moves the lines of the anonymous cart into userID's cart,
+// summing quantities for products present in both, and deletes the anonymous
+// cart. Like AddItem, each moved line takes its name and price from items,
+// the anonymous cart's products as the catalog has them now; lines whose
+// product is not in items are dropped. The operation is transactional.
+func (db *DB) MergeCarts(ctx context.Context, anonymousID, userID string, items []models.CartItem) (*models.Cart, error) {
+	userOwner := models.CartOwner{UserID: userID}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("MergeCarts begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var anonCartID int64
+	err = tx.QueryRow(ctx, `SELECT id FROM carts WHERE anonymous_id = $1 FOR UPDATE`, anonymousID).Scan(&anonCartID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Nothing to merge.
+			return db.GetCart(ctx, userOwner)
+		}
+		return nil, fmt.Errorf("MergeCarts select anonymous cart: %w", err)
+	}
+
+	userCartID, err := ensureCart(ctx, tx, userOwner)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range items {
+		_, err = tx.Exec(ctx, `INSERT INTO cart_items (cart_id, product_id, name, unit_price, quantity)
+			SELECT $1, product_id, $3, $4, quantity FROM cart_items WHERE cart_id = $2 AND product_id = $5
+			ON CONFLICT (cart_id, product_id) DO UPDATE
+			SET quantity = cart_items.quantity + EXCLUDED.quantity, name = EXCLUDED.name, unit_price = EXCLUDED.unit_price, updated_at = NOW()`,
+			userCartID, anonCartID, item.Name, item.UnitPrice, item.ProductID)
+		if err != nil {
+			return nil, fmt.Errorf("MergeCarts copy product %d: %w", item.ProductID, err)
+		}
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM carts WHERE id = $1`, anonCartID); err != nil {
+		return nil, fmt.Errorf("MergeCarts delete anonymous cart: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `UPDATE carts SET updated_at = NOW() WHERE id = $1`, userCartID); err != nil {
+		return nil, fmt.Errorf("MergeCarts touch cart: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("MergeCarts commit: %w", err)
+	}
+	return db.GetCart(ctx, userOwner)
+}
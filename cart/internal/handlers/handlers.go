@@ -0,0 +1,547 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"com.MixieMelts.cart/internal/inventory"
+	"com.MixieMelts.cart/internal/models"
+	"com.MixieMelts.cart/internal/products"
+	"com.MixieMelts.users/jwtverify"
+	"github.com/go-chi/chi/v5"
+)
+
+// CartIDHeader carries the anonymous cart id between the client and the
+// cart service. Guests keep the value the service hands back and send it on
+// every request until they log in and merge the cart.
+const CartIDHeader = "X-Cart-ID"
+
+var anonymousIDPattern = regexp.MustCompile(`^[a-f0-9]{32}$`)
+
+type ctxKey string
+
+const ownerKey ctxKey = "cartOwner"
+
+// DBLayer is the persistence interface used by the handlers.
+type DBLayer interface {
+	GetCart(ctx context.Context, owner models.CartOwner) (*models.Cart, error)
+	AddItem(ctx context.Context, owner models.CartOwner, item models.CartItem) (*models.Cart, error)
+	SetItem(ctx context.Context, owner models.CartOwner, item models.CartItem) (*models.Cart, error)
+	RemoveItem(ctx context.Context, owner models.CartOwner, productID int64) (*models.Cart, error)
+	ClearCart(ctx context.Context, owner models.CartOwner) error
+	MergeCarts(ctx context.Context, anonymousID, userID string, items []models.CartItem) (*models.Cart, error)
+	SetHold(ctx context.Context, owner models.CartOwner, reference string) (string, error)
+}
+
+// ProductCatalog looks up authoritative product data (name and price).
+type ProductCatalog interface {
+	GetProduct(ctx context.Context, id int64) (*products.Product, error)
+}
+
+// Inventory holds stock for the products in a cart.
+type Inventory interface {
+	Reserve(ctx context.Context, reference string, lines []inventory.Line) error
+	Release(ctx context.Context, reference string) error
+}
+
+// Handler provides HTTP handlers for the cart service.
+type Handler struct {
+	db        DBLayer
+	catalog   ProductCatalog
+	inventory Inventory
+	verifier  *jwtverify.Verifier
+}
+
+// New creates a new Handler. verifier checks the users service's tokens.
+func New(db DBLayer, catalog ProductCatalog, inv Inventory, verifier *jwtverify.Verifier) *Handler {
+	return &Handler{db: db, catalog: catalog, inventory: inv, verifier: verifier}
+}
+
+// ItemPayload is the request body for adding or updating a cart line.
+type ItemPayload struct {
+	ProductID int64 `json:"product_id"`
+	Quantity  int   `json:"quantity"`
+	// UnitPrice is the price the client showed the customer. When present it
+	// must match the current product price or the request is rejected.
+	UnitPrice *float64 `json:"unit_price,omitempty"`
+}
+
+// MergePayload is the request body for merging an anonymous cart.
+type MergePayload struct {
+	AnonymousID string `json:"anonymous_id"`
+}
+
+// GetCart returns the caller's cart.
+func (h *Handler) GetCart(w http.ResponseWriter, r *http.Request) {
+	owner := ownerFromContext(r.Context())
+	cart, err := h.db.GetCart(r.Context(), owner)
+	if err != nil {
+		log.Printf("GetCart error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to get cart")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, cart)
+}
+
+// AddItem adds a product to the caller's cart.
+func (h *Handler) AddItem(w http.ResponseWriter, r *http.Request) {
+	var p ItemPayload
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if p.Quantity == 0 {
+		p.Quantity = 1
+	}
+	if p.Quantity < 0 {
+		respondWithError(w, http.StatusBadRequest, "quantity must be positive")
+		return
+	}
+
+	item, ok := h.validateItem(w, r, p)
+	if !ok {
+		return
+	}
+
+	owner := ownerFromContext(r.Context())
+	current, err := h.db.GetCart(r.Context(), owner)
+	if err != nil {
+		log.Printf("AddItem get cart error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to add item")
+		return
+	}
+	want := quantities(current.Items)
+	want[item.ProductID] += item.Quantity
+	hold, ok := h.holdFor(w, r, "AddItem", owner, current, want)
+	if !ok {
+		return
+	}
+
+	cart, err := h.db.AddItem(r.Context(), owner, item)
+	if err != nil {
+		log.Printf("AddItem error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to add item")
+		return
+	}
+	h.saveHold(r.Context(), owner, cart, hold)
+	respondWithJSON(w, http.StatusOK, cart)
+}
+
+// UpdateItem sets the quantity of a product in the caller's cart. A quantity
+// of zero removes the line.
+func (h *Handler) UpdateItem(w http.ResponseWriter, r *http.Request) {
+	productID, err := strconv.ParseInt(chi.URLParam(r, "productID"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid product id")
+		return
+	}
+
+	var p ItemPayload
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if p.Quantity < 0 {
+		respondWithError(w, http.StatusBadRequest, "quantity must not be negative")
+		return
+	}
+	p.ProductID = productID
+
+	owner := ownerFromContext(r.Context())
+	if p.Quantity == 0 {
+		h.removeItem(w, r, "UpdateItem", productID)
+		return
+	}
+
+	item, ok := h.validateItem(w, r, p)
+	if !ok {
+		return
+	}
+
+	current, err := h.db.GetCart(r.Context(), owner)
+	if err != nil {
+		log.Printf("UpdateItem get cart error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to update item")
+		return
+	}
+	want := quantities(current.Items)
+	want[item.ProductID] = item.Quantity
+	hold, ok := h.holdFor(w, r, "UpdateItem", owner, current, want)
+	if !ok {
+		return
+	}
+
+	cart, err := h.db.SetItem(r.Context(), owner, item)
+	if err != nil {
+		log.Printf("UpdateItem error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to update item")
+		return
+	}
+	h.saveHold(r.Context(), owner, cart, hold)
+	respondWithJSON(w, http.StatusOK, cart)
+}
+
+// RemoveItem deletes a product from the caller's cart.
+func (h *Handler) RemoveItem(w http.ResponseWriter, r *http.Request) {
+	productID, err := strconv.ParseInt(chi.URLParam(r, "productID"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid product id")
+		return
+	}
+
+	h.removeItem(w, r, "RemoveItem", productID)
+}
+
+// removeItem deletes a product from the caller's cart and shrinks its hold
+// to match.
+func (h *Handler) removeItem(w http.ResponseWriter, r *http.Request, op string, productID int64) {
+	owner := ownerFromContext(r.Context())
+	current, err := h.db.GetCart(r.Context(), owner)
+	if err != nil {
+		log.Printf("%s get cart error: %v", op, err)
+		respondWithError(w, http.StatusInternalServerError, "failed to remove item")
+		return
+	}
+
+	cart, err := h.db.RemoveItem(r.Context(), owner, productID)
+	if err != nil {
+		log.Printf("%s error: %v", op, err)
+		respondWithError(w, http.StatusInternalServerError, "failed to remove item")
+		return
+	}
+	if current.HoldReference != "" {
+		// A smaller hold only fails if inventory does; the cart then goes
+		// without one until its next change.
+		hold, err := h.rehold(r.Context(), current, quantities(cart.Items))
+		if err != nil {
+			log.Printf("%s hold error: %v", op, err)
+		}
+		h.saveHold(r.Context(), owner, cart, hold)
+	}
+	respondWithJSON(w, http.StatusOK, cart)
+}
+
+// ClearCart empties the caller's cart and releases its hold.
+func (h *Handler) ClearCart(w http.ResponseWriter, r *http.Request) {
+	owner := ownerFromContext(r.Context())
+	current, err := h.db.GetCart(r.Context(), owner)
+	if err != nil {
+		log.Printf("ClearCart get cart error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to clear cart")
+		return
+	}
+	if err := h.db.ClearCart(r.Context(), owner); err != nil {
+		log.Printf("ClearCart error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to clear cart")
+		return
+	}
+	h.release(r.Context(), current.HoldReference)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MergeCart folds the guest cart identified by the request body (or the
+// X-Cart-ID header) into the signed-in user's cart. Clients call this right
+// after login so items added before signing in are not lost.
+func (h *Handler) MergeCart(w http.ResponseWriter, r *http.Request) {
+	owner := ownerFromContext(r.Context())
+	if owner.IsAnonymous() {
+		respondWithError(w, http.StatusUnauthorized, "login required to merge carts")
+		return
+	}
+
+	var p MergePayload
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+	}
+	if p.AnonymousID == "" {
+		p.AnonymousID = r.Header.Get(CartIDHeader)
+	}
+	if !anonymousIDPattern.MatchString(p.AnonymousID) {
+		respondWithError(w, http.StatusBadRequest, "anonymous_id required")
+		return
+	}
+
+	guest, err := h.db.GetCart(r.Context(), models.CartOwner{AnonymousID: p.AnonymousID})
+	if err != nil {
+		log.Printf("MergeCart get guest cart error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to merge carts")
+		return
+	}
+	current, err := h.db.GetCart(r.Context(), owner)
+	if err != nil {
+		log.Printf("MergeCart get cart error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to merge carts")
+		return
+	}
+
+	items, ok := h.refreshItems(w, r, guest.Items)
+	if !ok {
+		return
+	}
+
+	cart, err := h.db.MergeCarts(r.Context(), p.AnonymousID, owner.UserID, items)
+	if err != nil {
+		log.Printf("MergeCart error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to merge carts")
+		return
+	}
+	if len(guest.Items) > 0 {
+		// The guest's lines are kept even if the merged cart cannot be held
+		// in full; checkout reserves again anyway.
+		h.release(r.Context(), guest.HoldReference)
+		hold, err := h.rehold(r.Context(), current, quantities(cart.Items))
+		if err != nil {
+			log.Printf("MergeCart hold error: %v", err)
+		}
+		h.saveHold(r.Context(), owner, cart, hold)
+	}
+	respondWithJSON(w, http.StatusOK, cart)
+}
+
+// validateItem checks the product id and price against the products service
+// and returns the cart line to store. On failure it writes the response and
+// returns false.
+func (h *Handler) validateItem(w http.ResponseWriter, r *http.Request, p ItemPayload) (models.CartItem, bool) {
+	if p.ProductID <= 0 {
+		respondWithError(w, http.StatusBadRequest, "product_id required")
+		return models.CartItem{}, false
+	}
+
+	prod, err := h.catalog.GetProduct(r.Context(), p.ProductID)
+	if errors.Is(err, products.ErrNotFound) {
+		respondWithError(w, http.StatusBadRequest, "unknown product_id")
+		return models.CartItem{}, false
+	}
+	if err != nil {
+		log.Printf("validateItem product %d error: %v", p.ProductID, err)
+		respondWithError(w, http.StatusBadGateway, "failed to validate product")
+		return models.CartItem{}, false
+	}
+
+	if p.UnitPrice != nil && math.Abs(*p.UnitPrice-prod.Price) >= 0.005 {
+		respondWithJSON(w, http.StatusConflict, map[string]any{
+			"message":       "price has changed",
+			"product_id":    prod.ID,
+			"current_price": prod.Price,
+		})
+		return models.CartItem{}, false
+	}
+
+	return models.CartItem{
+		ProductID: prod.ID,
+		Name:      prod.Name,
+		UnitPrice: prod.Price,
+		Quantity:  p.Quantity,
+	}, true
+}
+
+// refreshItems looks up the products of a cart's lines and returns the
+// lines with their current name and price, leaving out products the catalog
+// no longer has. On failure it writes the response and returns false.
+func (h *Handler) refreshItems(w http.ResponseWriter, r *http.Request, items []models.CartItem) ([]models.CartItem, bool) {
+	refreshed := make([]models.CartItem, 0, len(items))
+	for _, item := range items {
+		prod, err := h.catalog.GetProduct(r.Context(), item.ProductID)
+		if errors.Is(err, products.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			log.Printf("refreshItems product %d error: %v", item.ProductID, err)
+			respondWithError(w, http.StatusBadGateway, "failed to validate product")
+			return nil, false
+		}
+		item.Name, item.UnitPrice = prod.Name, prod.Price
+		refreshed = append(refreshed, item)
+	}
+	return refreshed, true
+}
+
+// holdFor swaps the cart's stock hold for one covering want before the cart
+// is changed, so a customer cannot put more in their cart than inventory can
+// promise. It returns the reference that now holds the cart. If inventory
+// refuses the hold it records the one put back, writes a 409 and returns
+// false. Other inventory failures are logged and the change goes ahead;
+// checkout reserves stock again in any case.
+func (h *Handler) holdFor(w http.ResponseWriter, r *http.Request, op string, owner models.CartOwner, current *models.Cart, want map[int64]int) (string, bool) {
+	hold, err := h.rehold(r.Context(), current, want)
+	if errors.Is(err, inventory.ErrInsufficientStock) {
+		h.saveHold(r.Context(), owner, current, hold)
+		respondWithError(w, http.StatusConflict, "not enough stock")
+		return "", false
+	}
+	if err != nil {
+		log.Printf("%s hold error: %v", op, err)
+	}
+	return hold, true
+}
+
+// rehold replaces cart's hold with one for want and returns the reference
+// that holds the cart afterwards. The cart's own hold is released first so
+// it does not count against the new one; if the new hold is refused, the
+// cart's current lines are held again so it keeps what it had.
+func (h *Handler) rehold(ctx context.Context, cart *models.Cart, want map[int64]int) (string, error) {
+	if cart.HoldReference != "" {
+		if err := h.inventory.Release(ctx, cart.HoldReference); err != nil {
+			return cart.HoldReference, err
+		}
+	}
+	hold, err := h.hold(ctx, want)
+	if errors.Is(err, inventory.ErrInsufficientStock) && len(cart.Items) > 0 {
+		restored, rerr := h.hold(ctx, quantities(cart.Items))
+		if rerr != nil {
+			log.Printf("rehold restore error: %v", rerr)
+		}
+		return restored, err
+	}
+	return hold, err
+}
+
+// hold reserves stock for want under a new reference and returns it, or ""
+// if want is empty or the hold could not be made.
+func (h *Handler) hold(ctx context.Context, want map[int64]int) (string, error) {
+	var lines []inventory.Line
+	for productID, qty := range want {
+		if qty > 0 {
+			lines = append(lines, inventory.Line{ProductID: productID, Quantity: float64(qty)})
+		}
+	}
+	if len(lines) == 0 {
+		return "", nil
+	}
+	reference, err := newHoldReference()
+	if err != nil {
+		return "", err
+	}
+	if err := h.inventory.Reserve(ctx, reference, lines); err != nil {
+		return "", err
+	}
+	return reference, nil
+}
+
+// saveHold records hold on owner's cart and releases any other hold the
+// cart had, such as one left by a concurrent change.
+func (h *Handler) saveHold(ctx context.Context, owner models.CartOwner, cart *models.Cart, hold string) {
+	if hold == "" && cart.HoldReference == "" {
+		return
+	}
+	cart.HoldReference = hold
+	previous, err := h.db.SetHold(ctx, owner, hold)
+	if err != nil {
+		log.Printf("saveHold error: %v", err)
+		h.release(ctx, hold)
+		cart.HoldReference = ""
+		return
+	}
+	if previous != hold {
+		h.release(ctx, previous)
+	}
+}
+
+// release gives up a hold, logging failures; an unreleased hold still
+// lapses on its own.
+func (h *Handler) release(ctx context.Context, hold string) {
+	if hold == "" {
+		return
+	}
+	if err := h.inventory.Release(ctx, hold); err != nil {
+		log.Printf("release hold %s error: %v", hold, err)
+	}
+}
+
+// quantities maps each product in items to its quantity.
+func quantities(items []models.CartItem) map[int64]int {
+	want := map[int64]int{}
+	for _, it := range items {
+		want[it.ProductID] += it.Quantity
+	}
+	return want
+}
+
+// newHoldReference returns a fresh reservation reference for a cart hold.
+// Inventory keeps references forever, so every hold needs a new one.
+func newHoldReference() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("hold reference: %w", err)
+	}
+	return "cart-" + hex.EncodeToString(b), nil
+}
+
+// --- UTILITY & MIDDLEWARE ---
+
+// OwnerMiddleware resolves whose cart a request operates on. Requests with a
+// valid bearer token use the token's subject; requests without one use the
+// anonymous cart id from the X-Cart-ID header, minting a new id when the
+// header is missing.
+func (h *Handler) OwnerMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var owner models.CartOwner
+
+		if authHeader := r.Header.Get("Authorization"); authHeader != "" {
+			tokenString, ok := strings.CutPrefix(authHeader, "Bearer ")
+			if !ok {
+				respondWithError(w, http.StatusUnauthorized, "invalid authorization header")
+				return
+			}
+			claims := &jwtverify.Claims{}
+			if err := h.verifier.Parse(tokenString, claims); err != nil || claims.Subject == "" {
+				respondWithError(w, http.StatusUnauthorized, "invalid token")
+				return
+			}
+			owner.UserID = claims.Subject
+		} else {
+			owner.AnonymousID = r.Header.Get(CartIDHeader)
+			if !anonymousIDPattern.MatchString(owner.AnonymousID) {
+				id, err := newAnonymousID()
+				if err != nil {
+					log.Printf("OwnerMiddleware error: %v", err)
+					respondWithError(w, http.StatusInternalServerError, "failed to create cart id")
+					return
+				}
+				owner.AnonymousID = id
+			}
+			w.Header().Set(CartIDHeader, owner.AnonymousID)
+		}
+
+		ctx := context.WithValue(r.Context(), ownerKey, owner)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func ownerFromContext(ctx context.Context) models.CartOwner {
+	owner, _ := ctx.Value(ownerKey).(models.CartOwner)
+	return owner
+}
+
+func newAnonymousID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("anonymous id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func respondWithError(w http.ResponseWriter, code int, message string) {
+	respondWithJSON(w, code, map[string]string{"message": message})
+}
+
+func respondWithJSON(w http.ResponseWriter, code int, payload any) {
+	response, _ := json.Marshal(payload)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(response)
+}
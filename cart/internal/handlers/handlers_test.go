@@ -0,0 +1,463 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"com.MixieMelts.cart/internal/inventory"
+	"com.MixieMelts.cart/internal/models"
+	"com.MixieMelts.cart/internal/products"
+	"com.MixieMelts.users/jwtverify"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// MockDB is a mock implementation of the DBLayer for testing purposes.
+type MockDB struct {
+	GetCartFunc    func(ctx context.Context, owner models.CartOwner) (*models.Cart, error)
+	AddItemFunc    func(ctx context.Context, owner models.CartOwner, item models.CartItem) (*models.Cart, error)
+	SetItemFunc    func(ctx context.Context, owner models.CartOwner, item models.CartItem) (*models.Cart, error)
+	RemoveItemFunc func(ctx context.Context, owner models.CartOwner, productID int64) (*models.Cart, error)
+	ClearCartFunc  func(ctx context.Context, owner models.CartOwner) error
+	MergeCartsFunc func(ctx context.Context, anonymousID, userID string, items []models.CartItem) (*models.Cart, error)
+	SetHoldFunc    func(ctx context.Context, owner models.CartOwner, reference string) (string, error)
+}
+
+func (m *MockDB) GetCart(ctx context.Context, owner models.CartOwner) (*models.Cart, error) {
+	if m.GetCartFunc != nil {
+		return m.GetCartFunc(ctx, owner)
+	}
+	return nil, errors.New("GetCartFunc not implemented")
+}
+
+func (m *MockDB) AddItem(ctx context.Context, owner models.CartOwner, item models.CartItem) (*models.Cart, error) {
+	if m.AddItemFunc != nil {
+		return m.AddItemFunc(ctx, owner, item)
+	}
+	return nil, errors.New("AddItemFunc not implemented")
+}
+
+func (m *MockDB) SetItem(ctx context.Context, owner models.CartOwner, item models.CartItem) (*models.Cart, error) {
+	if m.SetItemFunc != nil {
+		return m.SetItemFunc(ctx, owner, item)
+	}
+	return nil, errors.New("SetItemFunc not implemented")
+}
+
+func (m *MockDB) RemoveItem(ctx context.Context, owner models.CartOwner, productID int64) (*models.Cart, error) {
+	if m.RemoveItemFunc != nil {
+		return m.RemoveItemFunc(ctx, owner, productID)
+	}
+	return nil, errors.New("RemoveItemFunc not implemented")
+}
+
+func (m *MockDB) ClearCart(ctx context.Context, owner models.CartOwner) error {
+	if m.ClearCartFunc != nil {
+		return m.ClearCartFunc(ctx, owner)
+	}
+	return errors.New("ClearCartFunc not implemented")
+}
+
+func (m *MockDB) MergeCarts(ctx context.Context, anonymousID, userID string, items []models.CartItem) (*models.Cart, error) {
+	if m.MergeCartsFunc != nil {
+		return m.MergeCartsFunc(ctx, anonymousID, userID, items)
+	}
+	return nil, errors.New("MergeCartsFunc not implemented")
+}
+
+func (m *MockDB) SetHold(ctx context.Context, owner models.CartOwner, reference string) (string, error) {
+	if m.SetHoldFunc != nil {
+		return m.SetHoldFunc(ctx, owner, reference)
+	}
+	return "", nil
+}
+
+// emptyCart is a GetCartFunc for an owner with nothing in their cart yet.
+func emptyCart(ctx context.Context, owner models.CartOwner) (*models.Cart, error) {
+	return &models.Cart{UserID: owner.UserID, AnonymousID: owner.AnonymousID, Items: []models.CartItem{}}, nil
+}
+
+// MockInventory records the holds made and released.
+type MockInventory struct {
+	ReserveErr error
+	Reserved   map[string][]inventory.Line
+	Released   []string
+}
+
+func (m *MockInventory) Reserve(ctx context.Context, reference string, lines []inventory.Line) error {
+	if m.ReserveErr != nil {
+		return m.ReserveErr
+	}
+	if m.Reserved == nil {
+		m.Reserved = map[string][]inventory.Line{}
+	}
+	m.Reserved[reference] = lines
+	return nil
+}
+
+func (m *MockInventory) Release(ctx context.Context, reference string) error {
+	m.Released = append(m.Released, reference)
+	return nil
+}
+
+// MockCatalog is a mock implementation of ProductCatalog.
+type MockCatalog struct {
+	Products map[int64]products.Product
+	Err      error
+}
+
+func (m *MockCatalog) GetProduct(ctx context.Context, id int64) (*products.Product, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	p, ok := m.Products[id]
+	if !ok {
+		return nil, products.ErrNotFound
+	}
+	return &p, nil
+}
+
+var testSecret = []byte("test-secret")
+
+func signedToken(t *testing.T, sub string) string {
+	t.Helper()
+	claims := &jwt.RegisteredClaims{
+		Subject:   sub,
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+	s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func withOwner(req *http.Request, owner models.CartOwner) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), ownerKey, owner))
+}
+
+func withURLParam(req *http.Request, key, value string) *http.Request {
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add(key, value)
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+}
+
+func TestOwnerMiddleware(t *testing.T) {
+	anonID := "0123456789abcdef0123456789abcdef"
+
+	tests := []struct {
+		name       string
+		authHeader string
+		cartHeader string
+		wantStatus int
+		wantUser   string
+		wantAnon   string // "" means any freshly minted id
+	}{
+		{name: "valid token", authHeader: "Bearer " + signedToken(t, "42"), wantStatus: http.StatusOK, wantUser: "42"},
+		{name: "invalid token", authHeader: "Bearer not-a-token", wantStatus: http.StatusUnauthorized},
+		{name: "malformed header", authHeader: "Token abc", wantStatus: http.StatusUnauthorized},
+		{name: "guest with existing id", cartHeader: anonID, wantStatus: http.StatusOK, wantAnon: anonID},
+		{name: "guest without id", wantStatus: http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			h := New(&MockDB{}, &MockCatalog{}, &MockInventory{}, jwtverify.New(nil, testSecret))
+
+			var got models.CartOwner
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = ownerFromContext(r.Context())
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req, _ := http.NewRequest("GET", "/cart", nil)
+			if tc.authHeader != "" {
+				req.Header.Set("Authorization", tc.authHeader)
+			}
+			if tc.cartHeader != "" {
+				req.Header.Set(CartIDHeader, tc.cartHeader)
+			}
+			rr := httptest.NewRecorder()
+			h.OwnerMiddleware(next).ServeHTTP(rr, req)
+
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("expected status %d got %d; body: %s", tc.wantStatus, rr.Code, rr.Body.String())
+			}
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+			if got.UserID != tc.wantUser {
+				t.Fatalf("expected user %q got %q", tc.wantUser, got.UserID)
+			}
+			if tc.wantUser == "" {
+				if !anonymousIDPattern.MatchString(got.AnonymousID) {
+					t.Fatalf("expected anonymous id, got %q", got.AnonymousID)
+				}
+				if tc.wantAnon != "" && got.AnonymousID != tc.wantAnon {
+					t.Fatalf("expected anonymous id %q got %q", tc.wantAnon, got.AnonymousID)
+				}
+				if rr.Header().Get(CartIDHeader) != got.AnonymousID {
+					t.Fatalf("expected %s header %q got %q", CartIDHeader, got.AnonymousID, rr.Header().Get(CartIDHeader))
+				}
+			}
+		})
+	}
+}
+
+func TestAddItem(t *testing.T) {
+	catalog := &MockCatalog{Products: map[int64]products.Product{
+		1: {ID: 1, Name: "Serene Sanctuary", Price: 5.49},
+	}}
+	price := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name       string
+		payload    any
+		catalogErr error
+		reserveErr error
+		dbErr      error
+		wantStatus int
+		wantQty    int
+		wantHold   bool
+	}{
+		{name: "ok", payload: ItemPayload{ProductID: 1, Quantity: 2}, wantStatus: http.StatusOK, wantQty: 2, wantHold: true},
+		{name: "defaults quantity to one", payload: ItemPayload{ProductID: 1}, wantStatus: http.StatusOK, wantQty: 1, wantHold: true},
+		{name: "matching price", payload: ItemPayload{ProductID: 1, Quantity: 1, UnitPrice: price(5.49)}, wantStatus: http.StatusOK, wantQty: 1, wantHold: true},
+		{name: "out of stock", payload: ItemPayload{ProductID: 1, Quantity: 1}, reserveErr: inventory.ErrInsufficientStock, wantStatus: http.StatusConflict},
+		{name: "inventory down still adds", payload: ItemPayload{ProductID: 1, Quantity: 1}, reserveErr: errors.New("dial tcp"), wantStatus: http.StatusOK, wantQty: 1},
+		{name: "stale price", payload: ItemPayload{ProductID: 1, Quantity: 1, UnitPrice: price(4.99)}, wantStatus: http.StatusConflict},
+		{name: "unknown product", payload: ItemPayload{ProductID: 99, Quantity: 1}, wantStatus: http.StatusBadRequest},
+		{name: "negative quantity", payload: ItemPayload{ProductID: 1, Quantity: -1}, wantStatus: http.StatusBadRequest},
+		{name: "invalid body", payload: "{bad", wantStatus: http.StatusBadRequest},
+		{name: "products service down", payload: ItemPayload{ProductID: 1, Quantity: 1}, catalogErr: errors.New("dial tcp"), wantStatus: http.StatusBadGateway},
+		{name: "db error", payload: ItemPayload{ProductID: 1, Quantity: 1}, dbErr: errors.New("db fail"), wantStatus: http.StatusInternalServerError},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var stored models.CartItem
+			var savedHold string
+			mockDB := &MockDB{
+				GetCartFunc: emptyCart,
+				AddItemFunc: func(ctx context.Context, owner models.CartOwner, item models.CartItem) (*models.Cart, error) {
+					if tc.dbErr != nil {
+						return nil, tc.dbErr
+					}
+					stored = item
+					return &models.Cart{UserID: owner.UserID, Items: []models.CartItem{item}}, nil
+				},
+				SetHoldFunc: func(ctx context.Context, owner models.CartOwner, reference string) (string, error) {
+					savedHold = reference
+					return "", nil
+				},
+			}
+			cat := &MockCatalog{Products: catalog.Products, Err: tc.catalogErr}
+			inv := &MockInventory{ReserveErr: tc.reserveErr}
+			h := New(mockDB, cat, inv, jwtverify.New(nil, testSecret))
+
+			var body []byte
+			if s, ok := tc.payload.(string); ok {
+				body = []byte(s)
+			} else {
+				body, _ = json.Marshal(tc.payload)
+			}
+			req, _ := http.NewRequest("POST", "/cart/items", bytes.NewBuffer(body))
+			req = withOwner(req, models.CartOwner{UserID: "7"})
+			rr := httptest.NewRecorder()
+			h.AddItem(rr, req)
+
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("expected status %d got %d; body: %s", tc.wantStatus, rr.Code, rr.Body.String())
+			}
+			if tc.wantStatus == http.StatusOK {
+				if stored.Quantity != tc.wantQty {
+					t.Fatalf("expected quantity %d got %d", tc.wantQty, stored.Quantity)
+				}
+				if stored.UnitPrice != 5.49 || stored.Name != "Serene Sanctuary" {
+					t.Fatalf("expected catalog name and price to be stored, got %+v", stored)
+				}
+			}
+			if tc.wantStatus == http.StatusConflict && stored.ProductID != 0 {
+				t.Fatalf("expected nothing stored when stock is short, got %+v", stored)
+			}
+			lines, held := inv.Reserved[savedHold]
+			if held != tc.wantHold {
+				t.Fatalf("expected hold=%v, saved %q with holds %v", tc.wantHold, savedHold, inv.Reserved)
+			}
+			if held && (len(lines) != 1 || lines[0].ProductID != 1 || lines[0].Quantity != float64(tc.wantQty)) {
+				t.Fatalf("expected a hold for %d of product 1, got %v", tc.wantQty, lines)
+			}
+		})
+	}
+}
+
+func TestUpdateItem(t *testing.T) {
+	catalog := &MockCatalog{Products: map[int64]products.Product{
+		3: {ID: 3, Name: "Cozy Cashmere", Price: 6.49},
+	}}
+
+	tests := []struct {
+		name        string
+		productID   string
+		quantity    int
+		wantStatus  int
+		wantRemoved bool
+	}{
+		{name: "set quantity", productID: "3", quantity: 4, wantStatus: http.StatusOK},
+		{name: "zero removes", productID: "3", quantity: 0, wantStatus: http.StatusOK, wantRemoved: true},
+		{name: "bad id", productID: "abc", quantity: 1, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			removed := false
+			mockDB := &MockDB{
+				GetCartFunc: emptyCart,
+				SetItemFunc: func(ctx context.Context, owner models.CartOwner, item models.CartItem) (*models.Cart, error) {
+					if item.Quantity != tc.quantity {
+						t.Fatalf("expected quantity %d got %d", tc.quantity, item.Quantity)
+					}
+					return &models.Cart{Items: []models.CartItem{item}}, nil
+				},
+				RemoveItemFunc: func(ctx context.Context, owner models.CartOwner, productID int64) (*models.Cart, error) {
+					removed = true
+					return &models.Cart{Items: []models.CartItem{}}, nil
+				},
+			}
+			h := New(mockDB, catalog, &MockInventory{}, jwtverify.New(nil, testSecret))
+
+			body, _ := json.Marshal(ItemPayload{Quantity: tc.quantity})
+			req, _ := http.NewRequest("PUT", "/cart/items/"+tc.productID, bytes.NewBuffer(body))
+			req = withURLParam(req, "productID", tc.productID)
+			req = withOwner(req, models.CartOwner{AnonymousID: "0123456789abcdef0123456789abcdef"})
+			rr := httptest.NewRecorder()
+			h.UpdateItem(rr, req)
+
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("expected status %d got %d; body: %s", tc.wantStatus, rr.Code, rr.Body.String())
+			}
+			if removed != tc.wantRemoved {
+				t.Fatalf("expected removed=%v got %v", tc.wantRemoved, removed)
+			}
+		})
+	}
+}
+
+func TestMergeCart(t *testing.T) {
+	anonID := "0123456789abcdef0123456789abcdef"
+	catalog := map[int64]products.Product{1: {ID: 1, Name: "Lavender Melt", Price: 6.5}}
+
+	tests := []struct {
+		name       string
+		owner      models.CartOwner
+		body       string
+		header     string
+		catalogErr error
+		mergeErr   error
+		wantStatus int
+	}{
+		{name: "merge from body", owner: models.CartOwner{UserID: "5"}, body: `{"anonymous_id":"` + anonID + `"}`, wantStatus: http.StatusOK},
+		{name: "merge from header", owner: models.CartOwner{UserID: "5"}, header: anonID, wantStatus: http.StatusOK},
+		{name: "guest cannot merge", owner: models.CartOwner{AnonymousID: anonID}, body: `{"anonymous_id":"` + anonID + `"}`, wantStatus: http.StatusUnauthorized},
+		{name: "missing anonymous id", owner: models.CartOwner{UserID: "5"}, wantStatus: http.StatusBadRequest},
+		{name: "products unavailable", owner: models.CartOwner{UserID: "5"}, header: anonID, catalogErr: errors.New("dial tcp"), wantStatus: http.StatusBadGateway},
+		{name: "db error", owner: models.CartOwner{UserID: "5"}, header: anonID, mergeErr: errors.New("db fail"), wantStatus: http.StatusInternalServerError},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB := &MockDB{
+				GetCartFunc: func(ctx context.Context, owner models.CartOwner) (*models.Cart, error) {
+					if owner.AnonymousID == "" {
+						return &models.Cart{UserID: owner.UserID, Items: []models.CartItem{}}, nil
+					}
+					// The guest's lines carry the name and price they
+					// were added at; product 2 has since been removed.
+					return &models.Cart{AnonymousID: owner.AnonymousID, Items: []models.CartItem{
+						{ProductID: 1, Name: "Lavender", UnitPrice: 5, Quantity: 2},
+						{ProductID: 2, Name: "Retired Melt", UnitPrice: 4, Quantity: 1},
+					}}, nil
+				},
+				MergeCartsFunc: func(ctx context.Context, anonymousID, userID string, items []models.CartItem) (*models.Cart, error) {
+					if tc.mergeErr != nil {
+						return nil, tc.mergeErr
+					}
+					if anonymousID != anonID || userID != tc.owner.UserID {
+						t.Fatalf("unexpected merge args %q -> %q", anonymousID, userID)
+					}
+					if len(items) != 1 || items[0].ProductID != 1 || items[0].Name != "Lavender Melt" || items[0].UnitPrice != 6.5 {
+						t.Fatalf("expected the guest's lines refreshed from the catalog, got %+v", items)
+					}
+					return &models.Cart{UserID: userID, Items: items}, nil
+				},
+				SetHoldFunc: func(ctx context.Context, owner models.CartOwner, reference string) (string, error) {
+					return "", nil
+				},
+			}
+			h := New(mockDB, &MockCatalog{Products: catalog, Err: tc.catalogErr}, &MockInventory{}, jwtverify.New(nil, testSecret))
+
+			req, _ := http.NewRequest("POST", "/cart/merge", bytes.NewBufferString(tc.body))
+			if tc.header != "" {
+				req.Header.Set(CartIDHeader, tc.header)
+			}
+			req = withOwner(req, tc.owner)
+			rr := httptest.NewRecorder()
+			h.MergeCart(rr, req)
+
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("expected status %d got %d; body: %s", tc.wantStatus, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestRemoveItemShrinksHold(t *testing.T) {
+	owner := models.CartOwner{UserID: "7"}
+	mockDB := &MockDB{
+		GetCartFunc: func(ctx context.Context, owner models.CartOwner) (*models.Cart, error) {
+			return &models.Cart{UserID: owner.UserID, HoldReference: "cart-old", Items: []models.CartItem{
+				{ProductID: 1, Quantity: 2},
+				{ProductID: 2, Quantity: 1},
+			}}, nil
+		},
+		RemoveItemFunc: func(ctx context.Context, owner models.CartOwner, productID int64) (*models.Cart, error) {
+			return &models.Cart{UserID: owner.UserID, Items: []models.CartItem{{ProductID: 1, Quantity: 2}}}, nil
+		},
+		SetHoldFunc: func(ctx context.Context, owner models.CartOwner, reference string) (string, error) {
+			return "cart-old", nil
+		},
+	}
+	inv := &MockInventory{}
+	h := New(mockDB, &MockCatalog{}, inv, jwtverify.New(nil, testSecret))
+
+	req, _ := http.NewRequest("DELETE", "/cart/items/2", nil)
+	req = withURLParam(req, "productID", "2")
+	req = withOwner(req, owner)
+	rr := httptest.NewRecorder()
+	h.RemoveItem(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200 got %d; body: %s", rr.Code, rr.Body.String())
+	}
+	if len(inv.Released) == 0 || inv.Released[0] != "cart-old" {
+		t.Fatalf("expected the old hold to be released first, got %v", inv.Released)
+	}
+	var got models.Cart
+	if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	lines := inv.Reserved[got.HoldReference]
+	if len(lines) != 1 || lines[0].ProductID != 1 || lines[0].Quantity != 2 {
+		t.Fatalf("expected the new hold to cover only product 1, got %v", inv.Reserved)
+	}
+}
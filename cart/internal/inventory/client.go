@@ -0,0 +1,118 @@
+// Package inventory is a small HTTP client for the inventory service's
+// internal reservation endpoints, used to hold stock for the products in a
+// cart.
+package inventory
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrInsufficientStock is returned when inventory cannot cover the products
+// a hold asks for.
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// Line is a product and the number of units to hold stock for.
+type Line struct {
+	ProductID int64   `json:"product_id"`
+	Quantity  float64 `json:"quantity"`
+}
+
+type reservationRequest struct {
+	Reference string `json:"reference"`
+	Lines     []Line `json:"lines"`
+	CreatedBy string `json:"created_by"`
+}
+
+// serviceTokenHeader carries the token the inventory service requires on
+// its internal routes.
+const serviceTokenHeader = "X-Service-Token"
+
+// Client talks to the inventory service over HTTP.
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// New creates a client for the inventory service rooted at baseURL
+// (e.g. "http://inventory:8083") that authenticates with the shared service
+// token.
+func New(baseURL, token string) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		token:      token,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Reserve holds the stock needed for lines under reference. The hold lapses
+// after inventory's default hold time unless it is released first.
+func (c *Client) Reserve(ctx context.Context, reference string, lines []Line) error {
+	body, err := json.Marshal(reservationRequest{Reference: reference, Lines: lines, CreatedBy: "cart"})
+	if err != nil {
+		return fmt.Errorf("Reserve marshal: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/internal/reservations", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("Reserve build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(serviceTokenHeader, c.token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Reserve request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated, http.StatusOK:
+		return nil
+	case http.StatusConflict:
+		return fmt.Errorf("%w: %s", ErrInsufficientStock, errorMessage(resp))
+	default:
+		return fmt.Errorf("Reserve: unexpected status %d: %s", resp.StatusCode, errorMessage(resp))
+	}
+}
+
+// Release gives up the hold under reference. A reference with no
+// reservation counts as released.
+func (c *Client) Release(ctx context.Context, reference string) error {
+	u := fmt.Sprintf("%s/internal/reservations/%s", c.baseURL, url.PathEscape(reference))
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, u, nil)
+	if err != nil {
+		return fmt.Errorf("Release build request: %w", err)
+	}
+	req.Header.Set(serviceTokenHeader, c.token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Release request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNotFound:
+		return nil
+	default:
+		return fmt.Errorf("Release: unexpected status %d: %s", resp.StatusCode, errorMessage(resp))
+	}
+}
+
+func errorMessage(resp *http.Response) string {
+	var body struct {
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return resp.Status
+	}
+	return body.Message
+}
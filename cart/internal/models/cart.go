@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// CartOwner identifies whose cart is being operated on. Exactly one of the
+// fields is set: UserID for signed-in customers (the JWT `sub` claim) and
+// AnonymousID for guests that have not logged in yet.
+type CartOwner struct {
+	UserID      string `json:"user_id,omitempty"`
+	AnonymousID string `json:"anonymous_id,omitempty"`
+}
+
+// IsAnonymous reports whether the owner is a guest cart.
+func (o CartOwner) IsAnonymous() bool {
+	return o.UserID == ""
+}
+
+// Cart represents a customer's shopping cart.
+type Cart struct {
+	ID          int64  `json:"id,omitempty"`
+	UserID      string `json:"user_id,omitempty"`
+	AnonymousID string `json:"anonymous_id,omitempty"`
+	// HoldReference is the inventory reservation holding stock for the
+	// cart's items, if any. Checkout releases it before holding the order.
+	HoldReference string     `json:"hold_reference,omitempty"`
+	Items         []CartItem `json:"items"`
+	Subtotal      float64    `json:"subtotal"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// CartItem is a single product line in a cart. UnitPrice is the price the
+// products service reported when the line was last added or updated.
+type CartItem struct {
+	ProductID int64     `json:"product_id"`
+	Name      string    `json:"name"`
+	UnitPrice float64   `json:"unit_price"`
+	Quantity  int       `json:"quantity"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ComputeSubtotal recalculates the cart subtotal from its items.
+func (c *Cart) ComputeSubtotal() {
+	var total float64
+	for _, it := range c.Items {
+		total += it.UnitPrice * float64(it.Quantity)
+	}
+	c.Subtotal = total
+}
@@ -0,0 +1,65 @@
+// Package products is a small HTTP client for the products service, used to
+// validate product ids and prices before they are written to a cart.
+package products
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when the products service has no product with the
+// requested id.
+var ErrNotFound = errors.New("product not found")
+
+// Product is the subset of the products service representation the cart needs.
+type Product struct {
+	ID    int64   `json:"id"`
+	Name  string  `json:"name"`
+	Price float64 `json:"price"`
+}
+
+// Client talks to the products service over HTTP.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// New creates a client for the products service rooted at baseURL
+// (e.g. "http://products:8082").
+func New(baseURL string) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// GetProduct fetches a single product by id.
+func (c *Client) GetProduct(ctx context.Context, id int64) (*Product, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/products/%d", c.baseURL, id), nil)
+	if err != nil {
+		return nil, fmt.Errorf("GetProduct build request: %w", err)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("GetProduct request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, ErrNotFound
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("GetProduct: unexpected status %d", resp.StatusCode)
+	}
+
+	var p Product
+	if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+		return nil, fmt.Errorf("GetProduct decode: %w", err)
+	}
+	return &p, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"com.MixieMelts.gateway/internal/config"
+	"com.MixieMelts.gateway/internal/gateway"
+	"com.MixieMelts.users/jwtverify"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/joho/godotenv"
+	"github.com/rs/cors"
+)
+
+// getenv returns the environment variable key, or fallback when it is unset.
+func getenv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func main() {
+	// Load .env file if present
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found or failed to load; falling back to environment variables")
+	}
+
+	cfg, err := config.Load(getenv("GATEWAY_CONFIG", "config.json"))
+	if err != nil {
+		log.Fatalf("Failed to load gateway config: %v", err)
+	}
+
+	// Tokens are verified against the users service's published keys when
+	// JWKS_URL is set, and with the shared HS256 secret when
+	// JWT_SECRET_KEY is; during a switch to signing keys both are set
+	verifier, err := jwtverify.Configure(os.Getenv("JWKS_URL"), os.Getenv("JWT_SECRET_KEY"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	g, err := gateway.New(cfg, verifier)
+	if err != nil {
+		log.Fatalf("Failed to build routes: %v", err)
+	}
+
+	// Router setup. No request timeout is applied here so event streams can
+	// stay open; backends set their own.
+	r := chi.NewRouter()
+	r.Use(gateway.Logger)
+	r.Use(middleware.Recoverer)
+
+	// CORS for every backend is decided here
+	r.Use(cors.New(cors.Options{
+		AllowedOrigins:   cfg.CORS.AllowedOrigins,
+		AllowedMethods:   cfg.CORS.AllowedMethods,
+		AllowedHeaders:   cfg.CORS.AllowedHeaders,
+		ExposedHeaders:   cfg.CORS.ExposedHeaders,
+		AllowCredentials: cfg.CORS.AllowCredentials,
+		MaxAge:           cfg.CORS.MaxAge,
+	}).Handler)
+
+	// Health check
+	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	})
+
+	// Everything else goes to a backend
+	r.Handle("/*", g)
+
+	// Start server
+	port := getenv("PORT", "8088")
+
+	srv := &http.Server{
+		Addr:              ":" + port,
+		Handler:           r,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	log.Printf("Starting gateway on port %s with %d routes", port, len(cfg.Routes))
+	if err := srv.ListenAndServe(); err != nil {
+		log.Fatalf("Failed to start server: %v", err)
+	}
+}
@@ -0,0 +1,15 @@
+module com.MixieMelts.gateway
+
+go 1.24.2
+
+require (
+	com.MixieMelts.users v0.0.0-00010101000000-000000000000
+	github.com/go-chi/chi/v5 v5.2.3
+	github.com/golang-jwt/jwt/v4 v4.5.2
+	github.com/joho/godotenv v1.5.1
+	github.com/rs/cors v1.11.1
+)
+
+// The users service's token verifier, shared by building from the
+// repository root
+replace com.MixieMelts.users => ../users
@@ -0,0 +1,197 @@
+// Package config loads the gateway's route table and edge policies from a
+// JSON file.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Auth is how a route treats the caller's bearer token.
+type Auth string
+
+const (
+	// AuthPublic routes forward requests as-is; a bad token is ignored and
+	// left for the backend to judge.
+	AuthPublic Auth = "public"
+	// AuthUser routes require a valid token.
+	AuthUser Auth = "user"
+	// AuthAdmin routes require a valid token for an admin.
+	AuthAdmin Auth = "admin"
+)
+
+// Valid reports whether a is a known auth mode.
+func (a Auth) Valid() bool {
+	switch a {
+	case AuthPublic, AuthUser, AuthAdmin:
+		return true
+	}
+	return false
+}
+
+// Config is the gateway's configuration file.
+type Config struct {
+	CORS CORS `json:"cors"`
+	// ClientIPHeader names a header holding the caller's address (e.g.
+	// X-Real-IP), set by a proxy in front of the gateway. Anyone can send it,
+	// so rate limits key on it only for connections from TrustedProxies and
+	// on the connection's remote address otherwise.
+	ClientIPHeader string `json:"client_ip_header"`
+	// TrustedProxies lists the addresses or CIDR ranges of the proxies whose
+	// ClientIPHeader is believed.
+	TrustedProxies []string `json:"trusted_proxies"`
+	Routes         []Route  `json:"routes"`
+}
+
+// CORS is the cross-origin policy applied to every route.
+type CORS struct {
+	AllowedOrigins   []string `json:"allowed_origins"`
+	AllowedMethods   []string `json:"allowed_methods"`
+	AllowedHeaders   []string `json:"allowed_headers"`
+	ExposedHeaders   []string `json:"exposed_headers"`
+	AllowCredentials bool     `json:"allow_credentials"`
+	MaxAge           int      `json:"max_age"`
+}
+
+// Route sends requests under a path prefix to a backend. Routes are matched
+// in file order, so more specific prefixes go first.
+type Route struct {
+	// Prefix is matched on whole path segments: "/cart" matches "/cart"
+	// and "/cart/items" but not "/carts".
+	Prefix string `json:"prefix"`
+	// Methods limits the route to these methods; empty means any.
+	Methods  []string `json:"methods"`
+	Upstream string   `json:"upstream"`
+	// StripPrefix removes Prefix from the path before forwarding.
+	StripPrefix bool `json:"strip_prefix"`
+	Auth        Auth `json:"auth"`
+	// Permission, on a user route, is a permission the token must grant,
+	// such as "inventory:write". Admin tokens grant every permission.
+	Permission string `json:"permission"`
+	// QueryToken also accepts the token as the access_token query
+	// parameter, for clients such as EventSource that cannot set headers.
+	QueryToken bool       `json:"query_token"`
+	RateLimit  *RateLimit `json:"rate_limit"`
+}
+
+// RateLimit is a token bucket per client: Burst requests at once, refilled
+// at RequestsPerSecond.
+type RateLimit struct {
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	Burst             int     `json:"burst"`
+}
+
+// Load reads and validates the configuration file at path.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse decodes and validates a configuration file. Unknown fields are
+// rejected so typos do not silently drop a policy.
+func Parse(data []byte) (*Config, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var c Config
+	if err := dec.Decode(&c); err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	if err := c.validate(); err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	return &c, nil
+}
+
+// TrustedProxyPrefixes parses TrustedProxies; a bare address is a range of
+// one.
+func (c *Config) TrustedProxyPrefixes() ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(c.TrustedProxies))
+	for _, p := range c.TrustedProxies {
+		if strings.Contains(p, "/") {
+			prefix, err := netip.ParsePrefix(p)
+			if err != nil {
+				return nil, fmt.Errorf("trusted proxy %q: %w", p, err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(p)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q: %w", p, err)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+func (c *Config) validate() error {
+	if len(c.Routes) == 0 {
+		return errors.New("no routes")
+	}
+	if _, err := c.TrustedProxyPrefixes(); err != nil {
+		return err
+	}
+	if c.ClientIPHeader != "" && len(c.TrustedProxies) == 0 {
+		return errors.New("client_ip_header needs trusted_proxies")
+	}
+	for i := range c.Routes {
+		rt := &c.Routes[i]
+		if !strings.HasPrefix(rt.Prefix, "/") {
+			return fmt.Errorf("route %d: prefix %q must start with /", i, rt.Prefix)
+		}
+		rt.Prefix = strings.TrimSuffix(rt.Prefix, "/")
+		u, err := url.Parse(rt.Upstream)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("route %s: invalid upstream %q", rt.Prefix, rt.Upstream)
+		}
+		if rt.Auth == "" {
+			rt.Auth = AuthPublic
+		}
+		if !rt.Auth.Valid() {
+			return fmt.Errorf("route %s: unknown auth %q", rt.Prefix, rt.Auth)
+		}
+		if rt.Permission != "" && rt.Auth != AuthUser {
+			return fmt.Errorf("route %s: permission needs auth %q", rt.Prefix, AuthUser)
+		}
+		for j, m := range rt.Methods {
+			if m == "" {
+				return fmt.Errorf("route %s: empty method", rt.Prefix)
+			}
+			rt.Methods[j] = strings.ToUpper(m)
+		}
+		if rl := rt.RateLimit; rl != nil && (rl.RequestsPerSecond <= 0 || rl.Burst <= 0) {
+			return fmt.Errorf("route %s: rate limit needs positive requests_per_second and burst", rt.Prefix)
+		}
+	}
+	return nil
+}
+
+// Matches reports whether the route handles a request for method and path.
+func (rt Route) Matches(method, path string) bool {
+	if len(rt.Methods) > 0 {
+		ok := false
+		for _, m := range rt.Methods {
+			if m == method {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	if rt.Prefix == "" {
+		return true
+	}
+	rest, ok := strings.CutPrefix(path, rt.Prefix)
+	return ok && (rest == "" || rest[0] == '/')
+}
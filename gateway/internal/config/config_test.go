@@ -0,0 +1,67 @@
+package config
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{name: "minimal route", data: `{"routes":[{"prefix":"/cart","upstream":"http://cart:8084"}]}`},
+		{name: "no routes", data: `{"routes":[]}`, wantErr: true},
+		{name: "relative prefix", data: `{"routes":[{"prefix":"cart","upstream":"http://cart:8084"}]}`, wantErr: true},
+		{name: "bad upstream", data: `{"routes":[{"prefix":"/cart","upstream":"cart:8084"}]}`, wantErr: true},
+		{name: "unknown auth", data: `{"routes":[{"prefix":"/cart","upstream":"http://cart:8084","auth":"staff"}]}`, wantErr: true},
+		{name: "permission on public route", data: `{"routes":[{"prefix":"/cart","upstream":"http://cart:8084","permission":"kitchen"}]}`, wantErr: true},
+		{name: "zero rate", data: `{"routes":[{"prefix":"/cart","upstream":"http://cart:8084","rate_limit":{"burst":5}}]}`, wantErr: true},
+		{name: "client ip header from trusted proxies", data: `{"client_ip_header":"X-Real-IP","trusted_proxies":["172.28.0.10","10.0.0.0/8"],"routes":[{"prefix":"/cart","upstream":"http://cart:8084"}]}`},
+		{name: "client ip header without trusted proxies", data: `{"client_ip_header":"X-Real-IP","routes":[{"prefix":"/cart","upstream":"http://cart:8084"}]}`, wantErr: true},
+		{name: "bad trusted proxy", data: `{"client_ip_header":"X-Real-IP","trusted_proxies":["frontend"],"routes":[{"prefix":"/cart","upstream":"http://cart:8084"}]}`, wantErr: true},
+		{name: "unknown field", data: `{"routes":[{"prefix":"/cart","upstream":"http://cart:8084","ratelimit":{}}]}`, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, err := Parse([]byte(tc.data))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error=%v got %v", tc.wantErr, err)
+			}
+			if err == nil && cfg.Routes[0].Auth != AuthPublic {
+				t.Fatalf("expected auth to default to public, got %q", cfg.Routes[0].Auth)
+			}
+		})
+	}
+}
+
+func TestShippedConfigIsValid(t *testing.T) {
+	data, err := os.ReadFile("../../config.json")
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	if _, err := Parse(data); err != nil {
+		t.Fatalf("config.json: %v", err)
+	}
+}
+
+func TestRouteMatches(t *testing.T) {
+	rt := Route{Prefix: "/cart", Methods: []string{"GET", "POST"}}
+	tests := []struct {
+		method, path string
+		want         bool
+	}{
+		{"GET", "/cart", true},
+		{"POST", "/cart/items", true},
+		{"GET", "/carts", false},
+		{"DELETE", "/cart", false},
+	}
+	for _, tc := range tests {
+		if got := rt.Matches(tc.method, tc.path); got != tc.want {
+			t.Errorf("%s %s: expected %v got %v", tc.method, tc.path, tc.want, got)
+		}
+	}
+}
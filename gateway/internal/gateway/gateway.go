@@ -0,0 +1,238 @@
+// Package gateway is the single entry point for browser traffic. It matches
+// each request to a configured route, applies the route's rate limit and
+// auth policy, and proxies it to the route's backend.
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/netip"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"com.MixieMelts.gateway/internal/config"
+	"com.MixieMelts.gateway/internal/ratelimit"
+	"com.MixieMelts.users/jwtverify"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const (
+	// UserIDHeader carries the verified user id to backends. It is removed
+	// from incoming requests so callers cannot set it themselves.
+	UserIDHeader = "X-User-ID"
+	// UserAdminHeader is "true" when the verified user is an admin.
+	UserAdminHeader = "X-User-Admin"
+)
+
+// Claims are the JWT claims issued by the users service.
+type Claims = jwtverify.Claims
+
+type route struct {
+	config.Route
+	proxy   *httputil.ReverseProxy
+	limiter *ratelimit.Limiter
+}
+
+// Gateway routes requests to backends.
+type Gateway struct {
+	routes         []route
+	verifier       *jwtverify.Verifier
+	clientIPHeader string
+	trustedProxies []netip.Prefix
+}
+
+// New builds a Gateway from a validated configuration. Bearer tokens are
+// checked with verifier.
+func New(cfg *config.Config, verifier *jwtverify.Verifier) (*Gateway, error) {
+	trusted, err := cfg.TrustedProxyPrefixes()
+	if err != nil {
+		return nil, fmt.Errorf("gateway: %w", err)
+	}
+	g := &Gateway{verifier: verifier, clientIPHeader: cfg.ClientIPHeader, trustedProxies: trusted}
+	for _, rc := range cfg.Routes {
+		upstream, err := url.Parse(rc.Upstream)
+		if err != nil {
+			return nil, fmt.Errorf("gateway: route %s: %w", rc.Prefix, err)
+		}
+		rt := route{Route: rc, proxy: newProxy(rc, upstream)}
+		if rc.RateLimit != nil {
+			rt.limiter = ratelimit.New(rc.RateLimit.RequestsPerSecond, rc.RateLimit.Burst)
+		}
+		g.routes = append(g.routes, rt)
+	}
+	return g, nil
+}
+
+func newProxy(rc config.Route, upstream *url.URL) *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			if rc.StripPrefix {
+				pr.Out.URL.Path = strings.TrimPrefix(pr.In.URL.Path, rc.Prefix)
+				pr.Out.URL.RawPath = ""
+				if pr.Out.URL.Path == "" {
+					pr.Out.URL.Path = "/"
+				}
+			}
+			pr.SetURL(upstream)
+			pr.SetXForwarded()
+		},
+		// Flush immediately so server-sent event streams are not buffered.
+		FlushInterval: -1,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			log.Printf("gateway: %s %s -> %s error: %v", r.Method, r.URL.Path, rc.Upstream, err)
+			respondWithError(w, http.StatusBadGateway, "upstream unavailable")
+		},
+	}
+}
+
+// ServeHTTP proxies r to the first route that matches it.
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt := g.match(r)
+	if rt == nil || internalPath(r.URL.Path) {
+		respondWithError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	if rt.limiter != nil {
+		if ok, wait := rt.limiter.Allow(g.clientIP(r)); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			respondWithError(w, http.StatusTooManyRequests, "rate limit exceeded")
+			return
+		}
+	}
+
+	// Only the gateway may set the user headers.
+	r.Header.Del(UserIDHeader)
+	r.Header.Del(UserAdminHeader)
+
+	claims, err := g.authenticate(r, rt.QueryToken)
+	switch {
+	case rt.Auth == config.AuthPublic:
+		// A bad token on a public route is ignored; the request goes
+		// through anonymously.
+	case err != nil:
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	case claims == nil:
+		respondWithError(w, http.StatusUnauthorized, "authorization header required")
+		return
+	case rt.Auth == config.AuthAdmin && !claims.Admin:
+		respondWithError(w, http.StatusForbidden, "admin access required")
+		return
+	case rt.Permission != "" && !claims.Can(rt.Permission):
+		respondWithError(w, http.StatusForbidden, "permission "+rt.Permission+" required")
+		return
+	}
+	if claims != nil && err == nil {
+		r.Header.Set(UserIDHeader, claims.Subject)
+		r.Header.Set(UserAdminHeader, strconv.FormatBool(claims.Admin))
+	}
+
+	rt.proxy.ServeHTTP(w, r)
+}
+
+func (g *Gateway) match(r *http.Request) *route {
+	for i := range g.routes {
+		if g.routes[i].Matches(r.Method, r.URL.Path) {
+			return &g.routes[i]
+		}
+	}
+	return nil
+}
+
+// internalPath reports whether path would reach a backend's /internal
+// routes, which serve other services only. Any "internal" segment counts, so
+// a route that strips its prefix cannot expose them either.
+func internalPath(path string) bool {
+	return slices.Contains(strings.Split(path, "/"), "internal")
+}
+
+// authenticate returns the claims of the request's bearer token, or nil
+// claims if it has none.
+func (g *Gateway) authenticate(r *http.Request, allowQuery bool) (*Claims, error) {
+	tokenString, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok && allowQuery {
+		tokenString = r.URL.Query().Get("access_token")
+	}
+	if tokenString == "" {
+		return nil, nil
+	}
+
+	claims := &Claims{}
+	if err := g.verifier.Parse(tokenString, claims); err != nil || claims.Subject == "" {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
+// clientIP is the key rate limits are counted against: the connection's
+// remote address, or the client IP header when the connection comes from a
+// trusted proxy.
+func (g *Gateway) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if g.clientIPHeader == "" {
+		return host
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !g.trusts(addr.Unmap()) {
+		return host
+	}
+	if ip := strings.TrimSpace(r.Header.Get(g.clientIPHeader)); ip != "" {
+		return ip
+	}
+	return host
+}
+
+// trusts reports whether addr is one of the trusted proxies.
+func (g *Gateway) trusts(addr netip.Addr) bool {
+	for _, p := range g.trustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Logger is chi's request logger with the access_token query parameter
+// redacted. A route with QueryToken, such as the ticket stream, takes its token there, and the log would
+// otherwise keep a usable copy of it.
+var Logger = middleware.RequestLogger(tokenRedactor{&middleware.DefaultLogFormatter{Logger: log.New(os.Stdout, "", log.LstdFlags)}})
+
+// tokenRedactor hands the wrapped formatter a request whose RequestURI has
+// the access token blanked out.
+type tokenRedactor struct {
+	middleware.LogFormatter
+}
+
+func (f tokenRedactor) NewLogEntry(r *http.Request) middleware.LogEntry {
+	q := r.URL.Query()
+	if !q.Has("access_token") {
+		return f.LogFormatter.NewLogEntry(r)
+	}
+	q.Set("access_token", "REDACTED")
+	u := *r.URL
+	u.RawQuery = q.Encode()
+	redacted := r.WithContext(r.Context())
+	redacted.RequestURI = u.RequestURI()
+	return f.LogFormatter.NewLogEntry(redacted)
+}
+
+func respondWithError(w http.ResponseWriter, code int, message string) {
+	response, _ := json.Marshal(map[string]string{"message": message})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(response)
+}
@@ -0,0 +1,274 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"com.MixieMelts.gateway/internal/config"
+	"com.MixieMelts.users/jwtverify"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var secret = []byte("secret")
+
+func token(t *testing.T, subject string, admin bool, perms ...string) string {
+	t.Helper()
+	claims := &Claims{
+		Admin:       admin,
+		Permissions: perms,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+	s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return s
+}
+
+// seen is what the backend received.
+type seen struct {
+	Path          string `json:"path"`
+	Authorization string `json:"authorization"`
+	UserID        string `json:"user_id"`
+	IsAdmin       string `json:"is_admin"`
+}
+
+func newGateway(t *testing.T, routes ...config.Route) *Gateway {
+	t.Helper()
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(seen{
+			Path:          r.URL.Path,
+			Authorization: r.Header.Get("Authorization"),
+			UserID:        r.Header.Get(UserIDHeader),
+			IsAdmin:       r.Header.Get(UserAdminHeader),
+		})
+	}))
+	t.Cleanup(backend.Close)
+
+	for i := range routes {
+		routes[i].Upstream = backend.URL
+	}
+	g, err := New(&config.Config{Routes: routes}, jwtverify.New(nil, secret))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return g
+}
+
+func TestServeHTTP(t *testing.T) {
+	g := newGateway(t,
+		config.Route{Prefix: "/api/inventory", StripPrefix: true, Auth: config.AuthAdmin},
+		config.Route{Prefix: "/orders", Auth: config.AuthUser},
+		config.Route{Prefix: "/tickets/stream", Auth: config.AuthUser, QueryToken: true},
+		config.Route{Prefix: "/tickets", Auth: config.AuthUser, Permission: "kitchen"},
+		config.Route{Prefix: "/products", Auth: config.AuthPublic},
+	)
+
+	userToken := token(t, "42", false)
+	adminToken := token(t, "1", true)
+	kitchenToken := token(t, "7", false, "inventory:read", "kitchen")
+
+	tests := []struct {
+		name       string
+		target     string
+		auth       string
+		spoofUser  string
+		wantStatus int
+		wantSeen   seen
+	}{
+		{
+			name:       "public route without token",
+			target:     "/products/1",
+			wantStatus: http.StatusOK,
+			wantSeen:   seen{Path: "/products/1"},
+		},
+		{
+			name:       "public route ignores bad token",
+			target:     "/products",
+			auth:       "Bearer nope",
+			wantStatus: http.StatusOK,
+			wantSeen:   seen{Path: "/products", Authorization: "Bearer nope"},
+		},
+		{
+			name:       "token and user headers are passed to the backend",
+			target:     "/orders",
+			auth:       "Bearer " + userToken,
+			wantStatus: http.StatusOK,
+			wantSeen:   seen{Path: "/orders", Authorization: "Bearer " + userToken, UserID: "42", IsAdmin: "false"},
+		},
+		{
+			name:       "spoofed user header is replaced",
+			target:     "/orders",
+			auth:       "Bearer " + userToken,
+			spoofUser:  "1",
+			wantStatus: http.StatusOK,
+			wantSeen:   seen{Path: "/orders", Authorization: "Bearer " + userToken, UserID: "42", IsAdmin: "false"},
+		},
+		{
+			name:       "spoofed user header without token is dropped",
+			target:     "/products",
+			spoofUser:  "1",
+			wantStatus: http.StatusOK,
+			wantSeen:   seen{Path: "/products"},
+		},
+		{name: "user route without token", target: "/orders", wantStatus: http.StatusUnauthorized},
+		{name: "user route with bad token", target: "/orders", auth: "Bearer nope", wantStatus: http.StatusUnauthorized},
+		{
+			name:       "admin route strips prefix",
+			target:     "/api/inventory/ingredients",
+			auth:       "Bearer " + adminToken,
+			wantStatus: http.StatusOK,
+			wantSeen:   seen{Path: "/ingredients", Authorization: "Bearer " + adminToken, UserID: "1", IsAdmin: "true"},
+		},
+		{name: "admin route as customer", target: "/api/inventory/ingredients", auth: "Bearer " + token(t, "42", false), wantStatus: http.StatusForbidden},
+		{
+			name:       "query token on stream",
+			target:     "/tickets/stream?access_token=" + token(t, "7", false),
+			wantStatus: http.StatusOK,
+			wantSeen:   seen{Path: "/tickets/stream", UserID: "7", IsAdmin: "false"},
+		},
+		{
+			name:       "permission route with permission",
+			target:     "/tickets",
+			auth:       "Bearer " + kitchenToken,
+			wantStatus: http.StatusOK,
+			wantSeen:   seen{Path: "/tickets", Authorization: "Bearer " + kitchenToken, UserID: "7", IsAdmin: "false"},
+		},
+		{
+			name:       "permission route as admin",
+			target:     "/tickets",
+			auth:       "Bearer " + adminToken,
+			wantStatus: http.StatusOK,
+			wantSeen:   seen{Path: "/tickets", Authorization: "Bearer " + adminToken, UserID: "1", IsAdmin: "true"},
+		},
+		{name: "permission route without permission", target: "/tickets", auth: "Bearer " + token(t, "42", false, "inventory:read"), wantStatus: http.StatusForbidden},
+		{name: "query token elsewhere", target: "/orders?access_token=" + token(t, "7", false), wantStatus: http.StatusUnauthorized},
+		{name: "internal routes are not exposed", target: "/internal/orders", wantStatus: http.StatusNotFound},
+		{name: "internal routes behind a stripped prefix", target: "/api/inventory/internal/reservations", auth: "Bearer " + adminToken, wantStatus: http.StatusNotFound},
+		{name: "internal routes behind a dot segment", target: "/api/inventory/x/../internal/batches", auth: "Bearer " + adminToken, wantStatus: http.StatusNotFound},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tc.target, nil)
+			if tc.auth != "" {
+				req.Header.Set("Authorization", tc.auth)
+			}
+			if tc.spoofUser != "" {
+				req.Header.Set(UserIDHeader, tc.spoofUser)
+				req.Header.Set(UserAdminHeader, "true")
+			}
+			rr := httptest.NewRecorder()
+			g.ServeHTTP(rr, req)
+
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("expected status %d got %d; body: %s", tc.wantStatus, rr.Code, rr.Body.String())
+			}
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+			var got seen
+			if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if got != tc.wantSeen {
+				t.Fatalf("expected backend to see %+v, got %+v", tc.wantSeen, got)
+			}
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	g := newGateway(t, config.Route{
+		Prefix:    "/api/users/login",
+		Auth:      config.AuthPublic,
+		RateLimit: &config.RateLimit{RequestsPerSecond: 0.5, Burst: 2},
+	})
+
+	codes := []int{}
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest("POST", "/api/users/login", nil)
+		rr := httptest.NewRecorder()
+		g.ServeHTTP(rr, req)
+		codes = append(codes, rr.Code)
+		if rr.Code == http.StatusTooManyRequests && rr.Header().Get("Retry-After") != "2" {
+			t.Fatalf("expected Retry-After 2, got %q", rr.Header().Get("Retry-After"))
+		}
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusOK || codes[2] != http.StatusTooManyRequests {
+		t.Fatalf("unexpected status codes %v", codes)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		header     string
+		trusted    []string
+		remoteAddr string
+		realIP     string
+		want       string
+	}{
+		{name: "no header configured", remoteAddr: "203.0.113.5:4000", realIP: "198.51.100.1", want: "203.0.113.5"},
+		{name: "header from trusted proxy", header: "X-Real-IP", trusted: []string{"172.28.0.10"}, remoteAddr: "172.28.0.10:4000", realIP: "198.51.100.1", want: "198.51.100.1"},
+		{name: "header from trusted range", header: "X-Real-IP", trusted: []string{"10.0.0.0/8"}, remoteAddr: "10.1.2.3:4000", realIP: "198.51.100.1", want: "198.51.100.1"},
+		{name: "spoofed header from client", header: "X-Real-IP", trusted: []string{"172.28.0.10"}, remoteAddr: "203.0.113.5:4000", realIP: "198.51.100.1", want: "203.0.113.5"},
+		{name: "trusted proxy without header", header: "X-Real-IP", trusted: []string{"172.28.0.10"}, remoteAddr: "172.28.0.10:4000", want: "172.28.0.10"},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &config.Config{
+				ClientIPHeader: tc.header,
+				TrustedProxies: tc.trusted,
+				Routes:         []config.Route{{Prefix: "/cart", Upstream: "http://cart:8084"}},
+			}
+			g, err := New(cfg, jwtverify.New(nil, secret))
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+
+			req := httptest.NewRequest("GET", "/cart", nil)
+			req.RemoteAddr = tc.remoteAddr
+			if tc.realIP != "" {
+				req.Header.Set("X-Real-IP", tc.realIP)
+			}
+			if got := g.clientIP(req); got != tc.want {
+				t.Fatalf("expected %q got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestLoggerRedactsAccessToken(t *testing.T) {
+	var buf bytes.Buffer
+	logger := middleware.RequestLogger(tokenRedactor{&middleware.DefaultLogFormatter{Logger: log.New(&buf, "", 0), NoColor: true}})
+	var seenToken string
+	h := logger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seenToken = r.URL.Query().Get("access_token")
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/tickets/stream?access_token=secret-token&x=1", nil))
+
+	if strings.Contains(buf.String(), "secret-token") {
+		t.Fatalf("token leaked into the log: %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "access_token=REDACTED") {
+		t.Fatalf("expected a redacted token in the log, got %s", buf.String())
+	}
+	if seenToken != "secret-token" {
+		t.Fatalf("expected the handler to see the token, got %q", seenToken)
+	}
+}
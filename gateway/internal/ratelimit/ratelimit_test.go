@@ -0,0 +1,55 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAllow(t *testing.T) {
+	now := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	l := New(2, 3)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("1.2.3.4"); !ok {
+			t.Fatalf("request %d within burst was limited", i+1)
+		}
+	}
+	ok, wait := l.Allow("1.2.3.4")
+	if ok {
+		t.Fatal("expected request beyond burst to be limited")
+	}
+	if wait != 500*time.Millisecond {
+		t.Fatalf("expected retry after 500ms, got %v", wait)
+	}
+
+	// Other clients have their own bucket.
+	if ok, _ := l.Allow("5.6.7.8"); !ok {
+		t.Fatal("expected another client to be allowed")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if ok, _ := l.Allow("1.2.3.4"); !ok {
+		t.Fatal("expected a token to have refilled")
+	}
+	if ok, _ := l.Allow("1.2.3.4"); ok {
+		t.Fatal("expected only one token to have refilled")
+	}
+}
+
+func TestIdleBucketsAreDropped(t *testing.T) {
+	now := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	l := New(1, 1)
+	l.now = func() time.Time { return now }
+
+	l.Allow("1.2.3.4")
+	now = now.Add(idleAfter)
+	l.Allow("5.6.7.8")
+
+	if _, ok := l.buckets["1.2.3.4"]; ok {
+		t.Fatal("expected idle bucket to be dropped")
+	}
+	if len(l.buckets) != 1 {
+		t.Fatalf("expected one bucket, got %d", len(l.buckets))
+	}
+}
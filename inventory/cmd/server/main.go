@@ -1,20 +1,34 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
 	"time"
 
+	"com.MixieMelts.inventory/internal/alerts"
 	"com.MixieMelts.inventory/internal/database"
 	"com.MixieMelts.inventory/internal/handlers"
+	"com.MixieMelts.inventory/internal/products"
+	"com.MixieMelts.inventory/internal/reconcile"
+	"com.MixieMelts.inventory/internal/reservations"
+	"com.MixieMelts.users/jwtverify"
+	"com.MixieMelts.users/outbox"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/joho/godotenv"
-	"github.com/rs/cors"
 )
 
+// getenv returns the environment variable key, or fallback when it is unset.
+func getenv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
 func main() {
 	// Load .env file if present
 	if err := godotenv.Load(); err != nil {
@@ -27,35 +41,156 @@ func main() {
 		log.Fatal("DATABASE_URL must be set")
 	}
 
+	// "migrate up|down|status" manages the schema and exits
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		m, err := database.Migrator()
+		if err == nil {
+			err = m.Command(context.Background(), dbURL, os.Args[2:], os.Stdout)
+		}
+		if err != nil {
+			log.Fatalf("migrate: %v", err)
+		}
+		return
+	}
+
+	// "stock verify|rebuild|adopt" checks stock against the ledger and exits
+	if len(os.Args) > 1 && os.Args[1] == "stock" {
+		if err := runStock(dbURL, os.Args[2:]); err != nil {
+			log.Fatalf("stock: %v", err)
+		}
+		return
+	}
+
 	db, err := database.New(dbURL)
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 
+	// Publish outbox events
+	transport, err := outbox.TransportFromEnv(db, "inventory_events")
+	if err != nil {
+		log.Fatalf("Invalid outbox configuration: %v", err)
+	}
+	outboxInterval, err := time.ParseDuration(getenv("OUTBOX_INTERVAL", "5s"))
+	if err != nil {
+		log.Fatalf("Invalid OUTBOX_INTERVAL: %v", err)
+	}
+	go outbox.NewRelay("inventory", db, transport).Run(context.Background(), outboxInterval)
+
+	// Re-evaluate low-stock alerts on a schedule; adjustments evaluate their
+	// own ingredient as they happen
+	alertInterval, err := time.ParseDuration(getenv("ALERT_INTERVAL", "15m"))
+	if err != nil {
+		log.Fatalf("Invalid ALERT_INTERVAL: %v", err)
+	}
+	go alerts.NewMonitor(db).Run(context.Background(), alertInterval)
+
+	// Mark lapsed reservations expired; they stop holding stock on expiry
+	// regardless
+	reservationInterval, err := time.ParseDuration(getenv("RESERVATION_EXPIRY_INTERVAL", "1m"))
+	if err != nil {
+		log.Fatalf("Invalid RESERVATION_EXPIRY_INTERVAL: %v", err)
+	}
+	go reservations.NewExpirer(db).Run(context.Background(), reservationInterval)
+
+	// Reconcile product ids against the products service, which owns them
+	interval, err := time.ParseDuration(getenv("RECONCILE_INTERVAL", "1h"))
+	if err != nil {
+		log.Fatalf("Invalid RECONCILE_INTERVAL: %v", err)
+	}
+	integrity := reconcile.New(db, products.New(getenv("PRODUCTS_URL", "http://localhost:8082")))
+	go integrity.Run(context.Background(), interval)
+
 	// Initialize handlers with the db
-	h := handlers.New(db)
+	h := handlers.New(db, integrity)
+
+	// Writes need a token from the users service granting inventory:write;
+	// internal routes need the shared INTERNAL_API_TOKEN instead
+	verifier, err := jwtverify.Configure(getenv("JWKS_URL", ""), getenv("JWT_SECRET_KEY", ""))
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// Router setup
 	r := chi.NewRouter()
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.Timeout(60 * time.Second))
-
-	// CORS - allow frontend to call the API
-	r.Use(cors.New(cors.Options{
-		AllowedOrigins:   []string{"frontend:"}, // adjust origin in env or here for production
-		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Content-Type", "Authorization"},
-		AllowCredentials: true,
-		MaxAge:           300,
-	}).Handler)
+	write := r.With(verifier.Middleware, jwtverify.Require(jwtverify.PermInventoryWrite))
 
 	// Ingredient (material) routes - track bases, waxes, scent oils, etc.
 	r.Get("/ingredients", h.GetIngredients)
 	r.Get("/ingredients/{id}", h.GetIngredient)
-	r.Post("/ingredients", h.CreateIngredient)
-	r.Put("/ingredients/{id}", h.UpdateIngredient)
-	r.Patch("/ingredients/{id}/adjust", h.AdjustIngredientStock)
+	write.Post("/ingredients", h.CreateIngredient)
+	write.Put("/ingredients/{id}", h.UpdateIngredient)
+	r.Get("/ingredients/{id}/adjustments", h.GetIngredientAdjustments)
+	write.Patch("/ingredients/{id}/adjust", h.AdjustIngredientStock)
+	r.Get("/adjustments", h.GetAdjustments)
+
+	// Recipes - ingredients used to make one unit of each product
+	r.Get("/recipes", h.GetRecipes)
+	r.Get("/products/{productID}/recipe", h.GetRecipe)
+	write.Post("/products/{productID}/recipe", h.CreateRecipeItem)
+	write.Put("/products/{productID}/recipe", h.ReplaceRecipe)
+	write.Delete("/products/{productID}/recipe", h.DeleteRecipe)
+	write.Put("/products/{productID}/recipe/{itemID}", h.UpdateRecipeItem)
+	write.Delete("/products/{productID}/recipe/{itemID}", h.DeleteRecipeItem)
+
+	// Finished goods - made-but-unsold melts per product
+	r.Get("/finished-goods", h.GetFinishedGoods)
+	r.Get("/finished-goods/{productID}", h.GetFinishedGood)
+	r.Get("/finished-goods/{productID}/adjustments", h.GetFinishedGoodAdjustments)
+	write.Patch("/finished-goods/{productID}/adjust", h.AdjustFinishedGoods)
+
+	// Production capacity - units each product can be made and sold from stock
+	r.Get("/capacity", h.GetCapacities)
+	r.Get("/capacity/{productID}", h.GetCapacity)
+
+	// Suppliers and purchase orders - receiving posts "receipt" adjustments
+	r.Get("/suppliers", h.GetSuppliers)
+	r.Get("/suppliers/{id}", h.GetSupplier)
+	write.Post("/suppliers", h.CreateSupplier)
+	write.Put("/suppliers/{id}", h.UpdateSupplier)
+	r.Get("/purchase-orders", h.GetPurchaseOrders)
+	r.Get("/purchase-orders/{id}", h.GetPurchaseOrder)
+	write.Post("/purchase-orders", h.CreatePurchaseOrder)
+	write.Post("/purchase-orders/{id}/submit", h.SubmitPurchaseOrder)
+	write.Post("/purchase-orders/{id}/cancel", h.CancelPurchaseOrder)
+	write.Post("/purchase-orders/{id}/close", h.ClosePurchaseOrder)
+	r.Get("/purchase-orders/{id}/receipts", h.GetReceipts)
+	write.Post("/purchase-orders/{id}/receipts", h.ReceivePurchaseOrder)
+
+	// Available-to-promise: stock less what reservations hold
+	r.Get("/available-to-promise", h.GetAvailableToPromise)
+
+	// Reorder suggestions from trailing usage; accepting drafts purchase orders
+	r.Get("/reorder-suggestions", h.GetReorderSuggestions)
+	write.Post("/reorder-suggestions/accept", h.AcceptReorderSuggestions)
+
+	// Low-stock alerts
+	r.Get("/alerts", h.GetStockAlerts)
+	write.Post("/alerts/{id}/acknowledge", h.AcknowledgeStockAlert)
+	write.Post("/alerts/{id}/snooze", h.SnoozeStockAlert)
+
+	// Admin - cross-service integrity report
+	r.Get("/admin/integrity", h.GetIntegrityReport)
+	write.Post("/admin/integrity/check", h.CheckIntegrity)
+
+	// Internal routes for other services, which authenticate with the shared
+	// INTERNAL_API_TOKEN
+	internalToken := os.Getenv("INTERNAL_API_TOKEN")
+	if internalToken == "" {
+		log.Println("INTERNAL_API_TOKEN is not set; internal routes will refuse every request")
+	}
+	internal := r.With(handlers.RequireServiceToken(internalToken))
+	internal.Post("/internal/requirements", h.GetRequirements)
+	internal.Post("/internal/reservations", h.CreateReservation)
+	internal.Get("/internal/reservations/{reference}", h.GetReservation)
+	internal.Post("/internal/reservations/{reference}/confirm", h.ConfirmReservation)
+	internal.Delete("/internal/reservations/{reference}", h.ReleaseReservation)
+	internal.Post("/internal/allocations", h.AllocateFinishedGoods)
+	internal.Post("/internal/batches", h.RecordBatch)
+	internal.Delete("/internal/batches/{reference}", h.ReverseBatch)
 
 	// Health check
 	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"com.MixieMelts.inventory/internal/database"
+	"com.MixieMelts.inventory/internal/models"
+)
+
+const stockUsage = "usage: stock verify | rebuild | adopt"
+
+// runStock implements the "stock" subcommand, which checks ingredient stock
+// against the adjustment ledger: "verify" lists drifted ingredients and fails
+// if there are any, "rebuild" resets their stock to the ledger and "adopt"
+// keeps their stock and posts correction adjustments for the drift.
+func runStock(dbURL string, args []string) error {
+	if len(args) != 1 {
+		return errors.New(stockUsage)
+	}
+
+	db, err := database.Open(dbURL)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	var list []models.StockDrift
+	switch args[0] {
+	case "verify":
+		list, err = db.StockDrift(ctx)
+	case "rebuild":
+		list, err = db.RebuildStock(ctx)
+	case "adopt":
+		list, err = db.AdoptStock(ctx, "stock-adopt")
+	default:
+		return fmt.Errorf("unknown command %q: %s", args[0], stockUsage)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(list) == 0 {
+		fmt.Println("stock matches the ledger")
+		return nil
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tINGREDIENT\tSTOCK\tLEDGER\tDRIFT\tUNIT")
+	for _, d := range list {
+		fmt.Fprintf(tw, "%d\t%s\t%g\t%g\t%+g\t%s\n", d.IngredientID, d.IngredientName, d.Stock, d.LedgerStock, d.Drift, d.Unit)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "verify":
+		return fmt.Errorf("%d ingredient(s) drifted from the ledger", len(list))
+	case "rebuild":
+		fmt.Printf("reset %d ingredient(s) to the ledger\n", len(list))
+	case "adopt":
+		fmt.Printf("posted corrections for %d ingredient(s)\n", len(list))
+	}
+	return nil
+}
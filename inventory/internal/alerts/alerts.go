@@ -0,0 +1,77 @@
+// Package alerts decides when an ingredient's stock raises or resolves a
+// low-stock alert and periodically re-evaluates every ingredient. An alert is
+// raised when stock drops below the ingredient's MinThreshold and there is no
+// active alert for it yet, and resolved once stock is back at or above the
+// threshold, so a single dip raises a single alert however many adjustments
+// happen while stock stays low.
+package alerts
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Action is what evaluating an ingredient does to its alert.
+type Action int
+
+const (
+	// None leaves the ingredient's alerts unchanged.
+	None Action = iota
+	// Raise opens a new alert.
+	Raise
+	// Resolve closes the active alert.
+	Resolve
+)
+
+// Evaluate returns the action for an ingredient with the given stock and
+// threshold; active reports whether it already has an unresolved alert. A
+// threshold of zero or less disables alerting.
+func Evaluate(stock, threshold float64, active bool) Action {
+	low := threshold > 0 && stock < threshold
+	switch {
+	case low && !active:
+		return Raise
+	case !low && active:
+		return Resolve
+	default:
+		return None
+	}
+}
+
+// Store evaluates the alerts of every ingredient.
+type Store interface {
+	EvaluateStockAlerts(ctx context.Context) (raised, resolved int, err error)
+}
+
+// Monitor re-evaluates every ingredient on a schedule, catching threshold
+// changes and anything that changed stock without an adjustment.
+type Monitor struct {
+	store Store
+}
+
+// NewMonitor creates a Monitor.
+func NewMonitor(store Store) *Monitor {
+	return &Monitor{store: store}
+}
+
+// Run evaluates every ingredient every interval until ctx is cancelled.
+func (m *Monitor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		raised, resolved, err := m.store.EvaluateStockAlerts(ctx)
+		if err != nil {
+			log.Printf("alerts: evaluation failed: %v", err)
+		} else if raised > 0 || resolved > 0 {
+			log.Printf("alerts: %d low-stock alerts raised, %d resolved", raised, resolved)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
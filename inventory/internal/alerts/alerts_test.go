@@ -0,0 +1,48 @@
+package alerts
+
+import "testing"
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		name      string
+		stock     float64
+		threshold float64
+		active    bool
+		want      Action
+	}{
+		{name: "drops below", stock: 4, threshold: 5, want: Raise},
+		{name: "still low", stock: 2, threshold: 5, active: true, want: None},
+		{name: "recovers", stock: 8, threshold: 5, active: true, want: Resolve},
+		{name: "at threshold resolves", stock: 5, threshold: 5, active: true, want: Resolve},
+		{name: "at threshold is not low", stock: 5, threshold: 5, want: None},
+		{name: "healthy", stock: 50, threshold: 5, want: None},
+		{name: "no threshold", stock: 0, threshold: 0, want: None},
+		{name: "threshold removed", stock: 0, threshold: 0, active: true, want: Resolve},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Evaluate(tc.stock, tc.threshold, tc.active); got != tc.want {
+				t.Fatalf("Evaluate(%v, %v, %v): got %v want %v", tc.stock, tc.threshold, tc.active, got, tc.want)
+			}
+		})
+	}
+}
+
+// Dips and recoveries in sequence raise exactly one alert per dip.
+func TestEvaluateDedupesDips(t *testing.T) {
+	stocks := []float64{10, 4, 3, 1, 6, 7, 2, 2, 9}
+	active := false
+	raised := 0
+	for _, s := range stocks {
+		switch Evaluate(s, 5, active) {
+		case Raise:
+			raised++
+			active = true
+		case Resolve:
+			active = false
+		}
+	}
+	if raised != 2 {
+		t.Fatalf("raised %d alerts for two dips", raised)
+	}
+}
@@ -0,0 +1,143 @@
+package database
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"com.MixieMelts.inventory/internal/models"
+)
+
+// Page sizes for the adjustment history.
+const (
+	DefaultAdjustmentPageSize = 50
+	MaxAdjustmentPageSize     = 500
+)
+
+// ErrInvalidCursor is returned for a history cursor this service did not
+// issue.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// AdjustmentFilter narrows the adjustment history. Zero values match
+// everything; From is inclusive and To exclusive.
+type AdjustmentFilter struct {
+	IngredientID int64
+	From, To     time.Time
+	Reason       string
+	Reference    string
+	CreatedBy    string
+	Cursor       string // from a previous AdjustmentPage
+	Limit        int    // page size, default DefaultAdjustmentPageSize
+}
+
+// encodeCursor identifies the last row of a page by its sort key.
+func encodeCursor(createdAt time.Time, id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(createdAt.UTC().Format(time.RFC3339Nano) + "," + strconv.FormatInt(id, 10)))
+}
+
+func decodeCursor(cursor string) (time.Time, int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	ts, idStr, ok := strings.Cut(string(raw), ",")
+	if !ok {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	return createdAt, id, nil
+}
+
+// AdjustmentHistory returns a page of the ingredient stock ledger, newest
+// first. Balances are summed over each ingredient's whole ledger before
+// filtering, so they are the stock each row actually left behind.
+func (db *DB) AdjustmentHistory(ctx context.Context, f AdjustmentFilter) (*models.AdjustmentPage, error) {
+	limit := f.Limit
+	if limit <= 0 {
+		limit = DefaultAdjustmentPageSize
+	}
+	limit = min(limit, MaxAdjustmentPageSize)
+
+	var (
+		inner, outer []string
+		args         []any
+	)
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	if f.IngredientID > 0 {
+		inner = append(inner, "ingredient_id = "+arg(f.IngredientID))
+	}
+	if !f.From.IsZero() {
+		outer = append(outer, "a.created_at >= "+arg(f.From))
+	}
+	if !f.To.IsZero() {
+		outer = append(outer, "a.created_at < "+arg(f.To))
+	}
+	for _, m := range []struct{ column, value string }{
+		{"a.reason", f.Reason}, {"a.reference", f.Reference}, {"a.created_by", f.CreatedBy},
+	} {
+		if m.value != "" {
+			outer = append(outer, m.column+" = "+arg(m.value))
+		}
+	}
+	if f.Cursor != "" {
+		createdAt, id, err := decodeCursor(f.Cursor)
+		if err != nil {
+			return nil, fmt.Errorf("AdjustmentHistory: %w", err)
+		}
+		outer = append(outer, fmt.Sprintf("(a.created_at, a.id) < (%s, %s)", arg(createdAt), arg(id)))
+	}
+
+	query := `SELECT a.id, a.ingredient_id, i.name, i.unit, a.change, COALESCE(a.reason, ''), COALESCE(a.reference, ''),
+		COALESCE(a.created_by, ''), a.created_at, a.balance
+	FROM (
+		SELECT *, SUM(change) OVER (PARTITION BY ingredient_id ORDER BY created_at, id) AS balance
+		FROM inventory_adjustments`
+	if len(inner) > 0 {
+		query += " WHERE " + strings.Join(inner, " AND ")
+	}
+	query += `) a JOIN ingredients i ON i.id = a.ingredient_id`
+	if len(outer) > 0 {
+		query += " WHERE " + strings.Join(outer, " AND ")
+	}
+	query += " ORDER BY a.created_at DESC, a.id DESC LIMIT " + arg(limit+1)
+
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("AdjustmentHistory: %w", err)
+	}
+	defer rows.Close()
+
+	page := &models.AdjustmentPage{Adjustments: []models.AdjustmentEntry{}}
+	for rows.Next() {
+		var e models.AdjustmentEntry
+		if err := rows.Scan(&e.ID, &e.IngredientID, &e.IngredientName, &e.Unit, &e.Change, &e.Reason, &e.Reference,
+			&e.CreatedBy, &e.CreatedAt, &e.Balance); err != nil {
+			return nil, fmt.Errorf("AdjustmentHistory scan: %w", err)
+		}
+		page.Adjustments = append(page.Adjustments, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("AdjustmentHistory rows: %w", err)
+	}
+
+	if len(page.Adjustments) > limit {
+		page.Adjustments = page.Adjustments[:limit]
+		last := page.Adjustments[limit-1]
+		page.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+	return page, nil
+}
@@ -0,0 +1,198 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"com.MixieMelts.inventory/internal/alerts"
+	"com.MixieMelts.inventory/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// Views of ListStockAlerts.
+const (
+	AlertViewOpen         = "open" // open and not snoozed
+	AlertViewSnoozed      = "snoozed"
+	AlertViewAcknowledged = "acknowledged"
+	AlertViewResolved     = "resolved"
+	AlertViewAll          = "all"
+)
+
+var (
+	// ErrUnknownAlertView is returned for a view ListStockAlerts does not know.
+	ErrUnknownAlertView = errors.New("unknown alert view")
+	// ErrAlertResolved is returned when acknowledging or snoozing an alert
+	// that has already been resolved.
+	ErrAlertResolved = errors.New("alert is resolved")
+)
+
+var alertViewConditions = map[string]string{
+	AlertViewOpen:         `a.status = 'open' AND (a.snoozed_until IS NULL OR a.snoozed_until <= NOW())`,
+	AlertViewSnoozed:      `a.status <> 'resolved' AND a.snoozed_until > NOW()`,
+	AlertViewAcknowledged: `a.status = 'acknowledged'`,
+	AlertViewResolved:     `a.status = 'resolved'`,
+	AlertViewAll:          `TRUE`,
+}
+
+const stockAlertColumns = `a.id, a.ingredient_id, i.name, i.unit, a.stock, a.threshold, i.stock, a.status, a.snoozed_until,
+	a.acknowledged_by, a.acknowledged_at, a.resolved_at, a.created_at, a.updated_at`
+
+const stockAlertFrom = ` FROM stock_alerts a JOIN ingredients i ON i.id = a.ingredient_id`
+
+func scanStockAlert(row pgx.Row) (*models.StockAlert, error) {
+	var a models.StockAlert
+	err := row.Scan(&a.ID, &a.IngredientID, &a.IngredientName, &a.Unit, &a.Stock, &a.Threshold, &a.CurrentStock, &a.Status,
+		&a.SnoozedUntil, &a.AcknowledgedBy, &a.AcknowledgedAt, &a.ResolvedAt, &a.CreatedAt, &a.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// evaluateStockAlertTx raises or resolves the low-stock alert of an
+// ingredient inside an existing transaction, locking the ingredient row so
+// concurrent evaluations agree on whether an alert is active. A raised alert
+// is published as an ingredient.low_stock event.
+func evaluateStockAlertTx(ctx context.Context, tx pgx.Tx, ingredientID int64) (alerts.Action, error) {
+	var stock, threshold float64
+	var active bool
+	err := tx.QueryRow(ctx, `SELECT COALESCE(stock, 0), COALESCE(min_threshold, 0),
+		EXISTS (SELECT 1 FROM stock_alerts WHERE ingredient_id = i.id AND status <> 'resolved')
+		FROM ingredients i WHERE id = $1 FOR UPDATE`, ingredientID).Scan(&stock, &threshold, &active)
+	if err != nil {
+		return alerts.None, fmt.Errorf("evaluate alert for ingredient %d: %w", ingredientID, err)
+	}
+
+	action := alerts.Evaluate(stock, threshold, active)
+	switch action {
+	case alerts.Raise:
+		var id int64
+		err := tx.QueryRow(ctx, `INSERT INTO stock_alerts (ingredient_id, stock, threshold) VALUES ($1,$2,$3) RETURNING id`,
+			ingredientID, stock, threshold).Scan(&id)
+		if err != nil {
+			return alerts.None, fmt.Errorf("raise alert for ingredient %d: %w", ingredientID, err)
+		}
+		alert, err := scanStockAlert(tx.QueryRow(ctx, `SELECT `+stockAlertColumns+stockAlertFrom+` WHERE a.id = $1`, id))
+		if err != nil {
+			return alerts.None, fmt.Errorf("load alert %d: %w", id, err)
+		}
+		if err := enqueueEventTx(ctx, tx, models.EventIngredientLowStock, strconv.FormatInt(ingredientID, 10), alert); err != nil {
+			return alerts.None, err
+		}
+	case alerts.Resolve:
+		_, err := tx.Exec(ctx, `UPDATE stock_alerts SET status = 'resolved', resolved_at = NOW(), updated_at = NOW()
+			WHERE ingredient_id = $1 AND status <> 'resolved'`, ingredientID)
+		if err != nil {
+			return alerts.None, fmt.Errorf("resolve alert for ingredient %d: %w", ingredientID, err)
+		}
+	}
+	return action, nil
+}
+
+// EvaluateStockAlerts evaluates the low-stock alert of every ingredient, one
+// transaction per ingredient, and returns how many alerts were raised and
+// resolved.
+func (db *DB) EvaluateStockAlerts(ctx context.Context) (raised, resolved int, err error) {
+	rows, err := db.Query(ctx, `SELECT id FROM ingredients ORDER BY id`)
+	if err != nil {
+		return 0, 0, fmt.Errorf("EvaluateStockAlerts: %w", err)
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return 0, 0, fmt.Errorf("EvaluateStockAlerts: %w", err)
+	}
+
+	for _, id := range ids {
+		action, err := db.evaluateStockAlert(ctx, id)
+		if err != nil {
+			return raised, resolved, fmt.Errorf("EvaluateStockAlerts: %w", err)
+		}
+		switch action {
+		case alerts.Raise:
+			raised++
+		case alerts.Resolve:
+			resolved++
+		}
+	}
+	return raised, resolved, nil
+}
+
+func (db *DB) evaluateStockAlert(ctx context.Context, ingredientID int64) (alerts.Action, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return alerts.None, fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	action, err := evaluateStockAlertTx(ctx, tx, ingredientID)
+	if err != nil {
+		return alerts.None, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return alerts.None, fmt.Errorf("commit: %w", err)
+	}
+	return action, nil
+}
+
+// ListStockAlerts returns the alerts in view, newest first.
+func (db *DB) ListStockAlerts(ctx context.Context, view string) ([]models.StockAlert, error) {
+	cond, ok := alertViewConditions[view]
+	if !ok {
+		return nil, fmt.Errorf("ListStockAlerts: %w: %q", ErrUnknownAlertView, view)
+	}
+	rows, err := db.Query(ctx, `SELECT `+stockAlertColumns+stockAlertFrom+` WHERE `+cond+` ORDER BY a.created_at DESC, a.id DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("ListStockAlerts: %w", err)
+	}
+	defer rows.Close()
+
+	list := []models.StockAlert{}
+	for rows.Next() {
+		a, err := scanStockAlert(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ListStockAlerts scan: %w", err)
+		}
+		list = append(list, *a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ListStockAlerts rows: %w", err)
+	}
+	return list, nil
+}
+
+// AcknowledgeStockAlert marks an alert as seen by by. It returns nil if the
+// alert does not exist and ErrAlertResolved if it is already resolved.
+func (db *DB) AcknowledgeStockAlert(ctx context.Context, id int64, by string) (*models.StockAlert, error) {
+	return db.updateActiveStockAlert(ctx, "AcknowledgeStockAlert", id,
+		`UPDATE stock_alerts SET status = 'acknowledged', acknowledged_by = $2, acknowledged_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND status <> 'resolved'`, by)
+}
+
+// SnoozeStockAlert hides an alert from the open view until until. It returns
+// nil if the alert does not exist and ErrAlertResolved if it is already
+// resolved.
+func (db *DB) SnoozeStockAlert(ctx context.Context, id int64, until time.Time) (*models.StockAlert, error) {
+	return db.updateActiveStockAlert(ctx, "SnoozeStockAlert", id,
+		`UPDATE stock_alerts SET snoozed_until = $2, updated_at = NOW() WHERE id = $1 AND status <> 'resolved'`, until)
+}
+
+func (db *DB) updateActiveStockAlert(ctx context.Context, op string, id int64, update string, arg any) (*models.StockAlert, error) {
+	tag, err := db.Exec(ctx, update, id, arg)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	alert, err := scanStockAlert(db.QueryRow(ctx, `SELECT `+stockAlertColumns+stockAlertFrom+` WHERE a.id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, fmt.Errorf("%s: %w", op, ErrAlertResolved)
+	}
+	return alert, nil
+}
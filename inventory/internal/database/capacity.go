@@ -0,0 +1,79 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"com.MixieMelts.inventory/internal/models"
+)
+
+// capacityQuery computes, per product, how many units current ingredient
+// stock can make and which ingredient runs out first, alongside finished
+// goods on hand. Recipe amounts and stock are converted to a common base unit
+// through the units table. A recipe line whose ingredient is missing, or
+// whose unit cannot be converted to the ingredient's, limits the product to
+// zero. $1 optionally restricts the result to one product.
+const capacityQuery = `WITH lines AS (
+		SELECT ri.product_id, ri.ingredient_id,
+			CASE WHEN i.id IS NULL OR su.dimension IS NULL OR ru.dimension IS DISTINCT FROM su.dimension THEN NULL
+				ELSE GREATEST(i.stock, 0) * su.to_base / (ri.amount * ru.to_base) END AS units
+		FROM recipe_items ri
+		LEFT JOIN ingredients i ON i.id = ri.ingredient_id
+		LEFT JOIN units su ON su.code = lower(trim(i.unit))
+		LEFT JOIN units ru ON ru.code = lower(trim(ri.unit))
+		WHERE ri.amount > 0 AND ($1::bigint IS NULL OR ri.product_id = $1)
+	), limiting AS (
+		SELECT DISTINCT ON (product_id) product_id, ingredient_id, units
+		FROM lines ORDER BY product_id, units ASC NULLS FIRST, ingredient_id
+	)
+	SELECT COALESCE(l.product_id, fg.product_id), COALESCE(l.ingredient_id, 0), COALESCE(l.units, 0), COALESCE(fg.quantity, 0)
+	FROM limiting l
+	FULL JOIN (SELECT product_id, quantity FROM finished_goods WHERE $1::bigint IS NULL OR product_id = $1) fg
+		ON fg.product_id = l.product_id
+	ORDER BY 1`
+
+// ProductCapacities returns the production capacity and finished goods of
+// every product that has a recipe or finished goods.
+func (db *DB) ProductCapacities(ctx context.Context) ([]models.ProductStockProjection, error) {
+	list, err := db.productCapacities(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ProductCapacities: %w", err)
+	}
+	return list, nil
+}
+
+// ProductCapacity returns a product's production capacity and finished goods.
+// A product inventory knows nothing about has no capacity.
+func (db *DB) ProductCapacity(ctx context.Context, productID int64) (*models.ProductStockProjection, error) {
+	list, err := db.productCapacities(ctx, &productID)
+	if err != nil {
+		return nil, fmt.Errorf("ProductCapacity: %w", err)
+	}
+	if len(list) == 0 {
+		return &models.ProductStockProjection{ProductID: productID, ComputedAt: time.Now().UTC()}, nil
+	}
+	return &list[0], nil
+}
+
+func (db *DB) productCapacities(ctx context.Context, productID *int64) ([]models.ProductStockProjection, error) {
+	rows, err := db.Query(ctx, capacityQuery, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	now := time.Now().UTC()
+	list := []models.ProductStockProjection{}
+	for rows.Next() {
+		p := models.ProductStockProjection{ComputedAt: now}
+		if err := rows.Scan(&p.ProductID, &p.LimitingItemID, &p.AvailableUnits, &p.OnHand); err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		p.LimitingAvail = p.AvailableUnits
+		p.SellableUnits = p.OnHand + int(math.Floor(p.AvailableUnits))
+		list = append(list, p)
+	}
+	return list, rows.Err()
+}
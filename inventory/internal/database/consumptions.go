@@ -0,0 +1,174 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"com.MixieMelts.inventory/internal/models"
+	"com.MixieMelts.inventory/internal/units"
+	"github.com/jackc/pgx/v5"
+)
+
+// ReasonReversal marks ledger entries that undo an earlier consumption.
+const ReasonReversal = "reversal"
+
+var (
+	// ErrInsufficientStock is returned when a consumption would take an
+	// ingredient's stock below zero.
+	ErrInsufficientStock = errors.New("insufficient stock")
+	// ErrMissingRecipe is returned when a consumption names a product that has
+	// no recipe items, so nothing could be drawn down for it.
+	ErrMissingRecipe = errors.New("product has no recipe")
+)
+
+// recipeRequirementsTx expands product quantities into per-ingredient totals
+// using recipe_items, converting each recipe amount into the unit the
+// ingredient is stocked in. A recipe unit that cannot be converted fails with
+// units.ErrIncompatible. Results are ordered by ingredient id so callers lock
+// ingredient rows in a consistent order.
+func recipeRequirementsTx(ctx context.Context, tx pgx.Tx, lines []models.ProductQuantity) ([]models.IngredientRequirement, error) {
+	productIDs := make([]int64, len(lines))
+	quantities := make([]float64, len(lines))
+	for i, l := range lines {
+		productIDs[i] = l.ProductID
+		quantities[i] = l.Quantity
+	}
+
+	rows, err := tx.Query(ctx, `SELECT l.product_id, ri.ingredient_id, i.name, i.unit, ri.unit, ri.amount * l.quantity
+		FROM unnest($1::bigint[], $2::float8[]) AS l(product_id, quantity)
+		LEFT JOIN recipe_items ri ON ri.product_id = l.product_id AND ri.ingredient_id IS NOT NULL
+		LEFT JOIN ingredients i ON i.id = ri.ingredient_id`,
+		productIDs, quantities)
+	if err != nil {
+		return nil, fmt.Errorf("recipe requirements query: %w", err)
+	}
+	defer rows.Close()
+
+	totals := map[int64]*models.IngredientRequirement{}
+	for rows.Next() {
+		var productID int64
+		var ingredientID *int64
+		var name, stockUnit, recipeUnit *string
+		var amount *float64
+		if err := rows.Scan(&productID, &ingredientID, &name, &stockUnit, &recipeUnit, &amount); err != nil {
+			return nil, fmt.Errorf("recipe requirements scan: %w", err)
+		}
+		if ingredientID == nil {
+			return nil, fmt.Errorf("%w: product %d", ErrMissingRecipe, productID)
+		}
+		if name == nil || stockUnit == nil {
+			return nil, fmt.Errorf("%w: product %d uses unknown ingredient %d", ErrMissingRecipe, productID, *ingredientID)
+		}
+		req, ok := totals[*ingredientID]
+		if !ok {
+			req = &models.IngredientRequirement{IngredientID: *ingredientID, IngredientName: *name, Unit: *stockUnit}
+			totals[*ingredientID] = req
+		}
+		if amount == nil || recipeUnit == nil {
+			continue
+		}
+		converted, err := units.Convert(*amount, *recipeUnit, *stockUnit)
+		if err != nil {
+			return nil, fmt.Errorf("product %d ingredient %d: %w", productID, *ingredientID, err)
+		}
+		req.Amount += converted
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("recipe requirements rows: %w", err)
+	}
+
+	reqs := make([]models.IngredientRequirement, 0, len(totals))
+	for _, r := range totals {
+		reqs = append(reqs, *r)
+	}
+	sort.Slice(reqs, func(i, j int) bool { return reqs[i].IngredientID < reqs[j].IngredientID })
+	return reqs, nil
+}
+
+// RecipeRequirements returns the ingredients needed to make lines, without
+// changing stock. It is used to build kitchen pull lists.
+func (db *DB) RecipeRequirements(ctx context.Context, lines []models.ProductQuantity) ([]models.IngredientRequirement, error) {
+	tx, err := db.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, fmt.Errorf("RecipeRequirements begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	reqs, err := recipeRequirementsTx(ctx, tx, lines)
+	if err != nil {
+		return nil, fmt.Errorf("RecipeRequirements: %w", err)
+	}
+	return reqs, nil
+}
+
+// adjustmentsByReferenceTx returns the ledger rows written for reference with
+// the given reason.
+func adjustmentsByReferenceTx(ctx context.Context, tx pgx.Tx, reference, reason string) ([]models.InventoryAdjustment, error) {
+	rows, err := tx.Query(ctx, `SELECT id, ingredient_id, change, reason, reference, created_by, created_at
+		FROM inventory_adjustments WHERE reference = $1 AND reason = $2 ORDER BY ingredient_id, id`, reference, reason)
+	if err != nil {
+		return nil, fmt.Errorf("adjustments by reference query: %w", err)
+	}
+	defer rows.Close()
+
+	var list []models.InventoryAdjustment
+	for rows.Next() {
+		var a models.InventoryAdjustment
+		if err := rows.Scan(&a.ID, &a.IngredientID, &a.Change, &a.Reason, &a.Reference, &a.CreatedBy, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("adjustments by reference scan: %w", err)
+		}
+		list = append(list, a)
+	}
+	return list, rows.Err()
+}
+
+// consumeIngredientsTx draws down the ingredients needed to make lines, one
+// ledger entry per ingredient, inside an existing transaction. It returns
+// ErrInsufficientStock if any ingredient is short.
+func consumeIngredientsTx(ctx context.Context, tx pgx.Tx, lines []models.ProductQuantity, reason, reference, createdBy string) error {
+	reqs, err := recipeRequirementsTx(ctx, tx, lines)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range reqs {
+		var stock float64
+		if err := tx.QueryRow(ctx, `SELECT stock FROM ingredients WHERE id = $1 FOR UPDATE`, r.IngredientID).Scan(&stock); err != nil {
+			return fmt.Errorf("lock ingredient %d: %w", r.IngredientID, err)
+		}
+		if stock < r.Amount {
+			return fmt.Errorf("%w: ingredient %d needs %.2f, has %.2f", ErrInsufficientStock, r.IngredientID, r.Amount, stock)
+		}
+		if _, err := adjustIngredientStockTx(ctx, tx, r.IngredientID, -r.Amount, reason, reference, createdBy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reverseConsumptionTx undoes every consumption recorded under reference
+// with the given reason by posting opposite ledger entries with reason
+// ReasonReversal, inside an existing transaction. Reversing the same
+// reference twice is a no-op.
+func reverseConsumptionTx(ctx context.Context, tx pgx.Tx, reference, reason, createdBy string) error {
+	reversed, err := adjustmentsByReferenceTx(ctx, tx, reference, ReasonReversal)
+	if err != nil {
+		return err
+	}
+	if len(reversed) > 0 {
+		return nil
+	}
+
+	consumed, err := adjustmentsByReferenceTx(ctx, tx, reference, reason)
+	if err != nil {
+		return err
+	}
+	for _, a := range consumed {
+		if _, err := adjustIngredientStockTx(ctx, tx, a.IngredientID, -a.Change, ReasonReversal, reference, createdBy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
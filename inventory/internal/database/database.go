@@ -13,44 +13,42 @@ type DB struct {
 	*pgxpool.Pool
 }
 
-// New creates a new database connection pool and ensures required tables exist.
-func New(config string) (*DB, error) {
+// Open creates a connection pool without touching the schema.
+func Open(config string) (*DB, error) {
 	pool, err := pgxpool.New(context.Background(), config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create connection pool: %w", err)
 	}
 
-	db := &DB{pool}
-
-	if err := db.createTables(context.Background()); err != nil {
-		pool.Close()
-		return nil, fmt.Errorf("failed to create tables: %w", err)
-	}
-
-	// Optional seed or migrations could run here
-	db.seed(context.Background())
-
 	log.Println("inventory: successfully created database connection pool")
-	return db, nil
+	return &DB{pool}, nil
 }
 
-func (db *DB) createTables(ctx context.Context) error {
-	if err := db.createIngredientsTable(ctx); err != nil {
-		return err
+// New creates a connection pool, applies pending migrations and seeds the
+// database.
+func New(config string) (*DB, error) {
+	db, err := Open(config)
+	if err != nil {
+		return nil, err
 	}
-	if err := db.createInventoryAdjustmentsTable(ctx); err != nil {
-		return err
+
+	if _, err := db.MigrateUp(context.Background()); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
-	if err := db.createRecipeItemsTable(ctx); err != nil {
-		return err
+	if err := db.syncUnits(context.Background()); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to sync units: %w", err)
 	}
-	return nil
+
+	db.seed(context.Background())
+
+	return db, nil
 }
 
 // seed performs optional initial data seeding. Keep minimal so tests can run deterministically.
 func (db *DB) seed(ctx context.Context) {
 	db.seedIngredients(ctx)
-	db.seedInventoryAdjustments(ctx)
 	// Seed recipe items (mirrors products' recipes). Enabled so inventory has recipe data for product lookups.
 	db.seedRecipeItems(ctx)
 }
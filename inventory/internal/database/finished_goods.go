@@ -0,0 +1,333 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"com.MixieMelts.inventory/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// Finished-goods ledger reasons written by the service itself.
+const (
+	// ReasonBatch marks units made by a kitchen batch, and the ingredients
+	// the batch consumed.
+	ReasonBatch = "batch"
+	// ReasonOrder marks units taken to fill an order, and the ingredients
+	// consumed when the order's reservation was confirmed.
+	ReasonOrder = "order"
+)
+
+// GetFinishedGoods returns the finished-goods stock of every product that
+// has any history, in product order.
+func (db *DB) GetFinishedGoods(ctx context.Context) ([]models.FinishedGood, error) {
+	rows, err := db.Query(ctx, `SELECT product_id, quantity, created_at, updated_at FROM finished_goods ORDER BY product_id`)
+	if err != nil {
+		return nil, fmt.Errorf("GetFinishedGoods: %w", err)
+	}
+	defer rows.Close()
+
+	list := []models.FinishedGood{}
+	for rows.Next() {
+		var fg models.FinishedGood
+		if err := rows.Scan(&fg.ProductID, &fg.Quantity, &fg.CreatedAt, &fg.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("GetFinishedGoods scan: %w", err)
+		}
+		list = append(list, fg)
+	}
+	return list, rows.Err()
+}
+
+// GetFinishedGood returns a product's finished-goods stock, or nil if none
+// has ever been recorded.
+func (db *DB) GetFinishedGood(ctx context.Context, productID int64) (*models.FinishedGood, error) {
+	var fg models.FinishedGood
+	err := db.QueryRow(ctx, `SELECT product_id, quantity, created_at, updated_at FROM finished_goods WHERE product_id = $1`, productID).
+		Scan(&fg.ProductID, &fg.Quantity, &fg.CreatedAt, &fg.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("GetFinishedGood: %w", err)
+	}
+	return &fg, nil
+}
+
+// GetFinishedGoodAdjustments returns a product's finished-goods ledger,
+// newest first.
+func (db *DB) GetFinishedGoodAdjustments(ctx context.Context, productID int64) ([]models.FinishedGoodAdjustment, error) {
+	rows, err := db.Query(ctx, `SELECT id, product_id, change, COALESCE(reason, ''), COALESCE(reference, ''), COALESCE(created_by, ''), created_at
+		FROM finished_goods_adjustments WHERE product_id = $1 ORDER BY created_at DESC, id DESC`, productID)
+	if err != nil {
+		return nil, fmt.Errorf("GetFinishedGoodAdjustments: %w", err)
+	}
+	defer rows.Close()
+
+	list := []models.FinishedGoodAdjustment{}
+	for rows.Next() {
+		var a models.FinishedGoodAdjustment
+		if err := rows.Scan(&a.ID, &a.ProductID, &a.Change, &a.Reason, &a.Reference, &a.CreatedBy, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("GetFinishedGoodAdjustments scan: %w", err)
+		}
+		list = append(list, a)
+	}
+	return list, rows.Err()
+}
+
+// AdjustFinishedGoods changes a product's finished-goods stock and records it
+// in the finished-goods ledger, e.g. after a stock count. It returns
+// ErrInsufficientStock rather than take stock below zero.
+func (db *DB) AdjustFinishedGoods(ctx context.Context, productID int64, change int, reason, reference, createdBy string) (int, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("AdjustFinishedGoods begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	newQty, err := adjustFinishedGoodsTx(ctx, tx, productID, change, reason, reference, createdBy)
+	if err != nil {
+		return 0, fmt.Errorf("AdjustFinishedGoods: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("AdjustFinishedGoods commit: %w", err)
+	}
+	return newQty, nil
+}
+
+// adjustFinishedGoodsTx updates a product's finished-goods stock and writes
+// the matching ledger row inside an existing transaction, creating the stock
+// row on first use.
+func adjustFinishedGoodsTx(ctx context.Context, tx pgx.Tx, productID int64, change int, reason, reference, createdBy string) (int, error) {
+	var newQty int
+	err := tx.QueryRow(ctx, `INSERT INTO finished_goods (product_id, quantity) VALUES ($1, 0)
+		ON CONFLICT (product_id) DO UPDATE SET product_id = EXCLUDED.product_id
+		RETURNING quantity`, productID).Scan(&newQty)
+	if err != nil {
+		return 0, fmt.Errorf("lock finished goods for product %d: %w", productID, err)
+	}
+	if newQty+change < 0 {
+		return 0, fmt.Errorf("%w: product %d has %d finished units, cannot take %d", ErrInsufficientStock, productID, newQty, -change)
+	}
+
+	err = tx.QueryRow(ctx, `UPDATE finished_goods SET quantity = quantity + $1, updated_at = NOW() WHERE product_id = $2 RETURNING quantity`,
+		change, productID).Scan(&newQty)
+	if err != nil {
+		return 0, fmt.Errorf("update finished goods for product %d: %w", productID, err)
+	}
+
+	_, err = tx.Exec(ctx, `INSERT INTO finished_goods_adjustments (product_id, change, reason, reference, created_by) VALUES ($1,$2,$3,$4,$5)`,
+		productID, change, reason, reference, createdBy)
+	if err != nil {
+		return 0, fmt.Errorf("insert finished goods adjustment for product %d: %w", productID, err)
+	}
+	return newQty, nil
+}
+
+// finishedGoodAdjustmentsTx returns the finished-goods ledger rows written
+// for reference with the given reason.
+func finishedGoodAdjustmentsTx(ctx context.Context, tx pgx.Tx, reference, reason string) ([]models.FinishedGoodAdjustment, error) {
+	rows, err := tx.Query(ctx, `SELECT id, product_id, change, reason, reference, created_by, created_at
+		FROM finished_goods_adjustments WHERE reference = $1 AND reason = $2 ORDER BY product_id, id`, reference, reason)
+	if err != nil {
+		return nil, fmt.Errorf("finished goods adjustments by reference query: %w", err)
+	}
+	defer rows.Close()
+
+	var list []models.FinishedGoodAdjustment
+	for rows.Next() {
+		var a models.FinishedGoodAdjustment
+		if err := rows.Scan(&a.ID, &a.ProductID, &a.Change, &a.Reason, &a.Reference, &a.CreatedBy, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("finished goods adjustments by reference scan: %w", err)
+		}
+		list = append(list, a)
+	}
+	return list, rows.Err()
+}
+
+// AllocateFinishedGoods fills req's lines from finished goods as far as stock
+// allows, in one transaction, and reports how much of each line was covered.
+// Units other reservations hold are left for them, while the reservation
+// under req.Reference, if any, takes what it holds and is filled by what is
+// allocated. Each allocated line is recorded as a ReasonOrder ledger entry
+// under req.Reference. Ingredients the order consumed for units it no longer
+// has to make are put back with ReasonReversal entries under the same
+// reference. Repeating a request for the same reference returns the
+// original allocation without taking stock again.
+func (db *DB) AllocateFinishedGoods(ctx context.Context, req models.AllocationRequest) (*models.Allocation, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("AllocateFinishedGoods begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var done bool
+	err = tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM finished_goods_allocations WHERE reference = $1)`, req.Reference).Scan(&done)
+	if err != nil {
+		return nil, fmt.Errorf("AllocateFinishedGoods check reference: %w", err)
+	}
+
+	alloc := &models.Allocation{Reference: req.Reference}
+	if done {
+		prior, err := finishedGoodAdjustmentsTx(ctx, tx, req.Reference, ReasonOrder)
+		if err != nil {
+			return nil, fmt.Errorf("AllocateFinishedGoods: %w", err)
+		}
+		taken := map[int64]int{}
+		for _, a := range prior {
+			taken[a.ProductID] -= a.Change
+		}
+		for _, l := range req.Lines {
+			n := min(taken[l.ProductID], l.Quantity)
+			taken[l.ProductID] -= n
+			alloc.Lines = append(alloc.Lines, models.AllocationLine{ProductID: l.ProductID, Requested: l.Quantity, Allocated: n})
+		}
+		return alloc, nil
+	}
+
+	if _, err := tx.Exec(ctx, `INSERT INTO finished_goods_allocations (reference) VALUES ($1)`, req.Reference); err != nil {
+		return nil, fmt.Errorf("AllocateFinishedGoods record reference: %w", err)
+	}
+	if err := lockReservationTx(ctx, tx, req.Reference); err != nil {
+		return nil, fmt.Errorf("AllocateFinishedGoods: %w", err)
+	}
+
+	for _, l := range req.Lines {
+		var onHand int
+		err := tx.QueryRow(ctx, `SELECT quantity FROM finished_goods WHERE product_id = $1 FOR UPDATE`, l.ProductID).Scan(&onHand)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("AllocateFinishedGoods lock product %d: %w", l.ProductID, err)
+		}
+		held, err := finishedGoodsHeldTx(ctx, tx, []int64{l.ProductID}, req.Reference)
+		if err != nil {
+			return nil, fmt.Errorf("AllocateFinishedGoods: %w", err)
+		}
+		n := max(min(onHand-int(math.Ceil(held[l.ProductID])), l.Quantity), 0)
+		if n > 0 {
+			if _, err := adjustFinishedGoodsTx(ctx, tx, l.ProductID, -n, ReasonOrder, req.Reference, req.CreatedBy); err != nil {
+				return nil, fmt.Errorf("AllocateFinishedGoods: %w", err)
+			}
+			made, err := fillReservationTx(ctx, tx, req.Reference, l.ProductID, req.Reference, float64(n))
+			if err != nil {
+				return nil, fmt.Errorf("AllocateFinishedGoods: %w", err)
+			}
+			if made > 0 {
+				lines, err := madeLinesTx(ctx, tx, req.Reference, l.ProductID, made)
+				if err != nil {
+					return nil, fmt.Errorf("AllocateFinishedGoods: %w", err)
+				}
+				if err := postLinesTx(ctx, tx, lines, 1, ReasonReversal, req.Reference, req.CreatedBy); err != nil {
+					return nil, fmt.Errorf("AllocateFinishedGoods: %w", err)
+				}
+			}
+		}
+		alloc.Lines = append(alloc.Lines, models.AllocationLine{ProductID: l.ProductID, Requested: l.Quantity, Allocated: n})
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("AllocateFinishedGoods commit: %w", err)
+	}
+	return alloc, nil
+}
+
+// RecordBatch books a finished kitchen batch in one transaction: each order
+// the batch was made for has its reservation filled with its share, the
+// ingredients for req.Quantity units are consumed except for the units
+// whose ingredients the orders consumed when they were confirmed, the units
+// are added to finished goods, and req.Allocated of them are taken for the
+// orders. All ledger entries and fills share req.Reference. Recording the
+// same batch twice is a no-op.
+func (db *DB) RecordBatch(ctx context.Context, req models.BatchRequest) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("RecordBatch begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	existing, err := finishedGoodAdjustmentsTx(ctx, tx, req.Reference, ReasonBatch)
+	if err != nil {
+		return fmt.Errorf("RecordBatch: %w", err)
+	}
+	if len(existing) > 0 {
+		return nil
+	}
+
+	// Reservations are locked before the stock rows, in reference order so
+	// concurrent batches for different products queue up.
+	orders := slices.Clone(req.Orders)
+	slices.SortFunc(orders, func(a, b models.OrderShare) int { return strings.Compare(a.Reference, b.Reference) })
+	var made float64
+	for _, o := range orders {
+		n, err := fillReservationTx(ctx, tx, o.Reference, req.ProductID, req.Reference, float64(o.Quantity))
+		if err != nil {
+			return fmt.Errorf("RecordBatch: %w", err)
+		}
+		made += n
+	}
+	if toConsume := float64(req.Quantity) - made; toConsume > 0 {
+		lines := []models.ProductQuantity{{ProductID: req.ProductID, Quantity: toConsume}}
+		if err := consumeIngredientsTx(ctx, tx, lines, ReasonBatch, req.Reference, req.CreatedBy); err != nil {
+			return fmt.Errorf("RecordBatch: %w", err)
+		}
+	}
+	if _, err := adjustFinishedGoodsTx(ctx, tx, req.ProductID, req.Quantity, ReasonBatch, req.Reference, req.CreatedBy); err != nil {
+		return fmt.Errorf("RecordBatch: %w", err)
+	}
+	if n := min(req.Allocated, req.Quantity); n > 0 {
+		if _, err := adjustFinishedGoodsTx(ctx, tx, req.ProductID, -n, ReasonOrder, req.Reference, req.CreatedBy); err != nil {
+			return fmt.Errorf("RecordBatch: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("RecordBatch commit: %w", err)
+	}
+	return nil
+}
+
+// ReverseBatch undoes a recorded batch: the reservations it filled are owed
+// their units again, the ingredients it consumed are restored and its
+// finished-goods entries are offset with ReasonReversal entries. Reversing a
+// batch twice, or one that was never recorded, is a no-op.
+func (db *DB) ReverseBatch(ctx context.Context, reference, createdBy string) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ReverseBatch begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	reversed, err := finishedGoodAdjustmentsTx(ctx, tx, reference, ReasonReversal)
+	if err != nil {
+		return fmt.Errorf("ReverseBatch: %w", err)
+	}
+	if len(reversed) > 0 {
+		return nil
+	}
+
+	if err := unfillReservationsTx(ctx, tx, reference); err != nil {
+		return fmt.Errorf("ReverseBatch: %w", err)
+	}
+	if err := reverseConsumptionTx(ctx, tx, reference, ReasonBatch, createdBy); err != nil {
+		return fmt.Errorf("ReverseBatch: %w", err)
+	}
+	for _, reason := range []string{ReasonOrder, ReasonBatch} {
+		entries, err := finishedGoodAdjustmentsTx(ctx, tx, reference, reason)
+		if err != nil {
+			return fmt.Errorf("ReverseBatch: %w", err)
+		}
+		for _, a := range entries {
+			if _, err := adjustFinishedGoodsTx(ctx, tx, a.ProductID, -a.Change, ReasonReversal, reference, createdBy); err != nil {
+				return fmt.Errorf("ReverseBatch: %w", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ReverseBatch commit: %w", err)
+	}
+	return nil
+}
@@ -2,11 +2,13 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 
 	"com.MixieMelts.inventory/internal/models"
-	"github.com/jackc/pgx"
+	"com.MixieMelts.inventory/internal/units"
+	"github.com/jackc/pgx/v5"
 )
 
 func (db *DB) seedIngredients(ctx context.Context) {
@@ -68,32 +70,15 @@ func (db *DB) seedIngredients(ctx context.Context) {
 		{ID: 44, Name: "Hay Absolute", Type: "Natural Fragrance", Unit: "mL", Stock: 100.0, MinThreshold: 25.0},
 	}
 
+	// Each ingredient's opening stock is posted to the ledger with it, so the
+	// ledger accounts for every seeded unit.
 	for _, it := range ingredients {
-		query := `INSERT INTO ingredients (name, type, unit, stock, min_threshold, notes)
-		VALUES ($1,$2,$3,$4,$5,$6)`
-		_, err := db.Exec(ctx, query, it.Name, it.Type, it.Unit, it.Stock, it.MinThreshold, it.Notes)
-		if err != nil {
+		if _, err := db.CreateIngredient(ctx, &it); err != nil {
 			log.Printf("inventory: seed insert %q: %v", it.Name, err)
 		}
 	}
 }
 
-func (db *DB) createIngredientsTable(ctx context.Context) error {
-	query := `CREATE TABLE IF NOT EXISTS ingredients (
-		id SERIAL PRIMARY KEY,
-		name TEXT NOT NULL UNIQUE,
-		type TEXT NOT NULL,
-		unit TEXT NOT NULL,
-		stock DOUBLE PRECISION DEFAULT 0,
-		min_threshold DOUBLE PRECISION DEFAULT 0,
-		notes TEXT,
-		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-	);`
-	_, err := db.Exec(ctx, query)
-	return err
-}
-
 // GetIngredients returns all ingredients.
 func (db *DB) GetIngredients(ctx context.Context) ([]models.Ingredient, error) {
 	rows, err := db.Query(ctx, `SELECT id, name, type, unit, stock, min_threshold, notes, created_at, updated_at FROM ingredients`)
@@ -118,7 +103,7 @@ func (db *DB) GetIngredient(ctx context.Context, id int64) (*models.Ingredient,
 	row := db.QueryRow(ctx, `SELECT id, name, type, unit, stock, min_threshold, notes, created_at, updated_at FROM ingredients WHERE id = $1`, id)
 	var it models.Ingredient
 	if err := row.Scan(&it.ID, &it.Name, &it.Type, &it.Unit, &it.Stock, &it.MinThreshold, &it.Notes, &it.CreatedAt, &it.UpdatedAt); err != nil {
-		if err == pgx.ErrNoRows {
+		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, nil
 		}
 		return nil, fmt.Errorf("GetIngredient scan: %w", err)
@@ -126,23 +111,98 @@ func (db *DB) GetIngredient(ctx context.Context, id int64) (*models.Ingredient,
 	return &it, nil
 }
 
-// CreateIngredient inserts a new ingredient and returns the new id.
+// CreateIngredient inserts a new ingredient and returns the new id. The unit
+// is stored in canonical form; an unknown unit is rejected with
+// units.ErrUnknownUnit. A non-zero Stock is posted to the ledger as the
+// ingredient's "initial" adjustment rather than written directly.
 func (db *DB) CreateIngredient(ctx context.Context, it *models.Ingredient) (int64, error) {
+	unit, err := units.Normalize(it.Unit)
+	if err != nil {
+		return 0, fmt.Errorf("CreateIngredient: %w", err)
+	}
+	it.Unit = unit
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("CreateIngredient begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
 	var id int64
-	err := db.QueryRow(ctx, `INSERT INTO ingredients (name, type, unit, stock, min_threshold, notes) VALUES ($1,$2,$3,$4,$5,$6) RETURNING id`,
-		it.Name, it.Type, it.Unit, it.Stock, it.MinThreshold, it.Notes).Scan(&id)
+	err = tx.QueryRow(ctx, `INSERT INTO ingredients (name, type, unit, stock, min_threshold, notes) VALUES ($1,$2,$3,0,$4,$5) RETURNING id`,
+		it.Name, it.Type, it.Unit, it.MinThreshold, it.Notes).Scan(&id)
 	if err != nil {
 		return 0, fmt.Errorf("CreateIngredient: %w", err)
 	}
+	if it.Stock != 0 {
+		if it.Stock, err = adjustIngredientStockTx(ctx, tx, id, it.Stock, ReasonInitial, "", ""); err != nil {
+			return 0, fmt.Errorf("CreateIngredient: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("CreateIngredient commit: %w", err)
+	}
 	return id, nil
 }
 
-// UpdateIngredient updates editable fields of an ingredient.
+// UpdateIngredient updates the metadata of an ingredient. Stock is left
+// alone, since it only changes through adjustments; it.Stock is set to the
+// stored value. The unit must be known and convertible from the unit of
+// every recipe item that uses the ingredient, otherwise units.ErrUnknownUnit
+// or units.ErrIncompatible is returned. It returns ErrUnknownIngredient if
+// the ingredient does not exist.
 func (db *DB) UpdateIngredient(ctx context.Context, it *models.Ingredient) error {
-	_, err := db.Exec(ctx, `UPDATE ingredients SET name=$1, type=$2, unit=$3, stock=$4, min_threshold=$5, notes=$6, updated_at=NOW() WHERE id=$7`,
-		it.Name, it.Type, it.Unit, it.Stock, it.MinThreshold, it.Notes, it.ID)
+	unit, err := units.Normalize(it.Unit)
 	if err != nil {
 		return fmt.Errorf("UpdateIngredient: %w", err)
 	}
+	it.Unit = unit
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("UpdateIngredient begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	rows, err := tx.Query(ctx, `SELECT DISTINCT unit FROM recipe_items WHERE ingredient_id = $1`, it.ID)
+	if err != nil {
+		return fmt.Errorf("UpdateIngredient recipe units: %w", err)
+	}
+	var recipeUnits []string
+	for rows.Next() {
+		var u string
+		if err := rows.Scan(&u); err != nil {
+			rows.Close()
+			return fmt.Errorf("UpdateIngredient recipe units scan: %w", err)
+		}
+		recipeUnits = append(recipeUnits, u)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("UpdateIngredient recipe units rows: %w", err)
+	}
+	for _, u := range recipeUnits {
+		if err := units.Compatible(u, it.Unit); err != nil {
+			return fmt.Errorf("UpdateIngredient: recipes use %s: %w", u, err)
+		}
+	}
+
+	err = tx.QueryRow(ctx, `UPDATE ingredients SET name=$1, type=$2, unit=$3, min_threshold=$4, notes=$5, updated_at=NOW() WHERE id=$6
+		RETURNING stock, created_at, updated_at`,
+		it.Name, it.Type, it.Unit, it.MinThreshold, it.Notes, it.ID).Scan(&it.Stock, &it.CreatedAt, &it.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("UpdateIngredient: %w: %d", ErrUnknownIngredient, it.ID)
+	}
+	if err != nil {
+		return fmt.Errorf("UpdateIngredient: %w", err)
+	}
+	// The threshold may have changed
+	if _, err := evaluateStockAlertTx(ctx, tx, it.ID); err != nil {
+		return fmt.Errorf("UpdateIngredient: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("UpdateIngredient commit: %w", err)
+	}
 	return nil
 }
@@ -0,0 +1,36 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"com.MixieMelts.inventory/internal/models"
+)
+
+// ProductReferences returns every product id inventory stores, in recipe
+// items or finished goods, with how many rows point at it. Product ids are
+// owned by the products service, so nothing in this database guarantees they
+// still exist.
+func (db *DB) ProductReferences(ctx context.Context) ([]models.ProductReference, error) {
+	rows, err := db.Query(ctx, `SELECT COALESCE(r.product_id, fg.product_id), COALESCE(r.items, 0), fg.quantity
+		FROM (SELECT product_id, COUNT(*) AS items FROM recipe_items GROUP BY product_id) r
+		FULL JOIN finished_goods fg ON fg.product_id = r.product_id
+		ORDER BY 1`)
+	if err != nil {
+		return nil, fmt.Errorf("ProductReferences: %w", err)
+	}
+	defer rows.Close()
+
+	refs := []models.ProductReference{}
+	for rows.Next() {
+		var ref models.ProductReference
+		if err := rows.Scan(&ref.ProductID, &ref.RecipeItems, &ref.FinishedGoods); err != nil {
+			return nil, fmt.Errorf("ProductReferences scan: %w", err)
+		}
+		refs = append(refs, ref)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ProductReferences rows: %w", err)
+	}
+	return refs, nil
+}
@@ -0,0 +1,40 @@
+package database
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+
+	"com.MixieMelts.users/migrate"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migrator returns the runner for the inventory service's schema migrations.
+func Migrator() (*migrate.Migrator, error) {
+	sub, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return migrate.New("inventory", sub)
+}
+
+// MigrateUp applies every pending schema migration and returns the ones
+// applied.
+func (db *DB) MigrateUp(ctx context.Context) ([]migrate.Migration, error) {
+	m, err := Migrator()
+	if err != nil {
+		return nil, fmt.Errorf("MigrateUp: %w", err)
+	}
+	applied, err := m.UpPool(ctx, db.Pool)
+	if err != nil {
+		return nil, fmt.Errorf("MigrateUp: %w", err)
+	}
+	for _, mg := range applied {
+		log.Printf("inventory: applied migration %d_%s", mg.Version, mg.Name)
+	}
+	return applied, nil
+}
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS finished_goods_allocations;
+DROP TABLE IF EXISTS finished_goods_adjustments;
+DROP TABLE IF EXISTS finished_goods;
+DROP TABLE IF EXISTS recipe_items;
+DROP TABLE IF EXISTS inventory_adjustments;
+DROP TABLE IF EXISTS ingredients;
+DROP TABLE IF EXISTS units;
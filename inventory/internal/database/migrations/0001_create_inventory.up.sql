@@ -0,0 +1,66 @@
+-- Baseline: matches the schema the service created before migrations, so
+-- existing databases adopt it without changes.
+CREATE TABLE IF NOT EXISTS units (
+	code TEXT PRIMARY KEY,
+	dimension TEXT NOT NULL,
+	to_base DOUBLE PRECISION NOT NULL CHECK (to_base > 0)
+);
+
+CREATE TABLE IF NOT EXISTS ingredients (
+	id SERIAL PRIMARY KEY,
+	name TEXT NOT NULL UNIQUE,
+	type TEXT NOT NULL,
+	unit TEXT NOT NULL,
+	stock DOUBLE PRECISION DEFAULT 0,
+	min_threshold DOUBLE PRECISION DEFAULT 0,
+	notes TEXT,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS inventory_adjustments (
+	id SERIAL PRIMARY KEY,
+	ingredient_id BIGINT NOT NULL REFERENCES ingredients(id) ON DELETE CASCADE,
+	change DOUBLE PRECISION NOT NULL,
+	reason TEXT,
+	reference TEXT,
+	created_by TEXT,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS recipe_items (
+	id SERIAL PRIMARY KEY,
+	product_id BIGINT NOT NULL,
+	ingredient_id BIGINT REFERENCES ingredients(id) ON DELETE RESTRICT,
+	unit VARCHAR(50),
+	amount DOUBLE PRECISION,
+	notes TEXT,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS finished_goods (
+	product_id BIGINT PRIMARY KEY,
+	quantity INTEGER NOT NULL DEFAULT 0 CHECK (quantity >= 0),
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS finished_goods_adjustments (
+	id SERIAL PRIMARY KEY,
+	product_id BIGINT NOT NULL REFERENCES finished_goods(product_id) ON DELETE CASCADE,
+	change INTEGER NOT NULL,
+	reason TEXT,
+	reference TEXT,
+	created_by TEXT,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS finished_goods_adjustments_reference ON finished_goods_adjustments (reference, reason);
+
+-- Records which order references have been allocated, so an order that
+-- finished goods could not cover at all is not allocated again when stock
+-- arrives later.
+CREATE TABLE IF NOT EXISTS finished_goods_allocations (
+	reference TEXT PRIMARY KEY,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS recipe_items_product_id;
+ALTER TABLE recipe_items
+	DROP CONSTRAINT IF EXISTS recipe_items_amount_positive,
+	ALTER COLUMN amount DROP NOT NULL,
+	ALTER COLUMN unit DROP NOT NULL,
+	ALTER COLUMN ingredient_id DROP NOT NULL;
@@ -0,0 +1,8 @@
+-- Recipe items are now always written with an ingredient, unit and positive
+-- amount; enforce it and index the per-product lookups.
+ALTER TABLE recipe_items
+	ALTER COLUMN ingredient_id SET NOT NULL,
+	ALTER COLUMN unit SET NOT NULL,
+	ALTER COLUMN amount SET NOT NULL,
+	ADD CONSTRAINT recipe_items_amount_positive CHECK (amount > 0);
+CREATE INDEX IF NOT EXISTS recipe_items_product_id ON recipe_items (product_id);
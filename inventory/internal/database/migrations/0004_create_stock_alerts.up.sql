@@ -0,0 +1,16 @@
+-- Low-stock alerts. An ingredient has at most one unresolved alert, so a dip
+-- below its threshold alerts once until stock recovers.
+CREATE TABLE stock_alerts (
+	id BIGSERIAL PRIMARY KEY,
+	ingredient_id BIGINT NOT NULL REFERENCES ingredients(id) ON DELETE CASCADE,
+	stock DOUBLE PRECISION NOT NULL,
+	threshold DOUBLE PRECISION NOT NULL,
+	status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'acknowledged', 'resolved')),
+	snoozed_until TIMESTAMP WITH TIME ZONE,
+	acknowledged_by TEXT NOT NULL DEFAULT '',
+	acknowledged_at TIMESTAMP WITH TIME ZONE,
+	resolved_at TIMESTAMP WITH TIME ZONE,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX stock_alerts_one_active ON stock_alerts (ingredient_id) WHERE status <> 'resolved';
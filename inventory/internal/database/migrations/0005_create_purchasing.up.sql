@@ -0,0 +1,54 @@
+-- Suppliers, purchase orders and the receipts posted against them. Line
+-- quantities are in the line's unit; receipts convert them into the
+-- ingredient's stock unit when they post adjustments.
+CREATE TABLE suppliers (
+	id BIGSERIAL PRIMARY KEY,
+	name TEXT NOT NULL UNIQUE,
+	email TEXT NOT NULL DEFAULT '',
+	phone TEXT NOT NULL DEFAULT '',
+	lead_time_days INTEGER NOT NULL DEFAULT 0 CHECK (lead_time_days >= 0),
+	notes TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE purchase_orders (
+	id BIGSERIAL PRIMARY KEY,
+	supplier_id BIGINT NOT NULL REFERENCES suppliers(id) ON DELETE RESTRICT,
+	status TEXT NOT NULL DEFAULT 'draft'
+		CHECK (status IN ('draft', 'ordered', 'partially_received', 'received', 'closed', 'cancelled')),
+	reference TEXT NOT NULL DEFAULT '',
+	notes TEXT NOT NULL DEFAULT '',
+	created_by TEXT NOT NULL DEFAULT '',
+	ordered_at TIMESTAMP WITH TIME ZONE,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX purchase_orders_supplier ON purchase_orders (supplier_id);
+
+CREATE TABLE purchase_order_lines (
+	id BIGSERIAL PRIMARY KEY,
+	purchase_order_id BIGINT NOT NULL REFERENCES purchase_orders(id) ON DELETE CASCADE,
+	ingredient_id BIGINT NOT NULL REFERENCES ingredients(id) ON DELETE RESTRICT,
+	quantity DOUBLE PRECISION NOT NULL CHECK (quantity > 0),
+	unit TEXT NOT NULL,
+	unit_cost DOUBLE PRECISION NOT NULL DEFAULT 0 CHECK (unit_cost >= 0),
+	expected_date DATE,
+	received_quantity DOUBLE PRECISION NOT NULL DEFAULT 0,
+	UNIQUE (purchase_order_id, ingredient_id)
+);
+
+CREATE TABLE purchase_order_receipts (
+	id BIGSERIAL PRIMARY KEY,
+	purchase_order_id BIGINT NOT NULL REFERENCES purchase_orders(id) ON DELETE CASCADE,
+	received_by TEXT NOT NULL DEFAULT '',
+	notes TEXT NOT NULL DEFAULT '',
+	received_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE purchase_order_receipt_lines (
+	receipt_id BIGINT NOT NULL REFERENCES purchase_order_receipts(id) ON DELETE CASCADE,
+	line_id BIGINT NOT NULL REFERENCES purchase_order_lines(id) ON DELETE CASCADE,
+	quantity DOUBLE PRECISION NOT NULL CHECK (quantity > 0),
+	PRIMARY KEY (receipt_id, line_id)
+);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS inventory_adjustments_created;
+DROP INDEX IF EXISTS inventory_adjustments_ingredient_created;
+ALTER TABLE inventory_adjustments ALTER COLUMN created_at DROP NOT NULL;
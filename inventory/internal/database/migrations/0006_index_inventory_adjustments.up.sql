@@ -0,0 +1,6 @@
+-- Adjustment history is paged by (created_at, id) and balances are summed
+-- per ingredient in the same order, so every row needs a timestamp.
+UPDATE inventory_adjustments SET created_at = TIMESTAMPTZ 'epoch' WHERE created_at IS NULL;
+ALTER TABLE inventory_adjustments ALTER COLUMN created_at SET NOT NULL;
+CREATE INDEX inventory_adjustments_ingredient_created ON inventory_adjustments (ingredient_id, created_at, id);
+CREATE INDEX inventory_adjustments_created ON inventory_adjustments (created_at, id);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS reservation_lines;
+DROP TABLE IF EXISTS reservation_products;
+DROP TABLE IF EXISTS reservations;
@@ -0,0 +1,28 @@
+-- Holds on ingredient stock for carts and pending orders. A hold counts
+-- against available-to-promise while it is active and unexpired; lines are
+-- in each ingredient's stock unit.
+CREATE TABLE reservations (
+	id BIGSERIAL PRIMARY KEY,
+	reference TEXT NOT NULL UNIQUE,
+	status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'confirmed', 'released', 'expired')),
+	created_by TEXT NOT NULL DEFAULT '',
+	expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX reservations_active_expiry ON reservations (expires_at) WHERE status = 'active';
+
+CREATE TABLE reservation_products (
+	reservation_id BIGINT NOT NULL REFERENCES reservations(id) ON DELETE CASCADE,
+	product_id BIGINT NOT NULL,
+	quantity DOUBLE PRECISION NOT NULL CHECK (quantity > 0),
+	PRIMARY KEY (reservation_id, product_id)
+);
+
+CREATE TABLE reservation_lines (
+	reservation_id BIGINT NOT NULL REFERENCES reservations(id) ON DELETE CASCADE,
+	ingredient_id BIGINT NOT NULL REFERENCES ingredients(id) ON DELETE RESTRICT,
+	quantity DOUBLE PRECISION NOT NULL CHECK (quantity > 0),
+	PRIMARY KEY (reservation_id, ingredient_id)
+);
+CREATE INDEX reservation_lines_ingredient ON reservation_lines (ingredient_id);
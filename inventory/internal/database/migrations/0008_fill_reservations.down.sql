@@ -0,0 +1,23 @@
+DROP TABLE IF EXISTS reservation_fills;
+
+-- Fold the per-product lines back into one line per ingredient.
+CREATE TEMP TABLE reservation_line_totals ON COMMIT DROP AS
+	SELECT reservation_id, ingredient_id, SUM(quantity) AS quantity
+	FROM reservation_lines GROUP BY reservation_id, ingredient_id;
+DELETE FROM reservation_lines;
+ALTER TABLE reservation_lines DROP CONSTRAINT reservation_lines_pkey;
+ALTER TABLE reservation_lines DROP COLUMN product_id;
+INSERT INTO reservation_lines (reservation_id, ingredient_id, quantity)
+	SELECT reservation_id, ingredient_id, quantity FROM reservation_line_totals;
+ALTER TABLE reservation_lines ADD PRIMARY KEY (reservation_id, ingredient_id);
+
+ALTER TABLE reservation_products DROP COLUMN from_stock;
+
+-- Confirmed reservations that were never filled consumed nothing; the old
+-- schema has no state for them, so they are released.
+DROP INDEX IF EXISTS reservations_confirmed;
+ALTER TABLE reservations DROP CONSTRAINT IF EXISTS reservations_status_check;
+UPDATE reservations SET status = 'released' WHERE status = 'confirmed';
+UPDATE reservations SET status = 'confirmed' WHERE status = 'filled';
+ALTER TABLE reservations ADD CONSTRAINT reservations_status_check
+	CHECK (status IN ('active', 'confirmed', 'released', 'expired'));
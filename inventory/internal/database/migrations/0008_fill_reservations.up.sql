@@ -0,0 +1,31 @@
+-- A confirmed reservation now keeps holding stock until the kitchen fills
+-- its order, instead of consuming ingredients when it is confirmed; the
+-- kitchen consumes them when it makes the batch. Each product records how
+-- much of it was promised from finished goods, lines are kept per product
+-- so a filled product stops holding its ingredients, and fills record what
+-- an allocation or batch delivered so a reversed batch can take it back.
+-- Reservations confirmed before this consumed their stock already, so they
+-- count as filled. Lines of older holds have product 0 and hold in full
+-- until the hold ends.
+ALTER TABLE reservations DROP CONSTRAINT IF EXISTS reservations_status_check;
+UPDATE reservations SET status = 'filled' WHERE status = 'confirmed';
+ALTER TABLE reservations ADD CONSTRAINT reservations_status_check
+	CHECK (status IN ('active', 'confirmed', 'filled', 'released', 'expired'));
+CREATE INDEX reservations_confirmed ON reservations (id) WHERE status = 'confirmed';
+
+ALTER TABLE reservation_products
+	ADD COLUMN from_stock DOUBLE PRECISION NOT NULL DEFAULT 0 CHECK (from_stock >= 0);
+
+ALTER TABLE reservation_lines ADD COLUMN product_id BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE reservation_lines DROP CONSTRAINT reservation_lines_pkey;
+ALTER TABLE reservation_lines ADD PRIMARY KEY (reservation_id, product_id, ingredient_id);
+
+CREATE TABLE reservation_fills (
+	reservation_id BIGINT NOT NULL REFERENCES reservations(id) ON DELETE CASCADE,
+	product_id BIGINT NOT NULL,
+	reference TEXT NOT NULL,
+	quantity DOUBLE PRECISION NOT NULL CHECK (quantity > 0),
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (reservation_id, product_id, reference)
+);
+CREATE INDEX reservation_fills_reference ON reservation_fills (reference);
@@ -0,0 +1,24 @@
+-- Confirming a reservation now consumes what its order still has to make
+-- through the ledger, under the reservation's reference, and batches only
+-- consume ingredients for units no order paid for. Reservations confirmed
+-- before this still hold their ingredients, so they are consumed here the
+-- same way.
+CREATE TEMP TABLE confirmed_reservation_lines ON COMMIT DROP AS
+	SELECT r.reference, rl.ingredient_id,
+		SUM(rl.quantity * LEAST(GREATEST(COALESCE((rp.quantity - COALESCE(f.filled, 0)) / NULLIF(rp.quantity - rp.from_stock, 0), 1), 0), 1)) AS quantity
+	FROM reservation_lines rl JOIN reservations r ON r.id = rl.reservation_id
+	LEFT JOIN reservation_products rp ON rp.reservation_id = rl.reservation_id AND rp.product_id = rl.product_id
+	LEFT JOIN (SELECT reservation_id, product_id, SUM(quantity) AS filled
+		FROM reservation_fills GROUP BY reservation_id, product_id) f
+		ON f.reservation_id = rl.reservation_id AND f.product_id = rl.product_id
+	WHERE r.status = 'confirmed'
+	GROUP BY r.reference, rl.ingredient_id;
+DELETE FROM confirmed_reservation_lines WHERE quantity <= 0;
+
+INSERT INTO inventory_adjustments (ingredient_id, change, reason, reference, created_by, created_at)
+	SELECT ingredient_id, -quantity, 'order', reference, 'migration', NOW()
+	FROM confirmed_reservation_lines;
+UPDATE ingredients i SET stock = i.stock - c.quantity, updated_at = NOW()
+	FROM (SELECT ingredient_id, SUM(quantity) AS quantity
+		FROM confirmed_reservation_lines GROUP BY ingredient_id) c
+	WHERE i.id = c.ingredient_id;
@@ -0,0 +1,364 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"com.MixieMelts.inventory/internal/models"
+	"com.MixieMelts.inventory/internal/purchasing"
+	"com.MixieMelts.inventory/internal/units"
+	"github.com/jackc/pgx/v5"
+)
+
+// ReasonReceipt marks ledger entries posted by receiving a purchase order.
+const ReasonReceipt = "receipt"
+
+// ErrUnknownPurchaseOrderLine is returned when a receipt names a line that
+// is not on the purchase order.
+var ErrUnknownPurchaseOrderLine = errors.New("unknown purchase order line")
+
+const purchaseOrderColumns = `po.id, po.supplier_id, s.name, po.status, po.reference, po.notes, po.created_by,
+	po.ordered_at, po.created_at, po.updated_at`
+
+const purchaseOrderFrom = ` FROM purchase_orders po JOIN suppliers s ON s.id = po.supplier_id`
+
+func scanPurchaseOrder(row pgx.Row, po *models.PurchaseOrder) error {
+	return row.Scan(&po.ID, &po.SupplierID, &po.SupplierName, &po.Status, &po.Reference, &po.Notes, &po.CreatedBy,
+		&po.OrderedAt, &po.CreatedAt, &po.UpdatedAt)
+}
+
+// purchaseOrderLinesTx loads a purchase order's lines, works out how far each
+// has been received and totals the order.
+func purchaseOrderLinesTx(ctx context.Context, tx pgx.Tx, po *models.PurchaseOrder) error {
+	rows, err := tx.Query(ctx, `SELECT l.id, l.ingredient_id, i.name, l.quantity, l.unit, l.unit_cost,
+		COALESCE(to_char(l.expected_date, 'YYYY-MM-DD'), ''), l.received_quantity
+		FROM purchase_order_lines l JOIN ingredients i ON i.id = l.ingredient_id
+		WHERE l.purchase_order_id = $1 ORDER BY l.id`, po.ID)
+	if err != nil {
+		return fmt.Errorf("purchase order %d lines: %w", po.ID, err)
+	}
+	defer rows.Close()
+
+	po.Lines = []models.PurchaseOrderLine{}
+	po.Total = 0
+	for rows.Next() {
+		var l models.PurchaseOrderLine
+		if err := rows.Scan(&l.ID, &l.IngredientID, &l.IngredientName, &l.Quantity, &l.Unit, &l.UnitCost, &l.ExpectedDate, &l.ReceivedQuantity); err != nil {
+			return fmt.Errorf("purchase order %d lines scan: %w", po.ID, err)
+		}
+		l.Outstanding = purchasing.Outstanding(l.Quantity, l.ReceivedQuantity)
+		l.OverReceived = purchasing.OverReceived(l.Quantity, l.ReceivedQuantity)
+		l.ReceiptStatus = string(purchasing.Line(l.Quantity, l.ReceivedQuantity))
+		po.Total += l.Quantity * l.UnitCost
+		po.Lines = append(po.Lines, l)
+	}
+	return rows.Err()
+}
+
+// purchaseOrderTx loads a purchase order with its lines, or returns nil if it
+// does not exist. With lock set the order row is locked for update, which
+// serializes receipts and status changes.
+func purchaseOrderTx(ctx context.Context, tx pgx.Tx, id int64, lock bool) (*models.PurchaseOrder, error) {
+	query := `SELECT ` + purchaseOrderColumns + purchaseOrderFrom + ` WHERE po.id = $1`
+	if lock {
+		query += ` FOR UPDATE OF po`
+	}
+	var po models.PurchaseOrder
+	if err := scanPurchaseOrder(tx.QueryRow(ctx, query, id), &po); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("purchase order %d: %w", id, err)
+	}
+	if err := purchaseOrderLinesTx(ctx, tx, &po); err != nil {
+		return nil, err
+	}
+	return &po, nil
+}
+
+// GetPurchaseOrder returns a purchase order with its lines, or nil if it does
+// not exist.
+func (db *DB) GetPurchaseOrder(ctx context.Context, id int64) (*models.PurchaseOrder, error) {
+	tx, err := db.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, fmt.Errorf("GetPurchaseOrder begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	po, err := purchaseOrderTx(ctx, tx, id, false)
+	if err != nil {
+		return nil, fmt.Errorf("GetPurchaseOrder: %w", err)
+	}
+	return po, nil
+}
+
+// ListPurchaseOrders returns purchase orders with their lines, newest first,
+// optionally restricted to a status and a supplier.
+func (db *DB) ListPurchaseOrders(ctx context.Context, status string, supplierID int64) ([]models.PurchaseOrder, error) {
+	tx, err := db.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, fmt.Errorf("ListPurchaseOrders begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	rows, err := tx.Query(ctx, `SELECT `+purchaseOrderColumns+purchaseOrderFrom+`
+		WHERE ($1 = '' OR po.status = $1) AND ($2 = 0 OR po.supplier_id = $2)
+		ORDER BY po.created_at DESC, po.id DESC`, status, supplierID)
+	if err != nil {
+		return nil, fmt.Errorf("ListPurchaseOrders: %w", err)
+	}
+	list := []models.PurchaseOrder{}
+	for rows.Next() {
+		var po models.PurchaseOrder
+		if err := scanPurchaseOrder(rows, &po); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("ListPurchaseOrders scan: %w", err)
+		}
+		list = append(list, po)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ListPurchaseOrders rows: %w", err)
+	}
+
+	for i := range list {
+		if err := purchaseOrderLinesTx(ctx, tx, &list[i]); err != nil {
+			return nil, fmt.Errorf("ListPurchaseOrders: %w", err)
+		}
+	}
+	return list, nil
+}
+
+// insertPurchaseOrderLineTx adds a line to a purchase order. The unit
+// defaults to the ingredient's stock unit and must convert to it.
+func insertPurchaseOrderLineTx(ctx context.Context, tx pgx.Tx, poID int64, l models.PurchaseOrderLine) error {
+	var stockUnit string
+	err := tx.QueryRow(ctx, `SELECT unit FROM ingredients WHERE id = $1 FOR SHARE`, l.IngredientID).Scan(&stockUnit)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%w: %d", ErrUnknownIngredient, l.IngredientID)
+	}
+	if err != nil {
+		return fmt.Errorf("ingredient %d: %w", l.IngredientID, err)
+	}
+
+	unit := stockUnit
+	if strings.TrimSpace(l.Unit) != "" {
+		if unit, err = units.Normalize(l.Unit); err != nil {
+			return err
+		}
+		if err := units.Compatible(unit, stockUnit); err != nil {
+			return fmt.Errorf("ingredient %d is stocked in %s: %w", l.IngredientID, stockUnit, err)
+		}
+	}
+
+	_, err = tx.Exec(ctx, `INSERT INTO purchase_order_lines (purchase_order_id, ingredient_id, quantity, unit, unit_cost, expected_date)
+		VALUES ($1,$2,$3,$4,$5,NULLIF($6, '')::date)`, poID, l.IngredientID, l.Quantity, unit, l.UnitCost, l.ExpectedDate)
+	if err != nil {
+		return fmt.Errorf("insert line for ingredient %d: %w", l.IngredientID, err)
+	}
+	return nil
+}
+
+// CreatePurchaseOrder creates a draft purchase order with its lines and
+// returns it as stored. It returns ErrUnknownSupplier, ErrUnknownIngredient,
+// units.ErrUnknownUnit or units.ErrIncompatible for invalid input.
+func (db *DB) CreatePurchaseOrder(ctx context.Context, po *models.PurchaseOrder) (*models.PurchaseOrder, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("CreatePurchaseOrder begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	created, err := createPurchaseOrderTx(ctx, tx, po)
+	if err != nil {
+		return nil, fmt.Errorf("CreatePurchaseOrder: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("CreatePurchaseOrder commit: %w", err)
+	}
+	return created, nil
+}
+
+// createPurchaseOrderTx inserts a draft purchase order with its lines and
+// returns it as stored.
+func createPurchaseOrderTx(ctx context.Context, tx pgx.Tx, po *models.PurchaseOrder) (*models.PurchaseOrder, error) {
+	var supplierExists bool
+	if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM suppliers WHERE id = $1)`, po.SupplierID).Scan(&supplierExists); err != nil {
+		return nil, fmt.Errorf("supplier: %w", err)
+	}
+	if !supplierExists {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownSupplier, po.SupplierID)
+	}
+
+	var id int64
+	err := tx.QueryRow(ctx, `INSERT INTO purchase_orders (supplier_id, status, reference, notes, created_by) VALUES ($1,$2,$3,$4,$5) RETURNING id`,
+		po.SupplierID, purchasing.StatusDraft, po.Reference, po.Notes, po.CreatedBy).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("insert: %w", err)
+	}
+	for _, l := range po.Lines {
+		if err := insertPurchaseOrderLineTx(ctx, tx, id, l); err != nil {
+			return nil, err
+		}
+	}
+	return purchaseOrderTx(ctx, tx, id, false)
+}
+
+// SetPurchaseOrderStatus submits, cancels or closes a purchase order and
+// returns it, or nil if it does not exist. A change its current status does
+// not allow fails with purchasing.ErrTransition.
+func (db *DB) SetPurchaseOrderStatus(ctx context.Context, id int64, next purchasing.Status) (*models.PurchaseOrder, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("SetPurchaseOrderStatus begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	po, err := purchaseOrderTx(ctx, tx, id, true)
+	if err != nil {
+		return nil, fmt.Errorf("SetPurchaseOrderStatus: %w", err)
+	}
+	if po == nil {
+		return nil, nil
+	}
+	if err := purchasing.Transition(purchasing.Status(po.Status), next); err != nil {
+		return nil, fmt.Errorf("SetPurchaseOrderStatus: %w: %s to %s", err, po.Status, next)
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE purchase_orders SET status = $1, updated_at = NOW(),
+		ordered_at = CASE WHEN $1 = 'ordered' THEN NOW() ELSE ordered_at END WHERE id = $2`, next, id)
+	if err != nil {
+		return nil, fmt.Errorf("SetPurchaseOrderStatus update: %w", err)
+	}
+	if po, err = purchaseOrderTx(ctx, tx, id, false); err != nil {
+		return nil, fmt.Errorf("SetPurchaseOrderStatus: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("SetPurchaseOrderStatus commit: %w", err)
+	}
+	return po, nil
+}
+
+// ReceivePurchaseOrder records goods arriving against a purchase order. Each
+// receipt line adds to its order line's received quantity and posts a
+// ReasonReceipt adjustment, converted into the ingredient's stock unit and
+// referenced "po-<id>/receipt-<receipt id>". Lines may be received in several
+// receipts and may end up short or over. The order's status follows the
+// lines; receipts against a draft, closed or cancelled order fail with
+// purchasing.ErrTransition. It returns nil if the order does not exist.
+func (db *DB) ReceivePurchaseOrder(ctx context.Context, id int64, r models.Receipt) (*models.Receipt, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ReceivePurchaseOrder begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	po, err := purchaseOrderTx(ctx, tx, id, true)
+	if err != nil {
+		return nil, fmt.Errorf("ReceivePurchaseOrder: %w", err)
+	}
+	if po == nil {
+		return nil, nil
+	}
+	if _, err := purchasing.AfterReceipt(purchasing.Status(po.Status), nil); err != nil {
+		return nil, fmt.Errorf("ReceivePurchaseOrder: %w: order is %s", err, po.Status)
+	}
+
+	err = tx.QueryRow(ctx, `INSERT INTO purchase_order_receipts (purchase_order_id, received_by, notes) VALUES ($1,$2,$3)
+		RETURNING id, received_at`, id, r.ReceivedBy, r.Notes).Scan(&r.ID, &r.ReceivedAt)
+	if err != nil {
+		return nil, fmt.Errorf("ReceivePurchaseOrder insert receipt: %w", err)
+	}
+	r.PurchaseOrderID = id
+	reference := fmt.Sprintf("po-%d/receipt-%d", id, r.ID)
+
+	lines := make(map[int64]*models.PurchaseOrderLine, len(po.Lines))
+	for i := range po.Lines {
+		lines[po.Lines[i].ID] = &po.Lines[i]
+	}
+	for i, rl := range r.Lines {
+		line := lines[rl.LineID]
+		if rl.LineID == 0 {
+			for j := range po.Lines {
+				if po.Lines[j].IngredientID == rl.IngredientID {
+					line = &po.Lines[j]
+				}
+			}
+		}
+		if line == nil {
+			return nil, fmt.Errorf("ReceivePurchaseOrder: %w: line %d, ingredient %d", ErrUnknownPurchaseOrderLine, rl.LineID, rl.IngredientID)
+		}
+		r.Lines[i].LineID, r.Lines[i].IngredientID = line.ID, line.IngredientID
+
+		var stockUnit string
+		if err := tx.QueryRow(ctx, `SELECT unit FROM ingredients WHERE id = $1`, line.IngredientID).Scan(&stockUnit); err != nil {
+			return nil, fmt.Errorf("ReceivePurchaseOrder ingredient %d: %w", line.IngredientID, err)
+		}
+		stockQuantity, err := units.Convert(rl.Quantity, line.Unit, stockUnit)
+		if err != nil {
+			return nil, fmt.Errorf("ReceivePurchaseOrder line %d: %w", line.ID, err)
+		}
+		if _, err := adjustIngredientStockTx(ctx, tx, line.IngredientID, stockQuantity, ReasonReceipt, reference, r.ReceivedBy); err != nil {
+			return nil, fmt.Errorf("ReceivePurchaseOrder: %w", err)
+		}
+		if _, err := tx.Exec(ctx, `UPDATE purchase_order_lines SET received_quantity = received_quantity + $1 WHERE id = $2`, rl.Quantity, line.ID); err != nil {
+			return nil, fmt.Errorf("ReceivePurchaseOrder update line %d: %w", line.ID, err)
+		}
+		if _, err := tx.Exec(ctx, `INSERT INTO purchase_order_receipt_lines (receipt_id, line_id, quantity) VALUES ($1,$2,$3)`, r.ID, line.ID, rl.Quantity); err != nil {
+			return nil, fmt.Errorf("ReceivePurchaseOrder insert receipt line %d: %w", line.ID, err)
+		}
+		line.ReceivedQuantity += rl.Quantity
+	}
+
+	quantities := make([]purchasing.Quantities, len(po.Lines))
+	for i, l := range po.Lines {
+		quantities[i] = purchasing.Quantities{Ordered: l.Quantity, Received: l.ReceivedQuantity}
+	}
+	status, err := purchasing.AfterReceipt(purchasing.Status(po.Status), quantities)
+	if err != nil {
+		return nil, fmt.Errorf("ReceivePurchaseOrder: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `UPDATE purchase_orders SET status = $1, updated_at = NOW() WHERE id = $2`, status, id); err != nil {
+		return nil, fmt.Errorf("ReceivePurchaseOrder update order: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("ReceivePurchaseOrder commit: %w", err)
+	}
+	return &r, nil
+}
+
+// GetReceipts returns the receipts posted against a purchase order, oldest
+// first.
+func (db *DB) GetReceipts(ctx context.Context, purchaseOrderID int64) ([]models.Receipt, error) {
+	rows, err := db.Query(ctx, `SELECT r.id, r.received_by, r.notes, r.received_at, rl.line_id, l.ingredient_id, rl.quantity
+		FROM purchase_order_receipts r
+		JOIN purchase_order_receipt_lines rl ON rl.receipt_id = r.id
+		JOIN purchase_order_lines l ON l.id = rl.line_id
+		WHERE r.purchase_order_id = $1 ORDER BY r.id, rl.line_id`, purchaseOrderID)
+	if err != nil {
+		return nil, fmt.Errorf("GetReceipts: %w", err)
+	}
+	defer rows.Close()
+
+	list := []models.Receipt{}
+	for rows.Next() {
+		var r models.Receipt
+		var rl models.ReceiptLine
+		if err := rows.Scan(&r.ID, &r.ReceivedBy, &r.Notes, &r.ReceivedAt, &rl.LineID, &rl.IngredientID, &rl.Quantity); err != nil {
+			return nil, fmt.Errorf("GetReceipts scan: %w", err)
+		}
+		if n := len(list); n == 0 || list[n-1].ID != r.ID {
+			r.PurchaseOrderID = purchaseOrderID
+			list = append(list, r)
+		}
+		last := &list[len(list)-1]
+		last.Lines = append(last.Lines, rl)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("GetReceipts rows: %w", err)
+	}
+	return list, nil
+}
@@ -2,10 +2,13 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 
 	"com.MixieMelts.inventory/internal/models"
+	"com.MixieMelts.inventory/internal/units"
+	"github.com/jackc/pgx/v5"
 )
 
 func (db *DB) seedRecipeItems(ctx context.Context) {
@@ -174,51 +177,195 @@ func (db *DB) seedRecipeItems(ctx context.Context) {
 	}
 }
 
-func (db *DB) createRecipeItemsTable(ctx context.Context) error {
-	// Ensure the canonical table exists with the new schema.
-	create := `CREATE TABLE IF NOT EXISTS recipe_items (
-		id SERIAL PRIMARY KEY,
-		product_id BIGINT NOT NULL,
-		ingredient_id BIGINT REFERENCES ingredients(id) ON DELETE RESTRICT,
-		unit VARCHAR(50),
-		amount DOUBLE PRECISION,
-		notes TEXT,
-		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-	);`
-	if _, err := db.Exec(ctx, create); err != nil {
-		return err
-	}
+// ErrUnknownIngredient is returned when a recipe item names an ingredient that
+// does not exist.
+var ErrUnknownIngredient = errors.New("unknown ingredient")
 
-	return nil
+const recipeItemColumns = `id, product_id, COALESCE(ingredient_id, 0),
+	COALESCE((SELECT name FROM ingredients WHERE id = recipe_items.ingredient_id), ''),
+	COALESCE((SELECT type FROM ingredients WHERE id = recipe_items.ingredient_id), ''),
+	COALESCE(unit, ''), COALESCE(amount, 0), COALESCE(notes, ''), created_at, updated_at`
+
+func scanRecipeItem(row pgx.Row, r *models.RecipeItem) error {
+	return row.Scan(&r.ID, &r.ProductID, &r.IngredientID, &r.IngredientName, &r.IngredientType,
+		&r.Unit, &r.Amount, &r.Notes, &r.CreatedAt, &r.UpdatedAt)
 }
 
 // GetRecipe returns recipe items for a product.
 func (db *DB) GetRecipe(ctx context.Context, productID int64) ([]models.RecipeItem, error) {
-	rows, err := db.Query(ctx, `SELECT id, product_id, ingredient_id, unit, amount, notes, created_at, updated_at FROM recipe_items WHERE product_id = $1`, productID)
+	rows, err := db.Query(ctx, `SELECT `+recipeItemColumns+` FROM recipe_items WHERE product_id = $1 ORDER BY id`, productID)
 	if err != nil {
 		return nil, fmt.Errorf("GetRecipe: %w", err)
 	}
 	defer rows.Close()
 
-	var items []models.RecipeItem
+	items := []models.RecipeItem{}
 	for rows.Next() {
 		var r models.RecipeItem
-		if err := rows.Scan(&r.ID, &r.ProductID, &r.IngredientID, &r.Unit, &r.Amount, &r.Notes, &r.CreatedAt, &r.UpdatedAt); err != nil {
+		if err := scanRecipeItem(rows, &r); err != nil {
 			return nil, fmt.Errorf("GetRecipe scan: %w", err)
 		}
 		items = append(items, r)
 	}
-	return items, nil
+	return items, rows.Err()
+}
+
+// GetRecipes returns the recipes of productIDs keyed by product id. Every
+// requested product has an entry, empty if it has no recipe. With no ids,
+// every product that has a recipe is returned.
+func (db *DB) GetRecipes(ctx context.Context, productIDs []int64) (map[int64][]models.RecipeItem, error) {
+	rows, err := db.Query(ctx, `SELECT `+recipeItemColumns+` FROM recipe_items
+		WHERE cardinality($1::bigint[]) = 0 OR product_id = ANY($1) ORDER BY product_id, id`, productIDs)
+	if err != nil {
+		return nil, fmt.Errorf("GetRecipes: %w", err)
+	}
+	defer rows.Close()
+
+	recipes := make(map[int64][]models.RecipeItem, len(productIDs))
+	for _, id := range productIDs {
+		recipes[id] = []models.RecipeItem{}
+	}
+	for rows.Next() {
+		var r models.RecipeItem
+		if err := scanRecipeItem(rows, &r); err != nil {
+			return nil, fmt.Errorf("GetRecipes scan: %w", err)
+		}
+		recipes[r.ProductID] = append(recipes[r.ProductID], r)
+	}
+	return recipes, rows.Err()
+}
+
+// validateRecipeItemTx normalizes a recipe item's unit, fills in its
+// ingredient's name and type, and checks that the ingredient exists and is stocked in a unit the recipe unit converts to. It
+// returns ErrUnknownIngredient, units.ErrUnknownUnit or units.ErrIncompatible.
+func validateRecipeItemTx(ctx context.Context, tx pgx.Tx, ri *models.RecipeItem) error {
+	unit, err := units.Normalize(ri.Unit)
+	if err != nil {
+		return err
+	}
+	ri.Unit = unit
+
+	var stockUnit string
+	err = tx.QueryRow(ctx, `SELECT unit, name, type FROM ingredients WHERE id = $1 FOR SHARE`, ri.IngredientID).
+		Scan(&stockUnit, &ri.IngredientName, &ri.IngredientType)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%w: %d", ErrUnknownIngredient, ri.IngredientID)
+	}
+	if err != nil {
+		return fmt.Errorf("lookup ingredient %d: %w", ri.IngredientID, err)
+	}
+	if err := units.Compatible(ri.Unit, stockUnit); err != nil {
+		return fmt.Errorf("ingredient %d is stocked in %s: %w", ri.IngredientID, stockUnit, err)
+	}
+	return nil
+}
+
+// insertRecipeItemTx validates and inserts a recipe item, filling in its id
+// and timestamps.
+func insertRecipeItemTx(ctx context.Context, tx pgx.Tx, ri *models.RecipeItem) error {
+	if err := validateRecipeItemTx(ctx, tx, ri); err != nil {
+		return err
+	}
+	return tx.QueryRow(ctx, `INSERT INTO recipe_items (product_id, ingredient_id, unit, amount, notes) VALUES ($1,$2,$3,$4,$5)
+		RETURNING id, created_at, updated_at`,
+		ri.ProductID, ri.IngredientID, ri.Unit, ri.Amount, ri.Notes).Scan(&ri.ID, &ri.CreatedAt, &ri.UpdatedAt)
 }
 
-// CreateRecipeItem adds an ingredient entry for a product.
+// CreateRecipeItem adds an ingredient entry for a product. The ingredient must
+// exist and the unit, stored in canonical form, must convert to the
+// ingredient's stock unit; otherwise ErrUnknownIngredient,
+// units.ErrUnknownUnit or units.ErrIncompatible is returned.
 func (db *DB) CreateRecipeItem(ctx context.Context, ri *models.RecipeItem) (int64, error) {
-	var id int64
-	err := db.QueryRow(ctx, `INSERT INTO recipe_items (product_id, ingredient_id, unit, amount, notes) VALUES ($1,$2,$3,$4,$5) RETURNING id`,
-		ri.ProductID, ri.IngredientID, ri.Unit, ri.Amount, ri.Notes).Scan(&id)
+	tx, err := db.Begin(ctx)
 	if err != nil {
+		return 0, fmt.Errorf("CreateRecipeItem begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := insertRecipeItemTx(ctx, tx, ri); err != nil {
 		return 0, fmt.Errorf("CreateRecipeItem: %w", err)
 	}
-	return id, nil
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("CreateRecipeItem commit: %w", err)
+	}
+	return ri.ID, nil
+}
+
+// UpdateRecipeItem replaces the ingredient, unit, amount and notes of one of
+// a product's recipe items, validated as in CreateRecipeItem. It returns nil
+// if the product has no such item.
+func (db *DB) UpdateRecipeItem(ctx context.Context, ri *models.RecipeItem) (*models.RecipeItem, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("UpdateRecipeItem begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := validateRecipeItemTx(ctx, tx, ri); err != nil {
+		return nil, fmt.Errorf("UpdateRecipeItem: %w", err)
+	}
+
+	var out models.RecipeItem
+	err = scanRecipeItem(tx.QueryRow(ctx, `UPDATE recipe_items SET ingredient_id=$1, unit=$2, amount=$3, notes=$4, updated_at=NOW()
+		WHERE id=$5 AND product_id=$6 RETURNING `+recipeItemColumns,
+		ri.IngredientID, ri.Unit, ri.Amount, ri.Notes, ri.ID, ri.ProductID), &out)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("UpdateRecipeItem: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("UpdateRecipeItem commit: %w", err)
+	}
+	return &out, nil
+}
+
+// DeleteRecipeItem removes one of a product's recipe items and reports
+// whether it existed.
+func (db *DB) DeleteRecipeItem(ctx context.Context, productID, id int64) (bool, error) {
+	tag, err := db.Exec(ctx, `DELETE FROM recipe_items WHERE id = $1 AND product_id = $2`, id, productID)
+	if err != nil {
+		return false, fmt.Errorf("DeleteRecipeItem: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// DeleteRecipe removes every recipe item of a product.
+func (db *DB) DeleteRecipe(ctx context.Context, productID int64) error {
+	if _, err := db.Exec(ctx, `DELETE FROM recipe_items WHERE product_id = $1`, productID); err != nil {
+		return fmt.Errorf("DeleteRecipe: %w", err)
+	}
+	return nil
+}
+
+// ReplaceRecipe swaps a product's whole recipe for items in one transaction.
+// Every item is validated as in CreateRecipeItem; if any fails, the existing
+// recipe is left untouched.
+func (db *DB) ReplaceRecipe(ctx context.Context, productID int64, items []models.RecipeItem) ([]models.RecipeItem, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ReplaceRecipe begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if _, err := tx.Exec(ctx, `DELETE FROM recipe_items WHERE product_id = $1`, productID); err != nil {
+		return nil, fmt.Errorf("ReplaceRecipe clear: %w", err)
+	}
+
+	out := make([]models.RecipeItem, len(items))
+	for i, it := range items {
+		it.ID = 0
+		it.ProductID = productID
+		if err := insertRecipeItemTx(ctx, tx, &it); err != nil {
+			return nil, fmt.Errorf("ReplaceRecipe item %d: %w", i, err)
+		}
+		out[i] = it
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("ReplaceRecipe commit: %w", err)
+	}
+	return out, nil
 }
@@ -0,0 +1,202 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"com.MixieMelts.inventory/internal/models"
+	"com.MixieMelts.inventory/internal/reorder"
+)
+
+// Defaults for models.ReorderOptions.
+const (
+	DefaultReorderWindowDays = 30
+	DefaultReorderCoverDays  = 30
+	DefaultLeadTimeDays      = 7
+)
+
+var (
+	// ErrNothingToReorder is returned when an accepted ingredient has no
+	// suggested quantity and none was given.
+	ErrNothingToReorder = errors.New("no reorder quantity")
+	// ErrNoSupplier is returned when an accepted ingredient has never been
+	// ordered and no supplier was given.
+	ErrNoSupplier = errors.New("no supplier")
+)
+
+// reorderQuery gathers, per ingredient, its net usage over the last $1 days,
+// what is outstanding on submitted purchase orders and the supplier and cost
+// of its latest purchase order line, all in its stock unit. Usage is every
+// stock decrease less reversals of earlier consumption; receipts and other
+// increases do not offset it.
+const reorderQuery = `WITH usage AS (
+		SELECT ingredient_id,
+			GREATEST(SUM(CASE WHEN change < 0 THEN -change WHEN reason = 'reversal' THEN -change ELSE 0 END), 0) AS consumed
+		FROM inventory_adjustments
+		WHERE created_at >= NOW() - make_interval(days => $1)
+		GROUP BY ingredient_id
+	), lines AS (
+		SELECT l.ingredient_id, po.supplier_id, po.status, po.created_at, po.id AS po_id,
+			lu.to_base / su.to_base AS factor, l.quantity, l.received_quantity, l.unit_cost
+		FROM purchase_order_lines l
+		JOIN purchase_orders po ON po.id = l.purchase_order_id
+		JOIN ingredients i ON i.id = l.ingredient_id
+		JOIN units lu ON lu.code = lower(trim(l.unit))
+		JOIN units su ON su.code = lower(trim(i.unit))
+		WHERE po.status <> 'cancelled'
+	), on_order AS (
+		SELECT ingredient_id, SUM(GREATEST(quantity - received_quantity, 0) * factor) AS quantity
+		FROM lines WHERE status IN ('ordered', 'partially_received')
+		GROUP BY ingredient_id
+	), latest AS (
+		SELECT DISTINCT ON (ingredient_id) ingredient_id, supplier_id, unit_cost / factor AS unit_cost
+		FROM lines ORDER BY ingredient_id, created_at DESC, po_id DESC
+	)
+	SELECT i.id, i.name, i.unit, i.stock, COALESCE(i.min_threshold, 0), COALESCE(u.consumed, 0), COALESCE(o.quantity, 0),
+		s.id, COALESCE(s.name, ''), s.lead_time_days, COALESCE(la.unit_cost, 0)
+	FROM ingredients i
+	LEFT JOIN usage u ON u.ingredient_id = i.id
+	LEFT JOIN on_order o ON o.ingredient_id = i.id
+	LEFT JOIN latest la ON la.ingredient_id = i.id
+	LEFT JOIN suppliers s ON s.id = la.supplier_id
+	ORDER BY i.id`
+
+// ReorderSuggestions proposes an order for every ingredient whose stock and
+// incoming orders would fall below its threshold before a delivery could
+// arrive, or for every ingredient when opts.All is set.
+func (db *DB) ReorderSuggestions(ctx context.Context, opts models.ReorderOptions) ([]models.ReorderSuggestion, error) {
+	opts = reorderDefaults(opts)
+	rows, err := db.Query(ctx, reorderQuery, opts.WindowDays)
+	if err != nil {
+		return nil, fmt.Errorf("ReorderSuggestions: %w", err)
+	}
+	defer rows.Close()
+
+	now := time.Now().UTC()
+	list := []models.ReorderSuggestion{}
+	for rows.Next() {
+		var (
+			s        models.ReorderSuggestion
+			leadTime *int
+		)
+		if err := rows.Scan(&s.IngredientID, &s.IngredientName, &s.Unit, &s.Stock, &s.MinThreshold, &s.Consumed, &s.OnOrder,
+			&s.SupplierID, &s.SupplierName, &leadTime, &s.UnitCost); err != nil {
+			return nil, fmt.Errorf("ReorderSuggestions scan: %w", err)
+		}
+		s.WindowDays, s.LeadTimeDays = opts.WindowDays, opts.DefaultLeadTimeDays
+		if leadTime != nil {
+			s.LeadTimeDays = *leadTime
+		}
+
+		r := reorder.Suggest(reorder.Input{
+			Stock:        s.Stock,
+			OnOrder:      s.OnOrder,
+			Threshold:    s.MinThreshold,
+			Consumed:     s.Consumed,
+			WindowDays:   opts.WindowDays,
+			LeadTimeDays: s.LeadTimeDays,
+			CoverDays:    opts.CoverDays,
+		}, now)
+		s.DailyUsage, s.DaysLeft, s.StockOutDate = r.DailyUsage, r.DaysLeft, r.StockOutDate
+		s.ReorderPoint, s.SuggestedQuantity, s.Reorder = r.ReorderPoint, r.Quantity, r.Reorder()
+		if s.Reorder || opts.All {
+			list = append(list, s)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ReorderSuggestions rows: %w", err)
+	}
+	return list, nil
+}
+
+func reorderDefaults(opts models.ReorderOptions) models.ReorderOptions {
+	if opts.WindowDays <= 0 {
+		opts.WindowDays = DefaultReorderWindowDays
+	}
+	if opts.CoverDays <= 0 {
+		opts.CoverDays = DefaultReorderCoverDays
+	}
+	if opts.DefaultLeadTimeDays <= 0 {
+		opts.DefaultLeadTimeDays = DefaultLeadTimeDays
+	}
+	return opts
+}
+
+// AcceptReorderSuggestions drafts purchase orders for the accepted items,
+// one per supplier in the order suppliers first appear, all in one
+// transaction. Each line is expected after its supplier's lead time. It
+// returns ErrUnknownIngredient, ErrNothingToReorder or ErrNoSupplier for an
+// item that cannot be ordered, and the errors of CreatePurchaseOrder.
+func (db *DB) AcceptReorderSuggestions(ctx context.Context, req models.ReorderAcceptRequest) ([]models.PurchaseOrder, error) {
+	opts := req.ReorderOptions
+	opts.All = true
+	suggestions, err := db.ReorderSuggestions(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("AcceptReorderSuggestions: %w", err)
+	}
+	byIngredient := make(map[int64]models.ReorderSuggestion, len(suggestions))
+	for _, s := range suggestions {
+		byIngredient[s.IngredientID] = s
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("AcceptReorderSuggestions begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	today := time.Now().UTC()
+	var orders []*models.PurchaseOrder
+	bySupplier := map[int64]*models.PurchaseOrder{}
+	for _, item := range req.Items {
+		s, ok := byIngredient[item.IngredientID]
+		if !ok {
+			return nil, fmt.Errorf("AcceptReorderSuggestions: %w: %d", ErrUnknownIngredient, item.IngredientID)
+		}
+		quantity := item.Quantity
+		if quantity <= 0 {
+			quantity = s.SuggestedQuantity
+		}
+		if quantity <= 0 {
+			return nil, fmt.Errorf("AcceptReorderSuggestions: %w for ingredient %d", ErrNothingToReorder, item.IngredientID)
+		}
+
+		// The suggested supplier's lead time and cost only apply to it.
+		supplierID := item.SupplierID
+		lastSupplier := s.SupplierID != nil && (supplierID == 0 || supplierID == *s.SupplierID)
+		if lastSupplier {
+			supplierID = *s.SupplierID
+		}
+		if supplierID == 0 {
+			return nil, fmt.Errorf("AcceptReorderSuggestions: %w for ingredient %d", ErrNoSupplier, item.IngredientID)
+		}
+
+		po, ok := bySupplier[supplierID]
+		if !ok {
+			po = &models.PurchaseOrder{SupplierID: supplierID, Notes: "Drafted from reorder suggestions", CreatedBy: req.CreatedBy}
+			bySupplier[supplierID] = po
+			orders = append(orders, po)
+		}
+		line := models.PurchaseOrderLine{IngredientID: s.IngredientID, Quantity: quantity, Unit: s.Unit}
+		if lastSupplier {
+			line.UnitCost = s.UnitCost
+			line.ExpectedDate = today.AddDate(0, 0, s.LeadTimeDays).Format("2006-01-02")
+		}
+		po.Lines = append(po.Lines, line)
+	}
+
+	created := make([]models.PurchaseOrder, 0, len(orders))
+	for _, po := range orders {
+		c, err := createPurchaseOrderTx(ctx, tx, po)
+		if err != nil {
+			return nil, fmt.Errorf("AcceptReorderSuggestions: %w", err)
+		}
+		created = append(created, *c)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("AcceptReorderSuggestions commit: %w", err)
+	}
+	return created, nil
+}
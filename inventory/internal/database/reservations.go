@@ -0,0 +1,563 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"com.MixieMelts.inventory/internal/models"
+	"com.MixieMelts.inventory/internal/reservations"
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrReservationNotActive is returned when confirming a reservation that was
+// released or has expired, or releasing one that was filled.
+var ErrReservationNotActive = errors.New("reservation is not active")
+
+// activeCondition selects the reservations r that hold ingredients: active
+// ones until they expire. Confirming a reservation consumes its ingredients.
+const activeCondition = `(r.status = 'active' AND r.expires_at > NOW())`
+
+// holdingCondition selects the reservations r that still hold stock, which
+// confirmed ones do in finished goods; it matches reservations.Holding.
+const holdingCondition = `(r.status = 'confirmed' OR ` + activeCondition + `)`
+
+// filledQuery sums what fills delivered per reservation and product.
+const filledQuery = `SELECT reservation_id, product_id, SUM(quantity) AS filled
+	FROM reservation_fills GROUP BY reservation_id, product_id`
+
+// outstandingLine is what reservation line rl still needs. Finished goods
+// cover a product's first fills, so its lines shrink only once fills pass
+// what was promised from stock, in proportion to what is left to make.
+// Lines without a product count in full.
+const outstandingLine = `rl.quantity * LEAST(GREATEST(COALESCE((rp.quantity - COALESCE(f.filled, 0)) / NULLIF(rp.quantity - rp.from_stock, 0), 1), 0), 1)`
+
+// outstandingFrom joins reservation lines rl to their reservation r, product
+// rp and fills f for outstandingLine.
+const outstandingFrom = `reservation_lines rl JOIN reservations r ON r.id = rl.reservation_id
+	LEFT JOIN reservation_products rp ON rp.reservation_id = rl.reservation_id AND rp.product_id = rl.product_id
+	LEFT JOIN (` + filledQuery + `) f ON f.reservation_id = rl.reservation_id AND f.product_id = rl.product_id`
+
+// heldQuery sums what active reservations hold per ingredient.
+const heldQuery = `SELECT rl.ingredient_id, SUM(` + outstandingLine + `) AS held
+	FROM ` + outstandingFrom + `
+	WHERE ` + activeCondition + `
+	GROUP BY rl.ingredient_id`
+
+// reservationTx loads a reservation by reference with its products and
+// lines, or returns nil if there is none. lock takes a row lock on it.
+func reservationTx(ctx context.Context, tx pgx.Tx, reference string, lock bool) (*models.Reservation, error) {
+	query := `SELECT id, reference, status, created_by, expires_at, created_at, updated_at FROM reservations WHERE reference = $1`
+	if lock {
+		query += " FOR UPDATE"
+	}
+	var res models.Reservation
+	err := tx.QueryRow(ctx, query, reference).Scan(&res.ID, &res.Reference, &res.Status, &res.CreatedBy, &res.ExpiresAt, &res.CreatedAt, &res.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reservation %q: %w", reference, err)
+	}
+	// A lapsed hold no longer holds anything, whether or not the expiry job
+	// has marked it yet.
+	if res.Status == string(reservations.StatusActive) && !reservations.Holding(reservations.StatusActive, res.ExpiresAt, time.Now()) {
+		res.Status = string(reservations.StatusExpired)
+	}
+
+	rows, err := tx.Query(ctx, `SELECT rp.product_id, rp.quantity, rp.from_stock, COALESCE(f.filled, 0)
+		FROM reservation_products rp
+		LEFT JOIN (`+filledQuery+`) f ON f.reservation_id = rp.reservation_id AND f.product_id = rp.product_id
+		WHERE rp.reservation_id = $1 ORDER BY rp.product_id`, res.ID)
+	if err != nil {
+		return nil, fmt.Errorf("reservation products: %w", err)
+	}
+	res.Products, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.ReservedProduct, error) {
+		var p models.ReservedProduct
+		return p, row.Scan(&p.ProductID, &p.Quantity, &p.FromStock, &p.Filled)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reservation products: %w", err)
+	}
+
+	rows, err = tx.Query(ctx, `SELECT rl.ingredient_id, i.name, i.unit, SUM(rl.quantity)
+		FROM reservation_lines rl JOIN ingredients i ON i.id = rl.ingredient_id
+		WHERE rl.reservation_id = $1 GROUP BY rl.ingredient_id, i.name, i.unit ORDER BY rl.ingredient_id`, res.ID)
+	if err != nil {
+		return nil, fmt.Errorf("reservation lines: %w", err)
+	}
+	res.Lines, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.IngredientRequirement, error) {
+		var l models.IngredientRequirement
+		return l, row.Scan(&l.IngredientID, &l.IngredientName, &l.Unit, &l.Amount)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reservation lines: %w", err)
+	}
+	return &res, nil
+}
+
+// GetReservation returns a reservation by reference, or nil if there is none.
+func (db *DB) GetReservation(ctx context.Context, reference string) (*models.Reservation, error) {
+	tx, err := db.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, fmt.Errorf("GetReservation begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	res, err := reservationTx(ctx, tx, reference, false)
+	if err != nil {
+		return nil, fmt.Errorf("GetReservation: %w", err)
+	}
+	return res, nil
+}
+
+// Reserve holds the stock needed for req.Lines for ttl. Each product is
+// promised from finished goods that no other reservation holds, and the
+// ingredients to make the rest are held against their available-to-promise.
+// The ingredient rows the products' recipes use are locked first, then the
+// finished-goods rows, before any holds are summed, so concurrent
+// reservations for the same stock queue up and none can promise what
+// another already holds. It returns ErrInsufficientStock if an ingredient's
+// available-to-promise does not cover the hold, or ErrMissingRecipe. A
+// reference that already exists returns its reservation unchanged, with
+// created false.
+func (db *DB) Reserve(ctx context.Context, req models.ReservationRequest, ttl time.Duration) (res *models.Reservation, created bool, err error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("Reserve begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if existing, err := reservationTx(ctx, tx, req.Reference, false); err != nil || existing != nil {
+		if err != nil {
+			return nil, false, fmt.Errorf("Reserve: %w", err)
+		}
+		return existing, false, nil
+	}
+
+	productIDs := make([]int64, len(req.Lines))
+	for i, l := range req.Lines {
+		productIDs[i] = l.ProductID
+	}
+	if _, err := tx.Exec(ctx, `SELECT id FROM ingredients
+		WHERE id IN (SELECT ingredient_id FROM recipe_items WHERE product_id = ANY($1))
+		ORDER BY id FOR UPDATE`, productIDs); err != nil {
+		return nil, false, fmt.Errorf("Reserve lock ingredients: %w", err)
+	}
+	onHand := map[int64]float64{}
+	rows, err := tx.Query(ctx, `SELECT product_id, quantity FROM finished_goods WHERE product_id = ANY($1) ORDER BY product_id FOR UPDATE`, productIDs)
+	if err != nil {
+		return nil, false, fmt.Errorf("Reserve lock finished goods: %w", err)
+	}
+	for rows.Next() {
+		var productID int64
+		var qty int
+		if err := rows.Scan(&productID, &qty); err != nil {
+			rows.Close()
+			return nil, false, fmt.Errorf("Reserve finished goods scan: %w", err)
+		}
+		onHand[productID] = float64(qty)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, false, fmt.Errorf("Reserve finished goods: %w", err)
+	}
+	fgHeld, err := finishedGoodsHeldTx(ctx, tx, productIDs, "")
+	if err != nil {
+		return nil, false, fmt.Errorf("Reserve: %w", err)
+	}
+
+	// Expand what has to be made product by product, so each line knows the
+	// product it is for.
+	fromStock := map[int64]float64{}
+	productLines := map[int64][]models.IngredientRequirement{}
+	needed := map[int64]float64{}
+	for _, l := range req.Lines {
+		stock, toMake := reservations.Split(l.Quantity, onHand[l.ProductID]-fgHeld[l.ProductID])
+		fromStock[l.ProductID] = stock
+		if toMake <= 0 {
+			continue
+		}
+		reqs, err := recipeRequirementsTx(ctx, tx, []models.ProductQuantity{{ProductID: l.ProductID, Quantity: toMake}})
+		if err != nil {
+			return nil, false, fmt.Errorf("Reserve: %w", err)
+		}
+		productLines[l.ProductID] = reqs
+		for _, r := range reqs {
+			needed[r.IngredientID] += r.Amount
+		}
+	}
+	ids := make([]int64, 0, len(needed))
+	for id := range needed {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	available, err := availableToPromiseTx(ctx, tx, ids)
+	if err != nil {
+		return nil, false, fmt.Errorf("Reserve: %w", err)
+	}
+	for _, id := range ids {
+		if a := available[id]; a.Available < needed[id] {
+			return nil, false, fmt.Errorf("Reserve: %w: ingredient %d needs %.2f, %.2f available", ErrInsufficientStock, id, needed[id], a.Available)
+		}
+	}
+
+	var id int64
+	err = tx.QueryRow(ctx, `INSERT INTO reservations (reference, created_by, expires_at) VALUES ($1, $2, $3)
+		ON CONFLICT (reference) DO NOTHING RETURNING id`, req.Reference, req.CreatedBy, time.Now().Add(ttl)).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// A concurrent request with the same reference committed first
+		existing, err := reservationTx(ctx, tx, req.Reference, false)
+		if err != nil {
+			return nil, false, fmt.Errorf("Reserve: %w", err)
+		}
+		return existing, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("Reserve insert: %w", err)
+	}
+	for _, l := range req.Lines {
+		if _, err := tx.Exec(ctx, `INSERT INTO reservation_products (reservation_id, product_id, quantity, from_stock) VALUES ($1,$2,$3,$4)`,
+			id, l.ProductID, l.Quantity, fromStock[l.ProductID]); err != nil {
+			return nil, false, fmt.Errorf("Reserve insert product %d: %w", l.ProductID, err)
+		}
+		for _, r := range productLines[l.ProductID] {
+			if r.Amount <= 0 {
+				continue
+			}
+			if _, err := tx.Exec(ctx, `INSERT INTO reservation_lines (reservation_id, product_id, ingredient_id, quantity) VALUES ($1,$2,$3,$4)`,
+				id, l.ProductID, r.IngredientID, r.Amount); err != nil {
+				return nil, false, fmt.Errorf("Reserve insert line %d: %w", r.IngredientID, err)
+			}
+		}
+	}
+
+	res, err = reservationTx(ctx, tx, req.Reference, false)
+	if err != nil {
+		return nil, false, fmt.Errorf("Reserve: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, false, fmt.Errorf("Reserve commit: %w", err)
+	}
+	return res, true, nil
+}
+
+// ConfirmReservation hands an active reservation to its placed order. The
+// ingredients it holds are consumed, one ReasonOrder ledger entry per
+// ingredient under the reservation's reference; the hold already kept them
+// from being promised elsewhere, so stock is not checked again. The finished
+// goods promised from stock stay held, past the expiry, until the kitchen
+// fills the order. Confirming twice, or once filled, returns the
+// reservation. It returns nil if there is no such reservation and
+// ErrReservationNotActive if it was released or has expired.
+func (db *DB) ConfirmReservation(ctx context.Context, reference string) (*models.Reservation, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ConfirmReservation begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	res, err := reservationTx(ctx, tx, reference, true)
+	if err != nil || res == nil {
+		if err != nil {
+			return nil, fmt.Errorf("ConfirmReservation: %w", err)
+		}
+		return nil, nil
+	}
+	switch reservations.Status(res.Status) {
+	case reservations.StatusConfirmed, reservations.StatusFilled:
+		return res, nil
+	case reservations.StatusActive:
+		lines, err := outstandingLinesTx(ctx, tx, res.ID)
+		if err != nil {
+			return nil, fmt.Errorf("ConfirmReservation: %w", err)
+		}
+		if err := postLinesTx(ctx, tx, lines, -1, ReasonOrder, res.Reference, res.CreatedBy); err != nil {
+			return nil, fmt.Errorf("ConfirmReservation: %w", err)
+		}
+		return db.setReservationStatus(ctx, tx, "ConfirmReservation", res, reservations.StatusConfirmed)
+	default:
+		return nil, fmt.Errorf("ConfirmReservation: %w: %s", ErrReservationNotActive, res.Status)
+	}
+}
+
+// ReleaseReservation ends an active or confirmed hold, e.g. when a cart is
+// abandoned or an order is not placed after all. The ingredients a
+// confirmed reservation consumed for units not yet delivered are put back
+// with ReasonReversal entries under its reference. Releasing a reservation
+// that was already released or has expired returns it unchanged. It
+// returns nil if there is no such reservation and ErrReservationNotActive
+// if it was filled.
+func (db *DB) ReleaseReservation(ctx context.Context, reference string) (*models.Reservation, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ReleaseReservation begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	res, err := reservationTx(ctx, tx, reference, true)
+	if err != nil || res == nil {
+		if err != nil {
+			return nil, fmt.Errorf("ReleaseReservation: %w", err)
+		}
+		return nil, nil
+	}
+	switch reservations.Status(res.Status) {
+	case reservations.StatusFilled:
+		return nil, fmt.Errorf("ReleaseReservation: %w: %s", ErrReservationNotActive, res.Status)
+	case reservations.StatusConfirmed:
+		lines, err := outstandingLinesTx(ctx, tx, res.ID)
+		if err != nil {
+			return nil, fmt.Errorf("ReleaseReservation: %w", err)
+		}
+		if err := postLinesTx(ctx, tx, lines, 1, ReasonReversal, res.Reference, res.CreatedBy); err != nil {
+			return nil, fmt.Errorf("ReleaseReservation: %w", err)
+		}
+		return db.setReservationStatus(ctx, tx, "ReleaseReservation", res, reservations.StatusReleased)
+	case reservations.StatusActive:
+		return db.setReservationStatus(ctx, tx, "ReleaseReservation", res, reservations.StatusReleased)
+	default:
+		return res, nil
+	}
+}
+
+// fillReservationTx records that fill delivered quantity units of a product
+// to the order holding reference, inside an existing transaction, and marks
+// the reservation filled once every product is covered. It returns how many
+// of the units cover ones the order's confirmation consumed ingredients for,
+// which the caller must not consume again. A reference with no holding
+// reservation, a product it does not hold and a repeated fill are ignored.
+func fillReservationTx(ctx context.Context, tx pgx.Tx, reference string, productID int64, fill string, quantity float64) (made float64, err error) {
+	var id int64
+	var status string
+	err = tx.QueryRow(ctx, `SELECT id, status FROM reservations WHERE reference = $1 FOR UPDATE`, reference).Scan(&id, &status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("fill reservation %q: %w", reference, err)
+	}
+	if s := reservations.Status(status); s != reservations.StatusActive && s != reservations.StatusConfirmed {
+		return 0, nil
+	}
+
+	var held, fromStock, filled float64
+	err = tx.QueryRow(ctx, `SELECT rp.quantity, rp.from_stock, COALESCE(f.filled, 0)
+		FROM reservation_products rp
+		LEFT JOIN (`+filledQuery+`) f ON f.reservation_id = rp.reservation_id AND f.product_id = rp.product_id
+		WHERE rp.reservation_id = $1 AND rp.product_id = $2`, id, productID).Scan(&held, &fromStock, &filled)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("fill reservation %q product %d: %w", reference, productID, err)
+	}
+
+	tag, err := tx.Exec(ctx, `INSERT INTO reservation_fills (reservation_id, product_id, reference, quantity)
+		VALUES ($1, $2, $3, $4) ON CONFLICT DO NOTHING`, id, productID, fill, quantity)
+	if err != nil {
+		return 0, fmt.Errorf("fill reservation %q product %d: %w", reference, productID, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return 0, nil
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE reservations r SET status = 'filled', updated_at = NOW()
+		WHERE r.id = $1 AND NOT EXISTS (
+			SELECT 1 FROM reservation_products rp
+			LEFT JOIN (`+filledQuery+`) f ON f.reservation_id = rp.reservation_id AND f.product_id = rp.product_id
+			WHERE rp.reservation_id = r.id AND COALESCE(f.filled, 0) < rp.quantity)`, id)
+	if err != nil {
+		return 0, fmt.Errorf("fill reservation %q status: %w", reference, err)
+	}
+	if reservations.Status(status) != reservations.StatusConfirmed {
+		return 0, nil
+	}
+	return reservations.Made(held, fromStock, filled, quantity), nil
+}
+
+// madeLinesTx returns the ingredients the confirmation of the reservation
+// under reference consumed for made units of a product, in ingredient id
+// order.
+func madeLinesTx(ctx context.Context, tx pgx.Tx, reference string, productID int64, made float64) ([]models.IngredientRequirement, error) {
+	rows, err := tx.Query(ctx, `SELECT rl.ingredient_id, rl.quantity * $3 / NULLIF(rp.quantity - rp.from_stock, 0)
+		FROM reservation_lines rl JOIN reservations r ON r.id = rl.reservation_id
+		JOIN reservation_products rp ON rp.reservation_id = rl.reservation_id AND rp.product_id = rl.product_id
+		WHERE r.reference = $1 AND rl.product_id = $2 AND rp.quantity > rp.from_stock
+		ORDER BY rl.ingredient_id`, reference, productID, made)
+	if err != nil {
+		return nil, fmt.Errorf("made lines for %q: %w", reference, err)
+	}
+	lines, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.IngredientRequirement, error) {
+		var l models.IngredientRequirement
+		return l, row.Scan(&l.IngredientID, &l.Amount)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("made lines for %q: %w", reference, err)
+	}
+	return lines, nil
+}
+
+// outstandingLinesTx returns what the reservation with the given id still
+// needs of each ingredient for units not yet delivered, in ingredient id
+// order.
+func outstandingLinesTx(ctx context.Context, tx pgx.Tx, id int64) ([]models.IngredientRequirement, error) {
+	rows, err := tx.Query(ctx, `SELECT rl.ingredient_id, SUM(`+outstandingLine+`)
+		FROM `+outstandingFrom+`
+		WHERE r.id = $1
+		GROUP BY rl.ingredient_id ORDER BY rl.ingredient_id`, id)
+	if err != nil {
+		return nil, fmt.Errorf("outstanding lines: %w", err)
+	}
+	lines, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.IngredientRequirement, error) {
+		var l models.IngredientRequirement
+		return l, row.Scan(&l.IngredientID, &l.Amount)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("outstanding lines: %w", err)
+	}
+	return lines, nil
+}
+
+// postLinesTx posts one ledger entry per line, changing its ingredient's
+// stock by sign times the line's amount, inside an existing transaction.
+// Lines must be in ingredient id order so concurrent posts lock the
+// ingredient rows in the same order.
+func postLinesTx(ctx context.Context, tx pgx.Tx, lines []models.IngredientRequirement, sign float64, reason, reference, createdBy string) error {
+	for _, l := range lines {
+		if l.Amount <= 0 {
+			continue
+		}
+		if _, err := adjustIngredientStockTx(ctx, tx, l.IngredientID, sign*l.Amount, reason, reference, createdBy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lockReservationTx locks the reservation under reference, if any, and then
+// the ingredient rows of its lines, inside an existing transaction. Stock
+// rows are always locked after the reservations that may post to them:
+// reservations, then ingredients, then finished goods.
+func lockReservationTx(ctx context.Context, tx pgx.Tx, reference string) error {
+	if _, err := tx.Exec(ctx, `SELECT id FROM reservations WHERE reference = $1 FOR UPDATE`, reference); err != nil {
+		return fmt.Errorf("lock reservation %q: %w", reference, err)
+	}
+	_, err := tx.Exec(ctx, `SELECT id FROM ingredients WHERE id IN (
+			SELECT rl.ingredient_id FROM reservation_lines rl JOIN reservations r ON r.id = rl.reservation_id WHERE r.reference = $1)
+		ORDER BY id FOR UPDATE`, reference)
+	if err != nil {
+		return fmt.Errorf("lock reservation %q ingredients: %w", reference, err)
+	}
+	return nil
+}
+
+// unfillReservationsTx takes back every fill recorded under fill, inside an
+// existing transaction, so the reservations hold that stock again. Filled
+// reservations return to confirmed.
+func unfillReservationsTx(ctx context.Context, tx pgx.Tx, fill string) error {
+	_, err := tx.Exec(ctx, `WITH undone AS (DELETE FROM reservation_fills WHERE reference = $1 RETURNING reservation_id)
+		UPDATE reservations SET status = 'confirmed', updated_at = NOW()
+		WHERE status = 'filled' AND id IN (SELECT reservation_id FROM undone)`, fill)
+	if err != nil {
+		return fmt.Errorf("unfill reservations for %q: %w", fill, err)
+	}
+	return nil
+}
+
+// finishedGoodsHeldTx returns how many finished units of each of the given
+// products holding reservations still expect, keyed by product id. The
+// reservation with reference except, if any, is left out.
+func finishedGoodsHeldTx(ctx context.Context, tx pgx.Tx, productIDs []int64, except string) (map[int64]float64, error) {
+	rows, err := tx.Query(ctx, `SELECT rp.product_id, SUM(GREATEST(rp.from_stock - COALESCE(f.filled, 0), 0))
+		FROM reservation_products rp JOIN reservations r ON r.id = rp.reservation_id
+		LEFT JOIN (`+filledQuery+`) f ON f.reservation_id = rp.reservation_id AND f.product_id = rp.product_id
+		WHERE `+holdingCondition+` AND rp.from_stock > 0 AND rp.product_id = ANY($1) AND r.reference <> $2
+		GROUP BY rp.product_id`, productIDs, except)
+	if err != nil {
+		return nil, fmt.Errorf("finished goods held: %w", err)
+	}
+	defer rows.Close()
+
+	held := map[int64]float64{}
+	for rows.Next() {
+		var productID int64
+		var n float64
+		if err := rows.Scan(&productID, &n); err != nil {
+			return nil, fmt.Errorf("finished goods held scan: %w", err)
+		}
+		held[productID] = n
+	}
+	return held, rows.Err()
+}
+
+// setReservationStatus records res's new status and commits tx.
+func (db *DB) setReservationStatus(ctx context.Context, tx pgx.Tx, op string, res *models.Reservation, status reservations.Status) (*models.Reservation, error) {
+	err := tx.QueryRow(ctx, `UPDATE reservations SET status = $1, updated_at = NOW() WHERE id = $2 RETURNING updated_at`, status, res.ID).
+		Scan(&res.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("%s update: %w", op, err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("%s commit: %w", op, err)
+	}
+	res.Status = string(status)
+	return res, nil
+}
+
+// ExpireReservations marks every lapsed active reservation expired and
+// returns how many there were. Lapsed holds already stopped counting against
+// available-to-promise; this only records it.
+func (db *DB) ExpireReservations(ctx context.Context) (int, error) {
+	tag, err := db.Exec(ctx, `UPDATE reservations SET status = 'expired', updated_at = NOW() WHERE status = 'active' AND expires_at <= NOW()`)
+	if err != nil {
+		return 0, fmt.Errorf("ExpireReservations: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// availableToPromiseTx returns the available-to-promise of the given
+// ingredients, or of every ingredient when ids is nil, keyed by id.
+func availableToPromiseTx(ctx context.Context, tx pgx.Tx, ids []int64) (map[int64]models.IngredientAvailability, error) {
+	rows, err := tx.Query(ctx, `SELECT i.id, i.name, i.unit, COALESCE(i.stock, 0), COALESCE(h.held, 0)
+		FROM ingredients i LEFT JOIN (`+heldQuery+`) h ON h.ingredient_id = i.id
+		WHERE $1::bigint[] IS NULL OR i.id = ANY($1)`, ids)
+	if err != nil {
+		return nil, fmt.Errorf("available to promise: %w", err)
+	}
+	defer rows.Close()
+
+	list := map[int64]models.IngredientAvailability{}
+	for rows.Next() {
+		var a models.IngredientAvailability
+		if err := rows.Scan(&a.IngredientID, &a.IngredientName, &a.Unit, &a.Stock, &a.Held); err != nil {
+			return nil, fmt.Errorf("available to promise scan: %w", err)
+		}
+		a.Available = a.Stock - a.Held
+		list[a.IngredientID] = a
+	}
+	return list, rows.Err()
+}
+
+// AvailableToPromise returns every ingredient's stock, what reservations
+// hold of it and the difference, in ingredient id order.
+func (db *DB) AvailableToPromise(ctx context.Context) ([]models.IngredientAvailability, error) {
+	tx, err := db.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, fmt.Errorf("AvailableToPromise begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	byID, err := availableToPromiseTx(ctx, tx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("AvailableToPromise: %w", err)
+	}
+	list := make([]models.IngredientAvailability, 0, len(byID))
+	for _, a := range byID {
+		list = append(list, a)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].IngredientID < list[j].IngredientID })
+	return list, nil
+}
@@ -0,0 +1,123 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"com.MixieMelts.inventory/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// Ledger reasons posted by the ingredient lifecycle and stock repairs.
+const (
+	// ReasonInitial marks an ingredient's opening stock when it is created.
+	ReasonInitial = "initial"
+	// ReasonCorrection marks ledger entries added by AdoptStock to account
+	// for stock that was changed without an adjustment.
+	ReasonCorrection = "correction"
+)
+
+// driftTolerance is how far stored stock may sit from the ledger before it
+// counts as drift, so float rounding across many adjustments is not
+// reported.
+const driftTolerance = 1e-6
+
+// stockDriftQuery lists every ingredient whose stock differs from the sum of
+// its ledger.
+const stockDriftQuery = `SELECT i.id, i.name, i.unit, COALESCE(i.stock, 0), COALESCE(l.total, 0)
+	FROM ingredients i
+	LEFT JOIN (SELECT ingredient_id, SUM(change) AS total FROM inventory_adjustments GROUP BY ingredient_id) l
+		ON l.ingredient_id = i.id
+	WHERE ABS(COALESCE(i.stock, 0) - COALESCE(l.total, 0)) > $1
+	ORDER BY i.id`
+
+func stockDriftTx(ctx context.Context, tx pgx.Tx) ([]models.StockDrift, error) {
+	rows, err := tx.Query(ctx, stockDriftQuery, driftTolerance)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.StockDrift{}
+	for rows.Next() {
+		var d models.StockDrift
+		if err := rows.Scan(&d.IngredientID, &d.IngredientName, &d.Unit, &d.Stock, &d.LedgerStock); err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		d.Drift = d.Stock - d.LedgerStock
+		list = append(list, d)
+	}
+	return list, rows.Err()
+}
+
+// StockDrift reports every ingredient whose stored stock is not the sum of
+// its adjustment ledger.
+func (db *DB) StockDrift(ctx context.Context) ([]models.StockDrift, error) {
+	tx, err := db.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, fmt.Errorf("StockDrift begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	list, err := stockDriftTx(ctx, tx)
+	if err != nil {
+		return nil, fmt.Errorf("StockDrift: %w", err)
+	}
+	return list, nil
+}
+
+// RebuildStock sets the stock of every drifted ingredient to the sum of its
+// ledger, re-evaluates its low-stock alert and returns the drift it
+// corrected. The ledger is the source of truth; stock changes it does not
+// record are discarded.
+func (db *DB) RebuildStock(ctx context.Context) ([]models.StockDrift, error) {
+	return db.repairStock(ctx, "RebuildStock", func(tx pgx.Tx, d models.StockDrift) error {
+		_, err := tx.Exec(ctx, `UPDATE ingredients SET stock = $1, updated_at = NOW() WHERE id = $2`, d.LedgerStock, d.IngredientID)
+		return err
+	})
+}
+
+// AdoptStock is the opposite repair: it keeps every drifted ingredient's
+// stored stock and posts a "correction" adjustment for the drift, so the
+// ledger accounts for it from then on. It is meant for databases whose
+// stock was edited directly before the ledger was authoritative.
+func (db *DB) AdoptStock(ctx context.Context, createdBy string) ([]models.StockDrift, error) {
+	return db.repairStock(ctx, "AdoptStock", func(tx pgx.Tx, d models.StockDrift) error {
+		_, err := tx.Exec(ctx, `INSERT INTO inventory_adjustments (ingredient_id, change, reason, reference, created_by, created_at)
+			VALUES ($1,$2,$3,'stock-adopt',$4,NOW())`, d.IngredientID, d.Drift, ReasonCorrection, createdBy)
+		return err
+	})
+}
+
+// repairStock applies fix to every drifted ingredient in one transaction,
+// with the ingredients locked so no adjustment lands in between.
+func (db *DB) repairStock(ctx context.Context, op string, fix func(pgx.Tx, models.StockDrift) error) ([]models.StockDrift, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s begin tx: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	// Lock every ingredient first; adjustments update the ingredient row,
+	// so the ledger read by the next statement cannot move under us.
+	if _, err := tx.Exec(ctx, `SELECT id FROM ingredients ORDER BY id FOR UPDATE`); err != nil {
+		return nil, fmt.Errorf("%s lock: %w", op, err)
+	}
+	list, err := stockDriftTx(ctx, tx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	for _, d := range list {
+		if err := fix(tx, d); err != nil {
+			return nil, fmt.Errorf("%s ingredient %d: %w", op, d.IngredientID, err)
+		}
+		if _, err := evaluateStockAlertTx(ctx, tx, d.IngredientID); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("%s commit: %w", op, err)
+	}
+	return list, nil
+}
@@ -0,0 +1,78 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"com.MixieMelts.inventory/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrUnknownSupplier is returned when a purchase order names a supplier that
+// does not exist.
+var ErrUnknownSupplier = errors.New("unknown supplier")
+
+const supplierColumns = `id, name, email, phone, lead_time_days, notes, created_at, updated_at`
+
+func scanSupplier(row pgx.Row, s *models.Supplier) error {
+	return row.Scan(&s.ID, &s.Name, &s.Email, &s.Phone, &s.LeadTimeDays, &s.Notes, &s.CreatedAt, &s.UpdatedAt)
+}
+
+// GetSuppliers returns every supplier, by name.
+func (db *DB) GetSuppliers(ctx context.Context) ([]models.Supplier, error) {
+	rows, err := db.Query(ctx, `SELECT `+supplierColumns+` FROM suppliers ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("GetSuppliers: %w", err)
+	}
+	defer rows.Close()
+
+	list := []models.Supplier{}
+	for rows.Next() {
+		var s models.Supplier
+		if err := scanSupplier(rows, &s); err != nil {
+			return nil, fmt.Errorf("GetSuppliers scan: %w", err)
+		}
+		list = append(list, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("GetSuppliers rows: %w", err)
+	}
+	return list, nil
+}
+
+// GetSupplier returns a supplier, or nil if it does not exist.
+func (db *DB) GetSupplier(ctx context.Context, id int64) (*models.Supplier, error) {
+	var s models.Supplier
+	if err := scanSupplier(db.QueryRow(ctx, `SELECT `+supplierColumns+` FROM suppliers WHERE id = $1`, id), &s); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("GetSupplier: %w", err)
+	}
+	return &s, nil
+}
+
+// CreateSupplier inserts a supplier and fills in its id and timestamps.
+func (db *DB) CreateSupplier(ctx context.Context, s *models.Supplier) error {
+	err := db.QueryRow(ctx, `INSERT INTO suppliers (name, email, phone, lead_time_days, notes) VALUES ($1,$2,$3,$4,$5)
+		RETURNING id, created_at, updated_at`, s.Name, s.Email, s.Phone, s.LeadTimeDays, s.Notes).Scan(&s.ID, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("CreateSupplier: %w", err)
+	}
+	return nil
+}
+
+// UpdateSupplier replaces a supplier's details. It reports false if the
+// supplier does not exist.
+func (db *DB) UpdateSupplier(ctx context.Context, s *models.Supplier) (bool, error) {
+	err := db.QueryRow(ctx, `UPDATE suppliers SET name = $1, email = $2, phone = $3, lead_time_days = $4, notes = $5, updated_at = NOW()
+		WHERE id = $6 RETURNING created_at, updated_at`, s.Name, s.Email, s.Phone, s.LeadTimeDays, s.Notes, s.ID).Scan(&s.CreatedAt, &s.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("UpdateSupplier: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,24 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"com.MixieMelts.inventory/internal/units"
+)
+
+// syncUnits mirrors the units package into the units lookup table so stock
+// queries can convert between units in SQL. Every accepted spelling of a
+// unit gets a row keyed by its lower-cased form. It runs after migrations on
+// every start, so the table follows the code rather than a migration.
+func (db *DB) syncUnits(ctx context.Context) error {
+	for alias, u := range units.Aliases() {
+		_, err := db.Exec(ctx, `INSERT INTO units (code, dimension, to_base) VALUES ($1,$2,$3)
+			ON CONFLICT (code) DO UPDATE SET dimension = EXCLUDED.dimension, to_base = EXCLUDED.to_base`,
+			alias, u.Dimension, u.ToBase)
+		if err != nil {
+			return fmt.Errorf("seed unit %q: %w", alias, err)
+		}
+	}
+	return nil
+}
@@ -1,27 +1,56 @@
 package handlers
 
 import (
-	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"com.MixieMelts.inventory/internal/database"
 	"com.MixieMelts.inventory/internal/models"
+	"com.MixieMelts.inventory/internal/purchasing"
+	"com.MixieMelts.inventory/internal/reconcile"
+	"com.MixieMelts.inventory/internal/reservations"
+	"com.MixieMelts.inventory/internal/units"
 
 	"github.com/go-chi/chi/v5"
 )
 
 // Handler provides HTTP handlers for the inventory service.
 type Handler struct {
-	db *database.DB
+	db        *database.DB
+	integrity *reconcile.Reconciler
 }
 
-// New creates a new Handler.
-func New(db *database.DB) *Handler {
-	return &Handler{db: db}
+// New creates a new Handler. integrity checks inventory's product references
+// for the admin integrity report.
+func New(db *database.DB, integrity *reconcile.Reconciler) *Handler {
+	return &Handler{db: db, integrity: integrity}
+}
+
+// ServiceTokenHeader carries the shared token other services present on
+// internal routes.
+const ServiceTokenHeader = "X-Service-Token"
+
+// RequireServiceToken admits only requests whose ServiceTokenHeader matches
+// token. Internal routes move stock for any order, so with no token
+// configured every request is refused rather than letting them open.
+func RequireServiceToken(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got := r.Header.Get(ServiceTokenHeader)
+			if token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				respondWithError(w, http.StatusUnauthorized, "invalid service token")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
 }
 
 // JSON helpers
@@ -38,12 +67,6 @@ func respondWithError(w http.ResponseWriter, code int, message string) {
 	respondWithJSON(w, code, map[string]string{"message": message})
 }
 
-// AdjustInventory adjusts the quantity for a finished-product inventory item.
-type adjustPayload struct {
-	Change int64  `json:"change"` // positive or negative integer
-	Reason string `json:"reason,omitempty"`
-}
-
 // -------------------- Ingredient Handlers --------------------
 
 // GetIngredients returns all ingredients (wax, bases, scent oils).
@@ -88,7 +111,11 @@ func (h *Handler) CreateIngredient(w http.ResponseWriter, r *http.Request) {
 	}
 
 	id, err := h.db.CreateIngredient(r.Context(), &it)
-	if err != nil {
+	switch {
+	case errors.Is(err, units.ErrUnknownUnit):
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	case err != nil:
 		log.Printf("CreateIngredient error: %v", err)
 		respondWithError(w, http.StatusInternalServerError, "failed to create ingredient")
 		return
@@ -97,7 +124,9 @@ func (h *Handler) CreateIngredient(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, http.StatusCreated, it)
 }
 
-// UpdateIngredient updates ingredient metadata and stock (full replace semantics).
+// UpdateIngredient replaces ingredient metadata. Stock in the body is ignored;
+// it only changes through adjustments (PATCH /ingredients/{id}/adjust), and
+// the response carries the stored value.
 func (h *Handler) UpdateIngredient(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
@@ -113,7 +142,18 @@ func (h *Handler) UpdateIngredient(w http.ResponseWriter, r *http.Request) {
 	}
 	it.ID = id
 
-	if err := h.db.UpdateIngredient(r.Context(), &it); err != nil {
+	err = h.db.UpdateIngredient(r.Context(), &it)
+	switch {
+	case errors.Is(err, units.ErrUnknownUnit):
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, units.ErrIncompatible):
+		respondWithError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	case errors.Is(err, database.ErrUnknownIngredient):
+		respondWithError(w, http.StatusNotFound, "ingredient not found")
+		return
+	case err != nil:
 		log.Printf("UpdateIngredient error: %v", err)
 		respondWithError(w, http.StatusInternalServerError, "failed to update ingredient")
 		return
@@ -157,18 +197,470 @@ func (h *Handler) AdjustIngredientStock(w http.ResponseWriter, r *http.Request)
 	})
 }
 
+// -------------------- Adjustment History Handlers --------------------
+
+// GetAdjustments pages through the stock ledger of every ingredient, newest
+// first. See adjustmentFilter for the query parameters; ?ingredient_id=
+// restricts it to one ingredient.
+func (h *Handler) GetAdjustments(w http.ResponseWriter, r *http.Request) {
+	f, msg := adjustmentFilter(r)
+	if msg != "" {
+		respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+	if v := r.URL.Query().Get("ingredient_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid ingredient_id")
+			return
+		}
+		f.IngredientID = id
+	}
+	h.respondWithAdjustments(w, r, f)
+}
+
+// GetIngredientAdjustments pages through one ingredient's stock ledger,
+// newest first, with the same filters as GetAdjustments.
+func (h *Handler) GetIngredientAdjustments(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	f, msg := adjustmentFilter(r)
+	if msg != "" {
+		respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+	f.IngredientID = id
+
+	it, err := h.db.GetIngredient(r.Context(), id)
+	if err != nil {
+		log.Printf("GetIngredientAdjustments error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to get ingredient")
+		return
+	}
+	if it == nil {
+		respondWithError(w, http.StatusNotFound, "ingredient not found")
+		return
+	}
+	h.respondWithAdjustments(w, r, f)
+}
+
+func (h *Handler) respondWithAdjustments(w http.ResponseWriter, r *http.Request, f database.AdjustmentFilter) {
+	page, err := h.db.AdjustmentHistory(r.Context(), f)
+	if errors.Is(err, database.ErrInvalidCursor) {
+		respondWithError(w, http.StatusBadRequest, "invalid cursor")
+		return
+	}
+	if err != nil {
+		log.Printf("AdjustmentHistory error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to list adjustments")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, page)
+}
+
+// adjustmentFilter reads the history query parameters: from and to (RFC
+// 3339 times, or YYYY-MM-DD dates with to including the whole day), reason,
+// reference and created_by (exact matches), cursor and limit. It returns a
+// message for the first invalid one.
+func adjustmentFilter(r *http.Request) (database.AdjustmentFilter, string) {
+	q := r.URL.Query()
+	f := database.AdjustmentFilter{
+		Reason:    q.Get("reason"),
+		Reference: q.Get("reference"),
+		CreatedBy: q.Get("created_by"),
+		Cursor:    q.Get("cursor"),
+	}
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+		day  int // days added to a bare date
+	}{{"from", &f.From, 0}, {"to", &f.To, 1}} {
+		v := q.Get(p.name)
+		if v == "" {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			*p.dst = t
+		} else if d, err := time.Parse("2006-01-02", v); err == nil {
+			*p.dst = d.AddDate(0, 0, p.day)
+		} else {
+			return f, p.name + " must be an RFC 3339 time or YYYY-MM-DD"
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > database.MaxAdjustmentPageSize {
+			return f, fmt.Sprintf("limit must be between 1 and %d", database.MaxAdjustmentPageSize)
+		}
+		f.Limit = n
+	}
+	return f, ""
+}
+
+// -------------------- Requirement Handlers --------------------
+
+// GetRequirements expands product quantities into the ingredients needed to
+// make them, without touching stock.
+func (h *Handler) GetRequirements(w http.ResponseWriter, r *http.Request) {
+	var req models.RequirementsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if len(req.Lines) == 0 {
+		respondWithError(w, http.StatusBadRequest, "lines required")
+		return
+	}
+	for _, l := range req.Lines {
+		if l.ProductID <= 0 || l.Quantity <= 0 {
+			respondWithError(w, http.StatusBadRequest, "each line needs a product_id and positive quantity")
+			return
+		}
+	}
+
+	reqs, err := h.db.RecipeRequirements(r.Context(), req.Lines)
+	switch {
+	case errors.Is(err, database.ErrMissingRecipe), errors.Is(err, units.ErrIncompatible):
+		respondWithError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	case err != nil:
+		log.Printf("GetRequirements error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to compute requirements")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, reqs)
+}
+
+// -------------------- Reservation Handlers --------------------
+
+// CreateReservation holds the stock needed for a set of products for a cart
+// or pending order, from {"reference", "lines", "ttl"}. It
+// returns 201 with a new hold, 200 with the existing one for a repeated
+// reference, and 409 if available-to-promise cannot cover it.
+func (h *Handler) CreateReservation(w http.ResponseWriter, r *http.Request) {
+	var req models.ReservationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Reference == "" {
+		respondWithError(w, http.StatusBadRequest, "reference required")
+		return
+	}
+	if len(req.Lines) == 0 {
+		respondWithError(w, http.StatusBadRequest, "lines required")
+		return
+	}
+	seen := map[int64]bool{}
+	for _, l := range req.Lines {
+		if l.ProductID <= 0 || l.Quantity <= 0 {
+			respondWithError(w, http.StatusBadRequest, "each line needs a product_id and positive quantity")
+			return
+		}
+		if seen[l.ProductID] {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("product %d appears more than once", l.ProductID))
+			return
+		}
+		seen[l.ProductID] = true
+	}
+	ttl, err := reservations.ParseTTL(req.TTL)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res, created, err := h.db.Reserve(r.Context(), req, ttl)
+	switch {
+	case errors.Is(err, database.ErrInsufficientStock):
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	case errors.Is(err, database.ErrMissingRecipe), errors.Is(err, units.ErrIncompatible):
+		respondWithError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	case err != nil:
+		log.Printf("CreateReservation error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to create reservation")
+		return
+	}
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	respondWithJSON(w, status, res)
+}
+
+// GetReservation returns a reservation by reference.
+func (h *Handler) GetReservation(w http.ResponseWriter, r *http.Request) {
+	res, err := h.db.GetReservation(r.Context(), chi.URLParam(r, "reference"))
+	if err != nil {
+		log.Printf("GetReservation error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to get reservation")
+		return
+	}
+	if res == nil {
+		respondWithError(w, http.StatusNotFound, "reservation not found")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, res)
+}
+
+// ConfirmReservation hands a reservation to its placed order, which keeps
+// the hold until the kitchen has filled the order.
+func (h *Handler) ConfirmReservation(w http.ResponseWriter, r *http.Request) {
+	res, err := h.db.ConfirmReservation(r.Context(), chi.URLParam(r, "reference"))
+	h.respondWithReservation(w, "ConfirmReservation", res, err)
+}
+
+// ReleaseReservation gives up a reservation's hold, e.g. when a cart is
+// abandoned or an order fails.
+func (h *Handler) ReleaseReservation(w http.ResponseWriter, r *http.Request) {
+	res, err := h.db.ReleaseReservation(r.Context(), chi.URLParam(r, "reference"))
+	h.respondWithReservation(w, "ReleaseReservation", res, err)
+}
+
+func (h *Handler) respondWithReservation(w http.ResponseWriter, op string, res *models.Reservation, err error) {
+	switch {
+	case errors.Is(err, database.ErrReservationNotActive):
+		respondWithError(w, http.StatusConflict, err.Error())
+	case err != nil:
+		log.Printf("%s error: %v", op, err)
+		respondWithError(w, http.StatusInternalServerError, "failed to update reservation")
+	case res == nil:
+		respondWithError(w, http.StatusNotFound, "reservation not found")
+	default:
+		respondWithJSON(w, http.StatusOK, res)
+	}
+}
+
+// GetAvailableToPromise lists every ingredient's stock, what reservations
+// hold and what is left to promise.
+func (h *Handler) GetAvailableToPromise(w http.ResponseWriter, r *http.Request) {
+	list, err := h.db.AvailableToPromise(r.Context())
+	if err != nil {
+		log.Printf("GetAvailableToPromise error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to compute available to promise")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, list)
+}
+
+// -------------------- Finished Goods Handlers --------------------
+
+// GetFinishedGoods returns the made-but-unsold stock of every product.
+func (h *Handler) GetFinishedGoods(w http.ResponseWriter, r *http.Request) {
+	list, err := h.db.GetFinishedGoods(r.Context())
+	if err != nil {
+		log.Printf("GetFinishedGoods error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to list finished goods")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, list)
+}
+
+// GetFinishedGood returns a product's finished-goods stock.
+func (h *Handler) GetFinishedGood(w http.ResponseWriter, r *http.Request) {
+	productID, err := strconv.ParseInt(chi.URLParam(r, "productID"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid product id")
+		return
+	}
+
+	fg, err := h.db.GetFinishedGood(r.Context(), productID)
+	if err != nil {
+		log.Printf("GetFinishedGood error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to get finished goods")
+		return
+	}
+	if fg == nil {
+		respondWithError(w, http.StatusNotFound, "no finished goods recorded for product")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, fg)
+}
+
+// GetFinishedGoodAdjustments returns a product's finished-goods ledger.
+func (h *Handler) GetFinishedGoodAdjustments(w http.ResponseWriter, r *http.Request) {
+	productID, err := strconv.ParseInt(chi.URLParam(r, "productID"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid product id")
+		return
+	}
+
+	list, err := h.db.GetFinishedGoodAdjustments(r.Context(), productID)
+	if err != nil {
+		log.Printf("GetFinishedGoodAdjustments error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to list finished goods adjustments")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, list)
+}
+
+// AdjustFinishedGoodsPayload is used for manual finished-goods adjustments.
+type AdjustFinishedGoodsPayload struct {
+	Change    int    `json:"change"`               // positive to add, negative to subtract
+	Reason    string `json:"reason,omitempty"`     // e.g., count, damaged, sample
+	Reference string `json:"reference,omitempty"`  // e.g., count sheet id
+	CreatedBy string `json:"created_by,omitempty"` // user or system who adjusted
+}
+
+// AdjustFinishedGoods adjusts a product's finished-goods stock and records
+// the adjustment.
+func (h *Handler) AdjustFinishedGoods(w http.ResponseWriter, r *http.Request) {
+	productID, err := strconv.ParseInt(chi.URLParam(r, "productID"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid product id")
+		return
+	}
+
+	var p AdjustFinishedGoodsPayload
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if p.Change == 0 {
+		respondWithError(w, http.StatusBadRequest, "change required")
+		return
+	}
+
+	newQty, err := h.db.AdjustFinishedGoods(r.Context(), productID, p.Change, p.Reason, p.Reference, p.CreatedBy)
+	switch {
+	case errors.Is(err, database.ErrInsufficientStock):
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		log.Printf("AdjustFinishedGoods error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to adjust finished goods")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]any{
+		"product_id":   productID,
+		"new_quantity": newQty,
+	})
+}
+
+// AllocateFinishedGoods fills an order from finished goods as far as stock
+// allows and reports what is left to make. It is an internal endpoint used
+// by the kitchen before it creates demand for an order.
+func (h *Handler) AllocateFinishedGoods(w http.ResponseWriter, r *http.Request) {
+	var req models.AllocationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Reference == "" {
+		respondWithError(w, http.StatusBadRequest, "reference required")
+		return
+	}
+	for _, l := range req.Lines {
+		if l.ProductID <= 0 || l.Quantity <= 0 {
+			respondWithError(w, http.StatusBadRequest, "each line needs a product_id and positive quantity")
+			return
+		}
+	}
+
+	alloc, err := h.db.AllocateFinishedGoods(r.Context(), req)
+	if err != nil {
+		log.Printf("AllocateFinishedGoods error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to allocate finished goods")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, alloc)
+}
+
+// RecordBatch books a finished kitchen batch: its ingredients are consumed
+// and its output goes through finished goods to the orders it was made for.
+func (h *Handler) RecordBatch(w http.ResponseWriter, r *http.Request) {
+	var req models.BatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Reference == "" {
+		respondWithError(w, http.StatusBadRequest, "reference required")
+		return
+	}
+	if req.ProductID <= 0 || req.Quantity <= 0 || req.Allocated < 0 {
+		respondWithError(w, http.StatusBadRequest, "product_id and positive quantity required")
+		return
+	}
+	for _, o := range req.Orders {
+		if o.Reference == "" || o.Quantity <= 0 {
+			respondWithError(w, http.StatusBadRequest, "each order needs a reference and positive quantity")
+			return
+		}
+	}
+
+	err := h.db.RecordBatch(r.Context(), req)
+	switch {
+	case errors.Is(err, database.ErrInsufficientStock):
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	case errors.Is(err, database.ErrMissingRecipe), errors.Is(err, units.ErrIncompatible):
+		respondWithError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	case err != nil:
+		log.Printf("RecordBatch error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to record batch")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ReverseBatch undoes a recorded batch, e.g. when the kitchen could not mark
+// its ticket done.
+func (h *Handler) ReverseBatch(w http.ResponseWriter, r *http.Request) {
+	reference := chi.URLParam(r, "reference")
+	if reference == "" {
+		respondWithError(w, http.StatusBadRequest, "reference required")
+		return
+	}
+
+	if err := h.db.ReverseBatch(r.Context(), reference, r.URL.Query().Get("created_by")); err != nil {
+		log.Printf("ReverseBatch error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to reverse batch")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // -------------------- Recipe Handlers --------------------
 
-// GetRecipe returns recipe items associated with a product.
-func (h *Handler) GetRecipe(w http.ResponseWriter, r *http.Request) {
-	prodIDStr := r.URL.Query().Get("product_id")
-	if prodIDStr == "" {
-		respondWithError(w, http.StatusBadRequest, "product_id query param required")
+// RecipePayload is the body of a bulk recipe replace.
+type RecipePayload struct {
+	Items []models.RecipeItem `json:"items"`
+}
+
+// GetRecipes returns the recipes of the products named by repeated
+// product_id query parameters, keyed by product id, or of every product with
+// a recipe when none are given.
+func (h *Handler) GetRecipes(w http.ResponseWriter, r *http.Request) {
+	var ids []int64
+	for _, v := range r.URL.Query()["product_id"] {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id <= 0 {
+			respondWithError(w, http.StatusBadRequest, "invalid product_id")
+			return
+		}
+		ids = append(ids, id)
+	}
+	recipes, err := h.db.GetRecipes(r.Context(), ids)
+	if err != nil {
+		log.Printf("GetRecipes error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to get recipes")
 		return
 	}
-	prodID, err := strconv.ParseInt(prodIDStr, 10, 64)
+	respondWithJSON(w, http.StatusOK, recipes)
+}
+
+// GetRecipe returns recipe items associated with a product.
+func (h *Handler) GetRecipe(w http.ResponseWriter, r *http.Request) {
+	prodID, err := strconv.ParseInt(chi.URLParam(r, "productID"), 10, 64)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "invalid product_id")
+		respondWithError(w, http.StatusBadRequest, "invalid product id")
 		return
 	}
 	items, err := h.db.GetRecipe(r.Context(), prodID)
@@ -182,21 +674,174 @@ func (h *Handler) GetRecipe(w http.ResponseWriter, r *http.Request) {
 
 // CreateRecipeItem adds an ingredient usage entry for a product.
 func (h *Handler) CreateRecipeItem(w http.ResponseWriter, r *http.Request) {
+	prodID, err := strconv.ParseInt(chi.URLParam(r, "productID"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid product id")
+		return
+	}
 	var ri models.RecipeItem
 	if err := json.NewDecoder(r.Body).Decode(&ri); err != nil {
 		respondWithError(w, http.StatusBadRequest, "invalid request body")
 		return
 	}
-	id, err := h.db.CreateRecipeItem(r.Context(), &ri)
-	if err != nil {
-		log.Printf("CreateRecipeItem error: %v", err)
-		respondWithError(w, http.StatusInternalServerError, "failed to create recipe item")
+	if msg := validateRecipeItem(ri); msg != "" {
+		respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+	ri.ProductID = prodID
+
+	_, err = h.db.CreateRecipeItem(r.Context(), &ri)
+	if respondWithRecipeError(w, "CreateRecipeItem", err) {
 		return
 	}
-	ri.ID = id
 	respondWithJSON(w, http.StatusCreated, ri)
 }
 
+// UpdateRecipeItem replaces one of a product's recipe items.
+func (h *Handler) UpdateRecipeItem(w http.ResponseWriter, r *http.Request) {
+	prodID, err := strconv.ParseInt(chi.URLParam(r, "productID"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid product id")
+		return
+	}
+	itemID, err := strconv.ParseInt(chi.URLParam(r, "itemID"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid recipe item id")
+		return
+	}
+	var ri models.RecipeItem
+	if err := json.NewDecoder(r.Body).Decode(&ri); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if msg := validateRecipeItem(ri); msg != "" {
+		respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+	ri.ID = itemID
+	ri.ProductID = prodID
+
+	updated, err := h.db.UpdateRecipeItem(r.Context(), &ri)
+	if respondWithRecipeError(w, "UpdateRecipeItem", err) {
+		return
+	}
+	if updated == nil {
+		respondWithError(w, http.StatusNotFound, "recipe item not found")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, updated)
+}
+
+// DeleteRecipeItem removes one of a product's recipe items.
+func (h *Handler) DeleteRecipeItem(w http.ResponseWriter, r *http.Request) {
+	prodID, err := strconv.ParseInt(chi.URLParam(r, "productID"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid product id")
+		return
+	}
+	itemID, err := strconv.ParseInt(chi.URLParam(r, "itemID"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid recipe item id")
+		return
+	}
+
+	found, err := h.db.DeleteRecipeItem(r.Context(), prodID, itemID)
+	if err != nil {
+		log.Printf("DeleteRecipeItem error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to delete recipe item")
+		return
+	}
+	if !found {
+		respondWithError(w, http.StatusNotFound, "recipe item not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ReplaceRecipe swaps a product's whole recipe for the items in the body in
+// one transaction. An empty list is rejected; use DeleteRecipe to clear a
+// recipe.
+func (h *Handler) ReplaceRecipe(w http.ResponseWriter, r *http.Request) {
+	prodID, err := strconv.ParseInt(chi.URLParam(r, "productID"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid product id")
+		return
+	}
+	var p RecipePayload
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if len(p.Items) == 0 {
+		respondWithError(w, http.StatusBadRequest, "items required")
+		return
+	}
+	seen := map[int64]bool{}
+	for _, it := range p.Items {
+		if msg := validateRecipeItem(it); msg != "" {
+			respondWithError(w, http.StatusBadRequest, msg)
+			return
+		}
+		if seen[it.IngredientID] {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("ingredient %d listed more than once", it.IngredientID))
+			return
+		}
+		seen[it.IngredientID] = true
+	}
+
+	items, err := h.db.ReplaceRecipe(r.Context(), prodID, p.Items)
+	if respondWithRecipeError(w, "ReplaceRecipe", err) {
+		return
+	}
+	respondWithJSON(w, http.StatusOK, items)
+}
+
+// DeleteRecipe removes a product's whole recipe.
+func (h *Handler) DeleteRecipe(w http.ResponseWriter, r *http.Request) {
+	prodID, err := strconv.ParseInt(chi.URLParam(r, "productID"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid product id")
+		return
+	}
+	if err := h.db.DeleteRecipe(r.Context(), prodID); err != nil {
+		log.Printf("DeleteRecipe error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to delete recipe")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validateRecipeItem checks the fields of a recipe item that do not need the
+// database and returns a message describing the first problem, or "".
+func validateRecipeItem(ri models.RecipeItem) string {
+	switch {
+	case ri.IngredientID <= 0:
+		return "ingredient_id required"
+	case ri.Amount <= 0:
+		return "amount must be positive"
+	case ri.Unit == "":
+		return "unit required"
+	}
+	return ""
+}
+
+// respondWithRecipeError writes the response for an error from a recipe
+// write and reports whether it did.
+func respondWithRecipeError(w http.ResponseWriter, op string, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, units.ErrUnknownUnit):
+		respondWithError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, database.ErrUnknownIngredient), errors.Is(err, units.ErrIncompatible):
+		respondWithError(w, http.StatusUnprocessableEntity, err.Error())
+	default:
+		log.Printf("%s error: %v", op, err)
+		respondWithError(w, http.StatusInternalServerError, "failed to save recipe")
+	}
+	return true
+}
+
 // -------------------- Utility / Diagnostics --------------------
 
 // Health check (simple)
@@ -204,55 +849,551 @@ func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
 }
 
-// Optional helper to compute production capacity for a product based on current ingredient stocks.
-// Returns the maximum number of product units that can be produced (may be fractional).
-func (h *Handler) ComputeProductCapacity(ctx context.Context, productID int64) (*models.ProductStockProjection, error) {
-	recipe, err := h.db.GetRecipe(ctx, productID)
+// -------------------- Capacity Handlers --------------------
+
+// GetCapacities returns, for every product with a recipe or finished goods,
+// how many units current stock can make and sell.
+func (h *Handler) GetCapacities(w http.ResponseWriter, r *http.Request) {
+	list, err := h.db.ProductCapacities(r.Context())
+	if err != nil {
+		log.Printf("GetCapacities error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to compute capacity")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, list)
+}
+
+// GetCapacity returns how many units of a product current stock can make and
+// sell.
+func (h *Handler) GetCapacity(w http.ResponseWriter, r *http.Request) {
+	productID, err := strconv.ParseInt(chi.URLParam(r, "productID"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid product id")
+		return
+	}
+
+	p, err := h.db.ProductCapacity(r.Context(), productID)
+	if err != nil {
+		log.Printf("GetCapacity error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to compute capacity")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, p)
+}
+
+// -------------------- Alert Handlers --------------------
+
+// GetStockAlerts lists low-stock alerts. ?status= picks the view: open (the
+// default, excluding snoozed alerts), snoozed, acknowledged, resolved or all.
+func (h *Handler) GetStockAlerts(w http.ResponseWriter, r *http.Request) {
+	view := r.URL.Query().Get("status")
+	if view == "" {
+		view = database.AlertViewOpen
+	}
+	list, err := h.db.ListStockAlerts(r.Context(), view)
+	if errors.Is(err, database.ErrUnknownAlertView) {
+		respondWithError(w, http.StatusBadRequest, "status must be open, snoozed, acknowledged, resolved or all")
+		return
+	}
+	if err != nil {
+		log.Printf("GetStockAlerts error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to list alerts")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, list)
+}
+
+// AcknowledgeAlertPayload names who acknowledged an alert.
+type AcknowledgeAlertPayload struct {
+	AcknowledgedBy string `json:"acknowledged_by,omitempty"`
+}
+
+// AcknowledgeStockAlert marks an alert as seen. It stays acknowledged until
+// stock recovers and resolves it.
+func (h *Handler) AcknowledgeStockAlert(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	var p AcknowledgeAlertPayload
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+	}
+
+	alert, err := h.db.AcknowledgeStockAlert(r.Context(), id, p.AcknowledgedBy)
+	respondWithAlert(w, "AcknowledgeStockAlert", alert, err)
+}
+
+// SnoozeAlertPayload is how long to snooze an alert: either a Go duration
+// such as "4h" or an RFC 3339 time.
+type SnoozeAlertPayload struct {
+	Duration string     `json:"duration,omitempty"`
+	Until    *time.Time `json:"until,omitempty"`
+}
+
+// SnoozeStockAlert hides an alert from the open view until the given time.
+func (h *Handler) SnoozeStockAlert(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	var p SnoozeAlertPayload
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	var until time.Time
+	switch {
+	case p.Duration != "" && p.Until != nil:
+		respondWithError(w, http.StatusBadRequest, "give either duration or until, not both")
+		return
+	case p.Duration != "":
+		d, err := time.ParseDuration(p.Duration)
+		if err != nil || d <= 0 {
+			respondWithError(w, http.StatusBadRequest, "duration must be a positive duration such as 4h")
+			return
+		}
+		until = time.Now().Add(d)
+	case p.Until != nil:
+		until = *p.Until
+	default:
+		respondWithError(w, http.StatusBadRequest, "duration or until is required")
+		return
+	}
+	if !until.After(time.Now()) {
+		respondWithError(w, http.StatusBadRequest, "snooze must end in the future")
+		return
+	}
+
+	alert, err := h.db.SnoozeStockAlert(r.Context(), id, until)
+	respondWithAlert(w, "SnoozeStockAlert", alert, err)
+}
+
+func respondWithAlert(w http.ResponseWriter, op string, alert *models.StockAlert, err error) {
+	switch {
+	case errors.Is(err, database.ErrAlertResolved):
+		respondWithError(w, http.StatusConflict, "alert is already resolved")
+	case err != nil:
+		log.Printf("%s error: %v", op, err)
+		respondWithError(w, http.StatusInternalServerError, "failed to update alert")
+	case alert == nil:
+		respondWithError(w, http.StatusNotFound, "alert not found")
+	default:
+		respondWithJSON(w, http.StatusOK, alert)
+	}
+}
+
+// -------------------- Supplier Handlers --------------------
+
+// GetSuppliers lists every supplier.
+func (h *Handler) GetSuppliers(w http.ResponseWriter, r *http.Request) {
+	list, err := h.db.GetSuppliers(r.Context())
+	if err != nil {
+		log.Printf("GetSuppliers error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to list suppliers")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, list)
+}
+
+// GetSupplier returns a single supplier.
+func (h *Handler) GetSupplier(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	s, err := h.db.GetSupplier(r.Context(), id)
+	if err != nil {
+		log.Printf("GetSupplier error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to get supplier")
+		return
+	}
+	if s == nil {
+		respondWithError(w, http.StatusNotFound, "supplier not found")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, s)
+}
+
+// CreateSupplier adds a supplier.
+func (h *Handler) CreateSupplier(w http.ResponseWriter, r *http.Request) {
+	var s models.Supplier
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if msg := validateSupplier(s); msg != "" {
+		respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+	if err := h.db.CreateSupplier(r.Context(), &s); err != nil {
+		log.Printf("CreateSupplier error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to create supplier")
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, s)
+}
+
+// UpdateSupplier replaces a supplier's details.
+func (h *Handler) UpdateSupplier(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	var s models.Supplier
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if msg := validateSupplier(s); msg != "" {
+		respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+	s.ID = id
+
+	found, err := h.db.UpdateSupplier(r.Context(), &s)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get recipe: %w", err)
+		log.Printf("UpdateSupplier error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to update supplier")
+		return
+	}
+	if !found {
+		respondWithError(w, http.StatusNotFound, "supplier not found")
+		return
 	}
-	if len(recipe) == 0 {
-		return &models.ProductStockProjection{
-			ProductID:      productID,
-			AvailableUnits: 0,
-		}, nil
+	respondWithJSON(w, http.StatusOK, s)
+}
+
+func validateSupplier(s models.Supplier) string {
+	switch {
+	case strings.TrimSpace(s.Name) == "":
+		return "name required"
+	case s.LeadTimeDays < 0:
+		return "lead_time_days must not be negative"
 	}
+	return ""
+}
 
-	// For each recipe item, fetch ingredient to determine available units.
-	var limitingItemID int64
-	var limitingUnits float64 = 1e18 // very large
-	for _, ri := range recipe {
-		ing, err := h.db.GetIngredient(ctx, ri.IngredientID)
+// -------------------- Purchase Order Handlers --------------------
+
+// GetPurchaseOrders lists purchase orders, newest first, optionally filtered
+// by ?status= and ?supplier_id=.
+func (h *Handler) GetPurchaseOrders(w http.ResponseWriter, r *http.Request) {
+	var supplierID int64
+	if v := r.URL.Query().Get("supplier_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get ingredient %d: %w", ri.IngredientID, err)
+			respondWithError(w, http.StatusBadRequest, "invalid supplier_id")
+			return
+		}
+		supplierID = id
+	}
+	list, err := h.db.ListPurchaseOrders(r.Context(), r.URL.Query().Get("status"), supplierID)
+	if err != nil {
+		log.Printf("GetPurchaseOrders error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to list purchase orders")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, list)
+}
+
+// GetPurchaseOrder returns a purchase order with its lines and how far each
+// has been received.
+func (h *Handler) GetPurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	po, err := h.db.GetPurchaseOrder(r.Context(), id)
+	if err != nil {
+		log.Printf("GetPurchaseOrder error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to get purchase order")
+		return
+	}
+	if po == nil {
+		respondWithError(w, http.StatusNotFound, "purchase order not found")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, po)
+}
+
+// CreatePurchaseOrder creates a draft purchase order. Each line names an
+// ingredient, a positive quantity, optionally a unit (default: the
+// ingredient's stock unit), a unit cost and an expected date (YYYY-MM-DD).
+func (h *Handler) CreatePurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	var po models.PurchaseOrder
+	if err := json.NewDecoder(r.Body).Decode(&po); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if msg := validatePurchaseOrder(po); msg != "" {
+		respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	created, err := h.db.CreatePurchaseOrder(r.Context(), &po)
+	if err != nil {
+		respondWithPurchasingError(w, "CreatePurchaseOrder", err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, created)
+}
+
+func validatePurchaseOrder(po models.PurchaseOrder) string {
+	if po.SupplierID <= 0 {
+		return "supplier_id required"
+	}
+	if len(po.Lines) == 0 {
+		return "at least one line required"
+	}
+	seen := map[int64]bool{}
+	for _, l := range po.Lines {
+		switch {
+		case l.IngredientID <= 0:
+			return "ingredient_id required on every line"
+		case seen[l.IngredientID]:
+			return fmt.Sprintf("ingredient %d appears more than once", l.IngredientID)
+		case l.Quantity <= 0:
+			return "quantity must be positive"
+		case l.UnitCost < 0:
+			return "unit_cost must not be negative"
 		}
-		if ing == nil {
-			// missing ingredient -> cannot produce
-			return &models.ProductStockProjection{
-				ProductID:      productID,
-				AvailableUnits: 0,
-				LimitingItemID: ri.IngredientID,
-				LimitingAvail:  0,
-			}, nil
+		if l.ExpectedDate != "" {
+			if _, err := time.Parse("2006-01-02", l.ExpectedDate); err != nil {
+				return "expected_date must be YYYY-MM-DD"
+			}
+		}
+		seen[l.IngredientID] = true
+	}
+	return ""
+}
+
+// SubmitPurchaseOrder marks a draft purchase order as ordered.
+func (h *Handler) SubmitPurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	h.setPurchaseOrderStatus(w, r, purchasing.StatusOrdered)
+}
+
+// CancelPurchaseOrder cancels a purchase order before anything is received.
+func (h *Handler) CancelPurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	h.setPurchaseOrderStatus(w, r, purchasing.StatusCancelled)
+}
+
+// ClosePurchaseOrder closes a partially received purchase order, accepting
+// that the outstanding quantities will not arrive.
+func (h *Handler) ClosePurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	h.setPurchaseOrderStatus(w, r, purchasing.StatusClosed)
+}
+
+func (h *Handler) setPurchaseOrderStatus(w http.ResponseWriter, r *http.Request, next purchasing.Status) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	po, err := h.db.SetPurchaseOrderStatus(r.Context(), id, next)
+	if err != nil {
+		respondWithPurchasingError(w, "SetPurchaseOrderStatus", err)
+		return
+	}
+	if po == nil {
+		respondWithError(w, http.StatusNotFound, "purchase order not found")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, po)
+}
+
+// ReceivePurchaseOrder records goods arriving against a purchase order and
+// posts the matching stock adjustments. Each line names the order line by
+// line_id or ingredient_id and the quantity received in the line's unit.
+func (h *Handler) ReceivePurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	var receipt models.Receipt
+	if err := json.NewDecoder(r.Body).Decode(&receipt); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if msg := validateReceipt(receipt); msg != "" {
+		respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	created, err := h.db.ReceivePurchaseOrder(r.Context(), id, receipt)
+	if err != nil {
+		respondWithPurchasingError(w, "ReceivePurchaseOrder", err)
+		return
+	}
+	if created == nil {
+		respondWithError(w, http.StatusNotFound, "purchase order not found")
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, created)
+}
+
+func validateReceipt(rc models.Receipt) string {
+	if len(rc.Lines) == 0 {
+		return "at least one line required"
+	}
+	seenLines, seenIngredients := map[int64]bool{}, map[int64]bool{}
+	for _, l := range rc.Lines {
+		switch {
+		case l.LineID <= 0 && l.IngredientID <= 0:
+			return "line_id or ingredient_id required on every line"
+		case l.Quantity <= 0:
+			return "quantity must be positive"
+		case l.LineID > 0 && seenLines[l.LineID], l.LineID <= 0 && seenIngredients[l.IngredientID]:
+			return "a line appears more than once"
 		}
-		if ri.Amount <= 0 {
+		seenLines[l.LineID] = true
+		seenIngredients[l.IngredientID] = true
+	}
+	return ""
+}
+
+// GetReceipts lists the receipts posted against a purchase order.
+func (h *Handler) GetReceipts(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	list, err := h.db.GetReceipts(r.Context(), id)
+	if err != nil {
+		log.Printf("GetReceipts error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to list receipts")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, list)
+}
+
+// respondWithPurchasingError writes the response for an error from a
+// purchase order operation.
+func respondWithPurchasingError(w http.ResponseWriter, op string, err error) {
+	switch {
+	case errors.Is(err, units.ErrUnknownUnit):
+		respondWithError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, purchasing.ErrTransition):
+		respondWithError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, database.ErrUnknownSupplier), errors.Is(err, database.ErrUnknownIngredient),
+		errors.Is(err, database.ErrUnknownPurchaseOrderLine), errors.Is(err, units.ErrIncompatible),
+		errors.Is(err, database.ErrNothingToReorder), errors.Is(err, database.ErrNoSupplier):
+		respondWithError(w, http.StatusUnprocessableEntity, err.Error())
+	default:
+		log.Printf("%s error: %v", op, err)
+		respondWithError(w, http.StatusInternalServerError, "failed to update purchase order")
+	}
+}
+
+// -------------------- Reorder Handlers --------------------
+
+// GetReorderSuggestions proposes purchase quantities and projected stock-out
+// dates from trailing usage, supplier lead times and MinThreshold. Query
+// parameters window_days, cover_days and lead_time_days (for ingredients
+// never ordered) override the defaults, and all=true includes ingredients
+// that need no order.
+func (h *Handler) GetReorderSuggestions(w http.ResponseWriter, r *http.Request) {
+	var opts models.ReorderOptions
+	q := r.URL.Query()
+	for _, p := range []struct {
+		name string
+		dst  *int
+	}{{"window_days", &opts.WindowDays}, {"cover_days", &opts.CoverDays}, {"lead_time_days", &opts.DefaultLeadTimeDays}} {
+		v := q.Get(p.name)
+		if v == "" {
 			continue
 		}
-		possible := ing.Stock / ri.Amount
-		if possible < limitingUnits {
-			limitingUnits = possible
-			limitingItemID = ing.ID
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			respondWithError(w, http.StatusBadRequest, "invalid "+p.name)
+			return
 		}
+		*p.dst = n
 	}
+	opts.All = q.Get("all") == "true"
 
-	if limitingUnits > 1e17 {
-		limitingUnits = 0
+	list, err := h.db.ReorderSuggestions(r.Context(), opts)
+	if err != nil {
+		log.Printf("GetReorderSuggestions error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to compute reorder suggestions")
+		return
 	}
+	respondWithJSON(w, http.StatusOK, list)
+}
 
-	return &models.ProductStockProjection{
-		ProductID:      productID,
-		AvailableUnits: limitingUnits,
-		LimitingItemID: limitingItemID,
-		LimitingAvail:  limitingUnits,
-	}, nil
+// AcceptReorderSuggestions drafts purchase orders, one per supplier, from
+// the accepted suggestions in {"items": [{"ingredient_id": 1}]}. An item may
+// override the suggested quantity or supplier.
+func (h *Handler) AcceptReorderSuggestions(w http.ResponseWriter, r *http.Request) {
+	var req models.ReorderAcceptRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if len(req.Items) == 0 {
+		respondWithError(w, http.StatusBadRequest, "at least one item required")
+		return
+	}
+	if req.WindowDays < 0 || req.CoverDays < 0 || req.DefaultLeadTimeDays < 0 {
+		respondWithError(w, http.StatusBadRequest, "window_days, cover_days and default_lead_time_days must not be negative")
+		return
+	}
+	seen := map[int64]bool{}
+	for _, item := range req.Items {
+		switch {
+		case item.IngredientID <= 0:
+			respondWithError(w, http.StatusBadRequest, "ingredient_id required on every item")
+			return
+		case seen[item.IngredientID]:
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("ingredient %d appears more than once", item.IngredientID))
+			return
+		case item.Quantity < 0:
+			respondWithError(w, http.StatusBadRequest, "quantity must not be negative")
+			return
+		}
+		seen[item.IngredientID] = true
+	}
+
+	orders, err := h.db.AcceptReorderSuggestions(r.Context(), req)
+	if err != nil {
+		respondWithPurchasingError(w, "AcceptReorderSuggestions", err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, orders)
+}
+
+// -------------------- Integrity Handlers --------------------
+
+// GetIntegrityReport returns the latest report of products inventory still
+// references but the products service no longer has, running a check first
+// if none has completed yet.
+func (h *Handler) GetIntegrityReport(w http.ResponseWriter, r *http.Request) {
+	if report := h.integrity.Latest(); report != nil {
+		respondWithJSON(w, http.StatusOK, report)
+		return
+	}
+	h.CheckIntegrity(w, r)
+}
+
+// CheckIntegrity checks inventory's product references now and returns the
+// new report.
+func (h *Handler) CheckIntegrity(w http.ResponseWriter, r *http.Request) {
+	report, err := h.integrity.Check(r.Context())
+	if err != nil {
+		log.Printf("CheckIntegrity error: %v", err)
+		respondWithError(w, http.StatusBadGateway, "failed to check product references")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, report)
 }
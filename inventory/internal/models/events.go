@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Event types published through the outbox.
+const (
+	EventIngredientStockAdjusted = "ingredient.stock_adjusted"
+	EventIngredientLowStock      = "ingredient.low_stock" // payload is the StockAlert
+)
+
+// StockAdjustedEvent is the payload of ingredient.stock_adjusted: one ledger
+// entry and the stock it left the ingredient with.
+type StockAdjustedEvent struct {
+	AdjustmentID   int64     `json:"adjustment_id"`
+	IngredientID   int64     `json:"ingredient_id"`
+	IngredientName string    `json:"ingredient_name"`
+	Unit           string    `json:"unit"`
+	Change         float64   `json:"change"`
+	Stock          float64   `json:"stock"` // stock after the change
+	Reason         string    `json:"reason,omitempty"`
+	Reference      string    `json:"reference,omitempty"`
+	CreatedBy      string    `json:"created_by,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
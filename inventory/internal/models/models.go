@@ -17,7 +17,7 @@ type Ingredient struct {
 	ID           int64          `json:"id"`
 	Name         string         `json:"name"`
 	Type         IngredientType `json:"type"`
-	Unit         string         `json:"unit"`                    // e.g. "g", "kg", "ml", "L"
+	Unit         string         `json:"unit"`                    // canonical unit code, e.g. "g", "kg", "mL", "L"
 	Stock        float64        `json:"stock"`                   // current on-hand quantity in Unit
 	MinThreshold float64        `json:"min_threshold,omitempty"` // optional reorder threshold
 	Notes        string         `json:"notes,omitempty"`
@@ -39,31 +39,33 @@ type InventoryAdjustment struct {
 /*
 RecipeItem represents a single ingredient entry for a product's recipe.
 
-This model is aligned with the products service recipe representation:
-  - stores the ingredient `name`, `type`, `unit`, and `amount` directly so the
-    products and inventory services can exchange recipe data without requiring a
-    cross-service lookup. The previous `IngredientID`/`Quantity` form assumed a
-    tight coupling to inventory ingredients; by storing the descriptive fields we
-    can keep recipe semantics consistent with the products service.
+Inventory owns recipes: the products service has no copy and fetches them
+through `GET /recipes`, so each item carries the ingredient's name and type
+alongside the `ingredient_id` it references.
 */
 type RecipeItem struct {
-	ID           int64     `json:"id"`
-	ProductID    int64     `json:"product_id"`              // references products service product id
-	IngredientID int64     `json:"ingredient_id,omitempty"` // optional reference to inventory ingredient
-	Unit         string    `json:"unit"`                    // unit for the amount (e.g. "g", "ml")
-	Amount       float64   `json:"amount"`                  // amount of the ingredient per unit product
-	Notes        string    `json:"notes,omitempty"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID             int64          `json:"id"`
+	ProductID      int64          `json:"product_id"`                // references products service product id
+	IngredientID   int64          `json:"ingredient_id,omitempty"`   // optional reference to inventory ingredient
+	IngredientName string         `json:"ingredient_name,omitempty"` // filled in on reads; ignored on writes
+	IngredientType IngredientType `json:"ingredient_type,omitempty"` // filled in on reads; ignored on writes
+	Unit           string         `json:"unit"`                      // unit for the amount (e.g. "g", "mL"); converted to the ingredient's unit
+	Amount         float64        `json:"amount"`                    // amount of the ingredient per unit product
+	Notes          string         `json:"notes,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
 }
 
 // ProductStockProjection is a helper model describing how many product units can be
-// produced based on current ingredient stocks and a recipe. Useful for admin UI.
+// produced based on current ingredient stocks and a recipe, and how many can be
+// sold counting finished goods already made. Useful for admin UI and storefront stock labels.
 type ProductStockProjection struct {
 	ProductID      int64     `json:"product_id"`
 	AvailableUnits float64   `json:"available_units"`  // fractional units allowed (e.g., 12.5 melts)
 	LimitingItemID int64     `json:"limiting_item_id"` // ingredient that limits production
 	LimitingAvail  float64   `json:"limiting_avail"`   // how many units that limiting ingredient permits
+	OnHand         int       `json:"on_hand"`          // finished goods already made
+	SellableUnits  int       `json:"sellable_units"`   // on hand plus whole units that can still be made
 	ComputedAt     time.Time `json:"computed_at"`
 }
 
@@ -83,3 +85,332 @@ type IngredientSummary struct {
 func ZeroTime() time.Time {
 	return time.Time{}
 }
+
+// ProductQuantity is a number of units of a product, used when expanding
+// product demand into ingredient requirements through recipes.
+type ProductQuantity struct {
+	ProductID int64   `json:"product_id"`
+	Quantity  float64 `json:"quantity"`
+}
+
+// IngredientRequirement is the total amount of one ingredient needed to make a
+// set of products, expressed in the unit the ingredient is stocked in.
+type IngredientRequirement struct {
+	IngredientID   int64   `json:"ingredient_id"`
+	IngredientName string  `json:"ingredient_name"`
+	Unit           string  `json:"unit"`
+	Amount         float64 `json:"amount"`
+}
+
+// RequirementsRequest asks inventory how much of each ingredient is needed to
+// make a set of products, without touching stock.
+type RequirementsRequest struct {
+	Lines []ProductQuantity `json:"lines"`
+}
+
+// FinishedGood is the number of made-but-unsold units of a product on hand.
+type FinishedGood struct {
+	ProductID int64     `json:"product_id"`
+	Quantity  int       `json:"quantity"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// FinishedGoodAdjustment records a change to a product's finished-goods
+// stock, the finished-goods counterpart of InventoryAdjustment.
+type FinishedGoodAdjustment struct {
+	ID        int64     `json:"id"`
+	ProductID int64     `json:"product_id"`
+	Change    int       `json:"change"`               // positive when made or returned, negative when sold or written off
+	Reason    string    `json:"reason,omitempty"`     // e.g. "batch", "order", "count", "reversal"
+	Reference string    `json:"reference,omitempty"`  // batch id, order reference, etc
+	CreatedBy string    `json:"created_by,omitempty"` // who made the adjustment
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AllocationRequest asks inventory to fill orders from finished goods.
+// Reference identifies the order (e.g. "order-12"); repeating a request with
+// the same reference returns the original allocation.
+type AllocationRequest struct {
+	Reference string             `json:"reference"`
+	CreatedBy string             `json:"created_by,omitempty"`
+	Lines     []ProductUnitCount `json:"lines"`
+}
+
+// ProductUnitCount is a whole number of units of a product.
+type ProductUnitCount struct {
+	ProductID int64 `json:"product_id"`
+	Quantity  int   `json:"quantity"`
+}
+
+// AllocationLine is how much of a requested line finished goods covered.
+// The rest has to be made.
+type AllocationLine struct {
+	ProductID int64 `json:"product_id"`
+	Requested int   `json:"requested"`
+	Allocated int   `json:"allocated"`
+}
+
+// Allocation is the result of an AllocationRequest.
+type Allocation struct {
+	Reference string           `json:"reference"`
+	Lines     []AllocationLine `json:"lines"`
+}
+
+// BatchRequest records a finished kitchen batch: the ingredients for
+// Quantity units are consumed, the units are added to finished goods, and
+// Allocated of them are immediately taken for the orders the batch was made
+// for. Any surplus stays on hand. Orders lists each order's share, which
+// fills its reservation.
+type BatchRequest struct {
+	Reference string       `json:"reference"`
+	ProductID int64        `json:"product_id"`
+	Quantity  int          `json:"quantity"`
+	Allocated int          `json:"allocated"`
+	Orders    []OrderShare `json:"orders,omitempty"`
+	CreatedBy string       `json:"created_by,omitempty"`
+}
+
+// OrderShare is the number of units of a batch made for one order,
+// identified by its reservation reference (e.g. "order-12").
+type OrderShare struct {
+	Reference string `json:"reference"`
+	Quantity  int    `json:"quantity"`
+}
+
+// ProductReference counts the inventory rows that point at a product: its
+// recipe items and its finished-goods stock.
+type ProductReference struct {
+	ProductID     int64 `json:"product_id"`
+	RecipeItems   int   `json:"recipe_items"`
+	FinishedGoods *int  `json:"finished_goods,omitempty"` // units on hand, nil when there is no finished-goods row
+}
+
+// IntegrityReport lists the products inventory references that the products
+// service no longer has.
+type IntegrityReport struct {
+	CheckedAt       time.Time          `json:"checked_at"`
+	ProductsChecked int                `json:"products_checked"` // products the products service returned
+	Orphans         []ProductReference `json:"orphans"`
+}
+
+// AlertStatus is the lifecycle state of a StockAlert.
+type AlertStatus string
+
+const (
+	AlertStatusOpen         AlertStatus = "open"
+	AlertStatusAcknowledged AlertStatus = "acknowledged"
+	AlertStatusResolved     AlertStatus = "resolved" // stock recovered; a new dip raises a new alert
+)
+
+// StockAlert is raised when an ingredient's stock drops below its
+// MinThreshold. Stock and Threshold are the values when it was raised.
+type StockAlert struct {
+	ID             int64       `json:"id"`
+	IngredientID   int64       `json:"ingredient_id"`
+	IngredientName string      `json:"ingredient_name"`
+	Unit           string      `json:"unit"`
+	Stock          float64     `json:"stock"`
+	Threshold      float64     `json:"threshold"`
+	CurrentStock   float64     `json:"current_stock"`
+	Status         AlertStatus `json:"status"`
+	SnoozedUntil   *time.Time  `json:"snoozed_until,omitempty"`
+	AcknowledgedBy string      `json:"acknowledged_by,omitempty"`
+	AcknowledgedAt *time.Time  `json:"acknowledged_at,omitempty"`
+	ResolvedAt     *time.Time  `json:"resolved_at,omitempty"`
+	CreatedAt      time.Time   `json:"created_at"`
+	UpdatedAt      time.Time   `json:"updated_at"`
+}
+
+// Supplier sells ingredients. LeadTimeDays is how long its deliveries usually
+// take to arrive after ordering.
+type Supplier struct {
+	ID           int64     `json:"id"`
+	Name         string    `json:"name"`
+	Email        string    `json:"email,omitempty"`
+	Phone        string    `json:"phone,omitempty"`
+	LeadTimeDays int       `json:"lead_time_days"`
+	Notes        string    `json:"notes,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// PurchaseOrder is an order of ingredients from a supplier. Status is one of
+// the purchasing.Status values.
+type PurchaseOrder struct {
+	ID           int64               `json:"id"`
+	SupplierID   int64               `json:"supplier_id"`
+	SupplierName string              `json:"supplier_name"`
+	Status       string              `json:"status"`
+	Reference    string              `json:"reference,omitempty"` // the supplier's or our own order number
+	Notes        string              `json:"notes,omitempty"`
+	CreatedBy    string              `json:"created_by,omitempty"`
+	Total        float64             `json:"total"` // ordered quantity times unit cost over all lines
+	Lines        []PurchaseOrderLine `json:"lines"`
+	OrderedAt    *time.Time          `json:"ordered_at,omitempty"`
+	CreatedAt    time.Time           `json:"created_at"`
+	UpdatedAt    time.Time           `json:"updated_at"`
+}
+
+// PurchaseOrderLine is the quantity of one ingredient on a purchase order,
+// in Unit. Outstanding and OverReceived track under- and over-receipt.
+type PurchaseOrderLine struct {
+	ID               int64   `json:"id"`
+	IngredientID     int64   `json:"ingredient_id"`
+	IngredientName   string  `json:"ingredient_name,omitempty"`
+	Quantity         float64 `json:"quantity"`
+	Unit             string  `json:"unit"`
+	UnitCost         float64 `json:"unit_cost"`
+	ExpectedDate     string  `json:"expected_date,omitempty"` // YYYY-MM-DD
+	ReceivedQuantity float64 `json:"received_quantity"`
+	Outstanding      float64 `json:"outstanding"`
+	OverReceived     float64 `json:"over_received"`
+	ReceiptStatus    string  `json:"receipt_status"` // one of the purchasing.LineStatus values
+}
+
+// Receipt records goods arriving against a purchase order. Each line posts
+// a "receipt" adjustment referencing the receipt.
+type Receipt struct {
+	ID              int64         `json:"id"`
+	PurchaseOrderID int64         `json:"purchase_order_id"`
+	ReceivedBy      string        `json:"received_by,omitempty"`
+	Notes           string        `json:"notes,omitempty"`
+	Lines           []ReceiptLine `json:"lines"`
+	ReceivedAt      time.Time     `json:"received_at"`
+}
+
+// ReceiptLine is how much of a purchase order line arrived, in the line's
+// unit.
+type ReceiptLine struct {
+	LineID       int64   `json:"line_id"`
+	IngredientID int64   `json:"ingredient_id,omitempty"`
+	Quantity     float64 `json:"quantity"`
+}
+
+// ReorderOptions tunes how reorder suggestions are computed. Zero values
+// take the defaults.
+type ReorderOptions struct {
+	WindowDays          int  `json:"window_days,omitempty"`            // trailing days of usage to average (default 30)
+	CoverDays           int  `json:"cover_days,omitempty"`             // days of usage an order covers beyond the lead time (default 30)
+	DefaultLeadTimeDays int  `json:"default_lead_time_days,omitempty"` // lead time for ingredients with no known supplier (default 7)
+	All                 bool `json:"all,omitempty"`                    // include ingredients that need no order
+}
+
+// ReorderSuggestion proposes an order for one ingredient. Quantities are in
+// the ingredient's stock unit. The supplier is the one the ingredient was
+// last ordered from, and UnitCost that order's cost per stock unit.
+type ReorderSuggestion struct {
+	IngredientID      int64      `json:"ingredient_id"`
+	IngredientName    string     `json:"ingredient_name"`
+	Unit              string     `json:"unit"`
+	Stock             float64    `json:"stock"`
+	OnOrder           float64    `json:"on_order"`
+	MinThreshold      float64    `json:"min_threshold"`
+	Consumed          float64    `json:"consumed"` // net usage over the window
+	WindowDays        int        `json:"window_days"`
+	DailyUsage        float64    `json:"daily_usage"`
+	DaysLeft          *float64   `json:"days_left,omitempty"`
+	StockOutDate      *time.Time `json:"stock_out_date,omitempty"`
+	SupplierID        *int64     `json:"supplier_id,omitempty"`
+	SupplierName      string     `json:"supplier_name,omitempty"`
+	LeadTimeDays      int        `json:"lead_time_days"`
+	UnitCost          float64    `json:"unit_cost,omitempty"`
+	ReorderPoint      float64    `json:"reorder_point"`
+	SuggestedQuantity float64    `json:"suggested_quantity"`
+	Reorder           bool       `json:"reorder"`
+}
+
+// ReorderAcceptItem accepts the suggestion for one ingredient. Quantity and
+// SupplierID override the suggested ones when set.
+type ReorderAcceptItem struct {
+	IngredientID int64   `json:"ingredient_id"`
+	Quantity     float64 `json:"quantity,omitempty"`
+	SupplierID   int64   `json:"supplier_id,omitempty"`
+}
+
+// ReorderAcceptRequest drafts purchase orders from accepted suggestions,
+// one per supplier. The options must match the ones the suggestions were
+// shown with.
+type ReorderAcceptRequest struct {
+	ReorderOptions
+	Items     []ReorderAcceptItem `json:"items"`
+	CreatedBy string              `json:"created_by,omitempty"`
+}
+
+// AdjustmentEntry is a row of an ingredient's stock ledger as read back
+// through the history API. Balance is the ingredient's stock after this
+// adjustment: the sum of its ledger up to and including the row.
+type AdjustmentEntry struct {
+	InventoryAdjustment
+	IngredientName string  `json:"ingredient_name"`
+	Unit           string  `json:"unit"`
+	Balance        float64 `json:"balance"`
+}
+
+// AdjustmentPage is one page of ledger entries, newest first. NextCursor is
+// passed back as ?cursor= for the following page and is empty on the last.
+type AdjustmentPage struct {
+	Adjustments []AdjustmentEntry `json:"adjustments"`
+	NextCursor  string            `json:"next_cursor,omitempty"`
+}
+
+// StockDrift is an ingredient whose stored stock differs from the sum of its
+// adjustment ledger. Drift is Stock minus LedgerStock.
+type StockDrift struct {
+	IngredientID   int64   `json:"ingredient_id"`
+	IngredientName string  `json:"ingredient_name"`
+	Unit           string  `json:"unit"`
+	Stock          float64 `json:"stock"`
+	LedgerStock    float64 `json:"ledger_stock"`
+	Drift          float64 `json:"drift"`
+}
+
+// ReservationRequest holds the stock needed for Lines: finished goods on
+// hand first, then the ingredients to make the rest. An active hold lasts
+// until it is confirmed, released or expires; a confirmed one until its
+// order is filled. Reference identifies the hold to its owner (a cart, or
+// "order-<id>" for an order, which is how the kitchen's allocations and
+// batches find it) and makes creation idempotent; TTL is a duration such as
+// "15m".
+type ReservationRequest struct {
+	Reference string            `json:"reference"`
+	Lines     []ProductQuantity `json:"lines"`
+	TTL       string            `json:"ttl,omitempty"`
+	CreatedBy string            `json:"created_by,omitempty"`
+}
+
+// Reservation is a hold on stock. Status is one of the reservations.Status
+// values; Lines are the ingredients reserved to make the products not
+// promised from finished goods, in their stock units.
+type Reservation struct {
+	ID        int64                   `json:"id"`
+	Reference string                  `json:"reference"`
+	Status    string                  `json:"status"`
+	Products  []ReservedProduct       `json:"products"`
+	Lines     []IngredientRequirement `json:"lines"`
+	CreatedBy string                  `json:"created_by,omitempty"`
+	ExpiresAt time.Time               `json:"expires_at"`
+	CreatedAt time.Time               `json:"created_at"`
+	UpdatedAt time.Time               `json:"updated_at"`
+}
+
+// ReservedProduct is one product of a reservation: FromStock of its
+// Quantity was promised from finished goods, and Filled units have been
+// delivered to the order so far.
+type ReservedProduct struct {
+	ProductID int64   `json:"product_id"`
+	Quantity  float64 `json:"quantity"`
+	FromStock float64 `json:"from_stock"`
+	Filled    float64 `json:"filled"`
+}
+
+// IngredientAvailability is an ingredient's available-to-promise: its stock
+// less what reservations hold.
+type IngredientAvailability struct {
+	IngredientID   int64   `json:"ingredient_id"`
+	IngredientName string  `json:"ingredient_name"`
+	Unit           string  `json:"unit"`
+	Stock          float64 `json:"stock"`
+	Held           float64 `json:"held"`
+	Available      float64 `json:"available"`
+}
@@ -0,0 +1,50 @@
+// Package products is a small HTTP client for the products service's
+// internal endpoints, used to check that product ids inventory stores still
+// exist.
+package products
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Client talks to the products service over HTTP.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// New creates a client for the products service rooted at baseURL
+// (e.g. "http://products:8082").
+func New(baseURL string) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// ProductIDs returns the id of every product.
+func (c *Client) ProductIDs(ctx context.Context) ([]int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/internal/product-ids", nil)
+	if err != nil {
+		return nil, fmt.Errorf("ProductIDs build request: %w", err)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ProductIDs request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ProductIDs: unexpected status %d", resp.StatusCode)
+	}
+	var ids []int64
+	if err := json.NewDecoder(resp.Body).Decode(&ids); err != nil {
+		return nil, fmt.Errorf("ProductIDs decode: %w", err)
+	}
+	return ids, nil
+}
@@ -0,0 +1,115 @@
+// Package purchasing holds the rules for receiving purchase orders: how a
+// line's received quantity compares with what was ordered, and which status a
+// purchase order moves to as receipts come in. Quantities are compared with
+// a small tolerance so unit conversions do not leave a line a rounding error
+// short.
+package purchasing
+
+import (
+	"errors"
+	"math"
+)
+
+// epsilon is how close a received quantity must be to the ordered quantity
+// to count as exactly received.
+const epsilon = 1e-9
+
+// Status is the lifecycle state of a purchase order.
+type Status string
+
+const (
+	StatusDraft             Status = "draft"   // being prepared; lines can still change
+	StatusOrdered           Status = "ordered" // sent to the supplier
+	StatusPartiallyReceived Status = "partially_received"
+	StatusReceived          Status = "received"  // every line received in full or more
+	StatusClosed            Status = "closed"    // closed by hand with lines still short
+	StatusCancelled         Status = "cancelled" // abandoned before anything was received
+)
+
+// LineStatus compares a line's received quantity with what was ordered.
+type LineStatus string
+
+const (
+	LinePending  LineStatus = "pending" // nothing received yet
+	LinePartial  LineStatus = "partial" // under-received so far
+	LineComplete LineStatus = "complete"
+	LineOver     LineStatus = "over" // more arrived than was ordered
+)
+
+// ErrTransition is returned for a status change the purchase order's current
+// status does not allow.
+var ErrTransition = errors.New("purchase order status does not allow this")
+
+// Line returns the receipt status of a line that ordered ordered and has
+// received received.
+func Line(ordered, received float64) LineStatus {
+	switch {
+	case received <= epsilon:
+		return LinePending
+	case received < ordered-epsilon:
+		return LinePartial
+	case received > ordered+epsilon:
+		return LineOver
+	default:
+		return LineComplete
+	}
+}
+
+// Outstanding is how much of a line is still to come; zero once the line is
+// complete or over-received.
+func Outstanding(ordered, received float64) float64 {
+	if math.Abs(ordered-received) <= epsilon {
+		return 0
+	}
+	return math.Max(ordered-received, 0)
+}
+
+// OverReceived is how much more than ordered a line received; zero unless
+// the line is over-received.
+func OverReceived(ordered, received float64) float64 {
+	if math.Abs(ordered-received) <= epsilon {
+		return 0
+	}
+	return math.Max(received-ordered, 0)
+}
+
+// Quantities is the ordered and received quantity of one line.
+type Quantities struct {
+	Ordered  float64
+	Received float64
+}
+
+// AfterReceipt returns the status of a purchase order in status current once
+// its lines have received the given quantities. Receipts are accepted only
+// once an order has been placed; an order that was already received in full
+// accepts further (over-)receipts.
+func AfterReceipt(current Status, lines []Quantities) (Status, error) {
+	switch current {
+	case StatusOrdered, StatusPartiallyReceived, StatusReceived:
+	default:
+		return current, ErrTransition
+	}
+	for _, l := range lines {
+		if s := Line(l.Ordered, l.Received); s == LinePending || s == LinePartial {
+			return StatusPartiallyReceived, nil
+		}
+	}
+	return StatusReceived, nil
+}
+
+// Transition checks a manual status change. Drafts are submitted as
+// ordered; orders are cancelled only before anything arrives and closed only
+// once something has, accepting whatever is still short.
+func Transition(current, next Status) error {
+	allowed := map[Status][]Status{
+		StatusOrdered:   {StatusDraft},
+		StatusCancelled: {StatusDraft, StatusOrdered},
+		StatusClosed:    {StatusPartiallyReceived},
+	}
+	for _, from := range allowed[next] {
+		if current == from {
+			return nil
+		}
+	}
+	return ErrTransition
+}
@@ -0,0 +1,87 @@
+package purchasing
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLine(t *testing.T) {
+	tests := []struct {
+		ordered, received float64
+		want              LineStatus
+		outstanding, over float64
+	}{
+		{ordered: 10, received: 0, want: LinePending, outstanding: 10},
+		{ordered: 10, received: 4, want: LinePartial, outstanding: 6},
+		{ordered: 10, received: 10, want: LineComplete},
+		{ordered: 10, received: 10 - 1e-12, want: LineComplete},
+		{ordered: 10, received: 12.5, want: LineOver, over: 2.5},
+	}
+	for _, tc := range tests {
+		if got := Line(tc.ordered, tc.received); got != tc.want {
+			t.Errorf("Line(%v, %v): got %s want %s", tc.ordered, tc.received, got, tc.want)
+		}
+		if got := Outstanding(tc.ordered, tc.received); got != tc.outstanding {
+			t.Errorf("Outstanding(%v, %v): got %v want %v", tc.ordered, tc.received, got, tc.outstanding)
+		}
+		if got := OverReceived(tc.ordered, tc.received); got != tc.over {
+			t.Errorf("OverReceived(%v, %v): got %v want %v", tc.ordered, tc.received, got, tc.over)
+		}
+	}
+}
+
+func TestAfterReceipt(t *testing.T) {
+	tests := []struct {
+		name    string
+		current Status
+		lines   []Quantities
+		want    Status
+		wantErr bool
+	}{
+		{name: "partial", current: StatusOrdered, lines: []Quantities{{10, 10}, {5, 2}}, want: StatusPartiallyReceived},
+		{name: "untouched line keeps it partial", current: StatusOrdered, lines: []Quantities{{10, 10}, {5, 0}}, want: StatusPartiallyReceived},
+		{name: "completes", current: StatusPartiallyReceived, lines: []Quantities{{10, 10}, {5, 5}}, want: StatusReceived},
+		{name: "over counts as received", current: StatusOrdered, lines: []Quantities{{10, 11}, {5, 5}}, want: StatusReceived},
+		{name: "more after received", current: StatusReceived, lines: []Quantities{{10, 12}}, want: StatusReceived},
+		{name: "draft", current: StatusDraft, lines: []Quantities{{10, 10}}, wantErr: true},
+		{name: "closed", current: StatusClosed, lines: []Quantities{{10, 10}}, wantErr: true},
+		{name: "cancelled", current: StatusCancelled, lines: []Quantities{{10, 10}}, wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := AfterReceipt(tc.current, tc.lines)
+			if tc.wantErr {
+				if !errors.Is(err, ErrTransition) {
+					t.Fatalf("AfterReceipt: got %v, want ErrTransition", err)
+				}
+				return
+			}
+			if err != nil || got != tc.want {
+				t.Fatalf("AfterReceipt: got %s, %v; want %s", got, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestTransition(t *testing.T) {
+	tests := []struct {
+		from, to Status
+		ok       bool
+	}{
+		{StatusDraft, StatusOrdered, true},
+		{StatusOrdered, StatusOrdered, false},
+		{StatusDraft, StatusCancelled, true},
+		{StatusOrdered, StatusCancelled, true},
+		{StatusPartiallyReceived, StatusCancelled, false},
+		{StatusPartiallyReceived, StatusClosed, true},
+		{StatusOrdered, StatusClosed, false},
+		{StatusReceived, StatusClosed, false},
+		{StatusOrdered, StatusReceived, false},
+	}
+	for _, tc := range tests {
+		err := Transition(tc.from, tc.to)
+		if (err == nil) != tc.ok {
+			t.Errorf("Transition(%s, %s): got %v, want ok=%v", tc.from, tc.to, err, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// Package reconcile finds inventory rows that point at products the products
+// service no longer has. Each service owns its own schema, so product ids in
+// recipe items and finished goods cannot be protected by a foreign key; the
+// reconciler checks them periodically instead and keeps the latest report
+// for the admin endpoint. It only reports; cleaning up is left to an admin.
+package reconcile
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"com.MixieMelts.inventory/internal/models"
+)
+
+// Store reads the product ids inventory references.
+type Store interface {
+	ProductReferences(ctx context.Context) ([]models.ProductReference, error)
+}
+
+// Products lists the products that exist.
+type Products interface {
+	ProductIDs(ctx context.Context) ([]int64, error)
+}
+
+// Reconciler checks inventory's product references against the products
+// service.
+type Reconciler struct {
+	store    Store
+	products Products
+	now      func() time.Time
+
+	mu     sync.Mutex
+	latest *models.IntegrityReport
+}
+
+// New creates a Reconciler.
+func New(store Store, products Products) *Reconciler {
+	return &Reconciler{store: store, products: products, now: time.Now}
+}
+
+// Check compares references against products now, remembers the report as
+// the latest and returns it. References are read before products are listed,
+// so a product created during the check is never reported as missing.
+func (r *Reconciler) Check(ctx context.Context) (*models.IntegrityReport, error) {
+	refs, err := r.store.ProductReferences(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := r.products.ProductIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	exists := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		exists[id] = true
+	}
+	report := &models.IntegrityReport{
+		CheckedAt:       r.now(),
+		ProductsChecked: len(ids),
+		Orphans:         []models.ProductReference{},
+	}
+	for _, ref := range refs {
+		if !exists[ref.ProductID] {
+			report.Orphans = append(report.Orphans, ref)
+		}
+	}
+
+	r.mu.Lock()
+	r.latest = report
+	r.mu.Unlock()
+	return report, nil
+}
+
+// Latest returns the report of the last successful check, or nil if none
+// has completed.
+func (r *Reconciler) Latest() *models.IntegrityReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.latest
+}
+
+// Run calls Check every interval until ctx is cancelled.
+func (r *Reconciler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := r.Check(ctx)
+		if err != nil {
+			log.Printf("reconcile: check failed: %v", err)
+		} else if len(report.Orphans) > 0 {
+			log.Printf("reconcile: %d products referenced by inventory no longer exist", len(report.Orphans))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
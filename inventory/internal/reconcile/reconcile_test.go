@@ -0,0 +1,104 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"com.MixieMelts.inventory/internal/models"
+)
+
+type fakeStore struct {
+	refs []models.ProductReference
+	err  error
+}
+
+func (f fakeStore) ProductReferences(ctx context.Context) ([]models.ProductReference, error) {
+	return f.refs, f.err
+}
+
+type fakeProducts struct {
+	ids []int64
+	err error
+}
+
+func (f fakeProducts) ProductIDs(ctx context.Context) ([]int64, error) {
+	return f.ids, f.err
+}
+
+func intPtr(n int) *int { return &n }
+
+func TestCheck(t *testing.T) {
+	refs := []models.ProductReference{
+		{ProductID: 1, RecipeItems: 3, FinishedGoods: intPtr(4)},
+		{ProductID: 2, RecipeItems: 2},
+		{ProductID: 7, FinishedGoods: intPtr(0)},
+	}
+
+	tests := []struct {
+		name        string
+		products    []int64
+		wantOrphans []int64
+	}{
+		{name: "all exist", products: []int64{1, 2, 7, 9}, wantOrphans: nil},
+		{name: "recipe orphaned", products: []int64{1, 7}, wantOrphans: []int64{2}},
+		{name: "finished goods orphaned", products: []int64{1, 2}, wantOrphans: []int64{7}},
+		{name: "no products", products: []int64{}, wantOrphans: []int64{1, 2, 7}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+			r := New(fakeStore{refs: refs}, fakeProducts{ids: tc.products})
+			r.now = func() time.Time { return now }
+
+			report, err := r.Check(context.Background())
+			if err != nil {
+				t.Fatalf("Check: %v", err)
+			}
+			var got []int64
+			for _, o := range report.Orphans {
+				got = append(got, o.ProductID)
+			}
+			if !reflect.DeepEqual(got, tc.wantOrphans) {
+				t.Fatalf("orphans: got %v want %v", got, tc.wantOrphans)
+			}
+			if report.ProductsChecked != len(tc.products) || !report.CheckedAt.Equal(now) {
+				t.Fatalf("report header: got %d at %v", report.ProductsChecked, report.CheckedAt)
+			}
+			if r.Latest() != report {
+				t.Fatalf("Latest did not return the new report")
+			}
+		})
+	}
+}
+
+func TestCheckErrorKeepsLatest(t *testing.T) {
+	r := New(fakeStore{refs: []models.ProductReference{{ProductID: 1, RecipeItems: 1}}}, fakeProducts{ids: []int64{}})
+	first, err := r.Check(context.Background())
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+
+	r.products = fakeProducts{err: errors.New("products down")}
+	if _, err := r.Check(context.Background()); err == nil {
+		t.Fatalf("Check: expected error when products is unreachable")
+	}
+	if r.Latest() != first {
+		t.Fatalf("Latest: a failed check replaced the previous report")
+	}
+
+	r.store = fakeStore{err: errors.New("db down")}
+	if _, err := r.Check(context.Background()); err == nil {
+		t.Fatalf("Check: expected error when the store fails")
+	}
+}
+
+func TestLatestBeforeCheck(t *testing.T) {
+	r := New(fakeStore{}, fakeProducts{})
+	if r.Latest() != nil {
+		t.Fatalf("Latest: expected nil before the first check")
+	}
+}
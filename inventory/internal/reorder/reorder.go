@@ -0,0 +1,60 @@
+// Package reorder proposes when and how much of an ingredient to buy. Usage
+// is the average daily consumption over a trailing window of the adjustment
+// ledger. An ingredient needs ordering once the stock it has, plus what is
+// already on order, would fall below its MinThreshold before a new delivery
+// could arrive; the suggested quantity then covers the lead time and a
+// further number of days of usage on top of the threshold.
+package reorder
+
+import (
+	"math"
+	"time"
+)
+
+// Input describes one ingredient. Quantities are in its stock unit.
+type Input struct {
+	Stock        float64 // on hand
+	OnOrder      float64 // outstanding on submitted purchase orders
+	Threshold    float64 // MinThreshold; the stock to keep in reserve
+	Consumed     float64 // net usage over the window
+	WindowDays   int     // length of the trailing window
+	LeadTimeDays int     // how long a delivery takes to arrive
+	CoverDays    int     // days of usage an order should cover beyond the lead time
+}
+
+// Suggestion is the outcome for one ingredient.
+type Suggestion struct {
+	DailyUsage   float64    // average usage per day over the window
+	DaysLeft     *float64   // days until stock runs out at that rate; nil without usage
+	StockOutDate *time.Time // now plus DaysLeft; nil without usage
+	ReorderPoint float64    // Threshold plus usage over the lead time
+	Quantity     float64    // how much to order, rounded up to hundredths; zero when no order is needed
+}
+
+// Reorder reports whether the suggestion proposes an order.
+func (s Suggestion) Reorder() bool {
+	return s.Quantity > 0
+}
+
+// Suggest computes the suggestion for in as of now. Stock below zero is
+// treated as none, and a window of zero days or less as no usage.
+func Suggest(in Input, now time.Time) Suggestion {
+	var s Suggestion
+	if in.WindowDays > 0 && in.Consumed > 0 {
+		s.DailyUsage = in.Consumed / float64(in.WindowDays)
+	}
+	stock := math.Max(in.Stock, 0)
+	if s.DailyUsage > 0 {
+		days := stock / s.DailyUsage
+		out := now.Add(time.Duration(days * float64(24*time.Hour)))
+		s.DaysLeft, s.StockOutDate = &days, &out
+	}
+
+	s.ReorderPoint = math.Max(in.Threshold, 0) + s.DailyUsage*float64(max(in.LeadTimeDays, 0))
+	position := stock + math.Max(in.OnOrder, 0)
+	if s.ReorderPoint > 0 && position <= s.ReorderPoint {
+		target := s.ReorderPoint + s.DailyUsage*float64(max(in.CoverDays, 0))
+		s.Quantity = math.Ceil((target-position)*100) / 100
+	}
+	return s
+}
@@ -0,0 +1,86 @@
+package reorder
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSuggest(t *testing.T) {
+	now := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		in           Input
+		wantUsage    float64
+		wantDaysLeft float64 // -1 for no projection
+		wantPoint    float64
+		wantQty      float64
+	}{
+		{
+			name:         "no usage above threshold",
+			in:           Input{Stock: 10, Threshold: 5, WindowDays: 30},
+			wantDaysLeft: -1, wantPoint: 5,
+		},
+		{
+			name:         "no usage below threshold orders back up to it",
+			in:           Input{Stock: 2, Threshold: 5, WindowDays: 30, CoverDays: 30},
+			wantDaysLeft: -1, wantPoint: 5, wantQty: 3,
+		},
+		{
+			name:      "usage reaches reorder point within lead time",
+			in:        Input{Stock: 20, Threshold: 5, Consumed: 60, WindowDays: 30, LeadTimeDays: 10, CoverDays: 14},
+			wantUsage: 2, wantDaysLeft: 10, wantPoint: 25, wantQty: 33,
+		},
+		{
+			name:      "on order covers the reorder point",
+			in:        Input{Stock: 20, OnOrder: 10, Threshold: 5, Consumed: 60, WindowDays: 30, LeadTimeDays: 10, CoverDays: 14},
+			wantUsage: 2, wantDaysLeft: 10, wantPoint: 25,
+		},
+		{
+			name:      "plenty of stock",
+			in:        Input{Stock: 100, Consumed: 30, WindowDays: 30, LeadTimeDays: 7, CoverDays: 30},
+			wantUsage: 1, wantDaysLeft: 100, wantPoint: 7,
+		},
+		{
+			name:      "negative stock counts as none",
+			in:        Input{Stock: -4, Consumed: 30, WindowDays: 30, LeadTimeDays: 2, CoverDays: 5},
+			wantUsage: 1, wantDaysLeft: 0, wantPoint: 2, wantQty: 7,
+		},
+		{
+			name:         "no threshold and no usage never orders",
+			in:           Input{Stock: 0, WindowDays: 30, LeadTimeDays: 7},
+			wantDaysLeft: -1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Suggest(tt.in, now)
+			if s.DailyUsage != tt.wantUsage {
+				t.Errorf("DailyUsage = %v, want %v", s.DailyUsage, tt.wantUsage)
+			}
+			if s.ReorderPoint != tt.wantPoint {
+				t.Errorf("ReorderPoint = %v, want %v", s.ReorderPoint, tt.wantPoint)
+			}
+			if s.Quantity != tt.wantQty {
+				t.Errorf("Quantity = %v, want %v", s.Quantity, tt.wantQty)
+			}
+			if s.Reorder() != (tt.wantQty > 0) {
+				t.Errorf("Reorder() = %v", s.Reorder())
+			}
+			if tt.wantDaysLeft < 0 {
+				if s.DaysLeft != nil || s.StockOutDate != nil {
+					t.Errorf("got a stock-out projection, want none")
+				}
+				return
+			}
+			if s.DaysLeft == nil || *s.DaysLeft != tt.wantDaysLeft {
+				t.Fatalf("DaysLeft = %v, want %v", s.DaysLeft, tt.wantDaysLeft)
+			}
+			want := now.AddDate(0, 0, int(tt.wantDaysLeft))
+			if !s.StockOutDate.Equal(want) {
+				t.Errorf("StockOutDate = %v, want %v", s.StockOutDate, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+// Package reservations holds the rules for stock holds: how long a hold
+// lasts, when it still counts against available-to-promise, how a hold is
+// split between finished goods and ingredients, and a job that marks lapsed
+// holds expired. An active hold counts while its expiry is in the future, so
+// an overdue hold stops blocking stock the moment it lapses; the job only
+// tidies its status. A confirmed hold belongs to a placed order, which has
+// consumed the ingredients it held; it keeps the finished goods promised to
+// the order until the kitchen has filled it.
+package reservations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Status is the lifecycle state of a reservation.
+type Status string
+
+const (
+	StatusActive    Status = "active"    // holding stock until it expires
+	StatusConfirmed Status = "confirmed" // ingredients consumed; holding finished goods until it is filled
+	StatusFilled    Status = "filled"    // every product delivered by allocations or batches
+	StatusReleased  Status = "released"  // given up by its owner
+	StatusExpired   Status = "expired"   // lapsed before it was confirmed
+)
+
+// Hold durations.
+const (
+	DefaultTTL = 15 * time.Minute
+	MaxTTL     = 24 * time.Hour
+)
+
+// ErrInvalidTTL is returned for a hold duration that is not positive or
+// exceeds MaxTTL.
+var ErrInvalidTTL = errors.New("invalid ttl")
+
+// ParseTTL parses a hold duration such as "30m". An empty string is
+// DefaultTTL.
+func ParseTTL(s string) (time.Duration, error) {
+	if s == "" {
+		return DefaultTTL, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 || d > MaxTTL {
+		return 0, fmt.Errorf("%w: %q must be a duration up to %s", ErrInvalidTTL, s, MaxTTL)
+	}
+	return d, nil
+}
+
+// Holding reports whether a reservation with the given status and expiry
+// still holds stock at now.
+func Holding(status Status, expiresAt, now time.Time) bool {
+	return status == StatusConfirmed || status == StatusActive && now.Before(expiresAt)
+}
+
+// Split divides a held quantity of a product between finished goods and
+// ingredients: as much as possible is promised from the finished units
+// available, and the rest has to be made.
+func Split(quantity, available float64) (fromStock, toMake float64) {
+	fromStock = max(min(quantity, available), 0)
+	return fromStock, quantity - fromStock
+}
+
+// Made returns how many of fill units delivered to a held product cover
+// units that were to be made rather than taken from finished goods, given
+// the product's held quantity, how much of it was promised from stock and
+// how much was filled before. Fills cover the units promised from stock
+// first.
+func Made(quantity, fromStock, filled, fill float64) float64 {
+	return max(min(filled+fill, quantity)-max(fromStock, filled), 0)
+}
+
+// Store marks lapsed reservations expired.
+type Store interface {
+	ExpireReservations(ctx context.Context) (int, error)
+}
+
+// Expirer marks lapsed reservations expired on a schedule.
+type Expirer struct {
+	store Store
+}
+
+// NewExpirer creates an Expirer.
+func NewExpirer(store Store) *Expirer {
+	return &Expirer{store: store}
+}
+
+// Run expires lapsed reservations every interval until ctx is cancelled.
+func (e *Expirer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := e.store.ExpireReservations(ctx)
+		if err != nil {
+			log.Printf("reservations: expiry failed: %v", err)
+		} else if n > 0 {
+			log.Printf("reservations: %d reservations expired", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package reservations
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseTTL(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{in: "", want: DefaultTTL},
+		{in: "30m", want: 30 * time.Minute},
+		{in: "24h", want: MaxTTL},
+		{in: "25h", wantErr: true},
+		{in: "0s", wantErr: true},
+		{in: "-5m", wantErr: true},
+		{in: "soon", wantErr: true},
+	}
+	for _, tc := range tests {
+		got, err := ParseTTL(tc.in)
+		if tc.wantErr {
+			if !errors.Is(err, ErrInvalidTTL) {
+				t.Errorf("ParseTTL(%q): got err %v, want ErrInvalidTTL", tc.in, err)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("ParseTTL(%q): got %v, %v want %v", tc.in, got, err, tc.want)
+		}
+	}
+}
+
+func TestHolding(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		status    Status
+		expiresAt time.Time
+		want      bool
+	}{
+		{name: "active", status: StatusActive, expiresAt: now.Add(time.Minute), want: true},
+		{name: "lapsed but not yet marked", status: StatusActive, expiresAt: now.Add(-time.Second)},
+		{name: "expires now", status: StatusActive, expiresAt: now},
+		{name: "confirmed", status: StatusConfirmed, expiresAt: now.Add(time.Hour), want: true},
+		{name: "confirmed past expiry", status: StatusConfirmed, expiresAt: now.Add(-time.Hour), want: true},
+		{name: "filled", status: StatusFilled, expiresAt: now.Add(time.Hour)},
+		{name: "released", status: StatusReleased, expiresAt: now.Add(time.Hour)},
+		{name: "expired", status: StatusExpired, expiresAt: now.Add(-time.Hour)},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Holding(tc.status, tc.expiresAt, now); got != tc.want {
+				t.Fatalf("Holding: got %v want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name          string
+		quantity      float64
+		available     float64
+		wantFromStock float64
+		wantToMake    float64
+	}{
+		{name: "all on hand", quantity: 3, available: 10, wantFromStock: 3},
+		{name: "part on hand", quantity: 5, available: 2, wantFromStock: 2, wantToMake: 3},
+		{name: "none on hand", quantity: 4, wantToMake: 4},
+		{name: "over-promised stock", quantity: 4, available: -2, wantToMake: 4},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fromStock, toMake := Split(tc.quantity, tc.available)
+			if fromStock != tc.wantFromStock || toMake != tc.wantToMake {
+				t.Fatalf("Split: got %v, %v want %v, %v", fromStock, toMake, tc.wantFromStock, tc.wantToMake)
+			}
+		})
+	}
+}
+
+func TestMade(t *testing.T) {
+	tests := []struct {
+		name      string
+		quantity  float64
+		fromStock float64
+		filled    float64
+		fill      float64
+		want      float64
+	}{
+		{name: "nothing from stock", quantity: 4, fill: 4, want: 4},
+		{name: "within what stock covers", quantity: 5, fromStock: 3, fill: 2},
+		{name: "past what stock covers", quantity: 5, fromStock: 3, fill: 4, want: 1},
+		{name: "after earlier fills", quantity: 5, fromStock: 2, filled: 3, fill: 1, want: 1},
+		{name: "more than was held", quantity: 5, fromStock: 1, filled: 2, fill: 10, want: 3},
+		{name: "already filled", quantity: 5, filled: 5, fill: 2},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Made(tc.quantity, tc.fromStock, tc.filled, tc.fill); got != tc.want {
+				t.Fatalf("Made: got %v want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+// Package units normalizes the units ingredients are stocked and measured in
+// and converts amounts between units of the same dimension.
+package units
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrUnknownUnit is returned for a unit this package does not recognise.
+	ErrUnknownUnit = errors.New("unknown unit")
+	// ErrIncompatible is returned when converting between units of different
+	// dimensions, such as grams to millilitres.
+	ErrIncompatible = errors.New("incompatible units")
+)
+
+// Dimension is the kind of quantity a unit measures.
+type Dimension string
+
+const (
+	Mass   Dimension = "mass"
+	Volume Dimension = "volume"
+	Count  Dimension = "count"
+)
+
+// Unit is a unit of measure. ToBase is the size of one Unit in its
+// dimension's base unit: grams for mass, millilitres for volume and single
+// items for count.
+type Unit struct {
+	Code      string    `json:"code"`
+	Dimension Dimension `json:"dimension"`
+	ToBase    float64   `json:"to_base"`
+}
+
+var (
+	Gram        = Unit{Code: "g", Dimension: Mass, ToBase: 1}
+	Kilogram    = Unit{Code: "kg", Dimension: Mass, ToBase: 1000}
+	Ounce       = Unit{Code: "oz", Dimension: Mass, ToBase: 28.349523125}
+	Pound       = Unit{Code: "lb", Dimension: Mass, ToBase: 453.59237}
+	Millilitre  = Unit{Code: "mL", Dimension: Volume, ToBase: 1}
+	Litre       = Unit{Code: "L", Dimension: Volume, ToBase: 1000}
+	FluidOunce  = Unit{Code: "fl oz", Dimension: Volume, ToBase: 29.5735295625}
+	Drop        = Unit{Code: "drops", Dimension: Volume, ToBase: 0.05}
+	Each        = Unit{Code: "count", Dimension: Count, ToBase: 1}
+	all         = []Unit{Gram, Kilogram, Ounce, Pound, Millilitre, Litre, FluidOunce, Drop, Each}
+	byAlias     = map[string]Unit{}
+	unitAliases = map[string][]string{
+		"g":     {"gram", "grams"},
+		"kg":    {"kilogram", "kilograms"},
+		"oz":    {"ounce", "ounces"},
+		"lb":    {"lbs", "pound", "pounds"},
+		"mL":    {"millilitre", "millilitres", "milliliter", "milliliters"},
+		"L":     {"litre", "litres", "liter", "liters"},
+		"fl oz": {"floz", "fl. oz", "fluid ounce", "fluid ounces"},
+		"drops": {"drop", "gtt"},
+		"count": {"ct", "each", "ea", "pc", "pcs", "piece", "pieces", "unit", "units"},
+	}
+)
+
+func init() {
+	for _, u := range all {
+		byAlias[strings.ToLower(u.Code)] = u
+		for _, a := range unitAliases[u.Code] {
+			byAlias[a] = u
+		}
+	}
+}
+
+// All returns every supported unit in canonical form.
+func All() []Unit {
+	return append([]Unit(nil), all...)
+}
+
+// Aliases returns every spelling Parse accepts, lower-cased, with the unit it
+// stands for.
+func Aliases() map[string]Unit {
+	out := make(map[string]Unit, len(byAlias))
+	for a, u := range byAlias {
+		out[a] = u
+	}
+	return out
+}
+
+// Parse looks up a unit by code or alias, ignoring case and surrounding
+// space.
+func Parse(s string) (Unit, error) {
+	key := strings.Join(strings.Fields(strings.ToLower(s)), " ")
+	u, ok := byAlias[key]
+	if !ok {
+		return Unit{}, fmt.Errorf("%w: %q (want one of %s)", ErrUnknownUnit, s, strings.Join(codes(), ", "))
+	}
+	return u, nil
+}
+
+// Normalize returns the canonical code for a unit, e.g. "grams" becomes "g".
+func Normalize(s string) (string, error) {
+	u, err := Parse(s)
+	if err != nil {
+		return "", err
+	}
+	return u.Code, nil
+}
+
+// Compatible reports whether amounts in from can be converted to to.
+func Compatible(from, to string) error {
+	_, err := Convert(1, from, to)
+	return err
+}
+
+// Convert expresses amount, measured in from, in to. Both units must
+// measure the same dimension.
+func Convert(amount float64, from, to string) (float64, error) {
+	f, err := Parse(from)
+	if err != nil {
+		return 0, err
+	}
+	t, err := Parse(to)
+	if err != nil {
+		return 0, err
+	}
+	if f.Dimension != t.Dimension {
+		return 0, fmt.Errorf("%w: %s (%s) and %s (%s)", ErrIncompatible, f.Code, f.Dimension, t.Code, t.Dimension)
+	}
+	if f.Code == t.Code {
+		return amount, nil
+	}
+	return amount * f.ToBase / t.ToBase, nil
+}
+
+func codes() []string {
+	out := make([]string, len(all))
+	for i, u := range all {
+		out[i] = u.Code
+	}
+	return out
+}
@@ -0,0 +1,72 @@
+package units
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr error
+	}{
+		{in: "g", want: "g"},
+		{in: "KG", want: "kg"},
+		{in: " Grams ", want: "g"},
+		{in: "lbs", want: "lb"},
+		{in: "ml", want: "mL"},
+		{in: "mL", want: "mL"},
+		{in: "l", want: "L"},
+		{in: "fl  oz", want: "fl oz"},
+		{in: "Fluid Ounces", want: "fl oz"},
+		{in: "drop", want: "drops"},
+		{in: "pcs", want: "count"},
+		{in: "", wantErr: ErrUnknownUnit},
+		{in: "cups", wantErr: ErrUnknownUnit},
+	}
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			u, err := Parse(tc.in)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("Parse(%q) error = %v, want %v", tc.in, err, tc.wantErr)
+			}
+			if err == nil && u.Code != tc.want {
+				t.Fatalf("Parse(%q) = %q, want %q", tc.in, u.Code, tc.want)
+			}
+		})
+	}
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   float64
+		from, to string
+		want     float64
+		wantErr  error
+	}{
+		{name: "grams to kilograms", amount: 100, from: "g", to: "kg", want: 0.1},
+		{name: "kilograms to grams", amount: 2.5, from: "kg", to: "g", want: 2500},
+		{name: "pound to ounces", amount: 1, from: "lb", to: "oz", want: 16},
+		{name: "litre to millilitres", amount: 1, from: "L", to: "mL", want: 1000},
+		{name: "drops to millilitres", amount: 20, from: "drops", to: "mL", want: 1},
+		{name: "fluid ounce to millilitres", amount: 1, from: "fl oz", to: "ml", want: 29.5735295625},
+		{name: "same unit", amount: 7, from: "count", to: "each", want: 7},
+		{name: "mass to volume", amount: 1, from: "g", to: "mL", wantErr: ErrIncompatible},
+		{name: "count to mass", amount: 1, from: "count", to: "kg", wantErr: ErrIncompatible},
+		{name: "unknown", amount: 1, from: "tbsp", to: "mL", wantErr: ErrUnknownUnit},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Convert(tc.amount, tc.from, tc.to)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("Convert error = %v, want %v", err, tc.wantErr)
+			}
+			if err == nil && math.Abs(got-tc.want) > 1e-9 {
+				t.Fatalf("Convert(%v %s -> %s) = %v, want %v", tc.amount, tc.from, tc.to, got, tc.want)
+			}
+		})
+	}
+}
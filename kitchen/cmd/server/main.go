@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"com.MixieMelts.kitchen/internal/database"
+	"com.MixieMelts.kitchen/internal/handlers"
+	"com.MixieMelts.kitchen/internal/inventory"
+	"com.MixieMelts.kitchen/internal/notifier"
+	"com.MixieMelts.kitchen/internal/stream"
+	"com.MixieMelts.users/jwtverify"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/joho/godotenv"
+)
+
+// getenv returns the environment variable key, or fallback when it is unset.
+func getenv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func main() {
+	// Load .env file if present
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found or failed to load; falling back to environment variables")
+	}
+
+	// Create database connection
+	dbURL := os.Getenv("DATABASE_URL")
+	if dbURL == "" {
+		log.Fatal("DATABASE_URL must be set")
+	}
+
+	db, err := database.New(dbURL)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	inventoryClient := inventory.New(getenv("INVENTORY_URL", "http://inventory:8083"), os.Getenv("INTERNAL_API_TOKEN"))
+	hub := stream.NewHub(db)
+	n := notifier.New(db, inventoryClient, inventoryClient, hub)
+
+	// Pull lists that could not be built when an order arrived are retried
+	interval, err := time.ParseDuration(getenv("PULL_LIST_REFRESH_INTERVAL", "1m"))
+	if err != nil {
+		log.Fatalf("Invalid PULL_LIST_REFRESH_INTERVAL: %v", err)
+	}
+	go n.Run(context.Background(), interval)
+
+	// Tokens are verified against the users service's published keys
+	verifier, err := jwtverify.Configure(os.Getenv("JWKS_URL"), os.Getenv("JWT_SECRET_KEY"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	h := handlers.New(db, n, inventoryClient, hub, verifier)
+
+	// Router setup. The request timeout is applied per group so the ticket
+	// stream can stay open.
+	r := chi.NewRouter()
+	r.Use(handlers.Logger)
+	r.Use(middleware.Recoverer)
+
+	// Ticket routes - require a token granting the kitchen permission
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.Timeout(60 * time.Second))
+		r.Use(h.AuthMiddleware, jwtverify.Require(jwtverify.PermKitchen))
+		r.Get("/tickets", h.GetTickets)
+		r.Get("/tickets/{id}", h.GetTicket)
+		r.Post("/tickets/{id}/claim", h.ClaimTicket)
+		r.Post("/tickets/{id}/complete", h.CompleteTicket)
+		r.Post("/tickets/{id}/void", h.VoidTicket)
+	})
+
+	// Live ticket changes as server-sent events
+	r.With(h.StreamAuthMiddleware, jwtverify.Require(jwtverify.PermKitchen)).Get("/tickets/stream", h.StreamTickets)
+
+	// Internal routes for other services, which authenticate with the shared
+	// INTERNAL_API_TOKEN
+	internalToken := os.Getenv("INTERNAL_API_TOKEN")
+	if internalToken == "" {
+		log.Println("INTERNAL_API_TOKEN is not set; internal routes will refuse every request")
+	}
+	r.With(middleware.Timeout(60*time.Second), handlers.RequireServiceToken(internalToken)).Post("/internal/events", h.ReceiveEvent)
+
+	// Health check
+	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	})
+
+	// Start server
+	port := getenv("PORT", "8087")
+
+	log.Printf("Starting kitchen service on port %s", port)
+	if err := http.ListenAndServe(":"+port, r); err != nil {
+		log.Fatalf("Failed to start server: %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"com.MixieMelts.kitchen/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+func (db *DB) createBatchesTable(ctx context.Context) error {
+	query := `CREATE TABLE IF NOT EXISTS batches (
+		id TEXT PRIMARY KEY,
+		ticket_id BIGINT NOT NULL UNIQUE REFERENCES tickets(id) ON DELETE CASCADE,
+		product_id BIGINT NOT NULL,
+		quantity INTEGER NOT NULL CHECK (quantity > 0),
+		completed_by TEXT NOT NULL,
+		completed_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE SEQUENCE IF NOT EXISTS batch_attempts;`
+	_, err := db.Exec(ctx, query)
+	return err
+}
+
+// ClaimTicket assigns a queued ticket to userID and starts it.
+func (db *DB) ClaimTicket(ctx context.Context, id int64, userID string) (*models.Ticket, error) {
+	return db.transition(ctx, "ClaimTicket", id, func(ctx context.Context, tx pgx.Tx, t *models.Ticket) error {
+		if t.Quantity == 0 {
+			return fmt.Errorf("%w: ticket has nothing to make", models.ErrInvalidTransition)
+		}
+		return t.Claim(userID, time.Now())
+	})
+}
+
+// StartBatch prepares a batch for a claimed ticket without locking it, so
+// the batch can be booked elsewhere before the ticket is completed. The
+// batch gets a new id for this attempt and batch.Quantity defaults to the
+// ticket's quantity. The returned ticket has its orders loaded. It returns
+// nil if the ticket does not exist.
+func (db *DB) StartBatch(ctx context.Context, id int64, batch models.Batch) (*models.Ticket, *models.Batch, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("StartBatch begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var t models.Ticket
+	err = scanTicket(tx.QueryRow(ctx, `SELECT `+ticketColumns+` FROM tickets WHERE id = $1`, id), &t)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("StartBatch select: %w", err)
+	}
+	// CompleteTicket checks again under the lock; this only spares inventory
+	// a booking that could never be kept.
+	check := t
+	if err := check.Complete(time.Now()); err != nil {
+		return nil, nil, fmt.Errorf("StartBatch: %w", err)
+	}
+
+	// Sequence values are not rolled back, so a failed attempt never hands
+	// its id to the next one.
+	var attempt int64
+	if err := tx.QueryRow(ctx, `SELECT nextval('batch_attempts')`).Scan(&attempt); err != nil {
+		return nil, nil, fmt.Errorf("StartBatch next attempt: %w", err)
+	}
+	batch.ID = models.BatchID(t.ID, attempt)
+	batch.TicketID, batch.ProductID = t.ID, t.ProductID
+	if batch.Quantity == 0 {
+		batch.Quantity = t.Quantity
+	}
+	if t.Orders, err = ticketOrdersTx(ctx, tx, t.ID); err != nil {
+		return nil, nil, fmt.Errorf("StartBatch: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, nil, fmt.Errorf("StartBatch commit: %w", err)
+	}
+	return &t, &batch, nil
+}
+
+// CompleteTicket marks a claimed ticket done and records the batch
+// StartBatch prepared for it, once that batch has been booked. If another
+// attempt completed or voided the ticket in the meantime, it fails with
+// models.ErrInvalidTransition and the caller should undo its booking.
+func (db *DB) CompleteTicket(ctx context.Context, id int64, batch models.Batch) (*models.Ticket, error) {
+	return db.transition(ctx, "CompleteTicket", id, func(ctx context.Context, tx pgx.Tx, t *models.Ticket) error {
+		if err := t.Complete(time.Now()); err != nil {
+			return err
+		}
+		err := tx.QueryRow(ctx, `INSERT INTO batches (id, ticket_id, product_id, quantity, completed_by) VALUES ($1,$2,$3,$4,$5)
+			RETURNING completed_at`, batch.ID, t.ID, t.ProductID, batch.Quantity, batch.CompletedBy).Scan(&batch.CompletedAt)
+		if err != nil {
+			return err
+		}
+		batch.TicketID, batch.ProductID = t.ID, t.ProductID
+		t.Batch = &batch
+		return nil
+	})
+}
+
+// VoidTicket abandons a ticket that has not been made.
+func (db *DB) VoidTicket(ctx context.Context, id int64) (*models.Ticket, error) {
+	return db.transition(ctx, "VoidTicket", id, func(ctx context.Context, tx pgx.Tx, t *models.Ticket) error {
+		return t.Void()
+	})
+}
+
+// transition locks a ticket, lets apply change it and saves the result in one
+// transaction. It returns nil if the ticket does not exist.
+func (db *DB) transition(ctx context.Context, op string, id int64, apply func(ctx context.Context, tx pgx.Tx, t *models.Ticket) error) (*models.Ticket, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s begin tx: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var t models.Ticket
+	err = scanTicket(tx.QueryRow(ctx, `SELECT `+ticketColumns+` FROM tickets WHERE id = $1 FOR UPDATE`, id), &t)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%s select: %w", op, err)
+	}
+
+	if err := apply(ctx, tx, &t); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = tx.QueryRow(ctx, `UPDATE tickets SET status = $1, claimed_by = $2, claimed_at = $3, completed_at = $4, updated_at = NOW()
+		WHERE id = $5 RETURNING updated_at`,
+		t.Status, t.ClaimedBy, t.ClaimedAt, t.CompletedAt, t.ID).Scan(&t.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("%s update: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("%s commit: %w", op, err)
+	}
+	if err := db.loadTicketDetails(ctx, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ticketOrdersTx returns a ticket's orders inside an existing transaction.
+func ticketOrdersTx(ctx context.Context, tx pgx.Tx, ticketID int64) ([]models.TicketOrder, error) {
+	rows, err := tx.Query(ctx, `SELECT order_id, quantity FROM ticket_orders WHERE ticket_id = $1 ORDER BY order_id`, ticketID)
+	if err != nil {
+		return nil, fmt.Errorf("ticket orders: %w", err)
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.TicketOrder, error) {
+		var o models.TicketOrder
+		return o, row.Scan(&o.OrderID, &o.Quantity)
+	})
+}
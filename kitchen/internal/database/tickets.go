@@ -0,0 +1,271 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"com.MixieMelts.kitchen/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+func (db *DB) createTicketsTable(ctx context.Context) error {
+	query := `CREATE TABLE IF NOT EXISTS tickets (
+		id SERIAL PRIMARY KEY,
+		product_id BIGINT NOT NULL,
+		product_name TEXT NOT NULL,
+		quantity INTEGER NOT NULL DEFAULT 0 CHECK (quantity >= 0),
+		status VARCHAR(32) NOT NULL,
+		pull_list_quantity INTEGER,
+		claimed_by TEXT NOT NULL DEFAULT '',
+		claimed_at TIMESTAMP WITH TIME ZONE,
+		completed_at TIMESTAMP WITH TIME ZONE,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE UNIQUE INDEX IF NOT EXISTS tickets_one_queued_per_product ON tickets (product_id) WHERE status = 'queued';`
+	_, err := db.Exec(ctx, query)
+	return err
+}
+
+func (db *DB) createTicketOrdersTable(ctx context.Context) error {
+	query := `CREATE TABLE IF NOT EXISTS ticket_orders (
+		order_id BIGINT NOT NULL,
+		product_id BIGINT NOT NULL,
+		ticket_id BIGINT NOT NULL REFERENCES tickets(id) ON DELETE CASCADE,
+		quantity INTEGER NOT NULL CHECK (quantity > 0),
+		PRIMARY KEY (order_id, product_id)
+	);`
+	_, err := db.Exec(ctx, query)
+	return err
+}
+
+func (db *DB) createTicketIngredientsTable(ctx context.Context) error {
+	query := `CREATE TABLE IF NOT EXISTS ticket_ingredients (
+		ticket_id BIGINT NOT NULL REFERENCES tickets(id) ON DELETE CASCADE,
+		ingredient_id BIGINT NOT NULL,
+		ingredient_name TEXT NOT NULL,
+		unit VARCHAR(32) NOT NULL,
+		amount DOUBLE PRECISION NOT NULL,
+		PRIMARY KEY (ticket_id, ingredient_id)
+	);`
+	_, err := db.Exec(ctx, query)
+	return err
+}
+
+// AddOrderToTickets folds an order's product lines into the queued ticket for
+// each product, creating tickets as needed, and returns the ids of the
+// tickets that changed. An order that was already added is ignored, so
+// redelivered events do not inflate tickets.
+func (db *DB) AddOrderToTickets(ctx context.Context, order models.Order) ([]int64, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("AddOrderToTickets begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var changed []int64
+	for _, it := range order.Items {
+		if it.ProductID == 0 || it.Quantity <= 0 {
+			continue
+		}
+
+		var seen bool
+		err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM ticket_orders WHERE order_id = $1 AND product_id = $2)`,
+			order.ID, it.ProductID).Scan(&seen)
+		if err != nil {
+			return nil, fmt.Errorf("AddOrderToTickets check order %d: %w", order.ID, err)
+		}
+		if seen {
+			continue
+		}
+
+		var ticketID int64
+		err = tx.QueryRow(ctx, `INSERT INTO tickets (product_id, product_name, status) VALUES ($1,$2,$3)
+			ON CONFLICT (product_id) WHERE status = 'queued' DO UPDATE SET product_name = EXCLUDED.product_name
+			RETURNING id`, it.ProductID, it.Name, models.TicketStatusQueued).Scan(&ticketID)
+		if err != nil {
+			return nil, fmt.Errorf("AddOrderToTickets upsert ticket for product %d: %w", it.ProductID, err)
+		}
+
+		tag, err := tx.Exec(ctx, `INSERT INTO ticket_orders (order_id, product_id, ticket_id, quantity) VALUES ($1,$2,$3,$4)
+			ON CONFLICT (order_id, product_id) DO NOTHING`, order.ID, it.ProductID, ticketID, it.Quantity)
+		if err != nil {
+			return nil, fmt.Errorf("AddOrderToTickets link order %d: %w", order.ID, err)
+		}
+		if tag.RowsAffected() == 0 {
+			continue
+		}
+
+		if _, err := tx.Exec(ctx, `UPDATE tickets SET quantity = quantity + $1, updated_at = NOW() WHERE id = $2`, it.Quantity, ticketID); err != nil {
+			return nil, fmt.Errorf("AddOrderToTickets update ticket %d: %w", ticketID, err)
+		}
+		changed = append(changed, ticketID)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("AddOrderToTickets commit: %w", err)
+	}
+	return changed, nil
+}
+
+// StalePullLists returns unfinished tickets whose pull list was not computed
+// for their current quantity.
+func (db *DB) StalePullLists(ctx context.Context) ([]models.TicketDemand, error) {
+	rows, err := db.Query(ctx, `SELECT id, product_id, quantity FROM tickets
+		WHERE status IN ($1, $2) AND quantity > 0 AND pull_list_quantity IS DISTINCT FROM quantity ORDER BY id`,
+		models.TicketStatusQueued, models.TicketStatusInProgress)
+	if err != nil {
+		return nil, fmt.Errorf("StalePullLists: %w", err)
+	}
+	defer rows.Close()
+
+	var demands []models.TicketDemand
+	for rows.Next() {
+		var d models.TicketDemand
+		if err := rows.Scan(&d.TicketID, &d.ProductID, &d.Quantity); err != nil {
+			return nil, fmt.Errorf("StalePullLists scan: %w", err)
+		}
+		demands = append(demands, d)
+	}
+	return demands, rows.Err()
+}
+
+// SetPullList replaces a ticket's pull list with items computed for quantity.
+// If the ticket's quantity has changed since, the pull list is left stale for
+// the next refresh.
+func (db *DB) SetPullList(ctx context.Context, ticketID int64, quantity int, items []models.PullItem) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("SetPullList begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	tag, err := tx.Exec(ctx, `UPDATE tickets SET pull_list_quantity = $1, updated_at = NOW() WHERE id = $2 AND quantity = $1`, quantity, ticketID)
+	if err != nil {
+		return fmt.Errorf("SetPullList update ticket %d: %w", ticketID, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return nil
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM ticket_ingredients WHERE ticket_id = $1`, ticketID); err != nil {
+		return fmt.Errorf("SetPullList clear ticket %d: %w", ticketID, err)
+	}
+	for _, it := range items {
+		_, err := tx.Exec(ctx, `INSERT INTO ticket_ingredients (ticket_id, ingredient_id, ingredient_name, unit, amount) VALUES ($1,$2,$3,$4,$5)`,
+			ticketID, it.IngredientID, it.IngredientName, it.Unit, it.Amount)
+		if err != nil {
+			return fmt.Errorf("SetPullList insert ingredient %d: %w", it.IngredientID, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("SetPullList commit: %w", err)
+	}
+	return nil
+}
+
+const ticketColumns = `id, product_id, product_name, quantity, status, pull_list_quantity IS DISTINCT FROM quantity,
+	claimed_by, claimed_at, completed_at, created_at, updated_at`
+
+func scanTicket(row pgx.Row, t *models.Ticket) error {
+	return row.Scan(&t.ID, &t.ProductID, &t.ProductName, &t.Quantity, &t.Status, &t.PullListStale,
+		&t.ClaimedBy, &t.ClaimedAt, &t.CompletedAt, &t.CreatedAt, &t.UpdatedAt)
+}
+
+// GetTicket returns a ticket with its orders and pull list, or nil if it does
+// not exist.
+func (db *DB) GetTicket(ctx context.Context, id int64) (*models.Ticket, error) {
+	var t models.Ticket
+	err := scanTicket(db.QueryRow(ctx, `SELECT `+ticketColumns+` FROM tickets WHERE id = $1`, id), &t)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("GetTicket: %w", err)
+	}
+	if err := db.loadTicketDetails(ctx, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ListTickets returns tickets, oldest first, optionally filtered by status.
+func (db *DB) ListTickets(ctx context.Context, status models.TicketStatus) ([]models.Ticket, error) {
+	rows, err := db.Query(ctx, `SELECT `+ticketColumns+` FROM tickets WHERE quantity > 0 AND ($1 = '' OR status = $1) ORDER BY created_at, id`, status)
+	if err != nil {
+		return nil, fmt.Errorf("ListTickets: %w", err)
+	}
+	defer rows.Close()
+
+	tickets := []models.Ticket{}
+	for rows.Next() {
+		var t models.Ticket
+		if err := scanTicket(rows, &t); err != nil {
+			return nil, fmt.Errorf("ListTickets scan: %w", err)
+		}
+		tickets = append(tickets, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ListTickets rows: %w", err)
+	}
+
+	for i := range tickets {
+		if err := db.loadTicketDetails(ctx, &tickets[i]); err != nil {
+			return nil, err
+		}
+	}
+	return tickets, nil
+}
+
+func (db *DB) loadTicketDetails(ctx context.Context, t *models.Ticket) error {
+	rows, err := db.Query(ctx, `SELECT order_id, quantity FROM ticket_orders WHERE ticket_id = $1 ORDER BY order_id`, t.ID)
+	if err != nil {
+		return fmt.Errorf("loadTicketDetails orders: %w", err)
+	}
+	t.Orders = []models.TicketOrder{}
+	for rows.Next() {
+		var o models.TicketOrder
+		if err := rows.Scan(&o.OrderID, &o.Quantity); err != nil {
+			rows.Close()
+			return fmt.Errorf("loadTicketDetails orders scan: %w", err)
+		}
+		t.Orders = append(t.Orders, o)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("loadTicketDetails orders rows: %w", err)
+	}
+
+	rows, err = db.Query(ctx, `SELECT ingredient_id, ingredient_name, unit, amount FROM ticket_ingredients WHERE ticket_id = $1 ORDER BY ingredient_id`, t.ID)
+	if err != nil {
+		return fmt.Errorf("loadTicketDetails pull list: %w", err)
+	}
+	t.PullList = []models.PullItem{}
+	for rows.Next() {
+		var p models.PullItem
+		if err := rows.Scan(&p.IngredientID, &p.IngredientName, &p.Unit, &p.Amount); err != nil {
+			rows.Close()
+			return fmt.Errorf("loadTicketDetails pull list scan: %w", err)
+		}
+		t.PullList = append(t.PullList, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("loadTicketDetails pull list rows: %w", err)
+	}
+
+	var b models.Batch
+	err = db.QueryRow(ctx, `SELECT id, ticket_id, product_id, quantity, completed_by, completed_at FROM batches WHERE ticket_id = $1`, t.ID).
+		Scan(&b.ID, &b.TicketID, &b.ProductID, &b.Quantity, &b.CompletedBy, &b.CompletedAt)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		t.Batch = nil
+	case err != nil:
+		return fmt.Errorf("loadTicketDetails batch: %w", err)
+	default:
+		t.Batch = &b
+	}
+	return nil
+}
@@ -0,0 +1,390 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"com.MixieMelts.kitchen/internal/inventory"
+	"com.MixieMelts.kitchen/internal/models"
+	"com.MixieMelts.users/jwtverify"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// eventOrderCreated is the event type the kitchen builds tickets from.
+const eventOrderCreated = "order.created"
+
+// ServiceTokenHeader carries the shared token other services present on
+// internal routes.
+const ServiceTokenHeader = "X-Service-Token"
+
+// heartbeatInterval is how often an idle ticket stream sends a comment so
+// proxies do not close it.
+const heartbeatInterval = 15 * time.Second
+
+// DBLayer is the ticket persistence used by the handlers.
+type DBLayer interface {
+	GetTicket(ctx context.Context, id int64) (*models.Ticket, error)
+	ListTickets(ctx context.Context, status models.TicketStatus) ([]models.Ticket, error)
+	ClaimTicket(ctx context.Context, id int64, userID string) (*models.Ticket, error)
+	StartBatch(ctx context.Context, id int64, batch models.Batch) (*models.Ticket, *models.Batch, error)
+	CompleteTicket(ctx context.Context, id int64, batch models.Batch) (*models.Ticket, error)
+	VoidTicket(ctx context.Context, id int64) (*models.Ticket, error)
+}
+
+// Notifier turns orders into tickets.
+type Notifier interface {
+	OrderCreated(ctx context.Context, order models.Order) error
+}
+
+// Ledger books completed batches in inventory.
+type Ledger interface {
+	RecordBatch(ctx context.Context, batchID string, productID int64, quantity int, orders []models.TicketOrder) error
+	ReverseBatch(ctx context.Context, batchID string) error
+}
+
+// Stream announces ticket changes and hands them out to live subscribers.
+type Stream interface {
+	TicketChanged(ctx context.Context, ticketID int64)
+	Subscribe() (<-chan []byte, func())
+}
+
+// Handler provides HTTP handlers for the kitchen service.
+type Handler struct {
+	db       DBLayer
+	notifier Notifier
+	ledger   Ledger
+	stream   Stream
+	verifier *jwtverify.Verifier
+}
+
+// New creates a new Handler. verifier checks the users service's tokens.
+func New(db DBLayer, n Notifier, ledger Ledger, stream Stream, verifier *jwtverify.Verifier) *Handler {
+	return &Handler{db: db, notifier: n, ledger: ledger, stream: stream, verifier: verifier}
+}
+
+// ReceiveEvent accepts events posted by other services. order.created events
+// are added to the kitchen's tickets; other event types are acknowledged and
+// ignored.
+func (h *Handler) ReceiveEvent(w http.ResponseWriter, r *http.Request) {
+	var e models.OrderCreatedEvent
+	if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if e.Type != eventOrderCreated {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if e.Order.ID <= 0 {
+		respondWithError(w, http.StatusBadRequest, "order id required")
+		return
+	}
+
+	if err := h.notifier.OrderCreated(r.Context(), e.Order); err != nil {
+		log.Printf("ReceiveEvent order %d error: %v", e.Order.ID, err)
+		respondWithError(w, http.StatusInternalServerError, "failed to record order")
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// GetTickets lists tickets, optionally filtered with ?status=.
+func (h *Handler) GetTickets(w http.ResponseWriter, r *http.Request) {
+	status := models.TicketStatus(r.URL.Query().Get("status"))
+	if status != "" && !status.Valid() {
+		respondWithError(w, http.StatusBadRequest, "invalid status")
+		return
+	}
+
+	tickets, err := h.db.ListTickets(r.Context(), status)
+	if err != nil {
+		log.Printf("GetTickets error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to list tickets")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, tickets)
+}
+
+// GetTicket returns a single ticket with its orders and pull list.
+func (h *Handler) GetTicket(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	ticket, err := h.db.GetTicket(r.Context(), id)
+	if err != nil {
+		log.Printf("GetTicket error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to get ticket")
+		return
+	}
+	if ticket == nil {
+		respondWithError(w, http.StatusNotFound, "ticket not found")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, ticket)
+}
+
+// ClaimTicket assigns a queued ticket to the caller. Orders arriving after
+// the claim go to a new ticket.
+func (h *Handler) ClaimTicket(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	ticket, err := h.db.ClaimTicket(r.Context(), id, userIDFromContext(r.Context()))
+	h.respondWithTransition(w, r, "ClaimTicket", ticket, err)
+}
+
+// VoidTicket abandons a ticket that has not been made. No ingredients are
+// consumed.
+func (h *Handler) VoidTicket(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	ticket, err := h.db.VoidTicket(r.Context(), id)
+	h.respondWithTransition(w, r, "VoidTicket", ticket, err)
+}
+
+// CompletePayload is the optional request body for POST
+// /tickets/{id}/complete.
+type CompletePayload struct {
+	// Quantity is how many units the batch actually made. It defaults to the
+	// ticket's quantity.
+	Quantity int `json:"quantity"`
+}
+
+// CompleteTicket finishes a claimed ticket. The batch is booked in inventory
+// under a batch id of its own: its ingredients are consumed and its output
+// goes to finished goods, from which the ticket's orders take their share,
+// filling their stock reservations, and any surplus stays on hand. The batch
+// is booked before the ticket is locked, so no lock is held while inventory
+// answers; if the ticket cannot be marked done afterwards, e.g. because a
+// concurrent completion won, the booking is reversed.
+func (h *Handler) CompleteTicket(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	var p CompletePayload
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil && !errors.Is(err, io.EOF) {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if p.Quantity < 0 {
+		respondWithError(w, http.StatusBadRequest, "quantity must be positive")
+		return
+	}
+
+	ticket, batch, err := h.db.StartBatch(r.Context(), id, models.Batch{Quantity: p.Quantity, CompletedBy: userIDFromContext(r.Context())})
+	if err != nil || ticket == nil {
+		h.respondWithTransition(w, r, "CompleteTicket", ticket, err)
+		return
+	}
+
+	bookErr := h.ledger.RecordBatch(r.Context(), batch.ID, ticket.ProductID, batch.Quantity, ticket.Orders)
+	if bookErr == nil {
+		ticket, err = h.db.CompleteTicket(r.Context(), id, *batch)
+	}
+	if bookErr != nil || err != nil {
+		// The ticket was not completed, but inventory may have booked the
+		// batch anyway, e.g. if its response was lost; undo it. The id is
+		// this attempt's own, so no other booking is touched.
+		if rerr := h.ledger.ReverseBatch(context.WithoutCancel(r.Context()), batch.ID); rerr != nil {
+			log.Printf("CompleteTicket reverse batch %s error: %v", batch.ID, rerr)
+		}
+	}
+
+	switch {
+	case errors.Is(bookErr, inventory.ErrInsufficientStock):
+		respondWithError(w, http.StatusConflict, bookErr.Error())
+	case errors.Is(bookErr, inventory.ErrMissingRecipe):
+		respondWithError(w, http.StatusUnprocessableEntity, bookErr.Error())
+	case bookErr != nil:
+		log.Printf("CompleteTicket record batch %s error: %v", batch.ID, bookErr)
+		respondWithError(w, http.StatusBadGateway, "failed to record batch")
+	default:
+		h.respondWithTransition(w, r, "CompleteTicket", ticket, err)
+	}
+}
+
+// respondWithTransition writes the result of a ticket state change and
+// announces successful changes on the ticket stream. Changes the current
+// state does not allow are reported as 409.
+func (h *Handler) respondWithTransition(w http.ResponseWriter, r *http.Request, op string, ticket *models.Ticket, err error) {
+	switch {
+	case errors.Is(err, models.ErrInvalidTransition):
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		log.Printf("%s error: %v", op, err)
+		respondWithError(w, http.StatusInternalServerError, "failed to update ticket")
+		return
+	case ticket == nil:
+		respondWithError(w, http.StatusNotFound, "ticket not found")
+		return
+	}
+
+	h.stream.TicketChanged(context.WithoutCancel(r.Context()), ticket.ID)
+	respondWithJSON(w, http.StatusOK, ticket)
+}
+
+// StreamTickets sends every ticket change to the caller as server-sent
+// events, each an "event: ticket" whose data is the ticket's JSON. Clients
+// load GET /tickets first and apply changes as they arrive; if the stream
+// ends they reconnect and reload.
+func (h *Handler) StreamTickets(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondWithError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	updates, unsubscribe := h.stream.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case msg, ok := <-updates:
+			if !ok {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "event: ticket\ndata: %s\n\n", msg); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// --- UTILITY & MIDDLEWARE ---
+
+// AuthMiddleware validates the bearer token issued by the users service and
+// puts its claims in the request context, where jwtverify.Require can check
+// them.
+func (h *Handler) AuthMiddleware(next http.Handler) http.Handler {
+	return h.authenticate(next, false)
+}
+
+// StreamAuthMiddleware is AuthMiddleware for event streams. Browsers cannot
+// set headers on an EventSource, so the token may also be passed as the
+// access_token query parameter.
+func (h *Handler) StreamAuthMiddleware(next http.Handler) http.Handler {
+	return h.authenticate(next, true)
+}
+
+func (h *Handler) authenticate(next http.Handler, allowQuery bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenString, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok && allowQuery {
+			tokenString = r.URL.Query().Get("access_token")
+		}
+		if tokenString == "" {
+			respondWithError(w, http.StatusUnauthorized, "authorization header required")
+			return
+		}
+
+		claims := &jwtverify.Claims{}
+		if err := h.verifier.Parse(tokenString, claims); err != nil || claims.Subject == "" {
+			respondWithError(w, http.StatusUnauthorized, "invalid token")
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(jwtverify.WithClaims(r.Context(), claims)))
+	})
+}
+
+// RequireServiceToken admits only requests whose ServiceTokenHeader matches
+// token. An event creates tickets and allocates finished goods, so with no
+// token configured every request is refused rather than letting them open.
+func RequireServiceToken(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got := r.Header.Get(ServiceTokenHeader)
+			if token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				respondWithError(w, http.StatusUnauthorized, "invalid service token")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Logger is chi's request logger with the access_token query parameter
+// redacted. The ticket stream takes its token there, and the log would
+// otherwise keep a usable copy of it.
+var Logger = middleware.RequestLogger(tokenRedactor{&middleware.DefaultLogFormatter{Logger: log.New(os.Stdout, "", log.LstdFlags)}})
+
+// tokenRedactor hands the wrapped formatter a request whose RequestURI has
+// the access token blanked out.
+type tokenRedactor struct {
+	middleware.LogFormatter
+}
+
+func (f tokenRedactor) NewLogEntry(r *http.Request) middleware.LogEntry {
+	q := r.URL.Query()
+	if !q.Has("access_token") {
+		return f.LogFormatter.NewLogEntry(r)
+	}
+	q.Set("access_token", "REDACTED")
+	u := *r.URL
+	u.RawQuery = q.Encode()
+	redacted := r.WithContext(r.Context())
+	redacted.RequestURI = u.RequestURI()
+	return f.LogFormatter.NewLogEntry(redacted)
+}
+
+func userIDFromContext(ctx context.Context) string {
+	claims, ok := jwtverify.ClaimsFromContext(ctx)
+	if !ok {
+		return ""
+	}
+	return claims.Subject
+}
+
+func respondWithError(w http.ResponseWriter, code int, message string) {
+	respondWithJSON(w, code, map[string]string{"message": message})
+}
+
+func respondWithJSON(w http.ResponseWriter, code int, payload any) {
+	response, _ := json.Marshal(payload)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(response)
+}
@@ -0,0 +1,508 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"com.MixieMelts.kitchen/internal/inventory"
+	"com.MixieMelts.kitchen/internal/models"
+	"com.MixieMelts.users/jwtverify"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// MockDB is a mock implementation of the DBLayer for testing purposes.
+type MockDB struct {
+	GetTicketFunc      func(ctx context.Context, id int64) (*models.Ticket, error)
+	ListTicketsFunc    func(ctx context.Context, status models.TicketStatus) ([]models.Ticket, error)
+	ClaimTicketFunc    func(ctx context.Context, id int64, userID string) (*models.Ticket, error)
+	StartBatchFunc     func(ctx context.Context, id int64, batch models.Batch) (*models.Ticket, *models.Batch, error)
+	CompleteTicketFunc func(ctx context.Context, id int64, batch models.Batch) (*models.Ticket, error)
+	VoidTicketFunc     func(ctx context.Context, id int64) (*models.Ticket, error)
+}
+
+func (m *MockDB) GetTicket(ctx context.Context, id int64) (*models.Ticket, error) {
+	if m.GetTicketFunc != nil {
+		return m.GetTicketFunc(ctx, id)
+	}
+	return nil, errors.New("GetTicketFunc not implemented")
+}
+
+func (m *MockDB) ListTickets(ctx context.Context, status models.TicketStatus) ([]models.Ticket, error) {
+	if m.ListTicketsFunc != nil {
+		return m.ListTicketsFunc(ctx, status)
+	}
+	return nil, errors.New("ListTicketsFunc not implemented")
+}
+
+func (m *MockDB) ClaimTicket(ctx context.Context, id int64, userID string) (*models.Ticket, error) {
+	if m.ClaimTicketFunc != nil {
+		return m.ClaimTicketFunc(ctx, id, userID)
+	}
+	return nil, errors.New("ClaimTicketFunc not implemented")
+}
+
+func (m *MockDB) StartBatch(ctx context.Context, id int64, batch models.Batch) (*models.Ticket, *models.Batch, error) {
+	if m.StartBatchFunc != nil {
+		return m.StartBatchFunc(ctx, id, batch)
+	}
+	return nil, nil, errors.New("StartBatchFunc not implemented")
+}
+
+func (m *MockDB) CompleteTicket(ctx context.Context, id int64, batch models.Batch) (*models.Ticket, error) {
+	if m.CompleteTicketFunc != nil {
+		return m.CompleteTicketFunc(ctx, id, batch)
+	}
+	return nil, errors.New("CompleteTicketFunc not implemented")
+}
+
+func (m *MockDB) VoidTicket(ctx context.Context, id int64) (*models.Ticket, error) {
+	if m.VoidTicketFunc != nil {
+		return m.VoidTicketFunc(ctx, id)
+	}
+	return nil, errors.New("VoidTicketFunc not implemented")
+}
+
+// MockNotifier records the orders it is given.
+type MockNotifier struct {
+	Err    error
+	Orders []models.Order
+}
+
+func (m *MockNotifier) OrderCreated(ctx context.Context, order models.Order) error {
+	m.Orders = append(m.Orders, order)
+	return m.Err
+}
+
+// MockLedger records booked and reversed batches.
+type MockLedger struct {
+	RecordErr error
+	Recorded  []models.Batch
+	Orders    [][]models.TicketOrder
+	Reversed  []string
+}
+
+func (m *MockLedger) RecordBatch(ctx context.Context, batchID string, productID int64, quantity int, orders []models.TicketOrder) error {
+	m.Recorded = append(m.Recorded, models.Batch{ID: batchID, ProductID: productID, Quantity: quantity})
+	m.Orders = append(m.Orders, orders)
+	return m.RecordErr
+}
+
+func (m *MockLedger) ReverseBatch(ctx context.Context, batchID string) error {
+	m.Reversed = append(m.Reversed, batchID)
+	return nil
+}
+
+// MockStream records announced ticket ids.
+type MockStream struct {
+	Changed []int64
+}
+
+func (m *MockStream) TicketChanged(ctx context.Context, ticketID int64) {
+	m.Changed = append(m.Changed, ticketID)
+}
+
+func (m *MockStream) Subscribe() (<-chan []byte, func()) {
+	return make(chan []byte), func() {}
+}
+
+func withUser(req *http.Request, userID string) *http.Request {
+	claims := &jwtverify.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: userID}}
+	return req.WithContext(jwtverify.WithClaims(req.Context(), claims))
+}
+
+var testSecret = []byte("test-secret")
+
+func signedToken(t *testing.T, subject string) string {
+	t.Helper()
+	claims := &jwt.RegisteredClaims{
+		Subject:   subject,
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+	s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(testSecret)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return s
+}
+
+func withID(req *http.Request, id string) *http.Request {
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", id)
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+}
+
+func TestReceiveEvent(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		notifyErr    error
+		wantStatus   int
+		wantNotified bool
+	}{
+		{
+			name:         "order created",
+			body:         `{"type":"order.created","order":{"id":5,"items":[{"product_id":1,"name":"Serene Sanctuary","quantity":24}]}}`,
+			wantStatus:   http.StatusAccepted,
+			wantNotified: true,
+		},
+		{name: "other events are ignored", body: `{"type":"order.cancelled","order":{"id":5}}`, wantStatus: http.StatusAccepted},
+		{name: "missing order id", body: `{"type":"order.created","order":{}}`, wantStatus: http.StatusBadRequest},
+		{
+			name:         "store failure",
+			body:         `{"type":"order.created","order":{"id":5,"items":[]}}`,
+			notifyErr:    errors.New("db down"),
+			wantStatus:   http.StatusInternalServerError,
+			wantNotified: true,
+		},
+		{name: "invalid body", body: `{bad`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			n := &MockNotifier{Err: tc.notifyErr}
+			h := New(&MockDB{}, n, &MockLedger{}, &MockStream{}, jwtverify.New(nil, testSecret))
+
+			req, _ := http.NewRequest("POST", "/internal/events", bytes.NewBufferString(tc.body))
+			rr := httptest.NewRecorder()
+			h.ReceiveEvent(rr, req)
+
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("expected status %d got %d; body: %s", tc.wantStatus, rr.Code, rr.Body.String())
+			}
+			if (len(n.Orders) > 0) != tc.wantNotified {
+				t.Fatalf("expected notified=%v got %v", tc.wantNotified, n.Orders)
+			}
+		})
+	}
+}
+
+func TestGetTickets(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantFilter models.TicketStatus
+	}{
+		{name: "all", wantStatus: http.StatusOK},
+		{name: "queued", query: "?status=queued", wantStatus: http.StatusOK, wantFilter: models.TicketStatusQueued},
+		{name: "in progress", query: "?status=in-progress", wantStatus: http.StatusOK, wantFilter: models.TicketStatusInProgress},
+		{name: "void", query: "?status=void", wantStatus: http.StatusOK, wantFilter: models.TicketStatusVoid},
+		{name: "unknown status", query: "?status=burnt", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var gotFilter models.TicketStatus
+			mockDB := &MockDB{
+				ListTicketsFunc: func(ctx context.Context, status models.TicketStatus) ([]models.Ticket, error) {
+					gotFilter = status
+					return []models.Ticket{}, nil
+				},
+			}
+			h := New(mockDB, &MockNotifier{}, &MockLedger{}, &MockStream{}, jwtverify.New(nil, testSecret))
+
+			req, _ := http.NewRequest("GET", "/tickets"+tc.query, nil)
+			rr := httptest.NewRecorder()
+			h.GetTickets(rr, req)
+
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("expected status %d got %d; body: %s", tc.wantStatus, rr.Code, rr.Body.String())
+			}
+			if gotFilter != tc.wantFilter {
+				t.Fatalf("expected filter %q got %q", tc.wantFilter, gotFilter)
+			}
+		})
+	}
+}
+
+func TestClaimTicket(t *testing.T) {
+	tests := []struct {
+		name        string
+		id          string
+		claimErr    error
+		missing     bool
+		wantStatus  int
+		wantChanged bool
+	}{
+		{name: "queued ticket", id: "3", wantStatus: http.StatusOK, wantChanged: true},
+		{name: "already claimed", id: "3", claimErr: fmt.Errorf("ClaimTicket: %w", models.ErrInvalidTransition), wantStatus: http.StatusConflict},
+		{name: "missing ticket", id: "3", missing: true, wantStatus: http.StatusNotFound},
+		{name: "bad id", id: "x", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var claimedBy string
+			mockDB := &MockDB{
+				ClaimTicketFunc: func(ctx context.Context, id int64, userID string) (*models.Ticket, error) {
+					claimedBy = userID
+					if tc.claimErr != nil || tc.missing {
+						return nil, tc.claimErr
+					}
+					return &models.Ticket{ID: id, Status: models.TicketStatusInProgress, ClaimedBy: userID}, nil
+				},
+			}
+			stream := &MockStream{}
+			h := New(mockDB, &MockNotifier{}, &MockLedger{}, stream, jwtverify.New(nil, testSecret))
+
+			req, _ := http.NewRequest("POST", "/tickets/"+tc.id+"/claim", nil)
+			req = withUser(withID(req, tc.id), "chef-1")
+			rr := httptest.NewRecorder()
+			h.ClaimTicket(rr, req)
+
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("expected status %d got %d; body: %s", tc.wantStatus, rr.Code, rr.Body.String())
+			}
+			if tc.wantStatus == http.StatusOK && claimedBy != "chef-1" {
+				t.Fatalf("expected ticket claimed by chef-1, got %q", claimedBy)
+			}
+			if (len(stream.Changed) > 0) != tc.wantChanged {
+				t.Fatalf("expected announced=%v got %v", tc.wantChanged, stream.Changed)
+			}
+		})
+	}
+}
+
+func TestCompleteTicket(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		status       models.TicketStatus
+		recordErr    error
+		completeErr  error
+		wantStatus   int
+		wantQuantity int
+		wantRecorded bool
+		wantReversed bool
+	}{
+		{
+			name:         "whole ticket",
+			status:       models.TicketStatusInProgress,
+			wantStatus:   http.StatusOK,
+			wantQuantity: 24,
+			wantRecorded: true,
+		},
+		{
+			name:         "short batch",
+			body:         `{"quantity":20}`,
+			status:       models.TicketStatusInProgress,
+			wantStatus:   http.StatusOK,
+			wantQuantity: 20,
+			wantRecorded: true,
+		},
+		{
+			name:         "surplus batch",
+			body:         `{"quantity":30}`,
+			status:       models.TicketStatusInProgress,
+			wantStatus:   http.StatusOK,
+			wantQuantity: 30,
+			wantRecorded: true,
+		},
+		{name: "not claimed", status: models.TicketStatusQueued, wantStatus: http.StatusConflict},
+		{name: "already done", status: models.TicketStatusDone, wantStatus: http.StatusConflict},
+		{
+			name:         "insufficient stock",
+			status:       models.TicketStatusInProgress,
+			recordErr:    fmt.Errorf("%w: ingredient 2 needs 480.00, has 100.00", inventory.ErrInsufficientStock),
+			wantStatus:   http.StatusConflict,
+			wantRecorded: true,
+			wantReversed: true,
+		},
+		{
+			name:         "inventory down",
+			status:       models.TicketStatusInProgress,
+			recordErr:    errors.New("dial tcp"),
+			wantStatus:   http.StatusBadGateway,
+			wantRecorded: true,
+			wantReversed: true,
+		},
+		{
+			name:         "saving the ticket fails",
+			status:       models.TicketStatusInProgress,
+			completeErr:  errors.New("CompleteTicket commit: conn closed"),
+			wantStatus:   http.StatusInternalServerError,
+			wantRecorded: true,
+			wantReversed: true,
+		},
+		{
+			name:         "completed concurrently",
+			status:       models.TicketStatusInProgress,
+			completeErr:  fmt.Errorf("CompleteTicket: %w: cannot complete a done ticket", models.ErrInvalidTransition),
+			wantStatus:   http.StatusConflict,
+			wantRecorded: true,
+			wantReversed: true,
+		},
+		{name: "negative quantity", body: `{"quantity":-1}`, status: models.TicketStatusInProgress, wantStatus: http.StatusBadRequest},
+		{name: "invalid body", body: `{bad`, status: models.TicketStatusInProgress, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var recorded *models.Batch
+			mockDB := &MockDB{
+				// Mirrors the database: the ticket must be in progress
+				// both when the batch is started and when it is saved.
+				StartBatchFunc: func(ctx context.Context, id int64, batch models.Batch) (*models.Ticket, *models.Batch, error) {
+					ticket := &models.Ticket{ID: id, ProductID: 1, Quantity: 24, Status: tc.status,
+						Orders: []models.TicketOrder{{OrderID: 11, Quantity: 20}, {OrderID: 12, Quantity: 4}}}
+					check := *ticket
+					if err := check.Complete(time.Now()); err != nil {
+						return nil, nil, fmt.Errorf("StartBatch: %w", err)
+					}
+					batch.ID = models.BatchID(id, 3)
+					if batch.Quantity == 0 {
+						batch.Quantity = ticket.Quantity
+					}
+					return ticket, &batch, nil
+				},
+				CompleteTicketFunc: func(ctx context.Context, id int64, batch models.Batch) (*models.Ticket, error) {
+					if tc.completeErr != nil {
+						return nil, tc.completeErr
+					}
+					recorded = &batch
+					return &models.Ticket{ID: id, ProductID: 1, Quantity: 24, Status: models.TicketStatusDone, Batch: &batch}, nil
+				},
+			}
+			ledger := &MockLedger{RecordErr: tc.recordErr}
+			h := New(mockDB, &MockNotifier{}, ledger, &MockStream{}, jwtverify.New(nil, testSecret))
+
+			req, _ := http.NewRequest("POST", "/tickets/7/complete", bytes.NewBufferString(tc.body))
+			req = withUser(withID(req, "7"), "chef-1")
+			rr := httptest.NewRecorder()
+			h.CompleteTicket(rr, req)
+
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("expected status %d got %d; body: %s", tc.wantStatus, rr.Code, rr.Body.String())
+			}
+			if (len(ledger.Recorded) > 0) != tc.wantRecorded {
+				t.Fatalf("expected recorded=%v got %v", tc.wantRecorded, ledger.Recorded)
+			}
+			if (len(ledger.Reversed) > 0) != tc.wantReversed {
+				t.Fatalf("expected reversed=%v got %v", tc.wantReversed, ledger.Reversed)
+			}
+			if tc.wantQuantity == 0 {
+				return
+			}
+			booked := ledger.Recorded[0]
+			if booked.ID != "batch-7-3" || booked.ProductID != 1 || booked.Quantity != tc.wantQuantity || len(ledger.Orders[0]) != 2 {
+				t.Fatalf("unexpected booking %+v for orders %v", booked, ledger.Orders[0])
+			}
+			if recorded == nil || recorded.ID != "batch-7-3" || recorded.Quantity != tc.wantQuantity || recorded.CompletedBy != "chef-1" {
+				t.Fatalf("unexpected batch %+v", recorded)
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	token := signedToken(t, "chef-1")
+	tests := []struct {
+		name       string
+		stream     bool
+		authHeader string
+		query      string
+		wantStatus int
+	}{
+		{name: "bearer token", authHeader: "Bearer " + token, wantStatus: http.StatusOK},
+		{name: "no token", wantStatus: http.StatusUnauthorized},
+		{name: "invalid token", authHeader: "Bearer not-a-token", wantStatus: http.StatusUnauthorized},
+		{name: "query token outside the stream", query: "?access_token=" + token, wantStatus: http.StatusUnauthorized},
+		{name: "query token on the stream", stream: true, query: "?access_token=" + token, wantStatus: http.StatusOK},
+		{name: "invalid query token on the stream", stream: true, query: "?access_token=nope", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			h := New(&MockDB{}, &MockNotifier{}, &MockLedger{}, &MockStream{}, jwtverify.New(nil, testSecret))
+			var gotUser string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotUser = userIDFromContext(r.Context())
+			})
+			mw := h.AuthMiddleware
+			if tc.stream {
+				mw = h.StreamAuthMiddleware
+			}
+
+			req, _ := http.NewRequest("GET", "/tickets/stream"+tc.query, nil)
+			if tc.authHeader != "" {
+				req.Header.Set("Authorization", tc.authHeader)
+			}
+			rr := httptest.NewRecorder()
+			mw(next).ServeHTTP(rr, req)
+
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("expected status %d got %d", tc.wantStatus, rr.Code)
+			}
+			if tc.wantStatus == http.StatusOK && gotUser != "chef-1" {
+				t.Fatalf("expected user chef-1 got %q", gotUser)
+			}
+		})
+	}
+}
+
+func TestRequireServiceToken(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured string
+		sent       string
+		wantStatus int
+	}{
+		{name: "matching token", configured: "s3cret", sent: "s3cret", wantStatus: http.StatusOK},
+		{name: "wrong token", configured: "s3cret", sent: "guess", wantStatus: http.StatusUnauthorized},
+		{name: "no token sent", configured: "s3cret", wantStatus: http.StatusUnauthorized},
+		{name: "none configured", sent: "", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+			req, _ := http.NewRequest("POST", "/internal/events", nil)
+			if tc.sent != "" {
+				req.Header.Set(ServiceTokenHeader, tc.sent)
+			}
+			rr := httptest.NewRecorder()
+			RequireServiceToken(tc.configured)(next).ServeHTTP(rr, req)
+
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("expected status %d got %d", tc.wantStatus, rr.Code)
+			}
+		})
+	}
+}
+
+func TestLoggerRedactsAccessToken(t *testing.T) {
+	var buf bytes.Buffer
+	logger := middleware.RequestLogger(tokenRedactor{&middleware.DefaultLogFormatter{Logger: log.New(&buf, "", 0), NoColor: true}})
+	var seenToken string
+	h := logger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seenToken = r.URL.Query().Get("access_token")
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/tickets/stream?access_token=secret-token&x=1", nil))
+
+	if strings.Contains(buf.String(), "secret-token") {
+		t.Fatalf("token leaked into the log: %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "access_token=REDACTED") {
+		t.Fatalf("expected a redacted token in the log, got %s", buf.String())
+	}
+	if seenToken != "secret-token" {
+		t.Fatalf("expected the handler to see the token, got %q", seenToken)
+	}
+}
@@ -0,0 +1,240 @@
+// Package inventory is a small HTTP client for the inventory service's
+// internal endpoints, used to fill orders from finished goods, build ticket
+// pull lists and record completed batches.
+package inventory
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"com.MixieMelts.kitchen/internal/models"
+)
+
+var (
+	// ErrInsufficientStock is returned when inventory cannot cover the
+	// ingredients a batch needs.
+	ErrInsufficientStock = errors.New("insufficient stock")
+	// ErrMissingRecipe is returned when a product has no recipe in inventory.
+	ErrMissingRecipe = errors.New("product has no recipe")
+)
+
+// Line is a product and the number of units to make.
+type Line struct {
+	ProductID int64   `json:"product_id"`
+	Quantity  float64 `json:"quantity"`
+}
+
+type requirementsRequest struct {
+	Lines []Line `json:"lines"`
+}
+
+// serviceTokenHeader carries the token the inventory service requires on
+// its internal routes.
+const serviceTokenHeader = "X-Service-Token"
+
+// Client talks to the inventory service over HTTP.
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// New creates a client for the inventory service rooted at baseURL
+// (e.g. "http://inventory:8083") that authenticates with the shared service
+// token.
+func New(baseURL, token string) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		token:      token,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Requirements returns the ingredients needed to make quantity units of a
+// product.
+func (c *Client) Requirements(ctx context.Context, productID int64, quantity int) ([]models.PullItem, error) {
+	body, err := json.Marshal(requirementsRequest{Lines: []Line{{ProductID: productID, Quantity: float64(quantity)}}})
+	if err != nil {
+		return nil, fmt.Errorf("Requirements encode: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/internal/requirements", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("Requirements build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(serviceTokenHeader, c.token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Requirements request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnprocessableEntity:
+		return nil, fmt.Errorf("%w: product %d", ErrMissingRecipe, productID)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("Requirements: unexpected status %d", resp.StatusCode)
+	}
+
+	var items []models.PullItem
+	if err := json.NewDecoder(resp.Body).Decode(&items); err != nil {
+		return nil, fmt.Errorf("Requirements decode: %w", err)
+	}
+	return items, nil
+}
+
+type allocationRequest struct {
+	Reference string           `json:"reference"`
+	CreatedBy string           `json:"created_by"`
+	Lines     []allocationLine `json:"lines"`
+}
+
+type allocationLine struct {
+	ProductID int64 `json:"product_id"`
+	Quantity  int   `json:"quantity"`
+	Allocated int   `json:"allocated,omitempty"`
+}
+
+// Allocate fills an order's product lines from finished goods on hand and
+// returns the units taken per product id; the rest has to be made. Repeating
+// the call for the same reference returns the original allocation.
+func (c *Client) Allocate(ctx context.Context, reference string, items []models.OrderItem) (map[int64]int, error) {
+	ar := allocationRequest{Reference: reference, CreatedBy: "kitchen"}
+	for _, it := range items {
+		if it.ProductID != 0 && it.Quantity > 0 {
+			ar.Lines = append(ar.Lines, allocationLine{ProductID: it.ProductID, Quantity: it.Quantity})
+		}
+	}
+	body, err := json.Marshal(ar)
+	if err != nil {
+		return nil, fmt.Errorf("Allocate encode: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/internal/allocations", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("Allocate build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(serviceTokenHeader, c.token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Allocate request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Allocate: unexpected status %d: %s", resp.StatusCode, errorMessage(resp))
+	}
+
+	var out struct {
+		Lines []allocationLine `json:"lines"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("Allocate decode: %w", err)
+	}
+	allocated := map[int64]int{}
+	for _, l := range out.Lines {
+		allocated[l.ProductID] += l.Allocated
+	}
+	return allocated, nil
+}
+
+type batchRequest struct {
+	Reference string       `json:"reference"`
+	ProductID int64        `json:"product_id"`
+	Quantity  int          `json:"quantity"`
+	Allocated int          `json:"allocated"`
+	Orders    []orderShare `json:"orders"`
+	CreatedBy string       `json:"created_by"`
+}
+
+type orderShare struct {
+	Reference string `json:"reference"`
+	Quantity  int    `json:"quantity"`
+}
+
+// RecordBatch books a finished batch in one inventory transaction: the
+// ingredients for quantity units of a product are consumed, the units are
+// added to finished goods, and each of orders takes its share, which fills
+// the stock its checkout reserved. Every ledger entry is tagged with
+// batchID, and repeating the call for the same batch does nothing.
+func (c *Client) RecordBatch(ctx context.Context, batchID string, productID int64, quantity int, orders []models.TicketOrder) error {
+	br := batchRequest{
+		Reference: batchID,
+		ProductID: productID,
+		Quantity:  quantity,
+		CreatedBy: "kitchen",
+	}
+	for _, o := range orders {
+		br.Allocated += o.Quantity
+		br.Orders = append(br.Orders, orderShare{Reference: models.OrderReference(o.OrderID), Quantity: o.Quantity})
+	}
+	body, err := json.Marshal(br)
+	if err != nil {
+		return fmt.Errorf("RecordBatch encode: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/internal/batches", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("RecordBatch build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(serviceTokenHeader, c.token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("RecordBatch request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent, http.StatusOK:
+		return nil
+	case http.StatusConflict:
+		return fmt.Errorf("%w: %s", ErrInsufficientStock, errorMessage(resp))
+	case http.StatusUnprocessableEntity:
+		return fmt.Errorf("%w: %s", ErrMissingRecipe, errorMessage(resp))
+	default:
+		return fmt.Errorf("RecordBatch: unexpected status %d: %s", resp.StatusCode, errorMessage(resp))
+	}
+}
+
+// ReverseBatch undoes a recorded batch, restoring its ingredients and
+// finished goods. It is safe to call more than once.
+func (c *Client) ReverseBatch(ctx context.Context, batchID string) error {
+	u := fmt.Sprintf("%s/internal/batches/%s?created_by=kitchen", c.baseURL, url.PathEscape(batchID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, u, nil)
+	if err != nil {
+		return fmt.Errorf("ReverseBatch build request: %w", err)
+	}
+	req.Header.Set(serviceTokenHeader, c.token)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("ReverseBatch request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("ReverseBatch: unexpected status %d: %s", resp.StatusCode, errorMessage(resp))
+	}
+	return nil
+}
+
+func errorMessage(resp *http.Response) string {
+	var body struct {
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return resp.Status
+	}
+	return body.Message
+}
@@ -0,0 +1,161 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidTransition is returned when a ticket cannot move to the
+// requested state from its current one.
+var ErrInvalidTransition = errors.New("invalid ticket state change")
+
+// TicketStatus is the lifecycle state of a kitchen ticket.
+type TicketStatus string
+
+const (
+	// TicketStatusQueued tickets are still collecting demand from new orders.
+	TicketStatusQueued TicketStatus = "queued"
+	// TicketStatusInProgress tickets have been claimed by someone in the
+	// kitchen and no longer change.
+	TicketStatusInProgress TicketStatus = "in-progress"
+	// TicketStatusDone tickets have been made.
+	TicketStatusDone TicketStatus = "done"
+	// TicketStatusVoid tickets were abandoned without being made.
+	TicketStatusVoid TicketStatus = "void"
+)
+
+// Valid reports whether s is a known ticket status.
+func (s TicketStatus) Valid() bool {
+	switch s {
+	case TicketStatusQueued, TicketStatusInProgress, TicketStatusDone, TicketStatusVoid:
+		return true
+	}
+	return false
+}
+
+// Ticket asks the kitchen to make Quantity units of a product. Orders for
+// the same product are folded into a single queued ticket until someone
+// claims it.
+type Ticket struct {
+	ID          int64         `json:"id"`
+	ProductID   int64         `json:"product_id"`
+	ProductName string        `json:"product_name"`
+	Quantity    int           `json:"quantity"`
+	Status      TicketStatus  `json:"status"`
+	Orders      []TicketOrder `json:"orders"`
+	// PullList is the ingredients to take from stock for the whole ticket.
+	PullList []PullItem `json:"pull_list"`
+	// PullListStale is set while the pull list does not yet reflect the
+	// ticket's current quantity, e.g. because inventory was unreachable.
+	PullListStale bool       `json:"pull_list_stale"`
+	ClaimedBy     string     `json:"claimed_by,omitempty"`
+	ClaimedAt     *time.Time `json:"claimed_at,omitempty"`
+	CompletedAt   *time.Time `json:"completed_at,omitempty"`
+	// Batch is the finished-goods output recorded when the ticket was done.
+	Batch     *Batch    `json:"batch,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Claim takes a queued ticket for userID. A claimed ticket stops collecting
+// new orders.
+func (t *Ticket) Claim(userID string, now time.Time) error {
+	if t.Status != TicketStatusQueued {
+		return fmt.Errorf("%w: cannot claim a %s ticket", ErrInvalidTransition, t.Status)
+	}
+	t.Status = TicketStatusInProgress
+	t.ClaimedBy = userID
+	t.ClaimedAt = &now
+	return nil
+}
+
+// Complete marks a claimed ticket done.
+func (t *Ticket) Complete(now time.Time) error {
+	if t.Status != TicketStatusInProgress {
+		return fmt.Errorf("%w: cannot complete a %s ticket", ErrInvalidTransition, t.Status)
+	}
+	t.Status = TicketStatusDone
+	t.CompletedAt = &now
+	return nil
+}
+
+// Void abandons a ticket that has not been made.
+func (t *Ticket) Void() error {
+	if t.Status != TicketStatusQueued && t.Status != TicketStatusInProgress {
+		return fmt.Errorf("%w: cannot void a %s ticket", ErrInvalidTransition, t.Status)
+	}
+	t.Status = TicketStatusVoid
+	return nil
+}
+
+// Batch is the finished-goods output of a completed ticket. Its ID is the
+// Reference on the inventory ledger entries that consumed its ingredients.
+type Batch struct {
+	ID          string    `json:"id"`
+	TicketID    int64     `json:"ticket_id"`
+	ProductID   int64     `json:"product_id"`
+	Quantity    int       `json:"quantity"`
+	CompletedBy string    `json:"completed_by"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// BatchID is the batch id for one attempt at completing a ticket. Each
+// attempt gets its own id, so an attempt that was reversed never makes
+// inventory skip a later one, and undoing a failed attempt cannot touch the
+// booking of another.
+func BatchID(ticketID, attempt int64) string {
+	return fmt.Sprintf("batch-%d-%d", ticketID, attempt)
+}
+
+// OrderReference is the inventory reference under which an order is filled
+// from finished goods and batches; checkout reserves the order's stock under
+// the same reference.
+func OrderReference(orderID int64) string {
+	return fmt.Sprintf("order-%d", orderID)
+}
+
+// TicketOrder is one order's share of a ticket.
+type TicketOrder struct {
+	OrderID  int64 `json:"order_id"`
+	Quantity int   `json:"quantity"`
+}
+
+// PullItem is an ingredient and the amount of it a ticket needs.
+type PullItem struct {
+	IngredientID   int64   `json:"ingredient_id"`
+	IngredientName string  `json:"ingredient_name"`
+	Unit           string  `json:"unit"`
+	Amount         float64 `json:"amount"`
+}
+
+// TicketDemand is a ticket's product and quantity, used to compute its pull
+// list.
+type TicketDemand struct {
+	TicketID  int64
+	ProductID int64
+	Quantity  int
+}
+
+// OrderCreatedEvent is the order.created event published by the orders
+// service. Only the fields the kitchen needs are decoded.
+type OrderCreatedEvent struct {
+	Type       string    `json:"type"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Order      Order     `json:"order"`
+}
+
+// Order is the kitchen's view of a confirmed order.
+type Order struct {
+	ID    int64       `json:"id"`
+	Items []OrderItem `json:"items"`
+}
+
+// OrderItem is a line on an order. Subscription box lines have no
+// ProductID and are not made from a ticket.
+type OrderItem struct {
+	ProductID         int64  `json:"product_id"`
+	SubscriptionBoxID int64  `json:"subscription_box_id"`
+	Name              string `json:"name"`
+	Quantity          int    `json:"quantity"`
+}
@@ -0,0 +1,142 @@
+// Package notifier turns order events into kitchen tickets. Each order is
+// filled from finished goods on hand first; whatever is left of its product
+// lines is folded into one queued ticket per product, and every ticket
+// carries a pull list of the ingredients needed to make it, computed from
+// the recipes held by the inventory service.
+package notifier
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"com.MixieMelts.kitchen/internal/inventory"
+	"com.MixieMelts.kitchen/internal/models"
+)
+
+// Store persists tickets.
+type Store interface {
+	AddOrderToTickets(ctx context.Context, order models.Order) ([]int64, error)
+	StalePullLists(ctx context.Context) ([]models.TicketDemand, error)
+	SetPullList(ctx context.Context, ticketID int64, quantity int, items []models.PullItem) error
+}
+
+// Recipes expands product demand into ingredients.
+type Recipes interface {
+	Requirements(ctx context.Context, productID int64, quantity int) ([]models.PullItem, error)
+}
+
+// FinishedGoods fills orders from made-but-unsold stock.
+type FinishedGoods interface {
+	Allocate(ctx context.Context, reference string, items []models.OrderItem) (map[int64]int, error)
+}
+
+// Broadcaster announces ticket changes to live kitchen screens.
+type Broadcaster interface {
+	TicketChanged(ctx context.Context, ticketID int64)
+}
+
+// Notifier builds kitchen tickets from orders.
+type Notifier struct {
+	store   Store
+	recipes Recipes
+	goods   FinishedGoods
+	bcast   Broadcaster
+}
+
+// New creates a Notifier.
+func New(store Store, recipes Recipes, goods FinishedGoods, bcast Broadcaster) *Notifier {
+	return &Notifier{store: store, recipes: recipes, goods: goods, bcast: bcast}
+}
+
+// OrderCreated fills a confirmed order from finished goods, adds what is
+// left to the kitchen's tickets and refreshes the affected pull lists. If
+// finished goods cannot be allocated the order is not recorded, so nothing
+// is made twice; the error is returned for the sender to retry. Once
+// recorded, pull lists that could not be built are filled in by a later
+// refresh.
+func (n *Notifier) OrderCreated(ctx context.Context, order models.Order) error {
+	allocated, err := n.goods.Allocate(ctx, models.OrderReference(order.ID), order.Items)
+	if err != nil {
+		return err
+	}
+	order.Items = remaining(order.Items, allocated)
+
+	changed, err := n.store.AddOrderToTickets(ctx, order)
+	if err != nil {
+		return err
+	}
+	if err := n.RefreshPullLists(ctx); err != nil {
+		log.Printf("notifier: pull lists for order %d not refreshed: %v", order.ID, err)
+	}
+	for _, id := range changed {
+		n.bcast.TicketChanged(ctx, id)
+	}
+	return nil
+}
+
+// remaining returns the parts of items that allocated does not cover.
+func remaining(items []models.OrderItem, allocated map[int64]int) []models.OrderItem {
+	var rest []models.OrderItem
+	for _, it := range items {
+		if it.ProductID != 0 {
+			n := min(allocated[it.ProductID], it.Quantity)
+			allocated[it.ProductID] -= n
+			it.Quantity -= n
+		}
+		if it.Quantity > 0 {
+			rest = append(rest, it)
+		}
+	}
+	return rest
+}
+
+// RefreshPullLists recomputes the pull list of every ticket whose quantity
+// changed since its list was built. Products without a recipe get an empty
+// pull list. It returns the first error from inventory after trying every
+// ticket.
+func (n *Notifier) RefreshPullLists(ctx context.Context) error {
+	stale, err := n.store.StalePullLists(ctx)
+	if err != nil {
+		return err
+	}
+
+	var firstErr error
+	for _, d := range stale {
+		items, err := n.recipes.Requirements(ctx, d.ProductID, d.Quantity)
+		if errors.Is(err, inventory.ErrMissingRecipe) {
+			log.Printf("notifier: ticket %d has no pull list: %v", d.TicketID, err)
+			items, err = nil, nil
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if err := n.store.SetPullList(ctx, d.TicketID, d.Quantity, items); err != nil {
+			return err
+		}
+		n.bcast.TicketChanged(ctx, d.TicketID)
+	}
+	return firstErr
+}
+
+// Run refreshes stale pull lists every interval until ctx is cancelled, so
+// tickets recover from inventory outages.
+func (n *Notifier) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := n.RefreshPullLists(ctx); err != nil {
+			log.Printf("notifier: refresh failed: %v", err)
+		}
+	}
+}
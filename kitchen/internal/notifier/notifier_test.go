@@ -0,0 +1,221 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"testing"
+
+	"com.MixieMelts.kitchen/internal/inventory"
+	"com.MixieMelts.kitchen/internal/models"
+)
+
+// fakeStore keeps queued tickets in memory, one per product.
+type fakeStore struct {
+	tickets map[int64]*models.Ticket // by product id
+	seen    map[[2]int64]bool        // order id, product id
+	listed  map[int64]int            // ticket id -> quantity the pull list was built for
+	nextID  int64
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{tickets: map[int64]*models.Ticket{}, seen: map[[2]int64]bool{}, listed: map[int64]int{}}
+}
+
+func (f *fakeStore) AddOrderToTickets(ctx context.Context, order models.Order) ([]int64, error) {
+	var changed []int64
+	for _, it := range order.Items {
+		if it.ProductID == 0 || f.seen[[2]int64{order.ID, it.ProductID}] {
+			continue
+		}
+		f.seen[[2]int64{order.ID, it.ProductID}] = true
+		t, ok := f.tickets[it.ProductID]
+		if !ok {
+			f.nextID++
+			t = &models.Ticket{ID: f.nextID, ProductID: it.ProductID, ProductName: it.Name, Status: models.TicketStatusQueued}
+			f.tickets[it.ProductID] = t
+		}
+		t.Quantity += it.Quantity
+		t.Orders = append(t.Orders, models.TicketOrder{OrderID: order.ID, Quantity: it.Quantity})
+		changed = append(changed, t.ID)
+	}
+	return changed, nil
+}
+
+func (f *fakeStore) StalePullLists(ctx context.Context) ([]models.TicketDemand, error) {
+	var stale []models.TicketDemand
+	for _, t := range f.tickets {
+		if q, ok := f.listed[t.ID]; !ok || q != t.Quantity {
+			stale = append(stale, models.TicketDemand{TicketID: t.ID, ProductID: t.ProductID, Quantity: t.Quantity})
+		}
+	}
+	return stale, nil
+}
+
+func (f *fakeStore) SetPullList(ctx context.Context, ticketID int64, quantity int, items []models.PullItem) error {
+	for _, t := range f.tickets {
+		if t.ID == ticketID && t.Quantity == quantity {
+			f.listed[ticketID] = quantity
+			t.PullList = items
+		}
+	}
+	return nil
+}
+
+// fakeRecipes needs 2.5 g of wax per melt for every product except 99, which
+// has no recipe.
+type fakeRecipes struct {
+	err error
+}
+
+func (f *fakeRecipes) Requirements(ctx context.Context, productID int64, quantity int) ([]models.PullItem, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	if productID == 99 {
+		return nil, inventory.ErrMissingRecipe
+	}
+	return []models.PullItem{{IngredientID: 1, IngredientName: "Soy Wax", Unit: "g", Amount: 2.5 * float64(quantity)}}, nil
+}
+
+// fakeGoods fills orders from finished goods on hand, remembering each
+// order's allocation like inventory does.
+type fakeGoods struct {
+	onHand map[int64]int
+	done   map[string]map[int64]int
+	err    error
+}
+
+func (f *fakeGoods) Allocate(ctx context.Context, reference string, items []models.OrderItem) (map[int64]int, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	if f.done == nil {
+		f.done = map[string]map[int64]int{}
+	}
+	if prior, ok := f.done[reference]; ok {
+		return maps.Clone(prior), nil
+	}
+	allocated := map[int64]int{}
+	for _, it := range items {
+		if n := min(f.onHand[it.ProductID], it.Quantity); n > 0 {
+			f.onHand[it.ProductID] -= n
+			allocated[it.ProductID] += n
+		}
+	}
+	f.done[reference] = maps.Clone(allocated)
+	return allocated, nil
+}
+
+// fakeBroadcaster records announced ticket ids.
+type fakeBroadcaster struct {
+	changed []int64
+}
+
+func (f *fakeBroadcaster) TicketChanged(ctx context.Context, ticketID int64) {
+	f.changed = append(f.changed, ticketID)
+}
+
+func TestOrderCreatedAggregatesTickets(t *testing.T) {
+	store := newFakeStore()
+	bcast := &fakeBroadcaster{}
+	n := New(store, &fakeRecipes{}, &fakeGoods{}, bcast)
+	ctx := context.Background()
+
+	orders := []models.Order{
+		{ID: 1, Items: []models.OrderItem{{ProductID: 1, Name: "Serene Sanctuary", Quantity: 10}, {SubscriptionBoxID: 1, Name: "Monthly Melt Box", Quantity: 1}}},
+		{ID: 2, Items: []models.OrderItem{{ProductID: 1, Name: "Serene Sanctuary", Quantity: 14}, {ProductID: 99, Name: "Mystery Melt", Quantity: 1}}},
+	}
+	for _, o := range orders {
+		if err := n.OrderCreated(ctx, o); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	announced := len(bcast.changed)
+	// Redelivered events are ignored.
+	if err := n.OrderCreated(ctx, orders[1]); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(bcast.changed) != announced {
+		t.Fatalf("expected no announcements for a redelivered event, got %v", bcast.changed[announced:])
+	}
+
+	if len(store.tickets) != 2 {
+		t.Fatalf("expected tickets for 2 products (boxes excluded), got %d", len(store.tickets))
+	}
+	serene := store.tickets[1]
+	if serene.Quantity != 24 || len(serene.Orders) != 2 {
+		t.Fatalf("expected one ticket for 24 melts from 2 orders, got %+v", serene)
+	}
+	if len(serene.PullList) != 1 || serene.PullList[0].Amount != 60 {
+		t.Fatalf("expected pull list of 60 g wax, got %+v", serene.PullList)
+	}
+	if mystery := store.tickets[99]; len(mystery.PullList) != 0 || store.listed[mystery.ID] != 1 {
+		t.Fatalf("expected an empty, up to date pull list without a recipe, got %+v", mystery)
+	}
+}
+
+func TestPullListsRecoverFromInventoryOutage(t *testing.T) {
+	store := newFakeStore()
+	recipes := &fakeRecipes{err: errors.New("connection refused")}
+	n := New(store, recipes, &fakeGoods{}, &fakeBroadcaster{})
+	ctx := context.Background()
+
+	order := models.Order{ID: 1, Items: []models.OrderItem{{ProductID: 1, Name: "Serene Sanctuary", Quantity: 4}}}
+	if err := n.OrderCreated(ctx, order); err != nil {
+		t.Fatalf("order should be recorded while recipes are unavailable, got %v", err)
+	}
+	if store.tickets[1].Quantity != 4 || store.tickets[1].PullList != nil {
+		t.Fatalf("expected ticket without pull list, got %+v", store.tickets[1])
+	}
+
+	recipes.err = nil
+	if err := n.RefreshPullLists(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := store.tickets[1].PullList; len(got) != 1 || got[0].Amount != 10 {
+		t.Fatalf("expected pull list after recovery, got %+v", got)
+	}
+}
+
+func TestOrderCreatedDrawsFinishedGoodsFirst(t *testing.T) {
+	store := newFakeStore()
+	goods := &fakeGoods{onHand: map[int64]int{1: 10, 2: 6}}
+	n := New(store, &fakeRecipes{}, goods, &fakeBroadcaster{})
+	ctx := context.Background()
+
+	order := models.Order{ID: 1, Items: []models.OrderItem{
+		{ProductID: 1, Name: "Serene Sanctuary", Quantity: 24},
+		{ProductID: 2, Name: "Maple Morning", Quantity: 6},
+	}}
+	if err := n.OrderCreated(ctx, order); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Redelivery must not take stock or add demand again.
+	if err := n.OrderCreated(ctx, order); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if serene := store.tickets[1]; serene == nil || serene.Quantity != 14 {
+		t.Fatalf("expected a ticket for the 14 melts not on hand, got %+v", serene)
+	}
+	if maple, ok := store.tickets[2]; ok {
+		t.Fatalf("expected no ticket for a line covered by finished goods, got %+v", maple)
+	}
+	if goods.onHand[1] != 0 || goods.onHand[2] != 0 {
+		t.Fatalf("expected finished goods to be used up, got %v", goods.onHand)
+	}
+}
+
+func TestOrderCreatedWithoutFinishedGoods(t *testing.T) {
+	store := newFakeStore()
+	n := New(store, &fakeRecipes{}, &fakeGoods{err: errors.New("connection refused")}, &fakeBroadcaster{})
+
+	order := models.Order{ID: 1, Items: []models.OrderItem{{ProductID: 1, Name: "Serene Sanctuary", Quantity: 4}}}
+	if err := n.OrderCreated(context.Background(), order); err == nil {
+		t.Fatal("expected an error when finished goods cannot be allocated")
+	}
+	if len(store.tickets) != 0 {
+		t.Fatalf("expected no demand without an allocation, got %+v", store.tickets)
+	}
+}
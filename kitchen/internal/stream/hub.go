@@ -0,0 +1,84 @@
+// Package stream fans ticket changes out to live subscribers, such as kitchen
+// screens listening over server-sent events.
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+
+	"com.MixieMelts.kitchen/internal/models"
+)
+
+// subscriberBuffer is how many messages a subscriber may fall behind before
+// it is dropped. Dropped subscribers see their channel closed and are
+// expected to reconnect and reload the ticket list.
+const subscriberBuffer = 32
+
+// Loader reads the current state of a ticket.
+type Loader interface {
+	GetTicket(ctx context.Context, id int64) (*models.Ticket, error)
+}
+
+// Hub broadcasts the full JSON of every changed ticket to all subscribers.
+type Hub struct {
+	loader Loader
+
+	mu   sync.Mutex
+	subs map[chan []byte]struct{}
+}
+
+// NewHub creates a Hub that loads changed tickets through loader.
+func NewHub(loader Loader) *Hub {
+	return &Hub{loader: loader, subs: map[chan []byte]struct{}{}}
+}
+
+// Subscribe registers a new subscriber. The returned function unsubscribes
+// it and must be called when the subscriber goes away.
+func (h *Hub) Subscribe() (<-chan []byte, func()) {
+	ch := make(chan []byte, subscriberBuffer)
+	h.mu.Lock()
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.subs[ch]; ok {
+			delete(h.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// TicketChanged broadcasts the current state of ticket id. Failures are
+// logged; a missed update is repaired by the subscriber's next reload.
+func (h *Hub) TicketChanged(ctx context.Context, id int64) {
+	t, err := h.loader.GetTicket(ctx, id)
+	if err != nil || t == nil {
+		log.Printf("stream: ticket %d not broadcast: %v", id, err)
+		return
+	}
+	h.Publish(t)
+}
+
+// Publish broadcasts t to every subscriber, dropping any that cannot keep up.
+func (h *Hub) Publish(t *models.Ticket) {
+	msg, err := json.Marshal(t)
+	if err != nil {
+		log.Printf("stream: encode ticket %d: %v", t.ID, err)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- msg:
+		default:
+			delete(h.subs, ch)
+			close(ch)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"com.MixieMelts.kitchen/internal/models"
+)
+
+type fakeLoader map[int64]*models.Ticket
+
+func (f fakeLoader) GetTicket(ctx context.Context, id int64) (*models.Ticket, error) {
+	return f[id], nil
+}
+
+func TestTicketChangedReachesSubscribers(t *testing.T) {
+	h := NewHub(fakeLoader{3: {ID: 3, Status: models.TicketStatusInProgress}})
+	updates, unsubscribe := h.Subscribe()
+	defer unsubscribe()
+
+	h.TicketChanged(context.Background(), 3)
+	h.TicketChanged(context.Background(), 9) // missing tickets are not sent
+
+	var got models.Ticket
+	if err := json.Unmarshal(<-updates, &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.ID != 3 || got.Status != models.TicketStatusInProgress {
+		t.Fatalf("unexpected ticket %+v", got)
+	}
+	select {
+	case msg := <-updates:
+		t.Fatalf("unexpected message %s", msg)
+	default:
+	}
+}
+
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	h := NewHub(fakeLoader{})
+	updates, unsubscribe := h.Subscribe()
+
+	for i := 0; i <= subscriberBuffer; i++ {
+		h.Publish(&models.Ticket{ID: int64(i)})
+	}
+	for range subscriberBuffer {
+		<-updates
+	}
+	if _, ok := <-updates; ok {
+		t.Fatal("expected the subscriber's channel to be closed")
+	}
+
+	// Unsubscribing after being dropped is safe.
+	unsubscribe()
+}
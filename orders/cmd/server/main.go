@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"com.MixieMelts.orders/internal/cart"
+	"com.MixieMelts.orders/internal/checkout"
+	"com.MixieMelts.orders/internal/database"
+	"com.MixieMelts.orders/internal/events"
+	"com.MixieMelts.orders/internal/handlers"
+	"com.MixieMelts.orders/internal/inventory"
+	"com.MixieMelts.orders/internal/products"
+	"com.MixieMelts.users/jwtverify"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/joho/godotenv"
+)
+
+// getenv returns the environment variable key, or fallback when it is unset.
+func getenv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func main() {
+	// Load .env file if present
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found or failed to load; falling back to environment variables")
+	}
+
+	// Create database connection
+	dbURL := os.Getenv("DATABASE_URL")
+	if dbURL == "" {
+		log.Fatal("DATABASE_URL must be set")
+	}
+
+	db, err := database.New(dbURL)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	// Clients for the services taking part in checkout
+	productsClient := products.New(getenv("PRODUCTS_URL", "http://products:8082"))
+	inventoryClient := inventory.New(getenv("INVENTORY_URL", "http://inventory:8083"), os.Getenv("INTERNAL_API_TOKEN"))
+	cartClient := cart.New(getenv("CART_URL", "http://cart:8084"))
+
+	// Order events are queued with the change they announce and posted to
+	// every URL in ORDER_EVENT_WEBHOOKS, with the shared INTERNAL_API_TOKEN,
+	// until accepted
+	var webhooks []string
+	for _, u := range strings.Split(os.Getenv("ORDER_EVENT_WEBHOOKS"), ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			webhooks = append(webhooks, u)
+		}
+	}
+	outboxInterval, err := time.ParseDuration(getenv("OUTBOX_INTERVAL", "5s"))
+	if err != nil {
+		log.Fatalf("Invalid OUTBOX_INTERVAL: %v", err)
+	}
+	go events.NewRelay(db, events.NewWebhookPublisher(webhooks, os.Getenv("INTERNAL_API_TOKEN"))).Run(context.Background(), outboxInterval)
+
+	co := checkout.New(db, productsClient, inventoryClient)
+	h := handlers.New(db, co, cartClient)
+
+	// Tokens are verified against the users service's published keys
+	verifier, err := jwtverify.Configure(os.Getenv("JWKS_URL"), os.Getenv("JWT_SECRET_KEY"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Router setup
+	r := chi.NewRouter()
+	r.Use(middleware.Logger)
+	r.Use(middleware.Recoverer)
+	r.Use(middleware.Timeout(60 * time.Second))
+
+	// Order routes - all require a signed-in customer
+	r.Group(func(r chi.Router) {
+		r.Use(verifier.Middleware)
+		r.Post("/orders", h.PlaceOrder)
+		r.Get("/orders", h.GetOrders)
+		r.Get("/orders/{id}", h.GetOrder)
+	})
+
+	// Internal routes for other services, which authenticate with the shared
+	// INTERNAL_API_TOKEN
+	internalToken := os.Getenv("INTERNAL_API_TOKEN")
+	if internalToken == "" {
+		log.Println("INTERNAL_API_TOKEN is not set; internal routes will refuse every request")
+	}
+	r.With(handlers.RequireServiceToken(internalToken)).Post("/internal/orders", h.CreateInternalOrder)
+
+	// Health check
+	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	})
+
+	// Start server
+	port := getenv("PORT", "8085")
+
+	log.Printf("Starting orders service on port %s", port)
+	if err := http.ListenAndServe(":"+port, r); err != nil {
+		log.Fatalf("Failed to start server: %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+// Package cart is a small HTTP client for the cart service. Requests are made
+// on behalf of the customer by forwarding their Authorization header.
+package cart
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Item is a line in the customer's cart.
+type Item struct {
+	ProductID int64   `json:"product_id"`
+	Name      string  `json:"name"`
+	UnitPrice float64 `json:"unit_price"`
+	Quantity  int     `json:"quantity"`
+}
+
+// Cart is the subset of the cart service representation orders needs.
+// HoldReference is the inventory reservation holding stock for the cart.
+type Cart struct {
+	HoldReference string `json:"hold_reference"`
+	Items         []Item `json:"items"`
+}
+
+// Client talks to the cart service over HTTP.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// New creates a client for the cart service rooted at baseURL
+// (e.g. "http://cart:8084").
+func New(baseURL string) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// GetCart returns the cart belonging to the caller identified by authorization.
+func (c *Client) GetCart(ctx context.Context, authorization string) (*Cart, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/cart", nil)
+	if err != nil {
+		return nil, fmt.Errorf("GetCart build request: %w", err)
+	}
+	req.Header.Set("Authorization", authorization)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("GetCart request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GetCart: unexpected status %d", resp.StatusCode)
+	}
+	var cart Cart
+	if err := json.NewDecoder(resp.Body).Decode(&cart); err != nil {
+		return nil, fmt.Errorf("GetCart decode: %w", err)
+	}
+	return &cart, nil
+}
+
+// ClearCart empties the cart belonging to the caller identified by authorization.
+func (c *Client) ClearCart(ctx context.Context, authorization string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, c.baseURL+"/cart", nil)
+	if err != nil {
+		return fmt.Errorf("ClearCart build request: %w", err)
+	}
+	req.Header.Set("Authorization", authorization)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("ClearCart request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("ClearCart: unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
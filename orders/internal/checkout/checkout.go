@@ -0,0 +1,297 @@
+// Package checkout turns requested order lines into a confirmed order. It runs
+// the work as a saga: each step that changes state in this or another service
+// registers a compensation, and a failure at any step runs the compensations
+// of that step and the completed ones in reverse so the order rolls back as a
+// whole.
+package checkout
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"com.MixieMelts.orders/internal/events"
+	"com.MixieMelts.orders/internal/inventory"
+	"com.MixieMelts.orders/internal/models"
+	"com.MixieMelts.orders/internal/products"
+)
+
+var (
+	// ErrEmptyOrder is returned when there is nothing to order.
+	ErrEmptyOrder = errors.New("order has no items")
+	// ErrInvalidLine is returned for a line that does not name exactly one
+	// product or subscription box, or has a non-positive quantity.
+	ErrInvalidLine = errors.New("invalid order line")
+	// ErrUnknownProduct is returned when a line names a product or
+	// subscription box the products service does not know.
+	ErrUnknownProduct = errors.New("unknown product")
+)
+
+// Request describes an order to place.
+type Request struct {
+	UserID string
+	// Source records where the order came from; it defaults to
+	// models.OrderSourceCheckout.
+	Source string
+	// Reference is an optional idempotency key stored on the order.
+	Reference string
+	// CartHold is the reservation holding stock for the cart the order is
+	// placed from, if any. It is released before the order reserves its own
+	// stock, so the customer's cart does not compete with their order.
+	CartHold string
+	Lines    []models.OrderLine
+}
+
+// Store persists orders.
+type Store interface {
+	CreateOrder(ctx context.Context, order *models.Order) (int64, error)
+	// ConfirmOrder marks the order confirmed and queues e for delivery in
+	// the same transaction.
+	ConfirmOrder(ctx context.Context, id int64, e events.Event) error
+	// DeleteOrder removes the order created under checkoutID, if any.
+	DeleteOrder(ctx context.Context, checkoutID string) error
+}
+
+// Catalog looks up current product and subscription box names and prices.
+type Catalog interface {
+	GetProduct(ctx context.Context, id int64) (*products.Product, error)
+	GetSubscriptionBox(ctx context.Context, id int64) (*products.SubscriptionBox, error)
+}
+
+// Inventory holds stock for orders.
+type Inventory interface {
+	Reserve(ctx context.Context, reference string, lines []inventory.Line) error
+	Confirm(ctx context.Context, reference string) error
+	Release(ctx context.Context, reference string) error
+}
+
+// Service places orders.
+type Service struct {
+	store     Store
+	catalog   Catalog
+	inventory Inventory
+}
+
+// New creates a checkout Service.
+func New(store Store, catalog Catalog, inv Inventory) *Service {
+	return &Service{store: store, catalog: catalog, inventory: inv}
+}
+
+// step is one unit of saga work and the action that undoes it.
+type step struct {
+	name       string
+	run        func(ctx context.Context) error
+	compensate func(ctx context.Context) error
+}
+
+// runSaga executes steps in order. If a step fails, its own compensation and
+// those of all previously completed steps run in reverse order and the step's
+// error is returned. The failed step is compensated too because a call that
+// errors, e.g. on a timeout, may still have taken effect; compensations must
+// therefore be safe to run for work that never happened. They run on a
+// context detached from ctx's cancellation so a client hanging up
+// mid-checkout cannot leave a half-placed order.
+func runSaga(ctx context.Context, steps []step) error {
+	for i, st := range steps {
+		if err := st.run(ctx); err != nil {
+			cctx := context.WithoutCancel(ctx)
+			for j := i; j >= 0; j-- {
+				if steps[j].compensate == nil {
+					continue
+				}
+				if cerr := steps[j].compensate(cctx); cerr != nil {
+					log.Printf("checkout: compensation %q failed: %v", steps[j].name, cerr)
+				}
+			}
+			return fmt.Errorf("%s: %w", st.name, err)
+		}
+	}
+	return nil
+}
+
+// PlaceOrder snapshots prices for the request's lines, records a pending
+// order, reserves the stock its products need in inventory under the
+// order's reference, confirms the reservation and then the order. If any
+// step fails, the reservation is released and the order deleted; the order
+// is found by a checkout id generated for this call, so it is removed even
+// when creating it failed after the row was written. The
+// reservation is what keeps two checkouts from promising the same stock,
+// and confirming it consumes the order's ingredients through inventory's
+// ledger under the order's reference.
+// Subscription box lines are priced but not reserved; their contents are
+// picked when the box is packed.
+//
+// The order.created event is queued with the confirmation, so it is
+// delivered, with retries, exactly when the order is confirmed.
+func (s *Service) PlaceOrder(ctx context.Context, req Request) (*models.Order, error) {
+	lines, err := mergeLines(req.Lines)
+	if err != nil {
+		return nil, err
+	}
+
+	checkoutID, err := newCheckoutID()
+	if err != nil {
+		return nil, err
+	}
+
+	source := req.Source
+	if source == "" {
+		source = models.OrderSourceCheckout
+	}
+	order := &models.Order{
+		UserID:     req.UserID,
+		Status:     models.OrderStatusPending,
+		Source:     source,
+		Reference:  req.Reference,
+		CheckoutID: checkoutID,
+	}
+	var reserved []inventory.Line
+
+	steps := []step{
+		{
+			name: "snapshot prices",
+			run: func(ctx context.Context) error {
+				for _, l := range lines {
+					item, err := s.snapshot(ctx, l)
+					if err != nil {
+						return err
+					}
+					order.Items = append(order.Items, item)
+					if item.ProductID != 0 {
+						reserved = append(reserved, inventory.Line{ProductID: item.ProductID, Quantity: float64(item.Quantity)})
+					}
+				}
+				order.ComputeTotal()
+				return nil
+			},
+		},
+		{
+			name: "create order",
+			run: func(ctx context.Context) error {
+				id, err := s.store.CreateOrder(ctx, order)
+				if err != nil {
+					return err
+				}
+				order.ID = id
+				return nil
+			},
+			compensate: func(ctx context.Context) error {
+				return s.store.DeleteOrder(ctx, order.CheckoutID)
+			},
+		},
+		{
+			name: "release cart hold",
+			run: func(ctx context.Context) error {
+				if req.CartHold == "" {
+					return nil
+				}
+				return s.inventory.Release(ctx, req.CartHold)
+			},
+		},
+		{
+			name: "reserve stock",
+			run: func(ctx context.Context) error {
+				if len(reserved) == 0 {
+					return nil
+				}
+				return s.inventory.Reserve(ctx, inventory.OrderReference(order.ID), reserved)
+			},
+			compensate: func(ctx context.Context) error {
+				if len(reserved) == 0 || order.ID == 0 {
+					return nil
+				}
+				return s.inventory.Release(ctx, inventory.OrderReference(order.ID))
+			},
+		},
+		{
+			// Released by the reserve step's compensation if the order
+			// cannot be confirmed after this.
+			name: "confirm reservation",
+			run: func(ctx context.Context) error {
+				if len(reserved) == 0 {
+					return nil
+				}
+				return s.inventory.Confirm(ctx, inventory.OrderReference(order.ID))
+			},
+		},
+		{
+			name: "confirm order",
+			run: func(ctx context.Context) error {
+				confirmed := *order
+				confirmed.Status = models.OrderStatusConfirmed
+				ev := events.Event{Type: events.TypeOrderCreated, OccurredAt: time.Now().UTC(), Order: confirmed}
+				if err := s.store.ConfirmOrder(ctx, order.ID, ev); err != nil {
+					return err
+				}
+				order.Status = models.OrderStatusConfirmed
+				return nil
+			},
+		},
+	}
+
+	if err := runSaga(ctx, steps); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// newCheckoutID returns a random id for one checkout attempt.
+func newCheckoutID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("checkout id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// snapshot prices a single line from the catalog.
+func (s *Service) snapshot(ctx context.Context, l models.OrderLine) (models.OrderItem, error) {
+	if l.SubscriptionBoxID != 0 {
+		b, err := s.catalog.GetSubscriptionBox(ctx, l.SubscriptionBoxID)
+		if errors.Is(err, products.ErrNotFound) {
+			return models.OrderItem{}, fmt.Errorf("%w: subscription box %d", ErrUnknownProduct, l.SubscriptionBoxID)
+		}
+		if err != nil {
+			return models.OrderItem{}, err
+		}
+		return models.OrderItem{SubscriptionBoxID: b.ID, Name: b.Name, UnitPrice: b.Price, Quantity: l.Quantity}, nil
+	}
+
+	p, err := s.catalog.GetProduct(ctx, l.ProductID)
+	if errors.Is(err, products.ErrNotFound) {
+		return models.OrderItem{}, fmt.Errorf("%w: %d", ErrUnknownProduct, l.ProductID)
+	}
+	if err != nil {
+		return models.OrderItem{}, err
+	}
+	return models.OrderItem{ProductID: p.ID, Name: p.Name, UnitPrice: p.Price, Quantity: l.Quantity}, nil
+}
+
+// mergeLines validates lines and folds repeated products and subscription
+// boxes into one line each.
+func mergeLines(lines []models.OrderLine) ([]models.OrderLine, error) {
+	if len(lines) == 0 {
+		return nil, ErrEmptyOrder
+	}
+	type key struct{ product, box int64 }
+	var merged []models.OrderLine
+	index := map[key]int{}
+	for _, l := range lines {
+		oneOf := (l.ProductID > 0) != (l.SubscriptionBoxID > 0)
+		if !oneOf || l.ProductID < 0 || l.SubscriptionBoxID < 0 || l.Quantity <= 0 {
+			return nil, fmt.Errorf("%w: product %d box %d quantity %d", ErrInvalidLine, l.ProductID, l.SubscriptionBoxID, l.Quantity)
+		}
+		k := key{l.ProductID, l.SubscriptionBoxID}
+		if i, ok := index[k]; ok {
+			merged[i].Quantity += l.Quantity
+			continue
+		}
+		index[k] = len(merged)
+		merged = append(merged, l)
+	}
+	return merged, nil
+}
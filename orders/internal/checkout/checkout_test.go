@@ -0,0 +1,294 @@
+package checkout
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"com.MixieMelts.orders/internal/events"
+	"com.MixieMelts.orders/internal/inventory"
+	"com.MixieMelts.orders/internal/models"
+	"com.MixieMelts.orders/internal/products"
+)
+
+// fakeStore records calls made by the saga.
+type fakeStore struct {
+	createErr error
+	statusErr error
+	created   *models.Order
+	deleted   []string
+	confirmed []events.Event
+}
+
+func (f *fakeStore) CreateOrder(ctx context.Context, order *models.Order) (int64, error) {
+	f.created = order
+	if f.createErr != nil {
+		return 0, f.createErr
+	}
+	return 10, nil
+}
+
+func (f *fakeStore) ConfirmOrder(ctx context.Context, id int64, e events.Event) error {
+	if f.statusErr != nil {
+		return f.statusErr
+	}
+	f.confirmed = append(f.confirmed, e)
+	return nil
+}
+
+func (f *fakeStore) DeleteOrder(ctx context.Context, checkoutID string) error {
+	f.deleted = append(f.deleted, checkoutID)
+	return nil
+}
+
+type fakeCatalog struct {
+	products map[int64]products.Product
+	boxes    map[int64]products.SubscriptionBox
+}
+
+func (f fakeCatalog) GetProduct(ctx context.Context, id int64) (*products.Product, error) {
+	p, ok := f.products[id]
+	if !ok {
+		return nil, products.ErrNotFound
+	}
+	return &p, nil
+}
+
+func (f fakeCatalog) GetSubscriptionBox(ctx context.Context, id int64) (*products.SubscriptionBox, error) {
+	b, ok := f.boxes[id]
+	if !ok {
+		return nil, products.ErrNotFound
+	}
+	return &b, nil
+}
+
+// fakeInventory records the reservations made, confirmed and released.
+type fakeInventory struct {
+	reserveErr error
+	confirmErr error
+	reserved   map[string][]inventory.Line
+	confirmed  []string
+	released   []string
+}
+
+func (f *fakeInventory) Reserve(ctx context.Context, reference string, lines []inventory.Line) error {
+	if f.reserved == nil {
+		f.reserved = map[string][]inventory.Line{}
+	}
+	f.reserved[reference] = lines
+	return f.reserveErr
+}
+
+func (f *fakeInventory) Confirm(ctx context.Context, reference string) error {
+	if f.confirmErr != nil {
+		return f.confirmErr
+	}
+	f.confirmed = append(f.confirmed, reference)
+	return nil
+}
+
+func (f *fakeInventory) Release(ctx context.Context, reference string) error {
+	f.released = append(f.released, reference)
+	return nil
+}
+
+func TestPlaceOrder(t *testing.T) {
+	catalog := fakeCatalog{
+		products: map[int64]products.Product{
+			1: {ID: 1, Name: "Serene Sanctuary", Price: 5.49},
+			2: {ID: 2, Name: "Citrus Sunshine", Price: 3.99},
+		},
+		boxes: map[int64]products.SubscriptionBox{
+			1: {ID: 1, Name: "Monthly Melt Box", Price: 24.99},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		lines        []models.OrderLine
+		createErr    error
+		reserveErr   error
+		confirmErr   error
+		statusErr    error
+		wantErr      error
+		wantAnyErr   bool
+		wantDeleted  bool
+		wantReserved int
+		wantReleased bool
+		wantItems    int
+		wantTotal    float64
+	}{
+		{
+			name:         "confirmed",
+			lines:        []models.OrderLine{{ProductID: 1, Quantity: 2}, {ProductID: 2, Quantity: 1}, {ProductID: 1, Quantity: 1}},
+			wantReserved: 2,
+			wantItems:    2,
+			wantTotal:    3*5.49 + 3.99,
+		},
+		{
+			name:      "subscription box skips inventory",
+			lines:     []models.OrderLine{{SubscriptionBoxID: 1, Quantity: 1}},
+			wantItems: 1,
+			wantTotal: 24.99,
+		},
+		{
+			name:         "box and product with the same id stay separate",
+			lines:        []models.OrderLine{{SubscriptionBoxID: 1, Quantity: 1}, {ProductID: 1, Quantity: 1}},
+			wantReserved: 1,
+			wantItems:    2,
+			wantTotal:    24.99 + 5.49,
+		},
+		{name: "empty", lines: nil, wantErr: ErrEmptyOrder},
+		{name: "invalid quantity", lines: []models.OrderLine{{ProductID: 1, Quantity: 0}}, wantErr: ErrInvalidLine},
+		{name: "product and box on one line", lines: []models.OrderLine{{ProductID: 1, SubscriptionBoxID: 1, Quantity: 1}}, wantErr: ErrInvalidLine},
+		{name: "unknown product", lines: []models.OrderLine{{ProductID: 9, Quantity: 1}}, wantErr: ErrUnknownProduct},
+		{name: "unknown box", lines: []models.OrderLine{{SubscriptionBoxID: 9, Quantity: 1}}, wantErr: ErrUnknownProduct},
+		{
+			name:        "create fails still deletes by checkout id",
+			lines:       []models.OrderLine{{ProductID: 1, Quantity: 1}},
+			createErr:   errors.New("db down"),
+			wantAnyErr:  true,
+			wantDeleted: true,
+		},
+		{
+			name:         "inventory short deletes order",
+			lines:        []models.OrderLine{{ProductID: 1, Quantity: 1}},
+			reserveErr:   inventory.ErrInsufficientStock,
+			wantErr:      inventory.ErrInsufficientStock,
+			wantDeleted:  true,
+			wantReserved: 1,
+			wantReleased: true,
+		},
+		{
+			name:         "reservation lapsed releases it and deletes order",
+			lines:        []models.OrderLine{{ProductID: 1, Quantity: 1}},
+			confirmErr:   inventory.ErrReservationNotActive,
+			wantErr:      inventory.ErrReservationNotActive,
+			wantDeleted:  true,
+			wantReserved: 1,
+			wantReleased: true,
+		},
+		{
+			name:         "confirm fails releases stock and deletes order",
+			lines:        []models.OrderLine{{ProductID: 1, Quantity: 1}},
+			statusErr:    errors.New("db down"),
+			wantAnyErr:   true,
+			wantDeleted:  true,
+			wantReserved: 1,
+			wantReleased: true,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			store := &fakeStore{createErr: tc.createErr, statusErr: tc.statusErr}
+			inv := &fakeInventory{reserveErr: tc.reserveErr, confirmErr: tc.confirmErr}
+			svc := New(store, catalog, inv)
+
+			order, err := svc.PlaceOrder(context.Background(), Request{UserID: "42", Lines: tc.lines})
+
+			if tc.wantErr != nil || tc.wantAnyErr {
+				if err == nil {
+					t.Fatalf("expected error, got order %+v", order)
+				}
+				if tc.wantErr != nil && !errors.Is(err, tc.wantErr) {
+					t.Fatalf("expected %v, got %v", tc.wantErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if gotDeleted := len(store.deleted) > 0; gotDeleted != tc.wantDeleted {
+				t.Fatalf("expected deleted=%v, got %v", tc.wantDeleted, store.deleted)
+			}
+			if tc.wantDeleted && (store.created == nil || store.created.CheckoutID == "" || store.deleted[0] != store.created.CheckoutID) {
+				t.Fatalf("expected the created order's checkout id to be deleted, got %v", store.deleted)
+			}
+			if got := len(inv.reserved["order-10"]); got != tc.wantReserved {
+				t.Fatalf("expected %d product lines reserved under order-10, got %v", tc.wantReserved, inv.reserved)
+			}
+			if gotReleased := len(inv.released) > 0; gotReleased != tc.wantReleased {
+				t.Fatalf("expected released=%v, got %v", tc.wantReleased, inv.released)
+			}
+
+			if err != nil {
+				if len(store.confirmed) != 0 {
+					t.Fatalf("expected no events for a failed order, got %v", store.confirmed)
+				}
+				return
+			}
+			if len(store.confirmed) != 1 {
+				t.Fatalf("expected one order.created event queued, got %+v", store.confirmed)
+			}
+			if ev := store.confirmed[0]; ev.Type != events.TypeOrderCreated || ev.Order.ID != order.ID || ev.Order.Status != models.OrderStatusConfirmed {
+				t.Fatalf("unexpected event %+v", ev)
+			}
+			if order.Status != models.OrderStatusConfirmed {
+				t.Fatalf("expected confirmed order, got %q", order.Status)
+			}
+			if order.Source != models.OrderSourceCheckout {
+				t.Fatalf("expected default source %q, got %q", models.OrderSourceCheckout, order.Source)
+			}
+			if len(order.Items) != tc.wantItems {
+				t.Fatalf("expected repeated lines to be merged into %d items, got %d", tc.wantItems, len(order.Items))
+			}
+			if diff := order.Total - tc.wantTotal; diff > 0.001 || diff < -0.001 {
+				t.Fatalf("expected total %.2f got %.2f", tc.wantTotal, order.Total)
+			}
+			if wantConfirmed := tc.wantReserved > 0; (len(inv.confirmed) == 1) != wantConfirmed {
+				t.Fatalf("expected reservation confirmed=%v, got %v", wantConfirmed, inv.confirmed)
+			}
+		})
+	}
+}
+
+func TestPlaceOrderReleasesCartHold(t *testing.T) {
+	catalog := fakeCatalog{products: map[int64]products.Product{1: {ID: 1, Name: "Serene Sanctuary", Price: 5.49}}}
+	store := &fakeStore{}
+	inv := &fakeInventory{}
+	svc := New(store, catalog, inv)
+
+	_, err := svc.PlaceOrder(context.Background(), Request{
+		UserID:   "42",
+		CartHold: "cart-abc",
+		Lines:    []models.OrderLine{{ProductID: 1, Quantity: 1}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(inv.released) != 1 || inv.released[0] != "cart-abc" {
+		t.Fatalf("expected the cart hold to be released, got %v", inv.released)
+	}
+	if len(inv.reserved["order-10"]) != 1 {
+		t.Fatalf("expected the order to reserve its own stock, got %v", inv.reserved)
+	}
+}
+
+func TestRunSagaCompensatesFailedStep(t *testing.T) {
+	var compensated []string
+	steps := []step{
+		{
+			name:       "first",
+			run:        func(ctx context.Context) error { return nil },
+			compensate: func(ctx context.Context) error { compensated = append(compensated, "first"); return nil },
+		},
+		{
+			name:       "second",
+			run:        func(ctx context.Context) error { return errors.New("timed out") },
+			compensate: func(ctx context.Context) error { compensated = append(compensated, "second"); return nil },
+		},
+		{
+			name:       "third",
+			run:        func(ctx context.Context) error { t.Fatal("ran a step after a failure"); return nil },
+			compensate: func(ctx context.Context) error { compensated = append(compensated, "third"); return nil },
+		},
+	}
+
+	if err := runSaga(context.Background(), steps); err == nil {
+		t.Fatal("expected the failed step's error")
+	}
+	if len(compensated) != 2 || compensated[0] != "second" || compensated[1] != "first" {
+		t.Fatalf("expected the failed step and then the completed one compensated, got %v", compensated)
+	}
+}
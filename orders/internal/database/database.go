@@ -0,0 +1,45 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DB is a thin wrapper around a pgx connection pool.
+type DB struct {
+	*pgxpool.Pool
+}
+
+// New creates a new database connection pool and ensures required tables exist.
+func New(config string) (*DB, error) {
+	pool, err := pgxpool.New(context.Background(), config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create connection pool: %w", err)
+	}
+
+	db := &DB{pool}
+
+	if err := db.createTables(context.Background()); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to create tables: %w", err)
+	}
+
+	log.Println("orders: successfully created database connection pool")
+	return db, nil
+}
+
+func (db *DB) createTables(ctx context.Context) error {
+	if err := db.createOrdersTable(ctx); err != nil {
+		return err
+	}
+	if err := db.createOrderItemsTable(ctx); err != nil {
+		return err
+	}
+	if err := db.createOrderEventsTable(ctx); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"com.MixieMelts.orders/internal/events"
+	"github.com/jackc/pgx/v5"
+)
+
+func (db *DB) createOrderEventsTable(ctx context.Context) error {
+	query := `CREATE TABLE IF NOT EXISTS order_events (
+		id BIGSERIAL PRIMARY KEY,
+		order_id BIGINT NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+		type VARCHAR(64) NOT NULL,
+		payload JSONB NOT NULL,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		published_at TIMESTAMP WITH TIME ZONE,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT
+	);
+	CREATE INDEX IF NOT EXISTS order_events_pending ON order_events (id) WHERE published_at IS NULL;`
+	_, err := db.Exec(ctx, query)
+	return err
+}
+
+// enqueueEventTx writes e to the order_events outbox inside tx.
+func enqueueEventTx(ctx context.Context, tx pgx.Tx, orderID int64, e events.Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("encode %s event: %w", e.Type, err)
+	}
+	if _, err := tx.Exec(ctx, `INSERT INTO order_events (order_id, type, payload) VALUES ($1,$2,$3)`, orderID, e.Type, body); err != nil {
+		return fmt.Errorf("enqueue %s event: %w", e.Type, err)
+	}
+	return nil
+}
+
+// PendingEvents returns up to limit undelivered order events, oldest first.
+func (db *DB) PendingEvents(ctx context.Context, limit int) ([]events.Event, error) {
+	rows, err := db.Query(ctx, `SELECT id, payload FROM order_events WHERE published_at IS NULL ORDER BY id LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("PendingEvents: %w", err)
+	}
+	defer rows.Close()
+
+	var list []events.Event
+	for rows.Next() {
+		var id int64
+		var payload []byte
+		if err := rows.Scan(&id, &payload); err != nil {
+			return nil, fmt.Errorf("PendingEvents scan: %w", err)
+		}
+		var e events.Event
+		if err := json.Unmarshal(payload, &e); err != nil {
+			return nil, fmt.Errorf("PendingEvents decode event %d: %w", id, err)
+		}
+		e.ID = id
+		list = append(list, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("PendingEvents rows: %w", err)
+	}
+	return list, nil
+}
+
+// MarkEventPublished records that an order event was delivered.
+func (db *DB) MarkEventPublished(ctx context.Context, id int64) error {
+	_, err := db.Exec(ctx, `UPDATE order_events SET published_at = NOW(), attempts = attempts + 1, last_error = NULL WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("MarkEventPublished: %w", err)
+	}
+	return nil
+}
+
+// MarkEventFailed records a failed delivery attempt of an order event.
+func (db *DB) MarkEventFailed(ctx context.Context, id int64, reason string) error {
+	_, err := db.Exec(ctx, `UPDATE order_events SET attempts = attempts + 1, last_error = $2 WHERE id = $1`, id, reason)
+	if err != nil {
+		return fmt.Errorf("MarkEventFailed: %w", err)
+	}
+	return nil
+}
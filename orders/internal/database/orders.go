@@ -0,0 +1,197 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"com.MixieMelts.orders/internal/events"
+	"com.MixieMelts.orders/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+func (db *DB) createOrdersTable(ctx context.Context) error {
+	query := `CREATE TABLE IF NOT EXISTS orders (
+		id SERIAL PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		status VARCHAR(32) NOT NULL,
+		source VARCHAR(32) NOT NULL DEFAULT 'checkout',
+		reference TEXT UNIQUE,
+		total NUMERIC(10, 2) NOT NULL DEFAULT 0,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	);`
+	if _, err := db.Exec(ctx, query); err != nil {
+		return err
+	}
+	_, err := db.Exec(ctx, `ALTER TABLE orders ADD COLUMN IF NOT EXISTS checkout_id TEXT UNIQUE`)
+	return err
+}
+
+func (db *DB) createOrderItemsTable(ctx context.Context) error {
+	query := `CREATE TABLE IF NOT EXISTS order_items (
+		id SERIAL PRIMARY KEY,
+		order_id BIGINT NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+		product_id BIGINT,
+		subscription_box_id BIGINT,
+		name TEXT NOT NULL,
+		unit_price NUMERIC(10, 2) NOT NULL,
+		quantity INTEGER NOT NULL CHECK (quantity > 0),
+		line_total NUMERIC(10, 2) NOT NULL,
+		CHECK ((product_id IS NULL) <> (subscription_box_id IS NULL))
+	);`
+	_, err := db.Exec(ctx, query)
+	return err
+}
+
+// CreateOrder inserts an order and its items in a single transaction and
+// returns the new order id.
+func (db *DB) CreateOrder(ctx context.Context, order *models.Order) (int64, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("CreateOrder begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var id int64
+	err = tx.QueryRow(ctx, `INSERT INTO orders (user_id, status, source, reference, checkout_id, total) VALUES ($1,$2,$3,NULLIF($4,''),NULLIF($5,''),$6) RETURNING id, created_at, updated_at`,
+		order.UserID, order.Status, order.Source, order.Reference, order.CheckoutID, order.Total).Scan(&id, &order.CreatedAt, &order.UpdatedAt)
+	if err != nil {
+		return 0, fmt.Errorf("CreateOrder insert order: %w", err)
+	}
+
+	for _, it := range order.Items {
+		_, err := tx.Exec(ctx, `INSERT INTO order_items (order_id, product_id, subscription_box_id, name, unit_price, quantity, line_total)
+			VALUES ($1,NULLIF($2,0),NULLIF($3,0),$4,$5,$6,$7)`,
+			id, it.ProductID, it.SubscriptionBoxID, it.Name, it.UnitPrice, it.Quantity, it.LineTotal)
+		if err != nil {
+			return 0, fmt.Errorf("CreateOrder insert item %q: %w", it.Name, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("CreateOrder commit: %w", err)
+	}
+	return id, nil
+}
+
+// ConfirmOrder marks an order confirmed and queues e in the order_events
+// outbox in the same transaction, so the event is delivered if and only if
+// the confirmation commits.
+func (db *DB) ConfirmOrder(ctx context.Context, id int64, e events.Event) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ConfirmOrder begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	tag, err := tx.Exec(ctx, `UPDATE orders SET status = $1, updated_at = NOW() WHERE id = $2`, models.OrderStatusConfirmed, id)
+	if err != nil {
+		return fmt.Errorf("ConfirmOrder: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("ConfirmOrder: order %d not found", id)
+	}
+	if err := enqueueEventTx(ctx, tx, id, e); err != nil {
+		return fmt.Errorf("ConfirmOrder: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ConfirmOrder commit: %w", err)
+	}
+	return nil
+}
+
+// DeleteOrder removes the order created by the given checkout attempt and
+// its items, if there is one. It is used to roll back an order whose
+// checkout saga failed, including when the insert's outcome is unknown.
+func (db *DB) DeleteOrder(ctx context.Context, checkoutID string) error {
+	if _, err := db.Exec(ctx, `DELETE FROM orders WHERE checkout_id = $1`, checkoutID); err != nil {
+		return fmt.Errorf("DeleteOrder: %w", err)
+	}
+	return nil
+}
+
+const orderColumns = `id, user_id, status, source, COALESCE(reference, ''), total, created_at, updated_at`
+
+func scanOrder(row pgx.Row, o *models.Order) error {
+	return row.Scan(&o.ID, &o.UserID, &o.Status, &o.Source, &o.Reference, &o.Total, &o.CreatedAt, &o.UpdatedAt)
+}
+
+// GetOrder returns a single order with its items, or nil if it does not exist.
+func (db *DB) GetOrder(ctx context.Context, id int64) (*models.Order, error) {
+	return db.getOrderWhere(ctx, "id = $1", id)
+}
+
+// GetOrderByReference returns the order created with the given idempotency
+// reference, or nil if there is none.
+func (db *DB) GetOrderByReference(ctx context.Context, reference string) (*models.Order, error) {
+	return db.getOrderWhere(ctx, "reference = $1", reference)
+}
+
+func (db *DB) getOrderWhere(ctx context.Context, where string, arg any) (*models.Order, error) {
+	var o models.Order
+	err := scanOrder(db.QueryRow(ctx, `SELECT `+orderColumns+` FROM orders WHERE `+where, arg), &o)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("GetOrder: %w", err)
+	}
+
+	items, err := db.getOrderItems(ctx, o.ID)
+	if err != nil {
+		return nil, err
+	}
+	o.Items = items
+	return &o, nil
+}
+
+// ListOrders returns a user's orders, newest first.
+func (db *DB) ListOrders(ctx context.Context, userID string) ([]models.Order, error) {
+	rows, err := db.Query(ctx, `SELECT `+orderColumns+` FROM orders WHERE user_id = $1 ORDER BY created_at DESC, id DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("ListOrders: %w", err)
+	}
+	defer rows.Close()
+
+	orders := []models.Order{}
+	for rows.Next() {
+		var o models.Order
+		if err := scanOrder(rows, &o); err != nil {
+			return nil, fmt.Errorf("ListOrders scan: %w", err)
+		}
+		orders = append(orders, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ListOrders rows: %w", err)
+	}
+
+	for i := range orders {
+		items, err := db.getOrderItems(ctx, orders[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		orders[i].Items = items
+	}
+	return orders, nil
+}
+
+func (db *DB) getOrderItems(ctx context.Context, orderID int64) ([]models.OrderItem, error) {
+	rows, err := db.Query(ctx, `SELECT COALESCE(product_id, 0), COALESCE(subscription_box_id, 0), name, unit_price, quantity, line_total
+		FROM order_items WHERE order_id = $1 ORDER BY id`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("getOrderItems: %w", err)
+	}
+	defer rows.Close()
+
+	items := []models.OrderItem{}
+	for rows.Next() {
+		var it models.OrderItem
+		if err := rows.Scan(&it.ProductID, &it.SubscriptionBoxID, &it.Name, &it.UnitPrice, &it.Quantity, &it.LineTotal); err != nil {
+			return nil, fmt.Errorf("getOrderItems scan: %w", err)
+		}
+		items = append(items, it)
+	}
+	return items, rows.Err()
+}
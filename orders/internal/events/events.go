@@ -0,0 +1,166 @@
+// Package events publishes order lifecycle events to other services over
+// HTTP webhooks. Events are queued in an outbox in the same transaction as
+// the change they announce, and a Relay delivers them until each is
+// accepted. Delivery is at least once, so consumers must ignore an order
+// they have already seen.
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"com.MixieMelts.orders/internal/models"
+)
+
+// TypeOrderCreated is published once an order has been confirmed.
+const TypeOrderCreated = "order.created"
+
+// Event is the JSON body posted to each webhook.
+type Event struct {
+	// ID is the event's outbox id, set once it has been queued.
+	ID         int64        `json:"id,omitempty"`
+	Type       string       `json:"type"`
+	OccurredAt time.Time    `json:"occurred_at"`
+	Order      models.Order `json:"order"`
+}
+
+// Publisher delivers events to interested services.
+type Publisher interface {
+	Publish(ctx context.Context, e Event) error
+}
+
+// serviceTokenHeader carries the shared token webhook receivers require.
+const serviceTokenHeader = "X-Service-Token"
+
+// WebhookPublisher posts every event to a fixed list of URLs.
+type WebhookPublisher struct {
+	urls       []string
+	token      string
+	httpClient *http.Client
+}
+
+// NewWebhookPublisher creates a publisher for urls that authenticates with
+// the shared service token. With no urls, Publish is a no-op.
+func NewWebhookPublisher(urls []string, token string) *WebhookPublisher {
+	return &WebhookPublisher{urls: urls, token: token, httpClient: &http.Client{Timeout: 5 * time.Second}}
+}
+
+// Publish posts e to every webhook and returns the joined errors of those
+// that did not accept it with a 2xx status.
+func (p *WebhookPublisher) Publish(ctx context.Context, e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("Publish encode: %w", err)
+	}
+
+	var errs []error
+	for _, url := range p.urls {
+		if err := p.post(ctx, url, body); err != nil {
+			errs = append(errs, fmt.Errorf("Publish %s to %s: %w", e.Type, url, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (p *WebhookPublisher) post(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(serviceTokenHeader, p.token)
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// batchSize bounds how many events one relay run delivers.
+const batchSize = 100
+
+// Store reads queued events and records their delivery.
+type Store interface {
+	PendingEvents(ctx context.Context, limit int) ([]Event, error)
+	MarkEventPublished(ctx context.Context, id int64) error
+	MarkEventFailed(ctx context.Context, id int64, reason string) error
+}
+
+// Relay delivers queued events through a Publisher.
+type Relay struct {
+	store     Store
+	publisher Publisher
+}
+
+// NewRelay creates a relay that delivers store's events through pub.
+func NewRelay(store Store, pub Publisher) *Relay {
+	return &Relay{store: store, publisher: pub}
+}
+
+// Result summarises one relay run.
+type Result struct {
+	Published int
+	Failed    int
+}
+
+// RunOnce delivers pending events oldest first. A failed delivery is recorded
+// on its event, which is retried on the next run. Order events do not depend
+// on each other, so one that keeps failing does not hold back the rest.
+func (r *Relay) RunOnce(ctx context.Context) (Result, error) {
+	var res Result
+	pending, err := r.store.PendingEvents(ctx, batchSize)
+	if err != nil {
+		return res, err
+	}
+
+	for _, e := range pending {
+		if err := r.publisher.Publish(ctx, e); err != nil {
+			res.Failed++
+			log.Printf("events: delivering %s event %d for order %d failed: %v", e.Type, e.ID, e.Order.ID, err)
+			if ferr := r.store.MarkEventFailed(ctx, e.ID, err.Error()); ferr != nil {
+				return res, ferr
+			}
+			continue
+		}
+		if err := r.store.MarkEventPublished(ctx, e.ID); err != nil {
+			return res, err
+		}
+		res.Published++
+	}
+	return res, nil
+}
+
+// Run calls RunOnce every interval until ctx is cancelled. A full batch
+// delivered without failures is followed straight away by the next one.
+func (r *Relay) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		res, err := r.RunOnce(ctx)
+		if err != nil {
+			log.Printf("events: relay run failed: %v", err)
+		}
+		if err == nil && res.Failed == 0 && res.Published == batchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"com.MixieMelts.orders/internal/models"
+)
+
+// fakeStore is an in-memory outbox.
+type fakeStore struct {
+	pending   []Event
+	published []int64
+	failed    []int64
+}
+
+func (f *fakeStore) PendingEvents(ctx context.Context, limit int) ([]Event, error) {
+	return f.pending, nil
+}
+
+func (f *fakeStore) MarkEventPublished(ctx context.Context, id int64) error {
+	f.published = append(f.published, id)
+	return nil
+}
+
+func (f *fakeStore) MarkEventFailed(ctx context.Context, id int64, reason string) error {
+	f.failed = append(f.failed, id)
+	return nil
+}
+
+// fakePublisher rejects the events of the orders in reject.
+type fakePublisher struct {
+	reject    map[int64]bool
+	delivered []int64
+}
+
+func (f *fakePublisher) Publish(ctx context.Context, e Event) error {
+	if f.reject[e.Order.ID] {
+		return errors.New("connection refused")
+	}
+	f.delivered = append(f.delivered, e.ID)
+	return nil
+}
+
+func TestRelayRunOnce(t *testing.T) {
+	store := &fakeStore{pending: []Event{
+		{ID: 1, Type: TypeOrderCreated, Order: models.Order{ID: 10}},
+		{ID: 2, Type: TypeOrderCreated, Order: models.Order{ID: 11}},
+		{ID: 3, Type: TypeOrderCreated, Order: models.Order{ID: 12}},
+	}}
+	pub := &fakePublisher{reject: map[int64]bool{11: true}}
+
+	res, err := NewRelay(store, pub).RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Published != 2 || res.Failed != 1 {
+		t.Fatalf("expected 2 published and 1 failed, got %+v", res)
+	}
+	if len(store.published) != 2 || store.published[0] != 1 || store.published[1] != 3 {
+		t.Fatalf("expected events 1 and 3 marked published, got %v", store.published)
+	}
+	if len(store.failed) != 1 || store.failed[0] != 2 {
+		t.Fatalf("expected event 2 marked failed for a retry, got %v", store.failed)
+	}
+}
+
+func TestWebhookPublisherSendsServiceToken(t *testing.T) {
+	var got string
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get(serviceTokenHeader)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer hook.Close()
+
+	pub := NewWebhookPublisher([]string{hook.URL}, "s3cret")
+	if err := pub.Publish(context.Background(), Event{ID: 1, Type: TypeOrderCreated}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "s3cret" {
+		t.Fatalf("expected the webhook to get the service token, got %q", got)
+	}
+}
@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"com.MixieMelts.orders/internal/cart"
+	"com.MixieMelts.orders/internal/checkout"
+	"com.MixieMelts.orders/internal/inventory"
+	"com.MixieMelts.orders/internal/models"
+	"com.MixieMelts.users/jwtverify"
+	"github.com/go-chi/chi/v5"
+)
+
+// ServiceTokenHeader carries the shared token other services present on
+// internal routes.
+const ServiceTokenHeader = "X-Service-Token"
+
+// DBLayer is the read side of order persistence used by the handlers.
+type DBLayer interface {
+	GetOrder(ctx context.Context, id int64) (*models.Order, error)
+	ListOrders(ctx context.Context, userID string) ([]models.Order, error)
+	GetOrderByReference(ctx context.Context, reference string) (*models.Order, error)
+}
+
+// Checkout places orders.
+type Checkout interface {
+	PlaceOrder(ctx context.Context, req checkout.Request) (*models.Order, error)
+}
+
+// CartClient reads and clears the caller's cart.
+type CartClient interface {
+	GetCart(ctx context.Context, authorization string) (*cart.Cart, error)
+	ClearCart(ctx context.Context, authorization string) error
+}
+
+// Handler provides HTTP handlers for the orders service.
+type Handler struct {
+	db       DBLayer
+	checkout Checkout
+	cart     CartClient
+}
+
+// New creates a new Handler.
+func New(db DBLayer, co Checkout, cartClient CartClient) *Handler {
+	return &Handler{db: db, checkout: co, cart: cartClient}
+}
+
+// PlaceOrderPayload is the optional request body for POST /orders. When Items
+// is empty the caller's cart is checked out instead.
+type PlaceOrderPayload struct {
+	Items []models.OrderLine `json:"items"`
+}
+
+// PlaceOrder turns the caller's cart (or the items in the request body) into
+// a confirmed order. The cart is emptied once the order is confirmed.
+func (h *Handler) PlaceOrder(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r.Context())
+
+	var p PlaceOrderPayload
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+	}
+
+	fromCart := len(p.Items) == 0
+	var cartHold string
+	if fromCart {
+		c, err := h.cart.GetCart(r.Context(), r.Header.Get("Authorization"))
+		if err != nil {
+			log.Printf("PlaceOrder get cart error: %v", err)
+			respondWithError(w, http.StatusBadGateway, "failed to load cart")
+			return
+		}
+		for _, it := range c.Items {
+			p.Items = append(p.Items, models.OrderLine{ProductID: it.ProductID, Quantity: it.Quantity})
+		}
+		cartHold = c.HoldReference
+	}
+
+	order, err := h.checkout.PlaceOrder(r.Context(), checkout.Request{UserID: userID, CartHold: cartHold, Lines: p.Items})
+	if err != nil {
+		respondWithCheckoutError(w, "PlaceOrder", err)
+		return
+	}
+
+	if fromCart {
+		if err := h.cart.ClearCart(r.Context(), r.Header.Get("Authorization")); err != nil {
+			// The order is already confirmed; a stale cart is only an inconvenience.
+			log.Printf("PlaceOrder clear cart for order %d: %v", order.ID, err)
+		}
+	}
+
+	log.Printf("Order %d placed for user %s", order.ID, userID)
+	respondWithJSON(w, http.StatusCreated, order)
+}
+
+// InternalOrderPayload is the request body for POST /internal/orders.
+type InternalOrderPayload struct {
+	UserID    string             `json:"user_id"`
+	Source    string             `json:"source"`
+	Reference string             `json:"reference"`
+	Items     []models.OrderLine `json:"items"`
+}
+
+// CreateInternalOrder places an order on behalf of another service, such as a
+// subscription renewal. The reference makes the call idempotent: if an order
+// already exists for it, that order is returned with 200 instead of placing a
+// second one.
+func (h *Handler) CreateInternalOrder(w http.ResponseWriter, r *http.Request) {
+	var p InternalOrderPayload
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if p.UserID == "" || p.Reference == "" {
+		respondWithError(w, http.StatusBadRequest, "user_id and reference are required")
+		return
+	}
+
+	existing, err := h.db.GetOrderByReference(r.Context(), p.Reference)
+	if err != nil {
+		log.Printf("CreateInternalOrder lookup error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to place order")
+		return
+	}
+	if existing != nil {
+		respondWithJSON(w, http.StatusOK, existing)
+		return
+	}
+
+	order, err := h.checkout.PlaceOrder(r.Context(), checkout.Request{
+		UserID:    p.UserID,
+		Source:    p.Source,
+		Reference: p.Reference,
+		Lines:     p.Items,
+	})
+	if err != nil {
+		// A concurrent request with the same reference may have won the race.
+		if existing, lerr := h.db.GetOrderByReference(r.Context(), p.Reference); lerr == nil && existing != nil {
+			respondWithJSON(w, http.StatusOK, existing)
+			return
+		}
+		respondWithCheckoutError(w, "CreateInternalOrder", err)
+		return
+	}
+
+	log.Printf("Order %d placed for user %s from %s (%s)", order.ID, order.UserID, order.Source, order.Reference)
+	respondWithJSON(w, http.StatusCreated, order)
+}
+
+// GetOrders returns the caller's orders.
+func (h *Handler) GetOrders(w http.ResponseWriter, r *http.Request) {
+	orders, err := h.db.ListOrders(r.Context(), userIDFromContext(r.Context()))
+	if err != nil {
+		log.Printf("GetOrders error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to list orders")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, orders)
+}
+
+// GetOrder returns one of the caller's orders.
+func (h *Handler) GetOrder(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	order, err := h.db.GetOrder(r.Context(), id)
+	if err != nil {
+		log.Printf("GetOrder error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to get order")
+		return
+	}
+	// Other customers' orders are reported as missing rather than forbidden.
+	if order == nil || order.UserID != userIDFromContext(r.Context()) {
+		respondWithError(w, http.StatusNotFound, "order not found")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, order)
+}
+
+// --- UTILITY & MIDDLEWARE ---
+
+// RequireServiceToken admits only requests whose ServiceTokenHeader matches
+// token. Internal routes act for any user, so with no token configured every
+// request is refused rather than letting them open.
+func RequireServiceToken(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got := r.Header.Get(ServiceTokenHeader)
+			if token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				respondWithError(w, http.StatusUnauthorized, "invalid service token")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// userIDFromContext returns the caller's user id from the token the
+// verifier's middleware checked.
+func userIDFromContext(ctx context.Context) string {
+	claims, ok := jwtverify.ClaimsFromContext(ctx)
+	if !ok {
+		return ""
+	}
+	return claims.Subject
+}
+
+// respondWithCheckoutError maps checkout failures to HTTP statuses.
+func respondWithCheckoutError(w http.ResponseWriter, op string, err error) {
+	switch {
+	case errors.Is(err, checkout.ErrEmptyOrder),
+		errors.Is(err, checkout.ErrInvalidLine),
+		errors.Is(err, checkout.ErrUnknownProduct):
+		respondWithError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, inventory.ErrInsufficientStock), errors.Is(err, inventory.ErrReservationNotActive):
+		respondWithError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, inventory.ErrMissingRecipe):
+		respondWithError(w, http.StatusUnprocessableEntity, err.Error())
+	default:
+		log.Printf("%s error: %v", op, err)
+		respondWithError(w, http.StatusInternalServerError, "failed to place order")
+	}
+}
+
+func respondWithError(w http.ResponseWriter, code int, message string) {
+	respondWithJSON(w, code, map[string]string{"message": message})
+}
+
+func respondWithJSON(w http.ResponseWriter, code int, payload any) {
+	response, _ := json.Marshal(payload)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(response)
+}
@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"com.MixieMelts.orders/internal/cart"
+	"com.MixieMelts.orders/internal/checkout"
+	"com.MixieMelts.orders/internal/inventory"
+	"com.MixieMelts.orders/internal/models"
+	"com.MixieMelts.users/jwtverify"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// MockDB is a mock implementation of the DBLayer for testing purposes.
+type MockDB struct {
+	GetOrderFunc            func(ctx context.Context, id int64) (*models.Order, error)
+	ListOrdersFunc          func(ctx context.Context, userID string) ([]models.Order, error)
+	GetOrderByReferenceFunc func(ctx context.Context, reference string) (*models.Order, error)
+}
+
+func (m *MockDB) GetOrder(ctx context.Context, id int64) (*models.Order, error) {
+	if m.GetOrderFunc != nil {
+		return m.GetOrderFunc(ctx, id)
+	}
+	return nil, errors.New("GetOrderFunc not implemented")
+}
+
+func (m *MockDB) ListOrders(ctx context.Context, userID string) ([]models.Order, error) {
+	if m.ListOrdersFunc != nil {
+		return m.ListOrdersFunc(ctx, userID)
+	}
+	return nil, errors.New("ListOrdersFunc not implemented")
+}
+
+func (m *MockDB) GetOrderByReference(ctx context.Context, reference string) (*models.Order, error) {
+	if m.GetOrderByReferenceFunc != nil {
+		return m.GetOrderByReferenceFunc(ctx, reference)
+	}
+	return nil, errors.New("GetOrderByReferenceFunc not implemented")
+}
+
+// MockCheckout is a mock implementation of Checkout.
+type MockCheckout struct {
+	PlaceOrderFunc func(ctx context.Context, req checkout.Request) (*models.Order, error)
+}
+
+func (m *MockCheckout) PlaceOrder(ctx context.Context, req checkout.Request) (*models.Order, error) {
+	return m.PlaceOrderFunc(ctx, req)
+}
+
+// MockCart is a mock implementation of CartClient.
+type MockCart struct {
+	Cart    *cart.Cart
+	Err     error
+	Cleared bool
+}
+
+func (m *MockCart) GetCart(ctx context.Context, authorization string) (*cart.Cart, error) {
+	return m.Cart, m.Err
+}
+
+func (m *MockCart) ClearCart(ctx context.Context, authorization string) error {
+	m.Cleared = true
+	return nil
+}
+
+func withUser(req *http.Request, userID string) *http.Request {
+	claims := &jwtverify.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: userID}}
+	return req.WithContext(jwtverify.WithClaims(req.Context(), claims))
+}
+
+func TestPlaceOrder(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		cart        *cart.Cart
+		cartErr     error
+		placeErr    error
+		wantStatus  int
+		wantLines   int
+		wantCleared bool
+	}{
+		{
+			name:        "checkout cart",
+			cart:        &cart.Cart{Items: []cart.Item{{ProductID: 1, Quantity: 2}, {ProductID: 3, Quantity: 1}}},
+			wantStatus:  http.StatusCreated,
+			wantLines:   2,
+			wantCleared: true,
+		},
+		{
+			name:       "explicit items leave cart alone",
+			body:       `{"items":[{"product_id":4,"quantity":1}]}`,
+			wantStatus: http.StatusCreated,
+			wantLines:  1,
+		},
+		{name: "cart service down", cartErr: errors.New("dial tcp"), wantStatus: http.StatusBadGateway},
+		{name: "empty cart", cart: &cart.Cart{}, placeErr: checkout.ErrEmptyOrder, wantStatus: http.StatusBadRequest},
+		{name: "out of stock", body: `{"items":[{"product_id":4,"quantity":1}]}`, placeErr: inventory.ErrInsufficientStock, wantStatus: http.StatusConflict},
+		{name: "saga failure", body: `{"items":[{"product_id":4,"quantity":1}]}`, placeErr: errors.New("boom"), wantStatus: http.StatusInternalServerError},
+		{name: "invalid body", body: `{bad`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var gotLines []models.OrderLine
+			co := &MockCheckout{
+				PlaceOrderFunc: func(ctx context.Context, req checkout.Request) (*models.Order, error) {
+					if req.UserID != "42" {
+						t.Fatalf("expected user 42 got %q", req.UserID)
+					}
+					gotLines = req.Lines
+					if tc.placeErr != nil {
+						return nil, tc.placeErr
+					}
+					return &models.Order{ID: 1, UserID: req.UserID, Status: models.OrderStatusConfirmed}, nil
+				},
+			}
+			mc := &MockCart{Cart: tc.cart, Err: tc.cartErr}
+			h := New(&MockDB{}, co, mc)
+
+			req, _ := http.NewRequest("POST", "/orders", bytes.NewBufferString(tc.body))
+			req = withUser(req, "42")
+			rr := httptest.NewRecorder()
+			h.PlaceOrder(rr, req)
+
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("expected status %d got %d; body: %s", tc.wantStatus, rr.Code, rr.Body.String())
+			}
+			if tc.wantStatus == http.StatusCreated && len(gotLines) != tc.wantLines {
+				t.Fatalf("expected %d lines got %d", tc.wantLines, len(gotLines))
+			}
+			if mc.Cleared != tc.wantCleared {
+				t.Fatalf("expected cart cleared=%v got %v", tc.wantCleared, mc.Cleared)
+			}
+		})
+	}
+}
+
+func TestCreateInternalOrder(t *testing.T) {
+	existing := &models.Order{ID: 7, UserID: "42", Reference: "subscription-1-2026-01-01"}
+
+	tests := []struct {
+		name       string
+		body       string
+		existing   *models.Order
+		placeErr   error
+		wantStatus int
+		wantPlaced bool
+	}{
+		{
+			name:       "new order",
+			body:       `{"user_id":"42","source":"subscription","reference":"subscription-1-2026-01-01","items":[{"subscription_box_id":1,"quantity":1}]}`,
+			wantStatus: http.StatusCreated,
+			wantPlaced: true,
+		},
+		{
+			name:       "retry returns existing order",
+			body:       `{"user_id":"42","source":"subscription","reference":"subscription-1-2026-01-01","items":[{"subscription_box_id":1,"quantity":1}]}`,
+			existing:   existing,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "unknown box",
+			body:       `{"user_id":"42","reference":"r","items":[{"subscription_box_id":9,"quantity":1}]}`,
+			placeErr:   checkout.ErrUnknownProduct,
+			wantStatus: http.StatusBadRequest,
+			wantPlaced: true,
+		},
+		{name: "missing reference", body: `{"user_id":"42","items":[{"product_id":1,"quantity":1}]}`, wantStatus: http.StatusBadRequest},
+		{name: "invalid body", body: `{bad`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			placed := false
+			mockDB := &MockDB{
+				GetOrderByReferenceFunc: func(ctx context.Context, reference string) (*models.Order, error) {
+					return tc.existing, nil
+				},
+			}
+			co := &MockCheckout{
+				PlaceOrderFunc: func(ctx context.Context, req checkout.Request) (*models.Order, error) {
+					placed = true
+					if req.Reference == "" || req.UserID != "42" {
+						t.Fatalf("unexpected request %+v", req)
+					}
+					if tc.placeErr != nil {
+						return nil, tc.placeErr
+					}
+					return &models.Order{ID: 8, UserID: req.UserID, Source: req.Source, Reference: req.Reference}, nil
+				},
+			}
+			h := New(mockDB, co, &MockCart{})
+
+			req, _ := http.NewRequest("POST", "/internal/orders", bytes.NewBufferString(tc.body))
+			rr := httptest.NewRecorder()
+			h.CreateInternalOrder(rr, req)
+
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("expected status %d got %d; body: %s", tc.wantStatus, rr.Code, rr.Body.String())
+			}
+			if placed != tc.wantPlaced {
+				t.Fatalf("expected placed=%v got %v", tc.wantPlaced, placed)
+			}
+		})
+	}
+}
+
+func TestGetOrder(t *testing.T) {
+	tests := []struct {
+		name       string
+		id         string
+		order      *models.Order
+		wantStatus int
+	}{
+		{name: "own order", id: "1", order: &models.Order{ID: 1, UserID: "42"}, wantStatus: http.StatusOK},
+		{name: "someone else's order", id: "1", order: &models.Order{ID: 1, UserID: "7"}, wantStatus: http.StatusNotFound},
+		{name: "missing", id: "2", order: nil, wantStatus: http.StatusNotFound},
+		{name: "bad id", id: "x", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB := &MockDB{
+				GetOrderFunc: func(ctx context.Context, id int64) (*models.Order, error) {
+					return tc.order, nil
+				},
+			}
+			h := New(mockDB, &MockCheckout{}, &MockCart{})
+
+			req, _ := http.NewRequest("GET", "/orders/"+tc.id, nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tc.id)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			req = withUser(req, "42")
+			rr := httptest.NewRecorder()
+			h.GetOrder(rr, req)
+
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("expected status %d got %d; body: %s", tc.wantStatus, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestRequireServiceToken(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured string
+		sent       string
+		wantStatus int
+	}{
+		{name: "matching token", configured: "s3cret", sent: "s3cret", wantStatus: http.StatusOK},
+		{name: "wrong token", configured: "s3cret", sent: "guess", wantStatus: http.StatusUnauthorized},
+		{name: "no token sent", configured: "s3cret", wantStatus: http.StatusUnauthorized},
+		{name: "none configured", sent: "", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+			req, _ := http.NewRequest("POST", "/internal/orders", nil)
+			if tc.sent != "" {
+				req.Header.Set(ServiceTokenHeader, tc.sent)
+			}
+			rr := httptest.NewRecorder()
+			RequireServiceToken(tc.configured)(next).ServeHTTP(rr, req)
+
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("expected status %d got %d", tc.wantStatus, rr.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+// Package inventory is a small HTTP client for the inventory service's
+// internal reservation endpoints, used by checkout to hold stock for an
+// order.
+package inventory
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInsufficientStock is returned when inventory cannot cover the
+	// ingredients needed for the requested products.
+	ErrInsufficientStock = errors.New("insufficient stock")
+	// ErrMissingRecipe is returned when a product has no recipe in inventory.
+	ErrMissingRecipe = errors.New("product has no recipe")
+	// ErrReservationNotActive is returned when confirming a reservation that
+	// expired or was released first.
+	ErrReservationNotActive = errors.New("reservation is not active")
+)
+
+// Line is a product and the number of units to reserve stock for.
+type Line struct {
+	ProductID int64   `json:"product_id"`
+	Quantity  float64 `json:"quantity"`
+}
+
+type reservationRequest struct {
+	Reference string `json:"reference"`
+	Lines     []Line `json:"lines"`
+	CreatedBy string `json:"created_by"`
+}
+
+// OrderReference is the reservation reference for an order. The kitchen
+// fills orders under the same reference, which ends the hold.
+func OrderReference(orderID int64) string {
+	return fmt.Sprintf("order-%d", orderID)
+}
+
+// serviceTokenHeader carries the token the inventory service requires on
+// its internal routes.
+const serviceTokenHeader = "X-Service-Token"
+
+// Client talks to the inventory service over HTTP.
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// New creates a client for the inventory service rooted at baseURL
+// (e.g. "http://inventory:8083") that authenticates with the shared service
+// token.
+func New(baseURL, token string) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		token:      token,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Reserve holds the stock needed for lines under reference: finished goods
+// on hand first, then the ingredients to make the rest. The hold lapses
+// unless it is confirmed. Reserving an existing reference again returns
+// without holding more.
+func (c *Client) Reserve(ctx context.Context, reference string, lines []Line) error {
+	body, err := json.Marshal(reservationRequest{Reference: reference, Lines: lines, CreatedBy: "orders"})
+	if err != nil {
+		return fmt.Errorf("Reserve marshal: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/internal/reservations", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("Reserve build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(serviceTokenHeader, c.token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Reserve request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated, http.StatusOK:
+		return nil
+	case http.StatusConflict:
+		return fmt.Errorf("%w: %s", ErrInsufficientStock, errorMessage(resp))
+	case http.StatusUnprocessableEntity:
+		return fmt.Errorf("%w: %s", ErrMissingRecipe, errorMessage(resp))
+	default:
+		return fmt.Errorf("Reserve: unexpected status %d: %s", resp.StatusCode, errorMessage(resp))
+	}
+}
+
+// Confirm hands the reservation under reference to its placed order, so it
+// consumes the ingredients it held under reference and holds finished goods
+// until the kitchen fills the order.
+func (c *Client) Confirm(ctx context.Context, reference string) error {
+	u := fmt.Sprintf("%s/internal/reservations/%s/confirm", c.baseURL, url.PathEscape(reference))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, nil)
+	if err != nil {
+		return fmt.Errorf("Confirm build request: %w", err)
+	}
+	req.Header.Set(serviceTokenHeader, c.token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Confirm request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusConflict:
+		return fmt.Errorf("%w: %s", ErrReservationNotActive, errorMessage(resp))
+	default:
+		return fmt.Errorf("Confirm: unexpected status %d: %s", resp.StatusCode, errorMessage(resp))
+	}
+}
+
+// Release gives up the reservation under reference. A reference with no
+// reservation counts as released, so it is safe to call for a hold that
+// was never made.
+func (c *Client) Release(ctx context.Context, reference string) error {
+	u := fmt.Sprintf("%s/internal/reservations/%s", c.baseURL, url.PathEscape(reference))
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, u, nil)
+	if err != nil {
+		return fmt.Errorf("Release build request: %w", err)
+	}
+	req.Header.Set(serviceTokenHeader, c.token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Release request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNotFound:
+		return nil
+	default:
+		return fmt.Errorf("Release: unexpected status %d: %s", resp.StatusCode, errorMessage(resp))
+	}
+}
+
+func errorMessage(resp *http.Response) string {
+	var body struct {
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return resp.Status
+	}
+	return body.Message
+}
@@ -0,0 +1,69 @@
+package models
+
+import "time"
+
+// OrderStatus is the lifecycle state of an order.
+type OrderStatus string
+
+const (
+	// OrderStatusPending is set while the checkout saga is still running.
+	OrderStatusPending OrderStatus = "pending"
+	// OrderStatusConfirmed is set once every saga step has succeeded.
+	OrderStatusConfirmed OrderStatus = "confirmed"
+	// OrderStatusCancelled is set when a confirmed order is later cancelled.
+	OrderStatusCancelled OrderStatus = "cancelled"
+)
+
+// Order sources record how an order was created.
+const (
+	OrderSourceCheckout     = "checkout"
+	OrderSourceSubscription = "subscription"
+)
+
+// Order represents a finalized purchase.
+type Order struct {
+	ID     int64       `json:"id"`
+	UserID string      `json:"user_id"`
+	Status OrderStatus `json:"status"`
+	Source string      `json:"source"`
+	// Reference is an optional caller-supplied idempotency key. Only one order
+	// can exist per reference, so retried requests return the original order.
+	Reference string `json:"reference,omitempty"`
+	// CheckoutID identifies the checkout attempt that created the order, so
+	// a failed attempt can remove its order even if it never learned the id.
+	CheckoutID string      `json:"-"`
+	Items      []OrderItem `json:"items"`
+	Total      float64     `json:"total"`
+	CreatedAt  time.Time   `json:"created_at"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+}
+
+// OrderItem is a line on an order, either a product or a subscription box.
+// Name and UnitPrice are snapshots taken from the products service when the
+// order was placed, so later price changes do not alter past orders.
+type OrderItem struct {
+	ProductID         int64   `json:"product_id,omitempty"`
+	SubscriptionBoxID int64   `json:"subscription_box_id,omitempty"`
+	Name              string  `json:"name"`
+	UnitPrice         float64 `json:"unit_price"`
+	Quantity          int     `json:"quantity"`
+	LineTotal         float64 `json:"line_total"`
+}
+
+// OrderLine is a requested product or subscription box and quantity, before
+// prices are known. Exactly one of ProductID and SubscriptionBoxID is set.
+type OrderLine struct {
+	ProductID         int64 `json:"product_id,omitempty"`
+	SubscriptionBoxID int64 `json:"subscription_box_id,omitempty"`
+	Quantity          int   `json:"quantity"`
+}
+
+// ComputeTotal recalculates line totals and the order total.
+func (o *Order) ComputeTotal() {
+	var total float64
+	for i := range o.Items {
+		o.Items[i].LineTotal = o.Items[i].UnitPrice * float64(o.Items[i].Quantity)
+		total += o.Items[i].LineTotal
+	}
+	o.Total = total
+}
@@ -0,0 +1,92 @@
+// Package products is a small HTTP client for the products service, used to
+// snapshot product and subscription box names and prices when an order is
+// placed.
+package products
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when the products service has no product or
+// subscription box with the requested id.
+var ErrNotFound = errors.New("not found in catalog")
+
+// Product is the subset of the products service representation orders need.
+type Product struct {
+	ID    int64   `json:"id"`
+	Name  string  `json:"name"`
+	Price float64 `json:"price"`
+}
+
+// SubscriptionBox is the subset of the products service subscription box
+// representation orders need.
+type SubscriptionBox struct {
+	ID    int64   `json:"id"`
+	Name  string  `json:"name"`
+	Price float64 `json:"price"`
+}
+
+// Client talks to the products service over HTTP.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// New creates a client for the products service rooted at baseURL
+// (e.g. "http://products:8082").
+func New(baseURL string) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// GetProduct fetches a single product by id.
+func (c *Client) GetProduct(ctx context.Context, id int64) (*Product, error) {
+	var p Product
+	if err := c.get(ctx, fmt.Sprintf("/products/%d", id), &p); err != nil {
+		return nil, fmt.Errorf("GetProduct: %w", err)
+	}
+	return &p, nil
+}
+
+// GetSubscriptionBox fetches a single subscription box by id.
+func (c *Client) GetSubscriptionBox(ctx context.Context, id int64) (*SubscriptionBox, error) {
+	var b SubscriptionBox
+	if err := c.get(ctx, fmt.Sprintf("/products/subscription-boxes/%d", id), &b); err != nil {
+		return nil, fmt.Errorf("GetSubscriptionBox: %w", err)
+	}
+	return &b, nil
+}
+
+// get decodes the JSON response for path into out. A 404 is reported as
+// ErrNotFound.
+func (c *Client) get(ctx context.Context, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+-- Gives every service its own login role and a schema of the same name that
+-- the role owns and defaults to. No role is granted anything on another
+-- service's schema, and nobody can create objects in public, so services can
+-- only share data through each other's APIs.
+--
+-- Runs once, when the postgres container initialises an empty data
+-- directory. Passwords match the role names for local development only.
+DO $$
+DECLARE
+	svc TEXT;
+BEGIN
+	FOREACH svc IN ARRAY ARRAY['users', 'products', 'inventory', 'cart', 'orders', 'subscriptions', 'kitchen'] LOOP
+		EXECUTE format('CREATE ROLE %I LOGIN PASSWORD %L', svc, svc);
+		EXECUTE format('CREATE SCHEMA %I AUTHORIZATION %I', svc, svc);
+		EXECUTE format('ALTER ROLE %I SET search_path = %I', svc, svc);
+	END LOOP;
+END
+$$;
+
+REVOKE ALL ON SCHEMA public FROM PUBLIC;
@@ -1,16 +1,20 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"com.MixieMelts.products/internal/database"
 	"com.MixieMelts.products/internal/handlers"
+	"com.MixieMelts.products/internal/inventory"
+	"com.MixieMelts.users/jwtverify"
+	"com.MixieMelts.users/outbox"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/joho/godotenv"
-	"github.com/rs/cors"
 )
 
 func main() {
@@ -20,34 +24,75 @@ func main() {
 		log.Println("Error loading .env file, using environment variables")
 	}
 
+	// "migrate up|down|status" manages the schema and exits
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		m, err := database.Migrator()
+		if err == nil {
+			err = m.Command(context.Background(), os.Getenv("DATABASE_URL"), os.Args[2:], os.Stdout)
+		}
+		if err != nil {
+			log.Fatalf("migrate: %v", err)
+		}
+		return
+	}
+
 	// Database connection
 	db, err := database.New(os.Getenv("DATABASE_URL"))
 	if err != nil {
 		log.Fatalf("Failed to connect to the database: %v", err)
 	}
 
+	// Publish outbox events
+	transport, err := outbox.TransportFromEnv(db, "products_events")
+	if err != nil {
+		log.Fatalf("Invalid outbox configuration: %v", err)
+	}
+	outboxInterval := 5 * time.Second
+	if v := os.Getenv("OUTBOX_INTERVAL"); v != "" {
+		outboxInterval, err = time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("Invalid OUTBOX_INTERVAL: %v", err)
+		}
+	}
+	go outbox.NewRelay("products", db, transport).Run(context.Background(), outboxInterval)
+
 	// Initialize handlers
-	h := handlers.New(db)
+	inventoryURL := os.Getenv("INVENTORY_URL")
+	if inventoryURL == "" {
+		inventoryURL = "http://localhost:8083"
+	}
+	recipeTTL := time.Minute
+	if v := os.Getenv("RECIPE_CACHE_TTL"); v != "" {
+		recipeTTL, err = time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("Invalid RECIPE_CACHE_TTL: %v", err)
+		}
+	}
+	inventoryClient := inventory.New(inventoryURL)
+	h := handlers.New(db, inventoryClient, inventory.NewRecipeCache(inventoryClient, recipeTTL))
+
+	// Writes need a token from the users service granting products:write
+	verifier, err := jwtverify.Configure(os.Getenv("JWKS_URL"), os.Getenv("JWT_SECRET_KEY"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	canWrite := chi.Chain(verifier.Middleware, jwtverify.Require(jwtverify.PermProductsWrite))
 
 	// Chi router
 	r := chi.NewRouter()
 	r.Use(middleware.Logger)
 
-	// CORS middleware
-	r.Use(cors.New(cors.Options{
-		AllowedOrigins:   []string{"frontend:"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Content-Type", "Authorization"},
-		AllowCredentials: true,
-		MaxAge:           300, // Maximum value not ignored by any major browsers
-	}).Handler)
-
 	r.Get("/products", h.GetProducts)
 	r.Get("/products/{id}", h.GetProduct)
-	r.Post("/products", h.CreateProduct)
+	r.With(canWrite...).Post("/products", h.CreateProduct)
+	r.With(canWrite...).Put("/products/{id}", h.UpdateProduct)
 
 	r.Get("/products/subscription-boxes", h.GetSubscriptionBoxes)
-	r.Post("/products/subscription-boxes", h.CreateSubscriptionBox)
+	r.Get("/products/subscription-boxes/{id}", h.GetSubscriptionBox)
+	r.With(canWrite...).Post("/products/subscription-boxes", h.CreateSubscriptionBox)
+
+	// Internal routes used by other services (not exposed to browsers)
+	r.Get("/internal/product-ids", h.GetProductIDs)
 
 	// Start server
 	port := os.Getenv("PORT")
@@ -3,18 +3,22 @@ module com.MixieMelts.products
 go 1.24.2
 
 require (
-	github.com/davecgh/go-spew v1.1.1
-	github.com/go-chi/chi/v5 v5.0.10
+	com.MixieMelts.users v0.0.0-00010101000000-000000000000
+	github.com/go-chi/chi/v5 v5.2.3
 	github.com/jackc/pgx/v5 v5.7.6
 	github.com/joho/godotenv v1.5.1
-	github.com/rs/cors v1.11.1
 )
 
 require (
+	github.com/golang-jwt/jwt/v4 v4.5.2 // indirect
 	github.com/jackc/pgpassfile v1.0.0 // indirect
 	github.com/jackc/pgservicefile v0.0.0-20240606120523-5a60cdf6a761 // indirect
 	github.com/jackc/puddle/v2 v2.2.2 // indirect
-	golang.org/x/crypto v0.37.0 // indirect
-	golang.org/x/sync v0.13.0 // indirect
-	golang.org/x/text v0.24.0 // indirect
+	golang.org/x/crypto v0.43.0 // indirect
+	golang.org/x/sync v0.17.0 // indirect
+	golang.org/x/text v0.30.0 // indirect
 )
+
+// The users module's token verifier and migration runner, shared by
+// building from the repository root
+replace com.MixieMelts.users => ../users
@@ -2,10 +2,13 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"strconv"
 
 	"com.MixieMelts.products/internal/models"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -14,24 +17,28 @@ type DB struct {
 	*pgxpool.Pool
 }
 
-// New creates a new database connection.
-func New(config string) (*DB, error) {
+// Open creates a connection pool without touching the schema.
+func Open(config string) (*DB, error) {
 	pool, err := pgxpool.New(context.Background(), config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create connection pool: %w", err)
 	}
 
 	log.Println("Successfully created database connection pool.")
+	return &DB{pool}, nil
+}
 
-	dbWrapper := &DB{pool}
-	if err := dbWrapper.createTables(context.Background()); err != nil {
-		pool.Close()
-		return nil, fmt.Errorf("failed to create tables: %w", err)
+// New creates a connection pool, applies pending migrations and seeds the
+// database.
+func New(config string) (*DB, error) {
+	dbWrapper, err := Open(config)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := dbWrapper.createSubscriptionTables(context.Background()); err != nil {
-		pool.Close()
-		return nil, fmt.Errorf("failed to create subscription tables: %w", err)
+	if _, err := dbWrapper.MigrateUp(context.Background()); err != nil {
+		dbWrapper.Close()
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
 
 	dbWrapper.Seed(context.Background())
@@ -39,55 +46,18 @@ func New(config string) (*DB, error) {
 	return dbWrapper, nil
 }
 
-func (db *DB) createTables(ctx context.Context) error {
-	// Create products + recipe_items canonical schema if missing.
-	// Keep the CREATE statement minimal and idempotent.
-	query := `
-	CREATE TABLE IF NOT EXISTS products (
-		id SERIAL PRIMARY KEY,
-		name VARCHAR(255) NOT NULL,
-		category VARCHAR(255) NOT NULL,
-		scent VARCHAR(255) NOT NULL,
-		price NUMERIC(10, 2) NOT NULL,
-		subscription BOOLEAN DEFAULT false,
-		image VARCHAR(255) NOT NULL,
-		description TEXT NOT NULL,
-		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-	);
-	`
-	if _, err := db.Exec(ctx, query); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (db *DB) createSubscriptionTables(ctx context.Context) error {
-	query := `
-	CREATE TABLE IF NOT EXISTS subscription_boxes (
-		id SERIAL PRIMARY KEY,
-		name VARCHAR(255) NOT NULL,
-		description TEXT NOT NULL,
-		price NUMERIC(10, 2) NOT NULL,
-		image VARCHAR(255) NOT NULL,
-		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-	);
-	`
-	_, err := db.Exec(ctx, query)
-	return err
-}
-
-// GetProducts retrieves all products from the database, including their recipe items.
+// GetProducts retrieves products from the database, optionally limited or
+// restricted to one id. Recipes are owned by the inventory service and are
+// not loaded here.
 func (db *DB) GetProducts(ctx context.Context, limit int, prodID int64) ([]models.Product, error) {
 	query := "SELECT id, name, category, scent, price, subscription, image, description, created_at, updated_at FROM products"
-	if limit > 0 {
-		query = fmt.Sprintf("%s LIMIT %d", query, limit)
-	}
 	if prodID > 0 {
 		query = fmt.Sprintf("%s WHERE id = %d", query, prodID)
 	}
+	query += " ORDER BY id"
+	if limit > 0 {
+		query = fmt.Sprintf("%s LIMIT %d", query, limit)
+	}
 	rows, err := db.Query(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get products: %w", err)
@@ -100,79 +70,33 @@ func (db *DB) GetProducts(ctx context.Context, limit int, prodID int64) ([]model
 		if err := rows.Scan(&product.ID, &product.Name, &product.Category, &product.Scent, &product.Price, &product.Subscription, &product.Image, &product.Description, &product.CreatedAt, &product.UpdatedAt); err != nil {
 			return nil, fmt.Errorf("failed to scan product: %w", err)
 		}
-
-		// Load recipe items for this product
-		recipeQuery := `SELECT ingredient_id, unit, amount, notes, created_at, updated_at FROM recipe_items WHERE product_id = $1 ORDER BY id`
-		rrows, err := db.Query(ctx, recipeQuery, product.ID)
-		defer rrows.Close()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get recipe items for product %d: %w", product.ID, err)
-		}
-		var recipe []models.Ingredient
-		for rrows.Next() {
-			var ing models.Ingredient
-			var typ string
-			if err := rrows.Scan(&ing.ID, &ing.Unit, &ing.Amount, &ing.Notes, &ing.CreatedAt, &ing.UpdatedAt); err != nil {
-				return nil, fmt.Errorf("failed to scan recipe item for product %d: %w", product.ID, err)
-			}
-			ingredientQuery := `SELECT name, type FROM ingredients WHERE id = $1`
-			ingRow := db.QueryRow(ctx, ingredientQuery, ing.ID)
-			if err = ingRow.Scan(&ing.Name, &typ); err != nil {
-				return nil, fmt.Errorf("failed to scan ingredient name for ingredient %d: %w", ing.ID, err)
-			}
-			ing.Type = models.IngredientType(typ)
-			recipe = append(recipe, ing)
-		}
-		if len(recipe) == 0 {
-			// Ensure we return an empty array instead of null when marshalled to JSON.
-			recipe = []models.Ingredient{}
-		}
-		product.Recipe = recipe
-
 		products = append(products, product)
 	}
 
-	return products, nil
+	return products, rows.Err()
 }
 
-// CreateProduct inserts a new product into the database and creates associated recipe items.
+// CreateProduct inserts a new product into the database. Any recipe on the
+// product is ignored; it is saved to the inventory service separately. It is
+// CreateProductTx, so the product.created event is always written.
 func (db *DB) CreateProduct(ctx context.Context, product *models.Product) (int64, error) {
-	query := `
-	INSERT INTO products (name, category, scent, price, subscription, image, description)
-	VALUES ($1, $2, $3, $4, $5, $6, $7)
-	RETURNING id;
-	`
-	var productID int64
-	err := db.QueryRow(ctx, query, product.Name, product.Category, product.Scent, product.Price, product.Subscription, product.Image, product.Description).Scan(&productID)
-	if err != nil {
-		return 0, fmt.Errorf("failed to create product: %w", err)
-	}
-
-	// If recipe items were provided, insert them into recipe_items table.
-	if len(product.Recipe) > 0 {
-		insertQuery := `
-		INSERT INTO recipe_items (product_id, name, type, unit, amount, notes)
-		VALUES ($1, $2, $3, $4, $5, $6);
-		`
-		for _, ing := range product.Recipe {
-			_, err := db.Exec(ctx, insertQuery, productID, ing.Name, string(ing.Type), ing.Unit, ing.Amount, ing.Notes)
-			if err != nil {
-				// Log and continue inserting remaining items - prefer partial success to failing entire request.
-				log.Printf("failed to insert recipe item %q for product %d: %v", ing.Name, productID, err)
-			}
-		}
-	}
-
-	return productID, nil
+	return db.CreateProductTx(ctx, product)
 }
 
-// GetProduct returns a single product by id including its recipe items.
+// GetProduct returns a single product by id, or nil if it does not exist.
 func (db *DB) GetProduct(ctx context.Context, id int64) (*models.Product, error) {
 	prods, err := db.GetProducts(ctx, 0, id)
-	return &prods[0], err
+	if err != nil {
+		return nil, err
+	}
+	if len(prods) == 0 {
+		return nil, nil
+	}
+	return &prods[0], nil
 }
 
-// CreateProductTx creates a product and its recipe items in a single transaction.
+// CreateProductTx creates a product and its product.created event in a
+// single transaction.
 func (db *DB) CreateProductTx(ctx context.Context, product *models.Product) (int64, error) {
 	tx, err := db.Begin(ctx)
 	if err != nil {
@@ -189,15 +113,8 @@ func (db *DB) CreateProductTx(ctx context.Context, product *models.Product) (int
 	if err := tx.QueryRow(ctx, insertProduct, product.Name, product.Category, product.Scent, product.Price, product.Subscription, product.Image, product.Description).Scan(&productID); err != nil {
 		return 0, fmt.Errorf("CreateProductTx insert product: %w", err)
 	}
-
-	// Insert provided recipe items under the same transaction.
-	if len(product.Recipe) > 0 {
-		insertItem := `INSERT INTO recipe_items (product_id, name, type, unit, amount, notes) VALUES ($1, $2, $3, $4, $5, $6);`
-		for _, ing := range product.Recipe {
-			if _, err := tx.Exec(ctx, insertItem, productID, ing.Name, string(ing.Type), ing.Unit, ing.Amount, ing.Notes); err != nil {
-				return 0, fmt.Errorf("CreateProductTx insert recipe item %q: %w", ing.Name, err)
-			}
-		}
+	if err := enqueueProductEventTx(ctx, tx, models.EventProductCreated, productID); err != nil {
+		return 0, fmt.Errorf("CreateProductTx: %w", err)
 	}
 
 	if err := tx.Commit(ctx); err != nil {
@@ -206,6 +123,85 @@ func (db *DB) CreateProductTx(ctx context.Context, product *models.Product) (int
 	return productID, nil
 }
 
+// UpdateProductTx replaces a product's catalog fields and writes its
+// product.updated event in a single transaction. It reports false if the
+// product does not exist.
+func (db *DB) UpdateProductTx(ctx context.Context, product *models.Product) (bool, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("UpdateProductTx begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	tag, err := tx.Exec(ctx, `UPDATE products SET name = $1, category = $2, scent = $3, price = $4, subscription = $5,
+		image = $6, description = $7, updated_at = NOW() WHERE id = $8`,
+		product.Name, product.Category, product.Scent, product.Price, product.Subscription, product.Image, product.Description, product.ID)
+	if err != nil {
+		return false, fmt.Errorf("UpdateProductTx update product: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+	if err := enqueueProductEventTx(ctx, tx, models.EventProductUpdated, product.ID); err != nil {
+		return false, fmt.Errorf("UpdateProductTx: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("UpdateProductTx commit: %w", err)
+	}
+	return true, nil
+}
+
+// DeleteProduct removes a product and writes its product.deleted event. It
+// is used to undo a creation whose recipe could not be saved, so consumers
+// that saw the product.created event learn it is gone.
+func (db *DB) DeleteProduct(ctx context.Context, id int64) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("DeleteProduct begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	p, err := scanProductEvent(tx.QueryRow(ctx, `DELETE FROM products WHERE id = $1 RETURNING `+productEventColumns, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("DeleteProduct: %w", err)
+	}
+	if err := enqueueEventTx(ctx, tx, models.EventProductDeleted, strconv.FormatInt(id, 10), p); err != nil {
+		return fmt.Errorf("DeleteProduct: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("DeleteProduct commit: %w", err)
+	}
+	return nil
+}
+
+// ProductIDs returns the id of every product, in id order. Other services
+// use it to find rows that still point at deleted products.
+func (db *DB) ProductIDs(ctx context.Context) ([]int64, error) {
+	rows, err := db.Query(ctx, `SELECT id FROM products ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("ProductIDs: %w", err)
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("ProductIDs scan: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ProductIDs rows: %w", err)
+	}
+	return ids, nil
+}
+
 func (db *DB) GetSubscriptionBoxes(ctx context.Context, limit int) ([]models.SubscriptionBox, error) {
 	query := "SELECT id, name, description, price, image, created_at, updated_at FROM subscription_boxes"
 	if limit > 0 {
@@ -228,6 +224,20 @@ func (db *DB) GetSubscriptionBoxes(ctx context.Context, limit int) ([]models.Sub
 	return subscriptionBoxes, nil
 }
 
+// GetSubscriptionBox returns a single subscription box by id, or nil if it does not exist.
+func (db *DB) GetSubscriptionBox(ctx context.Context, id int64) (*models.SubscriptionBox, error) {
+	query := "SELECT id, name, description, price, image, created_at, updated_at FROM subscription_boxes WHERE id = $1"
+	var box models.SubscriptionBox
+	err := db.QueryRow(ctx, query, id).Scan(&box.ID, &box.Name, &box.Description, &box.Price, &box.Image, &box.CreatedAt, &box.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get subscription box: %w", err)
+	}
+	return &box, nil
+}
+
 // CreateSubscriptionBox inserts a new subscription box into the database.
 func (db *DB) CreateSubscriptionBox(ctx context.Context, subscriptionBox *models.SubscriptionBox) (int64, error) {
 	query := `
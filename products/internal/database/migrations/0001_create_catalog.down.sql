@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS subscription_boxes;
+DROP TABLE IF EXISTS products;
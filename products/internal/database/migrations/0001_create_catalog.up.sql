@@ -0,0 +1,24 @@
+-- Baseline: matches the schema the service created before migrations, so
+-- existing databases adopt it without changes.
+CREATE TABLE IF NOT EXISTS products (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	category VARCHAR(255) NOT NULL,
+	scent VARCHAR(255) NOT NULL,
+	price NUMERIC(10, 2) NOT NULL,
+	subscription BOOLEAN DEFAULT false,
+	image VARCHAR(255) NOT NULL,
+	description TEXT NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS subscription_boxes (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	description TEXT NOT NULL,
+	price NUMERIC(10, 2) NOT NULL,
+	image VARCHAR(255) NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,13 @@
+-- Domain events written in the same transaction as the change they describe
+-- and published by the outbox relay.
+CREATE TABLE outbox_events (
+	id BIGSERIAL PRIMARY KEY,
+	type TEXT NOT NULL,
+	aggregate_id TEXT NOT NULL,
+	payload JSONB NOT NULL,
+	occurred_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	published_at TIMESTAMP WITH TIME ZONE,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT
+);
+CREATE INDEX outbox_events_pending ON outbox_events (id) WHERE published_at IS NULL;
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"com.MixieMelts.products/internal/models"
+	"com.MixieMelts.users/outbox"
+	"github.com/jackc/pgx/v5"
+)
+
+// enqueueEventTx writes an event to the outbox inside tx, so it is published
+// if and only if tx commits.
+func enqueueEventTx(ctx context.Context, tx pgx.Tx, eventType, aggregateID string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("encode %s event: %w", eventType, err)
+	}
+	_, err = tx.Exec(ctx, `INSERT INTO outbox_events (type, aggregate_id, payload) VALUES ($1,$2,$3)`, eventType, aggregateID, body)
+	if err != nil {
+		return fmt.Errorf("enqueue %s event: %w", eventType, err)
+	}
+	return nil
+}
+
+const productEventColumns = `id, name, category, scent, price, subscription, image, description, created_at, updated_at`
+
+func scanProductEvent(row pgx.Row) (models.ProductEvent, error) {
+	var p models.ProductEvent
+	err := row.Scan(&p.ID, &p.Name, &p.Category, &p.Scent, &p.Price, &p.Subscription, &p.Image, &p.Description, &p.CreatedAt, &p.UpdatedAt)
+	return p, err
+}
+
+// enqueueProductEventTx reads a product as the transaction sees it and
+// enqueues eventType with it as the payload.
+func enqueueProductEventTx(ctx context.Context, tx pgx.Tx, eventType string, productID int64) error {
+	p, err := scanProductEvent(tx.QueryRow(ctx, `SELECT `+productEventColumns+` FROM products WHERE id = $1`, productID))
+	if err != nil {
+		return fmt.Errorf("load product %d for %s event: %w", productID, eventType, err)
+	}
+	return enqueueEventTx(ctx, tx, eventType, strconv.FormatInt(productID, 10), p)
+}
+
+// PendingEvents returns up to limit unpublished outbox events, oldest first.
+func (db *DB) PendingEvents(ctx context.Context, limit int) ([]outbox.Event, error) {
+	rows, err := db.Query(ctx, `SELECT id, type, aggregate_id, payload, occurred_at FROM outbox_events
+		WHERE published_at IS NULL ORDER BY id LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("PendingEvents: %w", err)
+	}
+	defer rows.Close()
+
+	var events []outbox.Event
+	for rows.Next() {
+		var e outbox.Event
+		if err := rows.Scan(&e.ID, &e.Type, &e.AggregateID, &e.Payload, &e.OccurredAt); err != nil {
+			return nil, fmt.Errorf("PendingEvents scan: %w", err)
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("PendingEvents rows: %w", err)
+	}
+	return events, nil
+}
+
+// MarkEventPublished records that an outbox event was delivered.
+func (db *DB) MarkEventPublished(ctx context.Context, id int64) error {
+	_, err := db.Exec(ctx, `UPDATE outbox_events SET published_at = NOW(), attempts = attempts + 1, last_error = NULL WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("MarkEventPublished: %w", err)
+	}
+	return nil
+}
+
+// MarkEventFailed records a failed delivery attempt of an outbox event.
+func (db *DB) MarkEventFailed(ctx context.Context, id int64, reason string) error {
+	_, err := db.Exec(ctx, `UPDATE outbox_events SET attempts = attempts + 1, last_error = $2 WHERE id = $1`, id, reason)
+	if err != nil {
+		return fmt.Errorf("MarkEventFailed: %w", err)
+	}
+	return nil
+}
@@ -20,7 +20,8 @@ func (db *DB) seedProductsTable(ctx context.Context) {
 		return
 	}
 
-	// Create some sample products
+	// Create some sample products. Their recipes are owned and seeded by the
+	// inventory service, keyed by the ids these rows get in this order.
 	products := []models.Product{
 		{
 			Category:    "Year-Round",
@@ -29,13 +30,6 @@ func (db *DB) seedProductsTable(ctx context.Context) {
 			Description: "A calming, spa-like scent perfect for relaxation and de-stressing.",
 			Price:       5.49,
 			Image:       "https://placehold.co/400x400/e0e7ff/4c1d95?text=Serene+Sanctuary",
-			Recipe: []models.Ingredient{
-				{Name: "Soy Wax", Type: "Base", Unit: "g", Amount: 100.0},
-				{Name: "Lavender", Type: "Essential Oil", Unit: "mL", Amount: 4.0},    // 80 drops * 0.05
-				{Name: "Chamomile", Type: "Essential Oil", Unit: "mL", Amount: 3.0},   // 60 drops * 0.05
-				{Name: "Cedarwood", Type: "Essential Oil", Unit: "mL", Amount: 2.0},   // 40 drops * 0.05
-				{Name: "Ylang Ylang", Type: "Essential Oil", Unit: "mL", Amount: 1.0}, // 20 drops * 0.05
-			},
 		},
 		{
 			Category:    "Year-Round",
@@ -44,13 +38,6 @@ func (db *DB) seedProductsTable(ctx context.Context) {
 			Description: "A bright, energizing, and clean aroma that uplifts the mood.",
 			Price:       3.99,
 			Image:       "https://placehold.co/400x400/fef9c3/b45309?text=Citrus+Sunshine",
-			Recipe: []models.Ingredient{
-				{Name: "Soy Wax", Type: "Base", Unit: "g", Amount: 100.0},
-				{Name: "Sweet Orange", Type: "Essential Oil", Unit: "mL", Amount: 3.5}, // 70 drops * 0.05
-				{Name: "Lemon", Type: "Essential Oil", Unit: "mL", Amount: 3.0},        // 60 drops * 0.05
-				{Name: "Bergamot", Type: "Essential Oil", Unit: "mL", Amount: 2.5},     // 50 drops * 0.05
-				{Name: "Spearmint", Type: "Essential Oil", Unit: "mL", Amount: 1.0},    // 20 drops * 0.05
-			},
 		},
 		{
 			Category:    "Year-Round",
@@ -59,13 +46,6 @@ func (db *DB) seedProductsTable(ctx context.Context) {
 			Description: "A warm, soft, and comforting scent like being wrapped in a favorite blanket.",
 			Price:       6.49,
 			Image:       "https://placehold.co/400x400/f5f5f4/78350f?text=Cozy+Cashmere",
-			Recipe: []models.Ingredient{
-				{Name: "Soy Wax", Type: "Base", Unit: "g", Amount: 100.0},
-				{Name: "Vanilla Absolute", Type: "Natural Fragrance", Unit: "mL", Amount: 4.0}, // 80 drops * 0.05
-				{Name: "Sandalwood", Type: "Essential Oil", Unit: "mL", Amount: 3.0},           // 60 drops * 0.05
-				{Name: "Amyris", Type: "Essential Oil", Unit: "mL", Amount: 2.0},               // 40 drops * 0.05
-				{Name: "Peru Balsam", Type: "Natural Fragrance", Unit: "mL", Amount: 1.5},      // 30 drops * 0.05
-			},
 		},
 		{
 			Category:    "Year-Round",
@@ -74,13 +54,6 @@ func (db *DB) seedProductsTable(ctx context.Context) {
 			Description: "A fresh, green, and earthy scent that brings the outdoors in.",
 			Price:       4.99,
 			Image:       "https://placehold.co/400x400/dcfce7/14532d?text=Woodland+Walk",
-			Recipe: []models.Ingredient{
-				{Name: "Soy Wax", Type: "Base", Unit: "g", Amount: 100.0},
-				{Name: "Eucalyptus", Type: "Essential Oil", Unit: "mL", Amount: 3.5}, // 70 drops * 0.05
-				{Name: "Cypress", Type: "Essential Oil", Unit: "mL", Amount: 3.0},    // 60 drops * 0.05
-				{Name: "Rosemary", Type: "Essential Oil", Unit: "mL", Amount: 2.0},   // 40 drops * 0.05
-				{Name: "Peppermint", Type: "Essential Oil", Unit: "mL", Amount: 1.5}, // 30 drops * 0.05
-			},
 		},
 		{
 			Category:    "Year-Round",
@@ -89,12 +62,6 @@ func (db *DB) seedProductsTable(ctx context.Context) {
 			Description: "A classic, romantic, and elegant true floral scent.",
 			Price:       5.99,
 			Image:       "https://placehold.co/400x400/fce7f3/9d174d?text=Rose+Garden",
-			Recipe: []models.Ingredient{
-				{Name: "Soy Wax", Type: "Base", Unit: "g", Amount: 100.0},
-				{Name: "Rose Absolute", Type: "Natural Fragrance", Unit: "mL", Amount: 5.0}, // 100 drops * 0.05
-				{Name: "Palmarosa", Type: "Essential Oil", Unit: "mL", Amount: 3.0},         // 60 drops * 0.05
-				{Name: "Petitgrain", Type: "Essential Oil", Unit: "mL", Amount: 2.0},        // 40 drops * 0.05
-			},
 		},
 		{
 			Category:    "Spring",
@@ -103,13 +70,6 @@ func (db *DB) seedProductsTable(ctx context.Context) {
 			Description: "The fresh, earthy scent of rain on soil and budding greenery.",
 			Price:       4.99,
 			Image:       "https://placehold.co/400x400/dbeafe/1e3a8a?text=April+Showers",
-			Recipe: []models.Ingredient{
-				{Name: "Soy Wax", Type: "Base", Unit: "g", Amount: 100.0},
-				{Name: "Vetiver", Type: "Essential Oil", Unit: "mL", Amount: 3.5},     // 70 drops * 0.05
-				{Name: "Oakmoss", Type: "Natural Fragrance", Unit: "mL", Amount: 3.0}, // 60 drops * 0.05
-				{Name: "Petitgrain", Type: "Essential Oil", Unit: "mL", Amount: 2.0},  // 40 drops * 0.05
-				{Name: "Clary Sage", Type: "Essential Oil", Unit: "mL", Amount: 1.5},  // 30 drops * 0.05
-			},
 		},
 		{
 			Category:    "Spring",
@@ -118,13 +78,6 @@ func (db *DB) seedProductsTable(ctx context.Context) {
 			Description: "A sweet, light floral reminiscent of a field of blooming wildflowers.",
 			Price:       5.49,
 			Image:       "https://placehold.co/400x400/f5d0fe/701a75?text=Wildflower+Meadow",
-			Recipe: []models.Ingredient{
-				{Name: "Soy Wax", Type: "Base", Unit: "g", Amount: 100.0},
-				{Name: "Geranium", Type: "Essential Oil", Unit: "mL", Amount: 3.5},   // 70 drops * 0.05
-				{Name: "Lavender", Type: "Essential Oil", Unit: "mL", Amount: 3.0},   // 60 drops * 0.05
-				{Name: "Chamomile", Type: "Essential Oil", Unit: "mL", Amount: 2.0},  // 40 drops * 0.05
-				{Name: "Lemongrass", Type: "Essential Oil", Unit: "mL", Amount: 1.5}, // 30 drops * 0.05
-			},
 		},
 		{
 			Category:    "Spring",
@@ -133,11 +86,6 @@ func (db *DB) seedProductsTable(ctx context.Context) {
 			Description: "The sweet, heady, and iconic fragrance of a blooming lilac bush.",
 			Price:       6.99,
 			Image:       "https://placehold.co/400x400/ede9fe/5b21b6?text=Lilac+Bloom",
-			Recipe: []models.Ingredient{
-				{Name: "Soy Wax", Type: "Base", Unit: "g", Amount: 100.0},
-				{Name: "Lilac Natural Fragrance", Type: "Natural Fragrance", Unit: "mL", Amount: 9.0}, // 180 drops * 0.05
-				{Name: "Ylang Ylang", Type: "Essential Oil", Unit: "mL", Amount: 1.5},                 // 30 drops * 0.05
-			},
 		},
 		{
 			Category:    "Summer",
@@ -146,13 +94,6 @@ func (db *DB) seedProductsTable(ctx context.Context) {
 			Description: "A refreshing, vibrant scent like a mojito on the beach.",
 			Price:       4.49,
 			Image:       "https://placehold.co/400x400/a5f3fc/155e75?text=Coastal+Breeze",
-			Recipe: []models.Ingredient{
-				{Name: "Soy Wax", Type: "Base", Unit: "g", Amount: 100.0},
-				{Name: "Coconut Natural Fragrance", Type: "Natural Fragrance", Unit: "mL", Amount: 4.0}, // 80 drops * 0.05
-				{Name: "Lime", Type: "Essential Oil", Unit: "mL", Amount: 3.5},                          // 70 drops * 0.05
-				{Name: "Spearmint", Type: "Essential Oil", Unit: "mL", Amount: 1.5},                     // 30 drops * 0.05
-				{Name: "Amyris", Type: "Essential Oil", Unit: "mL", Amount: 1.0},                        // 20 drops * 0.05
-			},
 		},
 		{
 			Category:    "Summer",
@@ -161,12 +102,6 @@ func (db *DB) seedProductsTable(ctx context.Context) {
 			Description: "Sweet, juicy, and warm, like a ripe peach picked from the tree.",
 			Price:       5.99,
 			Image:       "https://placehold.co/400x400/ffedd5/f97316?text=Sun-Kissed+Peach",
-			Recipe: []models.Ingredient{
-				{Name: "Soy Wax", Type: "Base", Unit: "g", Amount: 100.0},
-				{Name: "Peach Natural Fragrance", Type: "Natural Fragrance", Unit: "mL", Amount: 6.0}, // 120 drops * 0.05
-				{Name: "Sweet Orange", Type: "Essential Oil", Unit: "mL", Amount: 2.5},                // 50 drops * 0.05
-				{Name: "Vanilla Absolute", Type: "Natural Fragrance", Unit: "mL", Amount: 2.0},        // 40 drops * 0.05
-			},
 		},
 		{
 			Category:    "Summer",
@@ -175,13 +110,6 @@ func (db *DB) seedProductsTable(ctx context.Context) {
 			Description: "An exotic and sweet blend that transports you to a tropical island.",
 			Price:       5.49,
 			Image:       "https://placehold.co/400x400/fef08a/eab308?text=Tropical+Getaway",
-			Recipe: []models.Ingredient{
-				{Name: "Soy Wax", Type: "Base", Unit: "g", Amount: 100.0},
-				{Name: "Pineapple Natural Fragrance", Type: "Natural Fragrance", Unit: "mL", Amount: 4.0}, // 80 drops * 0.05
-				{Name: "Coconut Natural Fragrance", Type: "Natural Fragrance", Unit: "mL", Amount: 3.5},   // 70 drops * 0.05
-				{Name: "Lime", Type: "Essential Oil", Unit: "mL", Amount: 1.5},                            // 30 drops * 0.05
-				{Name: "Vanilla Absolute", Type: "Natural Fragrance", Unit: "mL", Amount: 1.0},            // 20 drops * 0.05
-			},
 		},
 		{
 			Category:    "Autumn",
@@ -190,12 +118,6 @@ func (db *DB) seedProductsTable(ctx context.Context) {
 			Description: "The quintessential scent of fall—warm, spicy, and fruity.",
 			Price:       4.99,
 			Image:       "https://placehold.co/400x400/fee2e2/991b1b?text=Autumn+Harvest",
-			Recipe: []models.Ingredient{
-				{Name: "Soy Wax", Type: "Base", Unit: "g", Amount: 100.0},
-				{Name: "Apple Natural Fragrance", Type: "Natural Fragrance", Unit: "mL", Amount: 6.0}, // 120 drops * 0.05
-				{Name: "Cinnamon", Type: "Essential Oil", Unit: "mL", Amount: 2.5},                    // 50 drops * 0.05
-				{Name: "Clove", Type: "Essential Oil", Unit: "mL", Amount: 1.5},                       // 30 drops * 0.05
-			},
 		},
 		{
 			Category:    "Autumn",
@@ -204,13 +126,6 @@ func (db *DB) seedProductsTable(ctx context.Context) {
 			Description: "A smoky, woody, and cozy scent that evokes a crackling bonfire.",
 			Price:       6.49,
 			Image:       "https://placehold.co/400x400/737373/171717?text=Bonfire+Flannel",
-			Recipe: []models.Ingredient{
-				{Name: "Soy Wax", Type: "Base", Unit: "g", Amount: 100.0},
-				{Name: "Cedarwood", Type: "Essential Oil", Unit: "mL", Amount: 4.0},    // 80 drops * 0.05
-				{Name: "Frankincense", Type: "Essential Oil", Unit: "mL", Amount: 2.5}, // 50 drops * 0.05
-				{Name: "Vetiver", Type: "Essential Oil", Unit: "mL", Amount: 2.0},      // 40 drops * 0.05
-				{Name: "Birch Tar", Type: "Essential Oil", Unit: "mL", Amount: 0.5},    // 10 drops * 0.05
-			},
 		},
 		{
 			Category:    "Autumn",
@@ -219,14 +134,6 @@ func (db *DB) seedProductsTable(ctx context.Context) {
 			Description: "A comforting and classic blend of pumpkin and warm baking spices.",
 			Price:       3.99,
 			Image:       "https://placehold.co/400x400/fed7aa/c2410c?text=Pumpkin+Spice",
-			Recipe: []models.Ingredient{
-				{Name: "Soy Wax", Type: "Base", Unit: "g", Amount: 100.0},
-				{Name: "Cinnamon", Type: "Essential Oil", Unit: "mL", Amount: 3.0}, // 60 drops * 0.05
-				{Name: "Clove", Type: "Essential Oil", Unit: "mL", Amount: 2.0},    // 40 drops * 0.05
-				{Name: "Ginger", Type: "Essential Oil", Unit: "mL", Amount: 2.0},   // 40 drops * 0.05
-				{Name: "Nutmeg", Type: "Essential Oil", Unit: "mL", Amount: 1.5},   // 30 drops * 0.05
-				{Name: "Cardamom", Type: "Essential Oil", Unit: "mL", Amount: 1.0}, // 20 drops * 0.05
-			},
 		},
 		{
 			Category:    "Winter",
@@ -235,13 +142,6 @@ func (db *DB) seedProductsTable(ctx context.Context) {
 			Description: "A crisp, clean scent of a snow-covered evergreen forest.",
 			Price:       5.49,
 			Image:       "https://placehold.co/400x400/ecfdf5/065f46?text=Winter+Woods",
-			Recipe: []models.Ingredient{
-				{Name: "Soy Wax", Type: "Base", Unit: "g", Amount: 100.0},
-				{Name: "Fir Balsam", Type: "Essential Oil", Unit: "mL", Amount: 4.0},  // 80 drops * 0.05
-				{Name: "Pine Needle", Type: "Essential Oil", Unit: "mL", Amount: 3.0}, // 60 drops * 0.05
-				{Name: "Cypress", Type: "Essential Oil", Unit: "mL", Amount: 2.0},     // 40 drops * 0.05
-				{Name: "Cedarwood", Type: "Essential Oil", Unit: "mL", Amount: 1.0},   // 20 drops * 0.05
-			},
 		},
 		{
 			Category:    "Winter",
@@ -250,12 +150,6 @@ func (db *DB) seedProductsTable(ctx context.Context) {
 			Description: "A festive and bright blend of tart fruit and warm spices.",
 			Price:       4.99,
 			Image:       "https://placehold.co/400x400/fee2e2/dc2626?text=Spiced+Cranberry",
-			Recipe: []models.Ingredient{
-				{Name: "Soy Wax", Type: "Base", Unit: "g", Amount: 100.0},
-				{Name: "Cranberry Natural Fragrance", Type: "Natural Fragrance", Unit: "mL", Amount: 6.0}, // 120 drops * 0.05
-				{Name: "Sweet Orange", Type: "Essential Oil", Unit: "mL", Amount: 3.0},                    // 60 drops * 0.05
-				{Name: "Cinnamon", Type: "Essential Oil", Unit: "mL", Amount: 1.5},                        // 30 drops * 0.05
-			},
 		},
 		{
 			Category:    "Winter",
@@ -264,12 +158,6 @@ func (db *DB) seedProductsTable(ctx context.Context) {
 			Description: "A delicious and nostalgic mix of rich chocolate and cool, sweet mint.",
 			Price:       5.99,
 			Image:       "https://placehold.co/400x400/d1fae5/78350f?text=Peppermint+Cocoa",
-			Recipe: []models.Ingredient{
-				{Name: "Soy Wax", Type: "Base", Unit: "g", Amount: 100.0},
-				{Name: "Peppermint", Type: "Essential Oil", Unit: "mL", Amount: 4.5},           // 90 drops * 0.05
-				{Name: "Cocoa Absolute", Type: "Natural Fragrance", Unit: "mL", Amount: 3.5},   // 70 drops * 0.05
-				{Name: "Vanilla Absolute", Type: "Natural Fragrance", Unit: "mL", Amount: 2.5}, // 50 drops * 0.05
-			},
 		},
 		{
 			Category:    "Holiday",
@@ -278,13 +166,6 @@ func (db *DB) seedProductsTable(ctx context.Context) {
 			Description: "An earthy, spicy, and mysterious scent for a spooky atmosphere.",
 			Price:       5.99,
 			Image:       "https://placehold.co/400x400/a78bfa/3b0764?text=Witches'+Brew",
-			Recipe: []models.Ingredient{
-				{Name: "Soy Wax", Type: "Base", Unit: "g", Amount: 100.0},
-				{Name: "Patchouli", Type: "Essential Oil", Unit: "mL", Amount: 4.0},    // 80 drops * 0.05
-				{Name: "Frankincense", Type: "Essential Oil", Unit: "mL", Amount: 3.0}, // 60 drops * 0.05
-				{Name: "Cinnamon", Type: "Essential Oil", Unit: "mL", Amount: 2.0},     // 40 drops * 0.05
-				{Name: "Clove", Type: "Essential Oil", Unit: "mL", Amount: 1.0},        // 20 drops * 0.05
-			},
 		},
 		{
 			Category:    "Holiday",
@@ -293,13 +174,6 @@ func (db *DB) seedProductsTable(ctx context.Context) {
 			Description: "The earthy smell of dry hay, damp fallen leaves, and distant woods.",
 			Price:       6.99,
 			Image:       "https://placehold.co/400x400/fde68a/713f12?text=Haunted+Hayride",
-			Recipe: []models.Ingredient{
-				{Name: "Soy Wax", Type: "Base", Unit: "g", Amount: 100.0},
-				{Name: "Hay Absolute", Type: "Natural Fragrance", Unit: "mL", Amount: 3.5}, // 70 drops * 0.05
-				{Name: "Vetiver", Type: "Essential Oil", Unit: "mL", Amount: 3.0},          // 60 drops * 0.05
-				{Name: "Amyris", Type: "Essential Oil", Unit: "mL", Amount: 2.0},           // 40 drops * 0.05
-				{Name: "Cedarwood", Type: "Essential Oil", Unit: "mL", Amount: 1.5},        // 30 drops * 0.05
-			},
 		},
 		{
 			Category:    "Holiday",
@@ -308,12 +182,6 @@ func (db *DB) seedProductsTable(ctx context.Context) {
 			Description: "The fresh, nostalgic, and beloved scent of a freshly cut Christmas tree.",
 			Price:       4.99,
 			Image:       "https://placehold.co/400x400/bbf7d0/166534?text=Christmas+Tree",
-			Recipe: []models.Ingredient{
-				{Name: "Soy Wax", Type: "Base", Unit: "g", Amount: 100.0},
-				{Name: "Fir Balsam", Type: "Essential Oil", Unit: "mL", Amount: 5.0},   // 100 drops * 0.05
-				{Name: "Pine Needle", Type: "Essential Oil", Unit: "mL", Amount: 3.5},  // 70 drops * 0.05
-				{Name: "Sweet Orange", Type: "Essential Oil", Unit: "mL", Amount: 1.5}, // 30 drops * 0.05
-			},
 		},
 		{
 			Category:    "Holiday",
@@ -322,14 +190,6 @@ func (db *DB) seedProductsTable(ctx context.Context) {
 			Description: "Warm, spicy, and sweet, just like a freshly decorated gingerbread house.",
 			Price:       5.49,
 			Image:       "https://placehold.co/400x400/f3e8ff/92400e?text=Gingerbread+House",
-			Recipe: []models.Ingredient{
-				{Name: "Soy Wax", Type: "Base", Unit: "g", Amount: 100.0},
-				{Name: "Ginger", Type: "Essential Oil", Unit: "mL", Amount: 3.0},               // 60 drops * 0.05
-				{Name: "Cinnamon", Type: "Essential Oil", Unit: "mL", Amount: 2.5},             // 50 drops * 0.05
-				{Name: "Vanilla Absolute", Type: "Natural Fragrance", Unit: "mL", Amount: 2.0}, // 40 drops * 0.05
-				{Name: "Nutmeg", Type: "Essential Oil", Unit: "mL", Amount: 1.5},               // 30 drops * 0.05
-				{Name: "Clove", Type: "Essential Oil", Unit: "mL", Amount: 1.0},                // 20 drops * 0.05
-			},
 		}}
 
 	for _, product := range products {
@@ -3,11 +3,13 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
 	"strings"
 
+	"com.MixieMelts.products/internal/inventory"
 	"com.MixieMelts.products/internal/models"
 	"github.com/go-chi/chi/v5"
 )
@@ -17,26 +19,55 @@ type DBLayer interface {
 	// GetProducts returns multiple products (optionally limited).
 	GetProducts(ctx context.Context, limit int, prodID int64) ([]models.Product, error)
 
-	// GetProduct returns a single product by id including its recipe.
+	// GetProduct returns a single product by id.
 	GetProduct(ctx context.Context, id int64) (*models.Product, error)
 
-	// CreateProduct inserts a product without guaranteeing transactional recipe inserts.
+	// CreateProduct inserts a product.
 	CreateProduct(ctx context.Context, product *models.Product) (int64, error)
 
-	// CreateProductTx inserts a product and its recipe atomically in a transaction.
+	// CreateProductTx inserts a product in a transaction.
 	CreateProductTx(ctx context.Context, product *models.Product) (int64, error)
 
+	// UpdateProductTx replaces a product's catalog fields in a transaction,
+	// reporting false if it does not exist.
+	UpdateProductTx(ctx context.Context, product *models.Product) (bool, error)
+
+	// DeleteProduct removes a product; used to undo a creation whose recipe
+	// could not be saved.
+	DeleteProduct(ctx context.Context, id int64) error
+
+	// ProductIDs returns the id of every product.
+	ProductIDs(ctx context.Context) ([]int64, error)
+
 	GetSubscriptionBoxes(ctx context.Context, limit int) ([]models.SubscriptionBox, error)
+	GetSubscriptionBox(ctx context.Context, id int64) (*models.SubscriptionBox, error)
 	CreateSubscriptionBox(ctx context.Context, box *models.SubscriptionBox) (int64, error)
 }
 
+// StockSource reports how many units of each product can still be sold.
+type StockSource interface {
+	Capacities(ctx context.Context) (map[int64]inventory.Capacity, error)
+	Capacity(ctx context.Context, productID int64) (*inventory.Capacity, error)
+}
+
+// RecipeSource loads and saves product recipes, which the inventory service
+// owns.
+type RecipeSource interface {
+	Recipes(ctx context.Context, productIDs []int64) (map[int64][]inventory.RecipeItem, error)
+	ReplaceRecipe(ctx context.Context, productID int64, items []inventory.RecipeItem) ([]inventory.RecipeItem, error)
+}
+
 type Handler struct {
-	db DBLayer
+	db      DBLayer
+	stock   StockSource
+	recipes RecipeSource
 }
 
-// New creates a new handler.
-func New(db DBLayer) *Handler {
-	return &Handler{db: db}
+// New creates a new handler. Products are labelled with their stock from
+// stock and carry their recipe from recipes; pass nil for either to leave it
+// off.
+func New(db DBLayer, stock StockSource, recipes RecipeSource) *Handler {
+	return &Handler{db: db, stock: stock, recipes: recipes}
 }
 
 // GetProducts handles GET requests to /products.
@@ -57,12 +88,8 @@ func (h *Handler) GetProducts(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusInternalServerError, "Failed to get products")
 		return
 	}
-	// Ensure recipe is a non-nil slice for the frontend (avoid JSON `null`).
-	for i := range products {
-		if products[i].Recipe == nil {
-			products[i].Recipe = []models.Ingredient{}
-		}
-	}
+	h.attachRecipes(r.Context(), products)
+	h.labelStock(r.Context(), products)
 	log.Printf("handlers: returning %d products (limit=%d)", len(products), limit)
 	respondWithJSON(w, http.StatusOK, products)
 }
@@ -102,33 +129,94 @@ func (h *Handler) GetProduct(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusNotFound, "Product not found")
 		return
 	}
-	// Ensure recipe is a non-nil slice to prevent JSON null in the frontend.
-	if product.Recipe == nil {
-		product.Recipe = []models.Ingredient{}
+	products := []models.Product{*product}
+	h.attachRecipes(r.Context(), products)
+	product = &products[0]
+	if h.stock != nil {
+		if c, err := h.stock.Capacity(r.Context(), product.ID); err != nil {
+			log.Printf("GetProduct stock error: %v", err)
+		} else {
+			product.Stock = models.NewStock(c.SellableUnits)
+		}
 	}
 	log.Printf("handlers: returning product id=%d with %d recipe items", product.ID, len(product.Recipe))
 	respondWithJSON(w, http.StatusOK, product)
 }
 
+// GetProductIDs handles GET requests to /internal/product-ids. It lists the
+// id of every product so other services can reconcile their references.
+func (h *Handler) GetProductIDs(w http.ResponseWriter, r *http.Request) {
+	ids, err := h.db.ProductIDs(r.Context())
+	if err != nil {
+		log.Printf("GetProductIDs error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to list product ids")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, ids)
+}
+
+// labelStock fills in the stock of each product. If inventory cannot be
+// reached the products are left unlabelled rather than failing the listing.
+func (h *Handler) labelStock(ctx context.Context, products []models.Product) {
+	if h.stock == nil || len(products) == 0 {
+		return
+	}
+	capacities, err := h.stock.Capacities(ctx)
+	if err != nil {
+		log.Printf("GetProducts stock error: %v", err)
+		return
+	}
+	for i := range products {
+		products[i].Stock = models.NewStock(capacities[products[i].ID].SellableUnits)
+	}
+}
+
 // CreateProduct handles POST requests to /products.
-// This handler expects the database layer to provide a transactional create
-// that inserts the product and its recipe atomically.
+// The product row is created first and its recipe, if any, is then saved to
+// the inventory service. If inventory rejects the recipe or cannot be
+// reached, the product is deleted again so no product is left without the
+// recipe it was created with.
 func (h *Handler) CreateProduct(w http.ResponseWriter, r *http.Request) {
 	var product models.Product
 	if err := json.NewDecoder(r.Body).Decode(&product); err != nil {
 		respondWithError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
+	if len(product.Recipe) > 0 && h.recipes == nil {
+		respondWithError(w, http.StatusServiceUnavailable, "Recipes cannot be saved")
+		return
+	}
 
-	// Prefer transactional creation to ensure product + recipe are inserted atomically.
 	productID, err := h.db.CreateProductTx(r.Context(), &product)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to create product")
 		return
 	}
 
+	var recipe []models.Ingredient
+	if len(product.Recipe) > 0 {
+		items := make([]inventory.RecipeItem, len(product.Recipe))
+		for i, ing := range product.Recipe {
+			items[i] = inventory.RecipeItem{IngredientID: ing.ID, Unit: ing.Unit, Amount: ing.Amount, Notes: ing.Notes}
+		}
+		stored, err := h.recipes.ReplaceRecipe(r.Context(), productID, items)
+		if err != nil {
+			if derr := h.db.DeleteProduct(context.WithoutCancel(r.Context()), productID); derr != nil {
+				log.Printf("CreateProduct: product %d left without its recipe: %v", productID, derr)
+			}
+			if errors.Is(err, inventory.ErrInvalidRecipe) {
+				respondWithError(w, http.StatusUnprocessableEntity, err.Error())
+				return
+			}
+			log.Printf("CreateProduct recipe error: %v", err)
+			respondWithError(w, http.StatusBadGateway, "Failed to save recipe")
+			return
+		}
+		recipe = recipeFromInventory(stored)
+	}
+
 	// After creation, retrieve the product as stored in the DB so we return the
-	// authoritative representation (including any recipe items inserted by the DB).
+	// authoritative representation.
 	created, err := h.db.GetProduct(r.Context(), productID)
 	if err != nil {
 		// Treat inability to retrieve the created product as an internal server error.
@@ -140,14 +228,100 @@ func (h *Handler) CreateProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Ensure recipe is non-nil for JSON consumers.
+	created.Recipe = recipe
 	if created.Recipe == nil {
 		created.Recipe = []models.Ingredient{}
 	}
-
 	respondWithJSON(w, http.StatusCreated, created)
 }
 
+// UpdateProduct handles PUT requests to /products/{id}. It replaces the
+// product's catalog fields; recipes are edited in the inventory service, so
+// any recipe in the body is ignored.
+func (h *Handler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid product id")
+		return
+	}
+
+	var product models.Product
+	if err := json.NewDecoder(r.Body).Decode(&product); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	product.ID = id
+
+	found, err := h.db.UpdateProductTx(r.Context(), &product)
+	if err != nil {
+		log.Printf("UpdateProduct error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to update product")
+		return
+	}
+	if !found {
+		respondWithError(w, http.StatusNotFound, "Product not found")
+		return
+	}
+
+	updated, err := h.db.GetProduct(r.Context(), id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to retrieve updated product")
+		return
+	}
+	if updated == nil {
+		respondWithError(w, http.StatusNotFound, "Product not found")
+		return
+	}
+	products := []models.Product{*updated}
+	h.attachRecipes(r.Context(), products)
+	respondWithJSON(w, http.StatusOK, products[0])
+}
+
+// attachRecipes fills in each product's recipe from inventory. Products whose
+// recipe cannot be loaded get an empty recipe and are marked
+// RecipeUnavailable rather than failing the request.
+func (h *Handler) attachRecipes(ctx context.Context, products []models.Product) {
+	if len(products) == 0 {
+		return
+	}
+	var recipes map[int64][]inventory.RecipeItem
+	if h.recipes != nil {
+		ids := make([]int64, len(products))
+		for i, p := range products {
+			ids[i] = p.ID
+		}
+		var err error
+		recipes, err = h.recipes.Recipes(ctx, ids)
+		if err != nil {
+			log.Printf("handlers: recipes unavailable: %v", err)
+		}
+	}
+	for i := range products {
+		items, ok := recipes[products[i].ID]
+		if !ok {
+			products[i].Recipe = []models.Ingredient{}
+			products[i].RecipeUnavailable = true
+			continue
+		}
+		products[i].Recipe = recipeFromInventory(items)
+	}
+}
+
+func recipeFromInventory(items []inventory.RecipeItem) []models.Ingredient {
+	recipe := make([]models.Ingredient, len(items))
+	for i, it := range items {
+		recipe[i] = models.Ingredient{
+			ID:     it.IngredientID,
+			Name:   it.IngredientName,
+			Type:   models.IngredientType(it.IngredientType),
+			Unit:   it.Unit,
+			Amount: it.Amount,
+			Notes:  it.Notes,
+		}
+	}
+	return recipe
+}
+
 // GetSubscriptionBoxes handles GET requests to /subscription-boxes.
 func (h *Handler) GetSubscriptionBoxes(w http.ResponseWriter, r *http.Request) {
 	limitStr := r.URL.Query().Get("limit")
@@ -169,6 +343,26 @@ func (h *Handler) GetSubscriptionBoxes(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, http.StatusOK, subscriptionBoxes)
 }
 
+// GetSubscriptionBox handles GET requests to /subscription-boxes/{id}.
+func (h *Handler) GetSubscriptionBox(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid subscription box id")
+		return
+	}
+
+	box, err := h.db.GetSubscriptionBox(r.Context(), id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to get subscription box")
+		return
+	}
+	if box == nil {
+		respondWithError(w, http.StatusNotFound, "Subscription box not found")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, box)
+}
+
 // CreateSubscriptionBox handles POST requests to /subscription-boxes.
 func (h *Handler) CreateSubscriptionBox(w http.ResponseWriter, r *http.Request) {
 	var subscriptionBox models.SubscriptionBox
@@ -5,12 +5,16 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
 	"time"
 
+	"com.MixieMelts.products/internal/inventory"
 	"com.MixieMelts.products/internal/models"
+	"github.com/go-chi/chi/v5"
 )
 
 // MockDB is a mock implementation of the DBLayer for testing purposes.
@@ -20,7 +24,32 @@ type MockDB struct {
 	CreateProductFunc         func(ctx context.Context, product *models.Product) (int64, error)
 	CreateProductTxFunc       func(ctx context.Context, product *models.Product) (int64, error)
 	GetSubscriptionBoxesFunc  func(ctx context.Context, limit int) ([]models.SubscriptionBox, error)
+	GetSubscriptionBoxFunc    func(ctx context.Context, id int64) (*models.SubscriptionBox, error)
 	CreateSubscriptionBoxFunc func(ctx context.Context, box *models.SubscriptionBox) (int64, error)
+	DeleteProductFunc         func(ctx context.Context, id int64) error
+	ProductIDsFunc            func(ctx context.Context) ([]int64, error)
+	UpdateProductTxFunc       func(ctx context.Context, product *models.Product) (bool, error)
+}
+
+func (m *MockDB) UpdateProductTx(ctx context.Context, product *models.Product) (bool, error) {
+	if m.UpdateProductTxFunc != nil {
+		return m.UpdateProductTxFunc(ctx, product)
+	}
+	return false, errors.New("UpdateProductTxFunc not implemented")
+}
+
+func (m *MockDB) ProductIDs(ctx context.Context) ([]int64, error) {
+	if m.ProductIDsFunc != nil {
+		return m.ProductIDsFunc(ctx)
+	}
+	return nil, errors.New("ProductIDsFunc not implemented")
+}
+
+func (m *MockDB) DeleteProduct(ctx context.Context, id int64) error {
+	if m.DeleteProductFunc != nil {
+		return m.DeleteProductFunc(ctx, id)
+	}
+	return errors.New("DeleteProductFunc not implemented")
 }
 
 func (m *MockDB) GetProducts(ctx context.Context, limit int, id int64) ([]models.Product, error) {
@@ -68,6 +97,13 @@ func (m *MockDB) GetSubscriptionBoxes(ctx context.Context, limit int) ([]models.
 	return nil, errors.New("GetSubscriptionBoxesFunc not implemented")
 }
 
+func (m *MockDB) GetSubscriptionBox(ctx context.Context, id int64) (*models.SubscriptionBox, error) {
+	if m.GetSubscriptionBoxFunc != nil {
+		return m.GetSubscriptionBoxFunc(ctx, id)
+	}
+	return nil, errors.New("GetSubscriptionBoxFunc not implemented")
+}
+
 func (m *MockDB) CreateSubscriptionBox(ctx context.Context, box *models.SubscriptionBox) (int64, error) {
 	if m.CreateSubscriptionBoxFunc != nil {
 		return m.CreateSubscriptionBoxFunc(ctx, box)
@@ -128,7 +164,7 @@ func TestGetProducts(t *testing.T) {
 				},
 			}
 
-			handler := New(mockDB)
+			handler := New(mockDB, nil, nil)
 
 			req, err := http.NewRequest("GET", "/products"+tc.limitQuery, nil)
 			if err != nil {
@@ -155,11 +191,128 @@ func TestGetProducts(t *testing.T) {
 	}
 }
 
+// fakeStock is a StockSource backed by a map of sellable units per product.
+type fakeStock struct {
+	units map[int64]int
+	err   error
+}
+
+func (f *fakeStock) Capacities(ctx context.Context) (map[int64]inventory.Capacity, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	out := map[int64]inventory.Capacity{}
+	for id, n := range f.units {
+		out[id] = inventory.Capacity{ProductID: id, SellableUnits: n}
+	}
+	return out, nil
+}
+
+func (f *fakeStock) Capacity(ctx context.Context, productID int64) (*inventory.Capacity, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &inventory.Capacity{ProductID: productID, SellableUnits: f.units[productID]}, nil
+}
+
+// fakeRecipes is a RecipeSource backed by a map of recipes per product.
+type fakeRecipes struct {
+	recipes    map[int64][]inventory.RecipeItem
+	err        error
+	replaceErr error
+}
+
+func (f *fakeRecipes) Recipes(ctx context.Context, productIDs []int64) (map[int64][]inventory.RecipeItem, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	out := map[int64][]inventory.RecipeItem{}
+	for _, id := range productIDs {
+		out[id] = append([]inventory.RecipeItem{}, f.recipes[id]...)
+	}
+	return out, nil
+}
+
+func (f *fakeRecipes) ReplaceRecipe(ctx context.Context, productID int64, items []inventory.RecipeItem) ([]inventory.RecipeItem, error) {
+	if f.replaceErr != nil {
+		return nil, f.replaceErr
+	}
+	names := map[int64]string{1: "Soy Wax", 2: "Lavender"}
+	stored := make([]inventory.RecipeItem, len(items))
+	for i, it := range items {
+		it.IngredientName = names[it.IngredientID]
+		stored[i] = it
+	}
+	if f.recipes == nil {
+		f.recipes = map[int64][]inventory.RecipeItem{}
+	}
+	f.recipes[productID] = stored
+	return stored, nil
+}
+
+func TestGetProductsStock(t *testing.T) {
+	products := []models.Product{{ID: 1, Name: "Plenty"}, {ID: 2, Name: "Few"}, {ID: 3, Name: "None"}, {ID: 4, Name: "Unknown"}}
+
+	tests := []struct {
+		name  string
+		stock *fakeStock
+		want  map[int64]*models.Stock
+	}{
+		{
+			name:  "labels from inventory",
+			stock: &fakeStock{units: map[int64]int{1: 40, 2: 3, 3: 0}},
+			want: map[int64]*models.Stock{
+				1: {Status: models.StockInStock, Available: 40},
+				2: {Status: models.StockLowStock, Available: 3, Label: "Only 3 left"},
+				3: {Status: models.StockOutOfStock, Label: "Out of stock"},
+				4: {Status: models.StockOutOfStock, Label: "Out of stock"},
+			},
+		},
+		{
+			name:  "inventory unavailable",
+			stock: &fakeStock{err: errors.New("connection refused")},
+			want:  map[int64]*models.Stock{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB := &MockDB{
+				GetProductsFunc: func(ctx context.Context, limit int, id int64) ([]models.Product, error) {
+					return append([]models.Product(nil), products...), nil
+				},
+			}
+			handler := New(mockDB, tc.stock, nil)
+
+			req, _ := http.NewRequest("GET", "/products", nil)
+			rr := httptest.NewRecorder()
+			handler.GetProducts(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("unexpected status: got %d; body: %s", rr.Code, rr.Body.String())
+			}
+			var returned []models.Product
+			if err := json.NewDecoder(rr.Body).Decode(&returned); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			for _, p := range returned {
+				want := tc.want[p.ID]
+				if (want == nil) != (p.Stock == nil) || (want != nil && *want != *p.Stock) {
+					t.Fatalf("product %d: got stock %+v want %+v", p.ID, p.Stock, want)
+				}
+			}
+		})
+	}
+}
+
 // Tests for GetProduct handler
 func TestGetProductHandler(t *testing.T) {
 	t.Run("ok found", func(t *testing.T) {
 		now := time.Now()
-		mockP := &models.Product{ID: 42, Name: "Test Product", Price: 9.99, Recipe: []models.Ingredient{{ID: 1, Name: "Soy Wax", Unit: "g", Amount: 100}}, CreatedAt: now, UpdatedAt: now}
+		mockP := &models.Product{ID: 42, Name: "Test Product", Price: 9.99, CreatedAt: now, UpdatedAt: now}
+		recipes := &fakeRecipes{recipes: map[int64][]inventory.RecipeItem{
+			42: {{IngredientID: 1, IngredientName: "Soy Wax", Unit: "g", Amount: 100}},
+		}}
 
 		mockDB := &MockDB{
 			GetProductFunc: func(ctx context.Context, id int64) (*models.Product, error) {
@@ -170,7 +323,7 @@ func TestGetProductHandler(t *testing.T) {
 			},
 		}
 
-		h := New(mockDB)
+		h := New(mockDB, nil, recipes)
 		req, _ := http.NewRequest("GET", "/products/42", nil)
 		rr := httptest.NewRecorder()
 		// chi URLParam is not set by default; set it via Request context by using a router
@@ -185,18 +338,61 @@ func TestGetProductHandler(t *testing.T) {
 		if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
 			t.Fatalf("decode: %v", err)
 		}
-		if got.ID != mockP.ID || got.Name != mockP.Name || len(got.Recipe) != len(mockP.Recipe) {
+		if got.ID != mockP.ID || got.Name != mockP.Name || len(got.Recipe) != 1 || got.Recipe[0].Name != "Soy Wax" || got.RecipeUnavailable {
 			t.Fatalf("unexpected product returned: %+v", got)
 		}
 	})
 
+	t.Run("recipe unavailable", func(t *testing.T) {
+		mockDB := &MockDB{
+			GetProductFunc: func(ctx context.Context, id int64) (*models.Product, error) {
+				return &models.Product{ID: id, Name: "Test Product"}, nil
+			},
+		}
+		h := New(mockDB, nil, &fakeRecipes{err: errors.New("connection refused")})
+		req, _ := http.NewRequest("GET", "/products/42", nil)
+		rr := httptest.NewRecorder()
+		h.GetProduct(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200 got %d: %s", rr.Code, rr.Body.String())
+		}
+		var got models.Product
+		if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if !got.RecipeUnavailable || got.Recipe == nil || len(got.Recipe) != 0 {
+			t.Fatalf("expected an empty, unavailable recipe: %+v", got)
+		}
+	})
+
+	t.Run("ok with stock", func(t *testing.T) {
+		mockDB := &MockDB{
+			GetProductFunc: func(ctx context.Context, id int64) (*models.Product, error) {
+				return &models.Product{ID: id, Name: "Test Product"}, nil
+			},
+		}
+		h := New(mockDB, &fakeStock{units: map[int64]int{42: 2}}, nil)
+		req, _ := http.NewRequest("GET", "/products/42", nil)
+		rr := httptest.NewRecorder()
+		h.GetProduct(rr, req)
+
+		var got models.Product
+		if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if got.Stock == nil || got.Stock.Label != "Only 2 left" {
+			t.Fatalf("unexpected stock: %+v", got.Stock)
+		}
+	})
+
 	t.Run("not found", func(t *testing.T) {
 		mockDB := &MockDB{
 			GetProductFunc: func(ctx context.Context, id int64) (*models.Product, error) {
 				return nil, nil
 			},
 		}
-		h := New(mockDB)
+		h := New(mockDB, nil, nil)
 		req, _ := http.NewRequest("GET", "/products/999", nil)
 		rr := httptest.NewRecorder()
 		h.GetProduct(rr, req)
@@ -211,7 +407,7 @@ func TestGetProductHandler(t *testing.T) {
 				return nil, errors.New("db fail")
 			},
 		}
-		h := New(mockDB)
+		h := New(mockDB, nil, nil)
 		req, _ := http.NewRequest("GET", "/products/1", nil)
 		rr := httptest.NewRecorder()
 		h.GetProduct(rr, req)
@@ -286,7 +482,7 @@ func TestCreateProduct(t *testing.T) {
 				},
 			}
 
-			handler := New(mockDB)
+			handler := New(mockDB, nil, nil)
 
 			req, err := http.NewRequest("POST", "/products", bytes.NewBuffer(tc.payload))
 			if err != nil {
@@ -347,7 +543,7 @@ func TestSubscriptionBoxes(t *testing.T) {
 					},
 				}
 
-				handler := New(mockDB)
+				handler := New(mockDB, nil, nil)
 				req, _ := http.NewRequest("GET", "/products/subscription-boxes"+tc.query, nil)
 				rr := httptest.NewRecorder()
 				handler.GetSubscriptionBoxes(rr, req)
@@ -422,7 +618,7 @@ func TestSubscriptionBoxes(t *testing.T) {
 					},
 				}
 
-				handler := New(mockDB)
+				handler := New(mockDB, nil, nil)
 				req, _ := http.NewRequest("POST", "/products/subscription-boxes", bytes.NewBuffer(tc.payload))
 				req.Header.Set("Content-Type", "application/json")
 				rr := httptest.NewRecorder()
@@ -446,14 +642,155 @@ func TestSubscriptionBoxes(t *testing.T) {
 	})
 }
 
+// Table-driven tests for fetching a single subscription box
+func TestGetSubscriptionBox(t *testing.T) {
+	tests := []struct {
+		name       string
+		id         string
+		mockBox    *models.SubscriptionBox
+		mockErr    error
+		wantStatus int
+	}{
+		{name: "found", id: "1", mockBox: &models.SubscriptionBox{ID: 1, Name: "Monthly Surprise", Price: 29.99}, wantStatus: http.StatusOK},
+		{name: "not found", id: "9", mockBox: nil, wantStatus: http.StatusNotFound},
+		{name: "invalid id", id: "abc", wantStatus: http.StatusBadRequest},
+		{name: "db error", id: "1", mockErr: errors.New("db fail"), wantStatus: http.StatusInternalServerError},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB := &MockDB{
+				GetSubscriptionBoxFunc: func(ctx context.Context, id int64) (*models.SubscriptionBox, error) {
+					if tc.mockErr != nil {
+						return nil, tc.mockErr
+					}
+					return tc.mockBox, nil
+				},
+			}
+
+			handler := New(mockDB, nil, nil)
+			req, _ := http.NewRequest("GET", "/products/subscription-boxes/"+tc.id, nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tc.id)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			rr := httptest.NewRecorder()
+			handler.GetSubscriptionBox(rr, req)
+
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("[%s] unexpected status: got %d want %d; body: %s", tc.name, rr.Code, tc.wantStatus, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestUpdateProduct(t *testing.T) {
+	tests := []struct {
+		name       string
+		id         string
+		body       string
+		found      bool
+		mockErr    error
+		wantStatus int
+		wantName   string
+	}{
+		{name: "updated", id: "1", body: `{"name":"Vanilla Bean","price":11.5}`, found: true, wantStatus: http.StatusOK, wantName: "Vanilla Bean"},
+		{name: "not found", id: "9", body: `{"name":"Ghost"}`, wantStatus: http.StatusNotFound},
+		{name: "invalid id", id: "abc", body: `{}`, wantStatus: http.StatusBadRequest},
+		{name: "invalid body", id: "1", body: `{`, wantStatus: http.StatusBadRequest},
+		{name: "db error", id: "1", body: `{"name":"Vanilla Bean"}`, mockErr: errors.New("db fail"), wantStatus: http.StatusInternalServerError},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var stored models.Product
+			mockDB := &MockDB{
+				UpdateProductTxFunc: func(ctx context.Context, product *models.Product) (bool, error) {
+					if tc.mockErr != nil {
+						return false, tc.mockErr
+					}
+					stored = *product
+					return tc.found, nil
+				},
+				GetProductFunc: func(ctx context.Context, id int64) (*models.Product, error) {
+					p := stored
+					return &p, nil
+				},
+			}
+
+			handler := New(mockDB, nil, &fakeRecipes{})
+			req, _ := http.NewRequest("PUT", "/products/"+tc.id, bytes.NewBufferString(tc.body))
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tc.id)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			rr := httptest.NewRecorder()
+			handler.UpdateProduct(rr, req)
+
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("[%s] unexpected status: got %d want %d; body: %s", tc.name, rr.Code, tc.wantStatus, rr.Body.String())
+			}
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+			var got models.Product
+			if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+				t.Fatalf("[%s] failed to unmarshal: %v", tc.name, err)
+			}
+			if got.ID != 1 || got.Name != tc.wantName {
+				t.Fatalf("[%s] product: got id=%d name=%q", tc.name, got.ID, got.Name)
+			}
+		})
+	}
+}
+
+func TestGetProductIDs(t *testing.T) {
+	tests := []struct {
+		name       string
+		mockIDs    []int64
+		mockErr    error
+		wantStatus int
+		wantIDs    []int64
+	}{
+		{name: "ids", mockIDs: []int64{1, 2, 5}, wantStatus: http.StatusOK, wantIDs: []int64{1, 2, 5}},
+		{name: "empty", mockIDs: []int64{}, wantStatus: http.StatusOK, wantIDs: []int64{}},
+		{name: "db error", mockErr: errors.New("db fail"), wantStatus: http.StatusInternalServerError},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB := &MockDB{
+				ProductIDsFunc: func(ctx context.Context) ([]int64, error) {
+					return tc.mockIDs, tc.mockErr
+				},
+			}
+
+			handler := New(mockDB, nil, nil)
+			req, _ := http.NewRequest("GET", "/internal/product-ids", nil)
+			rr := httptest.NewRecorder()
+			handler.GetProductIDs(rr, req)
+
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("[%s] unexpected status: got %d want %d; body: %s", tc.name, rr.Code, tc.wantStatus, rr.Body.String())
+			}
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+			var got []int64
+			if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+				t.Fatalf("[%s] failed to unmarshal: %v", tc.name, err)
+			}
+			if !reflect.DeepEqual(got, tc.wantIDs) {
+				t.Fatalf("[%s] ids: got %v want %v", tc.name, got, tc.wantIDs)
+			}
+		})
+	}
+}
+
 // Table-driven tests for recipe items (creation and GET behavior)
 func TestRecipeItems(t *testing.T) {
 	now := time.Now()
-	// Define some sample recipe items used in tests
-	sampleRecipe := []models.Ingredient{
-		{ID: 1, Name: "Soy Wax", Type: models.IngredientType("Base"), Unit: "g", Amount: 100},
-		{ID: 2, Name: "Lavender", Type: models.IngredientType("Essential Oil"), Unit: "mL", Amount: 3},
-	}
 
 	tests := []struct {
 		name           string
@@ -462,9 +799,11 @@ func TestRecipeItems(t *testing.T) {
 		mockCreateErr  error
 		mockGetProduct *models.Product
 		mockGetErr     error
+		replaceErr     error
 		wantStatus     int
 		wantRecipeLen  int
 		wantFirstName  string
+		wantDeleted    bool
 	}{
 		{
 			name: "create with recipe success",
@@ -472,8 +811,8 @@ func TestRecipeItems(t *testing.T) {
 				Name:  "Recipe Product",
 				Price: 4.99,
 				Recipe: []models.Ingredient{
-					{Name: "Soy Wax", Type: models.IngredientType("Base"), Unit: "g", Amount: 100},
-					{Name: "Lavender", Type: models.IngredientType("Essential Oil"), Unit: "mL", Amount: 3},
+					{ID: 1, Unit: "g", Amount: 100},
+					{ID: 2, Unit: "mL", Amount: 3},
 				},
 			},
 			mockCreateID:  2001,
@@ -482,7 +821,6 @@ func TestRecipeItems(t *testing.T) {
 				ID:        2001,
 				Name:      "Recipe Product",
 				Price:     4.99,
-				Recipe:    sampleRecipe,
 				CreatedAt: now,
 				UpdatedAt: now,
 			},
@@ -501,10 +839,9 @@ func TestRecipeItems(t *testing.T) {
 			mockCreateID:  2002,
 			mockCreateErr: nil,
 			mockGetProduct: &models.Product{
-				ID:     2002,
-				Name:   "NoRecipe Product",
-				Price:  2.50,
-				Recipe: []models.Ingredient{},
+				ID:    2002,
+				Name:  "NoRecipe Product",
+				Price: 2.50,
 			},
 			mockGetErr:    nil,
 			wantStatus:    http.StatusCreated,
@@ -531,7 +868,7 @@ func TestRecipeItems(t *testing.T) {
 				Name:  "CreatedButGetFails",
 				Price: 3.33,
 				Recipe: []models.Ingredient{
-					{Name: "Butter", Type: models.IngredientType("Dairy"), Unit: "g", Amount: 50},
+					{ID: 1, Unit: "g", Amount: 50},
 				},
 			},
 			mockCreateID:   2003,
@@ -542,11 +879,36 @@ func TestRecipeItems(t *testing.T) {
 			wantRecipeLen:  0,
 			wantFirstName:  "",
 		},
+		{
+			name: "inventory rejects recipe",
+			createPayload: models.Product{
+				Name:   "BadRecipe",
+				Price:  3.33,
+				Recipe: []models.Ingredient{{ID: 999, Unit: "g", Amount: 50}},
+			},
+			mockCreateID: 2004,
+			replaceErr:   fmt.Errorf("%w: unknown ingredient: 999", inventory.ErrInvalidRecipe),
+			wantStatus:   http.StatusUnprocessableEntity,
+			wantDeleted:  true,
+		},
+		{
+			name: "inventory unreachable",
+			createPayload: models.Product{
+				Name:   "NoInventory",
+				Price:  3.33,
+				Recipe: []models.Ingredient{{ID: 1, Unit: "g", Amount: 50}},
+			},
+			mockCreateID: 2005,
+			replaceErr:   errors.New("connection refused"),
+			wantStatus:   http.StatusBadGateway,
+			wantDeleted:  true,
+		},
 	}
 
 	for _, tc := range tests {
 		tc := tc
 		t.Run(tc.name, func(t *testing.T) {
+			var deleted int64
 			// Prepare mock DB
 			mockDB := &MockDB{
 				CreateProductTxFunc: func(ctx context.Context, product *models.Product) (int64, error) {
@@ -567,9 +929,13 @@ func TestRecipeItems(t *testing.T) {
 					// simulate not found
 					return nil, nil
 				},
+				DeleteProductFunc: func(ctx context.Context, id int64) error {
+					deleted = id
+					return nil
+				},
 			}
 
-			handler := New(mockDB)
+			handler := New(mockDB, nil, &fakeRecipes{replaceErr: tc.replaceErr})
 
 			// Encode payload
 			payload, err := json.Marshal(tc.createPayload)
@@ -586,6 +952,9 @@ func TestRecipeItems(t *testing.T) {
 			if rr.Code != tc.wantStatus {
 				t.Fatalf("unexpected status: got %d want %d; body: %s", rr.Code, tc.wantStatus, rr.Body.String())
 			}
+			if tc.wantDeleted != (deleted == tc.mockCreateID && deleted != 0) {
+				t.Fatalf("deleted product %d, want deleted=%v", deleted, tc.wantDeleted)
+			}
 
 			if tc.wantStatus == http.StatusCreated {
 				var out models.Product
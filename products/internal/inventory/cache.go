@@ -0,0 +1,95 @@
+package inventory
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RecipeFetcher loads and saves recipes; *Client implements it.
+type RecipeFetcher interface {
+	Recipes(ctx context.Context, productIDs []int64) (map[int64][]RecipeItem, error)
+	ReplaceRecipe(ctx context.Context, productID int64, items []RecipeItem) ([]RecipeItem, error)
+}
+
+type recipeEntry struct {
+	items     []RecipeItem
+	fetchedAt time.Time
+}
+
+// RecipeCache keeps recipes fetched from inventory for a while so listing
+// products does not cost a round trip every time. When inventory cannot be
+// reached, recipes that have gone stale are served rather than none.
+type RecipeCache struct {
+	src RecipeFetcher
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[int64]recipeEntry
+}
+
+// NewRecipeCache caches recipes from src for ttl.
+func NewRecipeCache(src RecipeFetcher, ttl time.Duration) *RecipeCache {
+	return &RecipeCache{src: src, ttl: ttl, now: time.Now, entries: map[int64]recipeEntry{}}
+}
+
+// Recipes returns the recipes of productIDs keyed by product id, fetching
+// those not cached or older than the cache's ttl. If the fetch fails, the
+// error is returned along with every recipe the cache still holds, fresh or
+// stale; products missing from the map have no recipe available.
+func (c *RecipeCache) Recipes(ctx context.Context, productIDs []int64) (map[int64][]RecipeItem, error) {
+	out := make(map[int64][]RecipeItem, len(productIDs))
+	var missing []int64
+
+	c.mu.Lock()
+	now := c.now()
+	for _, id := range productIDs {
+		e, ok := c.entries[id]
+		if ok && now.Sub(e.fetchedAt) < c.ttl {
+			out[id] = e.items
+			continue
+		}
+		missing = append(missing, id)
+	}
+	c.mu.Unlock()
+
+	if len(missing) == 0 {
+		return out, nil
+	}
+
+	fetched, err := c.src.Recipes(ctx, missing)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		for _, id := range missing {
+			if e, ok := c.entries[id]; ok {
+				out[id] = e.items
+			}
+		}
+		return out, err
+	}
+	for _, id := range missing {
+		items, ok := fetched[id]
+		if !ok {
+			items = []RecipeItem{}
+		}
+		c.entries[id] = recipeEntry{items: items, fetchedAt: now}
+		out[id] = items
+	}
+	return out, nil
+}
+
+// ReplaceRecipe saves a product's recipe through the underlying fetcher and
+// caches the stored result.
+func (c *RecipeCache) ReplaceRecipe(ctx context.Context, productID int64, items []RecipeItem) ([]RecipeItem, error) {
+	stored, err := c.src.ReplaceRecipe(ctx, productID, items)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.entries[productID] = recipeEntry{items: stored, fetchedAt: c.now()}
+	c.mu.Unlock()
+	return stored, nil
+}
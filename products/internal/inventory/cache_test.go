@@ -0,0 +1,90 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type fakeFetcher struct {
+	recipes map[int64][]RecipeItem
+	err     error
+	calls   [][]int64
+}
+
+func (f *fakeFetcher) Recipes(ctx context.Context, productIDs []int64) (map[int64][]RecipeItem, error) {
+	f.calls = append(f.calls, productIDs)
+	if f.err != nil {
+		return nil, f.err
+	}
+	out := map[int64][]RecipeItem{}
+	for _, id := range productIDs {
+		if r, ok := f.recipes[id]; ok {
+			out[id] = r
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeFetcher) ReplaceRecipe(ctx context.Context, productID int64, items []RecipeItem) ([]RecipeItem, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return items, nil
+}
+
+func TestRecipeCache(t *testing.T) {
+	wax := []RecipeItem{{IngredientID: 1, IngredientName: "Soy Wax", Unit: "g", Amount: 100}}
+	src := &fakeFetcher{recipes: map[int64][]RecipeItem{1: wax}}
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := NewRecipeCache(src, time.Minute)
+	c.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	got, err := c.Recipes(ctx, []int64{1, 2})
+	if err != nil {
+		t.Fatalf("Recipes: %v", err)
+	}
+	if len(got[1]) != 1 || got[2] == nil || len(got[2]) != 0 {
+		t.Fatalf("unexpected recipes: %+v", got)
+	}
+
+	// Fresh entries are served from the cache.
+	now = now.Add(30 * time.Second)
+	if _, err := c.Recipes(ctx, []int64{1, 2}); err != nil {
+		t.Fatalf("Recipes: %v", err)
+	}
+	if len(src.calls) != 1 {
+		t.Fatalf("expected 1 fetch, got %d", len(src.calls))
+	}
+
+	// Expired entries are refetched; if inventory is down they are served stale.
+	now = now.Add(time.Minute)
+	src.err = errors.New("connection refused")
+	got, err = c.Recipes(ctx, []int64{1, 3})
+	if err == nil {
+		t.Fatal("expected fetch error")
+	}
+	if len(src.calls) != 2 || len(src.calls[1]) != 2 {
+		t.Fatalf("expected a refetch of both ids, got %v", src.calls)
+	}
+	if len(got[1]) != 1 {
+		t.Fatalf("expected stale recipe for product 1, got %+v", got[1])
+	}
+	if _, ok := got[3]; ok {
+		t.Fatal("product 3 was never fetched and should be missing")
+	}
+
+	// A saved recipe replaces the cached one.
+	src.err = nil
+	orange := []RecipeItem{{IngredientID: 6, IngredientName: "Sweet Orange", Unit: "mL", Amount: 3}}
+	if _, err := c.ReplaceRecipe(ctx, 3, orange); err != nil {
+		t.Fatalf("ReplaceRecipe: %v", err)
+	}
+	calls := len(src.calls)
+	got, err = c.Recipes(ctx, []int64{3})
+	if err != nil || len(src.calls) != calls || got[3][0].IngredientID != 6 {
+		t.Fatalf("expected cached saved recipe, got %+v (err %v, fetches %d)", got, err, len(src.calls)-calls)
+	}
+}
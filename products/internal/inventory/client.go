@@ -0,0 +1,160 @@
+// Package inventory is a small HTTP client for the inventory service, which
+// owns product recipes and reports how many units of each product can still
+// be sold.
+package inventory
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidRecipe is returned when inventory rejects a recipe, e.g. because
+// it names an unknown ingredient or unit.
+var ErrInvalidRecipe = errors.New("invalid recipe")
+
+// RecipeItem is one ingredient of a product's recipe.
+type RecipeItem struct {
+	IngredientID   int64   `json:"ingredient_id"`
+	IngredientName string  `json:"ingredient_name,omitempty"`
+	IngredientType string  `json:"ingredient_type,omitempty"`
+	Unit           string  `json:"unit"`
+	Amount         float64 `json:"amount"`
+	Notes          string  `json:"notes,omitempty"`
+}
+
+// Capacity is the subset of the inventory service's capacity representation
+// products needs.
+type Capacity struct {
+	ProductID int64 `json:"product_id"`
+	// SellableUnits counts finished goods on hand plus the whole units
+	// current ingredient stock can make.
+	SellableUnits int `json:"sellable_units"`
+}
+
+// Client talks to the inventory service over HTTP.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// New creates a client for the inventory service rooted at baseURL
+// (e.g. "http://inventory:8083").
+func New(baseURL string) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 3 * time.Second},
+	}
+}
+
+// Capacities returns the capacity of every product inventory knows about,
+// keyed by product id. Products missing from the map cannot be made or sold.
+func (c *Client) Capacities(ctx context.Context) (map[int64]Capacity, error) {
+	var list []Capacity
+	if err := c.get(ctx, "/capacity", &list); err != nil {
+		return nil, fmt.Errorf("Capacities: %w", err)
+	}
+	out := make(map[int64]Capacity, len(list))
+	for _, cp := range list {
+		out[cp.ProductID] = cp
+	}
+	return out, nil
+}
+
+// Capacity returns the capacity of a single product.
+func (c *Client) Capacity(ctx context.Context, productID int64) (*Capacity, error) {
+	var cp Capacity
+	if err := c.get(ctx, fmt.Sprintf("/capacity/%d", productID), &cp); err != nil {
+		return nil, fmt.Errorf("Capacity: %w", err)
+	}
+	return &cp, nil
+}
+
+// Recipes returns the recipes of productIDs keyed by product id. Every
+// requested product has an entry, empty if it has no recipe.
+func (c *Client) Recipes(ctx context.Context, productIDs []int64) (map[int64][]RecipeItem, error) {
+	q := url.Values{}
+	for _, id := range productIDs {
+		q.Add("product_id", strconv.FormatInt(id, 10))
+	}
+	var out map[int64][]RecipeItem
+	if err := c.get(ctx, "/recipes?"+q.Encode(), &out); err != nil {
+		return nil, fmt.Errorf("Recipes: %w", err)
+	}
+	return out, nil
+}
+
+// ReplaceRecipe swaps a product's whole recipe for items and returns the
+// recipe as stored. A recipe inventory rejects is reported as
+// ErrInvalidRecipe.
+func (c *Client) ReplaceRecipe(ctx context.Context, productID int64, items []RecipeItem) ([]RecipeItem, error) {
+	body, err := json.Marshal(struct {
+		Items []RecipeItem `json:"items"`
+	}{items})
+	if err != nil {
+		return nil, fmt.Errorf("ReplaceRecipe encode: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, fmt.Sprintf("%s/products/%d/recipe", c.baseURL, productID), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("ReplaceRecipe build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ReplaceRecipe request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidRecipe, errorMessage(resp))
+	default:
+		return nil, fmt.Errorf("ReplaceRecipe: unexpected status %d: %s", resp.StatusCode, errorMessage(resp))
+	}
+
+	var stored []RecipeItem
+	if err := json.NewDecoder(resp.Body).Decode(&stored); err != nil {
+		return nil, fmt.Errorf("ReplaceRecipe decode: %w", err)
+	}
+	return stored, nil
+}
+
+// get decodes the JSON response for path into out.
+func (c *Client) get(ctx context.Context, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode: %w", err)
+	}
+	return nil
+}
+
+func errorMessage(resp *http.Response) string {
+	var body struct {
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return resp.Status
+	}
+	return body.Message
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Event types published through the outbox.
+const (
+	EventProductCreated = "product.created"
+	EventProductUpdated = "product.updated"
+	EventProductDeleted = "product.deleted"
+)
+
+// ProductEvent is the payload of product events: the catalog fields of the
+// product as committed, or as they were before a deletion. Recipes and stock
+// belong to the inventory service and are not included.
+type ProductEvent struct {
+	ID           int64     `json:"id"`
+	Name         string    `json:"name"`
+	Category     string    `json:"category"`
+	Scent        string    `json:"scent"`
+	Price        float64   `json:"price"`
+	Subscription bool      `json:"subscription"`
+	Image        string    `json:"image"`
+	Description  string    `json:"description"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
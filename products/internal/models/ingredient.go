@@ -12,13 +12,15 @@ const (
 	IngredientTypeOther IngredientType = "other"
 )
 
-// Ingredient represents a raw material used to make products (wax, scent base, additive, etc).
+// Ingredient is one line of a product's recipe: a raw material (wax, scent
+// base, additive, etc) and how much of it goes into one unit of the product.
+// ID is the inventory service's ingredient id.
 type Ingredient struct {
 	ID        int64          `json:"id"`
 	Name      string         `json:"name"`
 	Type      IngredientType `json:"type"`
 	Unit      string         `json:"unit"`   // e.g. "g", "kg", "ml", "L"
-	Amount    float64        `json:"amount"` // current on-hand quantity in Unit
+	Amount    float64        `json:"amount"` // amount per unit of product, in Unit
 	Notes     string         `json:"notes,omitempty"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
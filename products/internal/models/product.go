@@ -4,15 +4,17 @@ import "time"
 
 // Product represents a product in the system.
 type Product struct {
-	ID           int64        `json:"id"`
-	Name         string       `json:"name"`
-	Category     string       `json:"category"`
-	Scent        string       `json:"scent"`
-	Price        float64      `json:"price"`
-	Subscription bool         `json:"subscription"`
-	Image        string       `json:"image"`
-	Recipe       []Ingredient `json:"recipe"`
-	Description  string       `json:"description"`
-	CreatedAt    time.Time    `json:"created_at"`
-	UpdatedAt    time.Time    `json:"updated_at"`
+	ID                int64        `json:"id"`
+	Name              string       `json:"name"`
+	Category          string       `json:"category"`
+	Scent             string       `json:"scent"`
+	Price             float64      `json:"price"`
+	Subscription      bool         `json:"subscription"`
+	Image             string       `json:"image"`
+	Recipe            []Ingredient `json:"recipe"`                       // owned by the inventory service
+	RecipeUnavailable bool         `json:"recipe_unavailable,omitempty"` // recipe could not be loaded; Recipe is empty
+	Description       string       `json:"description"`
+	Stock             *Stock       `json:"stock,omitempty"` // from inventory; omitted when it cannot be reached
+	CreatedAt         time.Time    `json:"created_at"`
+	UpdatedAt         time.Time    `json:"updated_at"`
 }
@@ -0,0 +1,37 @@
+package models
+
+import "fmt"
+
+// LowStockThreshold is the number of sellable units at or below which a
+// product is shown as running low.
+const LowStockThreshold = 5
+
+// StockStatus summarises how much of a product is left to sell.
+type StockStatus string
+
+const (
+	StockInStock    StockStatus = "in_stock"
+	StockLowStock   StockStatus = "low_stock"
+	StockOutOfStock StockStatus = "out_of_stock"
+)
+
+// Stock describes a product's availability for the storefront.
+type Stock struct {
+	Status    StockStatus `json:"status"`
+	Available int         `json:"available"`
+	// Label is a short customer-facing note such as "Only 3 left"; it is
+	// empty when the product is comfortably in stock.
+	Label string `json:"label,omitempty"`
+}
+
+// NewStock describes a product with available units left to sell.
+func NewStock(available int) *Stock {
+	switch {
+	case available <= 0:
+		return &Stock{Status: StockOutOfStock, Available: 0, Label: "Out of stock"}
+	case available <= LowStockThreshold:
+		return &Stock{Status: StockLowStock, Available: available, Label: fmt.Sprintf("Only %d left", available)}
+	default:
+		return &Stock{Status: StockInStock, Available: available}
+	}
+}
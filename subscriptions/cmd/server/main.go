@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"com.MixieMelts.subscriptions/internal/database"
+	"com.MixieMelts.subscriptions/internal/handlers"
+	"com.MixieMelts.subscriptions/internal/orders"
+	"com.MixieMelts.subscriptions/internal/products"
+	"com.MixieMelts.subscriptions/internal/scheduler"
+	"com.MixieMelts.users/jwtverify"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/joho/godotenv"
+)
+
+// getenv returns the environment variable key, or fallback when it is unset.
+func getenv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func main() {
+	// Load .env file if present
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found or failed to load; falling back to environment variables")
+	}
+
+	// Create database connection
+	dbURL := os.Getenv("DATABASE_URL")
+	if dbURL == "" {
+		log.Fatal("DATABASE_URL must be set")
+	}
+
+	db, err := database.New(dbURL)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	productsClient := products.New(getenv("PRODUCTS_URL", "http://products:8082"))
+	ordersClient := orders.New(getenv("ORDERS_URL", "http://orders:8085"), os.Getenv("INTERNAL_API_TOKEN"))
+
+	// Renewal scheduler
+	interval, err := time.ParseDuration(getenv("SCHEDULER_INTERVAL", "1m"))
+	if err != nil {
+		log.Fatalf("Invalid SCHEDULER_INTERVAL: %v", err)
+	}
+	go scheduler.New(db, ordersClient).Run(context.Background(), interval)
+
+	h := handlers.New(db, productsClient)
+
+	// Tokens are verified against the users service's published keys
+	verifier, err := jwtverify.Configure(os.Getenv("JWKS_URL"), os.Getenv("JWT_SECRET_KEY"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Router setup
+	r := chi.NewRouter()
+	r.Use(middleware.Logger)
+	r.Use(middleware.Recoverer)
+	r.Use(middleware.Timeout(60 * time.Second))
+
+	// Subscription routes - all require a signed-in customer
+	r.Group(func(r chi.Router) {
+		r.Use(verifier.Middleware)
+		r.Post("/subscriptions", h.CreateSubscription)
+		r.Get("/subscriptions", h.GetSubscriptions)
+		r.Get("/subscriptions/{id}", h.GetSubscription)
+		r.Get("/subscriptions/{id}/renewals", h.GetRenewals)
+		r.Post("/subscriptions/{id}/skip", h.SkipRenewal)
+		r.Post("/subscriptions/{id}/pause", h.PauseSubscription)
+		r.Post("/subscriptions/{id}/resume", h.ResumeSubscription)
+		r.Post("/subscriptions/{id}/cancel", h.CancelSubscription)
+	})
+
+	// Health check
+	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	})
+
+	// Start server
+	port := getenv("PORT", "8086")
+
+	log.Printf("Starting subscriptions service on port %s", port)
+	if err := http.ListenAndServe(":"+port, r); err != nil {
+		log.Fatalf("Failed to start server: %v", err)
+	}
+}
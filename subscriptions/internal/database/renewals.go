@@ -0,0 +1,180 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"com.MixieMelts.subscriptions/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+func (db *DB) createRenewalsTable(ctx context.Context) error {
+	query := `CREATE TABLE IF NOT EXISTS subscription_renewals (
+		id SERIAL PRIMARY KEY,
+		subscription_id BIGINT NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+		cycle_date DATE NOT NULL,
+		status VARCHAR(32) NOT NULL,
+		order_id BIGINT,
+		error TEXT NOT NULL DEFAULT '',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (subscription_id, cycle_date)
+	);`
+	_, err := db.Exec(ctx, query)
+	return err
+}
+
+const renewalColumns = `id, subscription_id, cycle_date, status, order_id, error, attempts, created_at, updated_at`
+
+func scanRenewal(row pgx.Row, r *models.Renewal) error {
+	return row.Scan(&r.ID, &r.SubscriptionID, &r.CycleDate, &r.Status, &r.OrderID, &r.Error, &r.Attempts, &r.CreatedAt, &r.UpdatedAt)
+}
+
+// ListRenewals returns a subscription's renewal history, newest cycle first.
+func (db *DB) ListRenewals(ctx context.Context, subscriptionID int64) ([]models.Renewal, error) {
+	rows, err := db.Query(ctx, `SELECT `+renewalColumns+` FROM subscription_renewals WHERE subscription_id = $1 ORDER BY cycle_date DESC`, subscriptionID)
+	if err != nil {
+		return nil, fmt.Errorf("ListRenewals: %w", err)
+	}
+	defer rows.Close()
+
+	renewals := []models.Renewal{}
+	for rows.Next() {
+		var r models.Renewal
+		if err := scanRenewal(rows, &r); err != nil {
+			return nil, fmt.Errorf("ListRenewals scan: %w", err)
+		}
+		renewals = append(renewals, r)
+	}
+	return renewals, rows.Err()
+}
+
+// DueSubscriptions returns up to limit active subscriptions whose next
+// renewal falls on or before asOf, oldest first.
+func (db *DB) DueSubscriptions(ctx context.Context, asOf time.Time, limit int) ([]models.Subscription, error) {
+	rows, err := db.Query(ctx, `SELECT `+subscriptionColumns+` FROM subscriptions
+		WHERE status = $1 AND next_renewal_at <= $2 ORDER BY next_renewal_at, id LIMIT $3`,
+		models.SubscriptionStatusActive, asOf, limit)
+	if err != nil {
+		return nil, fmt.Errorf("DueSubscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	subs := []models.Subscription{}
+	for rows.Next() {
+		var s models.Subscription
+		if err := scanSubscription(rows, &s); err != nil {
+			return nil, fmt.Errorf("DueSubscriptions scan: %w", err)
+		}
+		subs = append(subs, s)
+	}
+	return subs, rows.Err()
+}
+
+// BeginRenewal claims a subscription's cycle for ordering. It records a
+// pending renewal, or reclaims one that previously failed or has been pending
+// since before staleBefore (a scheduler that crashed mid-renewal). It returns
+// nil when the cycle is not claimable: the subscription is no longer active,
+// has moved past cycle, or another run owns or finished the cycle.
+func (db *DB) BeginRenewal(ctx context.Context, subscriptionID int64, cycle, staleBefore time.Time) (*models.Renewal, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("BeginRenewal begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	// Lock the subscription so a concurrent pause, skip or cancel either
+	// happens before the claim (and is seen here) or waits for it.
+	var status models.SubscriptionStatus
+	var next time.Time
+	err = tx.QueryRow(ctx, `SELECT status, next_renewal_at FROM subscriptions WHERE id = $1 FOR UPDATE`, subscriptionID).Scan(&status, &next)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("BeginRenewal select subscription: %w", err)
+	}
+	if status != models.SubscriptionStatusActive || !next.Equal(cycle) {
+		return nil, nil
+	}
+
+	var r models.Renewal
+	err = scanRenewal(tx.QueryRow(ctx, `INSERT INTO subscription_renewals (subscription_id, cycle_date, status, attempts)
+		VALUES ($1,$2,$3,1)
+		ON CONFLICT (subscription_id, cycle_date) DO UPDATE
+			SET status = EXCLUDED.status, attempts = subscription_renewals.attempts + 1, updated_at = NOW()
+			WHERE subscription_renewals.status = $4
+				OR (subscription_renewals.status = $3 AND subscription_renewals.updated_at < $5)
+		RETURNING `+renewalColumns,
+		subscriptionID, cycle, models.RenewalStatusPending, models.RenewalStatusFailed, staleBefore), &r)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("BeginRenewal upsert: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("BeginRenewal commit: %w", err)
+	}
+	return &r, nil
+}
+
+// CompleteRenewal records the order placed for a renewal and advances its
+// subscription to the next cycle.
+func (db *DB) CompleteRenewal(ctx context.Context, renewalID, orderID int64) error {
+	return db.finishRenewal(ctx, "CompleteRenewal", renewalID, true,
+		`UPDATE subscription_renewals SET status = $2, order_id = $3, error = '', updated_at = NOW()
+			WHERE id = $1 RETURNING subscription_id, cycle_date`,
+		models.RenewalStatusOrdered, orderID)
+}
+
+// FailRenewal records why a renewal could not be ordered. When giveUp is set
+// the cycle is abandoned and the subscription advances to the next one;
+// otherwise the scheduler retries the cycle on a later run.
+func (db *DB) FailRenewal(ctx context.Context, renewalID int64, reason string, giveUp bool) error {
+	return db.finishRenewal(ctx, "FailRenewal", renewalID, giveUp,
+		`UPDATE subscription_renewals SET status = $2, error = $3, updated_at = NOW()
+			WHERE id = $1 RETURNING subscription_id, cycle_date`,
+		models.RenewalStatusFailed, reason)
+}
+
+// finishRenewal runs update against a renewal and, if advance is set, moves
+// its subscription past the renewal's cycle in the same transaction.
+func (db *DB) finishRenewal(ctx context.Context, op string, renewalID int64, advance bool, update string, args ...any) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s begin tx: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var subscriptionID int64
+	var cycle time.Time
+	err = tx.QueryRow(ctx, update, append([]any{renewalID}, args...)...).Scan(&subscriptionID, &cycle)
+	if err != nil {
+		return fmt.Errorf("%s update renewal %d: %w", op, renewalID, err)
+	}
+
+	if advance {
+		var s models.Subscription
+		err = scanSubscription(tx.QueryRow(ctx, `SELECT `+subscriptionColumns+` FROM subscriptions WHERE id = $1 FOR UPDATE`, subscriptionID), &s)
+		if err != nil {
+			return fmt.Errorf("%s select subscription: %w", op, err)
+		}
+		// The customer may have skipped or resumed past this cycle meanwhile.
+		if s.NextRenewalAt.Equal(cycle) {
+			s.Advance()
+			if _, err := tx.Exec(ctx, `UPDATE subscriptions SET next_renewal_at = $1, updated_at = NOW() WHERE id = $2`, s.NextRenewalAt, s.ID); err != nil {
+				return fmt.Errorf("%s advance subscription: %w", op, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s commit: %w", op, err)
+	}
+	return nil
+}
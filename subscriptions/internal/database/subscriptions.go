@@ -0,0 +1,159 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"com.MixieMelts.subscriptions/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+func (db *DB) createSubscriptionsTable(ctx context.Context) error {
+	query := `CREATE TABLE IF NOT EXISTS subscriptions (
+		id SERIAL PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		box_id BIGINT NOT NULL,
+		status VARCHAR(32) NOT NULL,
+		cadence VARCHAR(32) NOT NULL,
+		anchor_day INTEGER NOT NULL CHECK (anchor_day BETWEEN 1 AND 31),
+		next_renewal_at DATE NOT NULL,
+		paused_at TIMESTAMP WITH TIME ZONE,
+		cancelled_at TIMESTAMP WITH TIME ZONE,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS subscriptions_due_idx ON subscriptions (next_renewal_at) WHERE status = 'active';`
+	_, err := db.Exec(ctx, query)
+	return err
+}
+
+const subscriptionColumns = `id, user_id, box_id, status, cadence, anchor_day, next_renewal_at, paused_at, cancelled_at, created_at, updated_at`
+
+func scanSubscription(row pgx.Row, s *models.Subscription) error {
+	return row.Scan(&s.ID, &s.UserID, &s.BoxID, &s.Status, &s.Cadence, &s.AnchorDay, &s.NextRenewalAt,
+		&s.PausedAt, &s.CancelledAt, &s.CreatedAt, &s.UpdatedAt)
+}
+
+// CreateSubscription inserts a subscription and fills in its id and
+// timestamps.
+func (db *DB) CreateSubscription(ctx context.Context, s *models.Subscription) error {
+	err := db.QueryRow(ctx, `INSERT INTO subscriptions (user_id, box_id, status, cadence, anchor_day, next_renewal_at)
+		VALUES ($1,$2,$3,$4,$5,$6) RETURNING id, created_at, updated_at`,
+		s.UserID, s.BoxID, s.Status, s.Cadence, s.AnchorDay, s.NextRenewalAt).Scan(&s.ID, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("CreateSubscription: %w", err)
+	}
+	return nil
+}
+
+// GetSubscription returns a subscription, or nil if it does not exist.
+func (db *DB) GetSubscription(ctx context.Context, id int64) (*models.Subscription, error) {
+	var s models.Subscription
+	err := scanSubscription(db.QueryRow(ctx, `SELECT `+subscriptionColumns+` FROM subscriptions WHERE id = $1`, id), &s)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("GetSubscription: %w", err)
+	}
+	return &s, nil
+}
+
+// ListSubscriptions returns a user's subscriptions, newest first.
+func (db *DB) ListSubscriptions(ctx context.Context, userID string) ([]models.Subscription, error) {
+	rows, err := db.Query(ctx, `SELECT `+subscriptionColumns+` FROM subscriptions WHERE user_id = $1 ORDER BY created_at DESC, id DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("ListSubscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	subs := []models.Subscription{}
+	for rows.Next() {
+		var s models.Subscription
+		if err := scanSubscription(rows, &s); err != nil {
+			return nil, fmt.Errorf("ListSubscriptions scan: %w", err)
+		}
+		subs = append(subs, s)
+	}
+	return subs, rows.Err()
+}
+
+// PauseSubscription stops a subscription from renewing.
+func (db *DB) PauseSubscription(ctx context.Context, id int64) (*models.Subscription, error) {
+	return db.transition(ctx, "PauseSubscription", id, func(ctx context.Context, tx pgx.Tx, s *models.Subscription) error {
+		return s.Pause(time.Now())
+	})
+}
+
+// ResumeSubscription reactivates a paused subscription.
+func (db *DB) ResumeSubscription(ctx context.Context, id int64) (*models.Subscription, error) {
+	return db.transition(ctx, "ResumeSubscription", id, func(ctx context.Context, tx pgx.Tx, s *models.Subscription) error {
+		return s.Resume(time.Now())
+	})
+}
+
+// CancelSubscription permanently stops a subscription.
+func (db *DB) CancelSubscription(ctx context.Context, id int64) (*models.Subscription, error) {
+	return db.transition(ctx, "CancelSubscription", id, func(ctx context.Context, tx pgx.Tx, s *models.Subscription) error {
+		return s.Cancel(time.Now())
+	})
+}
+
+// SkipRenewal skips a subscription's upcoming cycle, recording it as a
+// skipped renewal. A cycle the scheduler has already started cannot be
+// skipped.
+func (db *DB) SkipRenewal(ctx context.Context, id int64) (*models.Subscription, error) {
+	return db.transition(ctx, "SkipRenewal", id, func(ctx context.Context, tx pgx.Tx, s *models.Subscription) error {
+		cycle, err := s.Skip()
+		if err != nil {
+			return err
+		}
+		tag, err := tx.Exec(ctx, `INSERT INTO subscription_renewals (subscription_id, cycle_date, status)
+			VALUES ($1,$2,$3) ON CONFLICT (subscription_id, cycle_date) DO NOTHING`,
+			s.ID, cycle, models.RenewalStatusSkipped)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return fmt.Errorf("%w: renewal for %s is already in progress", models.ErrInvalidTransition, cycle.Format("2006-01-02"))
+		}
+		return nil
+	})
+}
+
+// transition locks a subscription, lets apply change it and saves the result
+// in one transaction. It returns nil if the subscription does not exist.
+func (db *DB) transition(ctx context.Context, op string, id int64, apply func(ctx context.Context, tx pgx.Tx, s *models.Subscription) error) (*models.Subscription, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s begin tx: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var s models.Subscription
+	err = scanSubscription(tx.QueryRow(ctx, `SELECT `+subscriptionColumns+` FROM subscriptions WHERE id = $1 FOR UPDATE`, id), &s)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%s select: %w", op, err)
+	}
+
+	if err := apply(ctx, tx, &s); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = tx.QueryRow(ctx, `UPDATE subscriptions SET status = $1, next_renewal_at = $2, paused_at = $3, cancelled_at = $4, updated_at = NOW()
+		WHERE id = $5 RETURNING updated_at`,
+		s.Status, s.NextRenewalAt, s.PausedAt, s.CancelledAt, s.ID).Scan(&s.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("%s update: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("%s commit: %w", op, err)
+	}
+	return &s, nil
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"com.MixieMelts.subscriptions/internal/models"
+	"com.MixieMelts.subscriptions/internal/products"
+	"com.MixieMelts.users/jwtverify"
+	"github.com/go-chi/chi/v5"
+)
+
+// DBLayer is the subscription persistence used by the handlers.
+type DBLayer interface {
+	CreateSubscription(ctx context.Context, s *models.Subscription) error
+	GetSubscription(ctx context.Context, id int64) (*models.Subscription, error)
+	ListSubscriptions(ctx context.Context, userID string) ([]models.Subscription, error)
+	ListRenewals(ctx context.Context, subscriptionID int64) ([]models.Renewal, error)
+	SkipRenewal(ctx context.Context, id int64) (*models.Subscription, error)
+	PauseSubscription(ctx context.Context, id int64) (*models.Subscription, error)
+	ResumeSubscription(ctx context.Context, id int64) (*models.Subscription, error)
+	CancelSubscription(ctx context.Context, id int64) (*models.Subscription, error)
+}
+
+// BoxCatalog validates subscription boxes against the products service.
+type BoxCatalog interface {
+	GetSubscriptionBox(ctx context.Context, id int64) (*products.SubscriptionBox, error)
+}
+
+// Handler provides HTTP handlers for the subscriptions service.
+type Handler struct {
+	db      DBLayer
+	catalog BoxCatalog
+	now     func() time.Time
+}
+
+// New creates a new Handler.
+func New(db DBLayer, catalog BoxCatalog) *Handler {
+	return &Handler{db: db, catalog: catalog, now: time.Now}
+}
+
+// SubscribePayload is the request body for POST /subscriptions.
+type SubscribePayload struct {
+	BoxID   int64          `json:"box_id"`
+	Cadence models.Cadence `json:"cadence"`
+	// StartDate is the first renewal, as YYYY-MM-DD. It defaults to today,
+	// in which case the first box is ordered on the scheduler's next run.
+	StartDate string `json:"start_date"`
+}
+
+// CreateSubscription subscribes the caller to a box.
+func (h *Handler) CreateSubscription(w http.ResponseWriter, r *http.Request) {
+	var p SubscribePayload
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if !p.Cadence.Valid() {
+		respondWithError(w, http.StatusBadRequest, "cadence must be monthly, bimonthly or quarterly")
+		return
+	}
+
+	today := models.Date(h.now())
+	start := today
+	if p.StartDate != "" {
+		d, err := time.Parse("2006-01-02", p.StartDate)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "start_date must be YYYY-MM-DD")
+			return
+		}
+		if d.Before(today) {
+			respondWithError(w, http.StatusBadRequest, "start_date cannot be in the past")
+			return
+		}
+		start = d
+	}
+
+	if _, err := h.catalog.GetSubscriptionBox(r.Context(), p.BoxID); err != nil {
+		if errors.Is(err, products.ErrNotFound) {
+			respondWithError(w, http.StatusBadRequest, "unknown subscription box")
+			return
+		}
+		log.Printf("CreateSubscription get box error: %v", err)
+		respondWithError(w, http.StatusBadGateway, "failed to look up subscription box")
+		return
+	}
+
+	sub := &models.Subscription{
+		UserID:        userIDFromContext(r.Context()),
+		BoxID:         p.BoxID,
+		Status:        models.SubscriptionStatusActive,
+		Cadence:       p.Cadence,
+		AnchorDay:     start.Day(),
+		NextRenewalAt: start,
+	}
+	if err := h.db.CreateSubscription(r.Context(), sub); err != nil {
+		log.Printf("CreateSubscription error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to create subscription")
+		return
+	}
+
+	log.Printf("Subscription %d created for user %s", sub.ID, sub.UserID)
+	respondWithJSON(w, http.StatusCreated, sub)
+}
+
+// GetSubscriptions returns the caller's subscriptions.
+func (h *Handler) GetSubscriptions(w http.ResponseWriter, r *http.Request) {
+	subs, err := h.db.ListSubscriptions(r.Context(), userIDFromContext(r.Context()))
+	if err != nil {
+		log.Printf("GetSubscriptions error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to list subscriptions")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, subs)
+}
+
+// GetSubscription returns one of the caller's subscriptions.
+func (h *Handler) GetSubscription(w http.ResponseWriter, r *http.Request) {
+	sub, ok := h.ownSubscription(w, r)
+	if !ok {
+		return
+	}
+	respondWithJSON(w, http.StatusOK, sub)
+}
+
+// GetRenewals returns the renewal history of one of the caller's
+// subscriptions.
+func (h *Handler) GetRenewals(w http.ResponseWriter, r *http.Request) {
+	sub, ok := h.ownSubscription(w, r)
+	if !ok {
+		return
+	}
+	renewals, err := h.db.ListRenewals(r.Context(), sub.ID)
+	if err != nil {
+		log.Printf("GetRenewals error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to list renewals")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, renewals)
+}
+
+// SkipRenewal skips the next cycle of one of the caller's subscriptions.
+func (h *Handler) SkipRenewal(w http.ResponseWriter, r *http.Request) {
+	h.transition(w, r, "SkipRenewal", h.db.SkipRenewal)
+}
+
+// PauseSubscription pauses one of the caller's subscriptions.
+func (h *Handler) PauseSubscription(w http.ResponseWriter, r *http.Request) {
+	h.transition(w, r, "PauseSubscription", h.db.PauseSubscription)
+}
+
+// ResumeSubscription resumes one of the caller's paused subscriptions.
+func (h *Handler) ResumeSubscription(w http.ResponseWriter, r *http.Request) {
+	h.transition(w, r, "ResumeSubscription", h.db.ResumeSubscription)
+}
+
+// CancelSubscription cancels one of the caller's subscriptions.
+func (h *Handler) CancelSubscription(w http.ResponseWriter, r *http.Request) {
+	h.transition(w, r, "CancelSubscription", h.db.CancelSubscription)
+}
+
+// transition applies a state change to one of the caller's subscriptions,
+// reporting changes the current state does not allow as 409.
+func (h *Handler) transition(w http.ResponseWriter, r *http.Request, op string, apply func(ctx context.Context, id int64) (*models.Subscription, error)) {
+	sub, ok := h.ownSubscription(w, r)
+	if !ok {
+		return
+	}
+
+	updated, err := apply(r.Context(), sub.ID)
+	switch {
+	case errors.Is(err, models.ErrInvalidTransition):
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		log.Printf("%s error: %v", op, err)
+		respondWithError(w, http.StatusInternalServerError, "failed to update subscription")
+		return
+	case updated == nil:
+		respondWithError(w, http.StatusNotFound, "subscription not found")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, updated)
+}
+
+// ownSubscription loads the subscription named in the URL and checks that it
+// belongs to the caller. It writes the error response and returns false if
+// not.
+func (h *Handler) ownSubscription(w http.ResponseWriter, r *http.Request) (*models.Subscription, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid id")
+		return nil, false
+	}
+
+	sub, err := h.db.GetSubscription(r.Context(), id)
+	if err != nil {
+		log.Printf("GetSubscription error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to get subscription")
+		return nil, false
+	}
+	// Other customers' subscriptions are reported as missing rather than
+	// forbidden.
+	if sub == nil || sub.UserID != userIDFromContext(r.Context()) {
+		respondWithError(w, http.StatusNotFound, "subscription not found")
+		return nil, false
+	}
+	return sub, true
+}
+
+// --- UTILITY & MIDDLEWARE ---
+
+// userIDFromContext returns the caller's user id from the token the
+// verifier's middleware checked.
+func userIDFromContext(ctx context.Context) string {
+	claims, ok := jwtverify.ClaimsFromContext(ctx)
+	if !ok {
+		return ""
+	}
+	return claims.Subject
+}
+
+func respondWithError(w http.ResponseWriter, code int, message string) {
+	respondWithJSON(w, code, map[string]string{"message": message})
+}
+
+func respondWithJSON(w http.ResponseWriter, code int, payload any) {
+	response, _ := json.Marshal(payload)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(response)
+}
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"com.MixieMelts.subscriptions/internal/models"
+	"com.MixieMelts.subscriptions/internal/products"
+	"com.MixieMelts.users/jwtverify"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// MockDB is a mock implementation of the DBLayer for testing purposes.
+type MockDB struct {
+	CreateSubscriptionFunc func(ctx context.Context, s *models.Subscription) error
+	GetSubscriptionFunc    func(ctx context.Context, id int64) (*models.Subscription, error)
+	ListSubscriptionsFunc  func(ctx context.Context, userID string) ([]models.Subscription, error)
+	ListRenewalsFunc       func(ctx context.Context, subscriptionID int64) ([]models.Renewal, error)
+	SkipRenewalFunc        func(ctx context.Context, id int64) (*models.Subscription, error)
+	PauseSubscriptionFunc  func(ctx context.Context, id int64) (*models.Subscription, error)
+	ResumeSubscriptionFunc func(ctx context.Context, id int64) (*models.Subscription, error)
+	CancelSubscriptionFunc func(ctx context.Context, id int64) (*models.Subscription, error)
+}
+
+func (m *MockDB) CreateSubscription(ctx context.Context, s *models.Subscription) error {
+	if m.CreateSubscriptionFunc != nil {
+		return m.CreateSubscriptionFunc(ctx, s)
+	}
+	return errors.New("CreateSubscriptionFunc not implemented")
+}
+
+func (m *MockDB) GetSubscription(ctx context.Context, id int64) (*models.Subscription, error) {
+	if m.GetSubscriptionFunc != nil {
+		return m.GetSubscriptionFunc(ctx, id)
+	}
+	return nil, errors.New("GetSubscriptionFunc not implemented")
+}
+
+func (m *MockDB) ListSubscriptions(ctx context.Context, userID string) ([]models.Subscription, error) {
+	if m.ListSubscriptionsFunc != nil {
+		return m.ListSubscriptionsFunc(ctx, userID)
+	}
+	return nil, errors.New("ListSubscriptionsFunc not implemented")
+}
+
+func (m *MockDB) ListRenewals(ctx context.Context, subscriptionID int64) ([]models.Renewal, error) {
+	if m.ListRenewalsFunc != nil {
+		return m.ListRenewalsFunc(ctx, subscriptionID)
+	}
+	return nil, errors.New("ListRenewalsFunc not implemented")
+}
+
+func (m *MockDB) SkipRenewal(ctx context.Context, id int64) (*models.Subscription, error) {
+	if m.SkipRenewalFunc != nil {
+		return m.SkipRenewalFunc(ctx, id)
+	}
+	return nil, errors.New("SkipRenewalFunc not implemented")
+}
+
+func (m *MockDB) PauseSubscription(ctx context.Context, id int64) (*models.Subscription, error) {
+	if m.PauseSubscriptionFunc != nil {
+		return m.PauseSubscriptionFunc(ctx, id)
+	}
+	return nil, errors.New("PauseSubscriptionFunc not implemented")
+}
+
+func (m *MockDB) ResumeSubscription(ctx context.Context, id int64) (*models.Subscription, error) {
+	if m.ResumeSubscriptionFunc != nil {
+		return m.ResumeSubscriptionFunc(ctx, id)
+	}
+	return nil, errors.New("ResumeSubscriptionFunc not implemented")
+}
+
+func (m *MockDB) CancelSubscription(ctx context.Context, id int64) (*models.Subscription, error) {
+	if m.CancelSubscriptionFunc != nil {
+		return m.CancelSubscriptionFunc(ctx, id)
+	}
+	return nil, errors.New("CancelSubscriptionFunc not implemented")
+}
+
+// MockCatalog is a mock implementation of BoxCatalog.
+type MockCatalog struct {
+	Err error
+}
+
+func (m *MockCatalog) GetSubscriptionBox(ctx context.Context, id int64) (*products.SubscriptionBox, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	return &products.SubscriptionBox{ID: id, Name: "Monthly Melt Box", Price: 24.99}, nil
+}
+
+func withUser(req *http.Request, userID string) *http.Request {
+	claims := &jwtverify.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: userID}}
+	return req.WithContext(jwtverify.WithClaims(req.Context(), claims))
+}
+
+func withID(req *http.Request, id string) *http.Request {
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", id)
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+}
+
+func TestCreateSubscription(t *testing.T) {
+	today := time.Date(2026, 10, 17, 9, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		body        string
+		catalogErr  error
+		wantStatus  int
+		wantNext    string
+		wantAnchor  int
+		wantCreated bool
+	}{
+		{
+			name:        "starts today by default",
+			body:        `{"box_id":1,"cadence":"monthly"}`,
+			wantStatus:  http.StatusCreated,
+			wantNext:    "2026-10-17",
+			wantAnchor:  17,
+			wantCreated: true,
+		},
+		{
+			name:        "future start date",
+			body:        `{"box_id":1,"cadence":"quarterly","start_date":"2026-10-31"}`,
+			wantStatus:  http.StatusCreated,
+			wantNext:    "2026-10-31",
+			wantAnchor:  31,
+			wantCreated: true,
+		},
+		{name: "unknown cadence", body: `{"box_id":1,"cadence":"weekly"}`, wantStatus: http.StatusBadRequest},
+		{name: "past start date", body: `{"box_id":1,"cadence":"monthly","start_date":"2026-10-01"}`, wantStatus: http.StatusBadRequest},
+		{name: "bad start date", body: `{"box_id":1,"cadence":"monthly","start_date":"soon"}`, wantStatus: http.StatusBadRequest},
+		{name: "unknown box", body: `{"box_id":9,"cadence":"monthly"}`, catalogErr: products.ErrNotFound, wantStatus: http.StatusBadRequest},
+		{name: "products down", body: `{"box_id":1,"cadence":"monthly"}`, catalogErr: errors.New("dial tcp"), wantStatus: http.StatusBadGateway},
+		{name: "invalid body", body: `{bad`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var created *models.Subscription
+			mockDB := &MockDB{
+				CreateSubscriptionFunc: func(ctx context.Context, s *models.Subscription) error {
+					created = s
+					s.ID = 1
+					return nil
+				},
+			}
+			h := New(mockDB, &MockCatalog{Err: tc.catalogErr})
+			h.now = func() time.Time { return today }
+
+			req, _ := http.NewRequest("POST", "/subscriptions", bytes.NewBufferString(tc.body))
+			req = withUser(req, "42")
+			rr := httptest.NewRecorder()
+			h.CreateSubscription(rr, req)
+
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("expected status %d got %d; body: %s", tc.wantStatus, rr.Code, rr.Body.String())
+			}
+			if (created != nil) != tc.wantCreated {
+				t.Fatalf("expected created=%v got %+v", tc.wantCreated, created)
+			}
+			if created == nil {
+				return
+			}
+			if created.UserID != "42" || created.Status != models.SubscriptionStatusActive {
+				t.Fatalf("unexpected subscription %+v", created)
+			}
+			if got := created.NextRenewalAt.Format("2006-01-02"); got != tc.wantNext || created.AnchorDay != tc.wantAnchor {
+				t.Fatalf("expected next %s anchor %d, got %s anchor %d", tc.wantNext, tc.wantAnchor, got, created.AnchorDay)
+			}
+		})
+	}
+}
+
+func TestSubscriptionTransitions(t *testing.T) {
+	tests := []struct {
+		name       string
+		id         string
+		owner      string
+		applyErr   error
+		wantStatus int
+		wantApply  bool
+	}{
+		{name: "own subscription", id: "1", owner: "42", wantStatus: http.StatusOK, wantApply: true},
+		{name: "not allowed from current state", id: "1", owner: "42", applyErr: fmt.Errorf("PauseSubscription: %w", models.ErrInvalidTransition), wantStatus: http.StatusConflict, wantApply: true},
+		{name: "someone else's subscription", id: "1", owner: "7", wantStatus: http.StatusNotFound},
+		{name: "bad id", id: "x", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			applied := false
+			mockDB := &MockDB{
+				GetSubscriptionFunc: func(ctx context.Context, id int64) (*models.Subscription, error) {
+					return &models.Subscription{ID: id, UserID: tc.owner, Status: models.SubscriptionStatusActive}, nil
+				},
+				PauseSubscriptionFunc: func(ctx context.Context, id int64) (*models.Subscription, error) {
+					applied = true
+					if tc.applyErr != nil {
+						return nil, tc.applyErr
+					}
+					return &models.Subscription{ID: id, UserID: tc.owner, Status: models.SubscriptionStatusPaused}, nil
+				},
+			}
+			h := New(mockDB, &MockCatalog{})
+
+			req, _ := http.NewRequest("POST", "/subscriptions/"+tc.id+"/pause", nil)
+			req = withUser(withID(req, tc.id), "42")
+			rr := httptest.NewRecorder()
+			h.PauseSubscription(rr, req)
+
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("expected status %d got %d; body: %s", tc.wantStatus, rr.Code, rr.Body.String())
+			}
+			if applied != tc.wantApply {
+				t.Fatalf("expected applied=%v got %v", tc.wantApply, applied)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidTransition is returned when a subscription cannot move to the
+// requested state from its current one.
+var ErrInvalidTransition = errors.New("invalid subscription state change")
+
+// SubscriptionStatus is the lifecycle state of a subscription.
+type SubscriptionStatus string
+
+const (
+	// SubscriptionStatusActive subscriptions renew on every cycle.
+	SubscriptionStatusActive SubscriptionStatus = "active"
+	// SubscriptionStatusPaused subscriptions keep their cadence but do not
+	// renew until resumed.
+	SubscriptionStatusPaused SubscriptionStatus = "paused"
+	// SubscriptionStatusCancelled subscriptions never renew again.
+	SubscriptionStatusCancelled SubscriptionStatus = "cancelled"
+)
+
+// Cadence is how often a subscription box ships.
+type Cadence string
+
+const (
+	CadenceMonthly   Cadence = "monthly"
+	CadenceBimonthly Cadence = "bimonthly"
+	CadenceQuarterly Cadence = "quarterly"
+)
+
+// Months returns the number of months between renewals, or 0 for an unknown
+// cadence.
+func (c Cadence) Months() int {
+	switch c {
+	case CadenceMonthly:
+		return 1
+	case CadenceBimonthly:
+		return 2
+	case CadenceQuarterly:
+		return 3
+	}
+	return 0
+}
+
+// Valid reports whether c is a supported cadence.
+func (c Cadence) Valid() bool {
+	return c.Months() > 0
+}
+
+// Subscription is a customer's recurring order for a subscription box.
+type Subscription struct {
+	ID      int64              `json:"id"`
+	UserID  string             `json:"user_id"`
+	BoxID   int64              `json:"box_id"`
+	Status  SubscriptionStatus `json:"status"`
+	Cadence Cadence            `json:"cadence"`
+	// AnchorDay is the day of the month renewals fall on. Months that are
+	// too short renew on their last day instead.
+	AnchorDay     int        `json:"anchor_day"`
+	NextRenewalAt time.Time  `json:"next_renewal_at"`
+	PausedAt      *time.Time `json:"paused_at,omitempty"`
+	CancelledAt   *time.Time `json:"cancelled_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// NextCycle returns the renewal date one cadence after from, on the anchor
+// day clamped to the length of the target month.
+func NextCycle(from time.Time, cadence Cadence, anchorDay int) time.Time {
+	first := time.Date(from.Year(), from.Month()+time.Month(cadence.Months()), 1, 0, 0, 0, 0, time.UTC)
+	lastDay := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(anchorDay, lastDay)-1)
+}
+
+// Date truncates t to midnight UTC of its calendar day.
+func Date(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// Advance moves the next renewal one cycle forward.
+func (s *Subscription) Advance() {
+	s.NextRenewalAt = NextCycle(s.NextRenewalAt, s.Cadence, s.AnchorDay)
+}
+
+// Skip gives up the upcoming renewal of an active subscription and returns
+// the cycle date that was skipped.
+func (s *Subscription) Skip() (time.Time, error) {
+	if s.Status != SubscriptionStatusActive {
+		return time.Time{}, fmt.Errorf("%w: cannot skip a %s subscription", ErrInvalidTransition, s.Status)
+	}
+	skipped := s.NextRenewalAt
+	s.Advance()
+	return skipped, nil
+}
+
+// Pause stops an active subscription from renewing.
+func (s *Subscription) Pause(now time.Time) error {
+	if s.Status != SubscriptionStatusActive {
+		return fmt.Errorf("%w: cannot pause a %s subscription", ErrInvalidTransition, s.Status)
+	}
+	s.Status = SubscriptionStatusPaused
+	s.PausedAt = &now
+	return nil
+}
+
+// Resume reactivates a paused subscription. Cycles that fell due while it was
+// paused are not ordered; the next renewal moves to the first cycle on or
+// after today.
+func (s *Subscription) Resume(now time.Time) error {
+	if s.Status != SubscriptionStatusPaused {
+		return fmt.Errorf("%w: cannot resume a %s subscription", ErrInvalidTransition, s.Status)
+	}
+	today := Date(now)
+	for s.NextRenewalAt.Before(today) {
+		s.Advance()
+	}
+	s.Status = SubscriptionStatusActive
+	s.PausedAt = nil
+	return nil
+}
+
+// Cancel permanently stops a subscription.
+func (s *Subscription) Cancel(now time.Time) error {
+	if s.Status == SubscriptionStatusCancelled {
+		return fmt.Errorf("%w: subscription is already cancelled", ErrInvalidTransition)
+	}
+	s.Status = SubscriptionStatusCancelled
+	s.CancelledAt = &now
+	return nil
+}
+
+// RenewalStatus is the state of a single renewal cycle.
+type RenewalStatus string
+
+const (
+	// RenewalStatusPending is set while the scheduler is placing the order.
+	RenewalStatusPending RenewalStatus = "pending"
+	// RenewalStatusOrdered is set once the orders service accepted the order.
+	RenewalStatusOrdered RenewalStatus = "ordered"
+	// RenewalStatusSkipped is set when the customer skipped the cycle.
+	RenewalStatusSkipped RenewalStatus = "skipped"
+	// RenewalStatusFailed is set when placing the order failed. Failed
+	// renewals are retried until the scheduler gives up on the cycle.
+	RenewalStatusFailed RenewalStatus = "failed"
+)
+
+// Renewal records what happened to one cycle of a subscription.
+type Renewal struct {
+	ID             int64         `json:"id"`
+	SubscriptionID int64         `json:"subscription_id"`
+	CycleDate      time.Time     `json:"cycle_date"`
+	Status         RenewalStatus `json:"status"`
+	OrderID        *int64        `json:"order_id,omitempty"`
+	Error          string        `json:"error,omitempty"`
+	Attempts       int           `json:"attempts"`
+	CreatedAt      time.Time     `json:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+}
+
+// RenewalReference is the idempotency key used when ordering a cycle, so a
+// retried renewal never creates a second order.
+func RenewalReference(subscriptionID int64, cycle time.Time) string {
+	return fmt.Sprintf("subscription-%d-%s", subscriptionID, cycle.Format("2006-01-02"))
+}
@@ -0,0 +1,93 @@
+// Package orders is a small HTTP client for the orders service, used to place
+// the order for each subscription renewal.
+package orders
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"com.MixieMelts.subscriptions/internal/models"
+)
+
+// serviceTokenHeader carries the token the orders service requires on its
+// internal routes.
+const serviceTokenHeader = "X-Service-Token"
+
+// Client talks to the orders service over HTTP.
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// New creates a client for the orders service rooted at baseURL
+// (e.g. "http://orders:8085") that authenticates with the shared service
+// token.
+func New(baseURL, token string) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		token:      token,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type line struct {
+	SubscriptionBoxID int64 `json:"subscription_box_id"`
+	Quantity          int   `json:"quantity"`
+}
+
+type createRequest struct {
+	UserID    string `json:"user_id"`
+	Source    string `json:"source"`
+	Reference string `json:"reference"`
+	Items     []line `json:"items"`
+}
+
+// CreateRenewalOrder places the order for one cycle of a subscription and
+// returns its id. The order is keyed by models.RenewalReference, so calling
+// it again for the same cycle returns the existing order.
+func (c *Client) CreateRenewalOrder(ctx context.Context, sub models.Subscription, cycle time.Time) (int64, error) {
+	body, err := json.Marshal(createRequest{
+		UserID:    sub.UserID,
+		Source:    "subscription",
+		Reference: models.RenewalReference(sub.ID, cycle),
+		Items:     []line{{SubscriptionBoxID: sub.BoxID, Quantity: 1}},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("CreateRenewalOrder encode: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/internal/orders", bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("CreateRenewalOrder build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(serviceTokenHeader, c.token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("CreateRenewalOrder request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		var e struct {
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&e)
+		return 0, fmt.Errorf("CreateRenewalOrder: status %d: %s", resp.StatusCode, e.Message)
+	}
+
+	var order struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&order); err != nil {
+		return 0, fmt.Errorf("CreateRenewalOrder decode: %w", err)
+	}
+	return order.ID, nil
+}
@@ -0,0 +1,66 @@
+// Package products is a small HTTP client for the products service, used to
+// validate subscription box ids when a customer subscribes.
+package products
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when the products service has no subscription box
+// with the requested id.
+var ErrNotFound = errors.New("subscription box not found")
+
+// SubscriptionBox is the subset of the products service representation
+// subscriptions need.
+type SubscriptionBox struct {
+	ID    int64   `json:"id"`
+	Name  string  `json:"name"`
+	Price float64 `json:"price"`
+}
+
+// Client talks to the products service over HTTP.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// New creates a client for the products service rooted at baseURL
+// (e.g. "http://products:8082").
+func New(baseURL string) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// GetSubscriptionBox fetches a single subscription box by id.
+func (c *Client) GetSubscriptionBox(ctx context.Context, id int64) (*SubscriptionBox, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/products/subscription-boxes/%d", c.baseURL, id), nil)
+	if err != nil {
+		return nil, fmt.Errorf("GetSubscriptionBox build request: %w", err)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("GetSubscriptionBox request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, ErrNotFound
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("GetSubscriptionBox: unexpected status %d", resp.StatusCode)
+	}
+
+	var b SubscriptionBox
+	if err := json.NewDecoder(resp.Body).Decode(&b); err != nil {
+		return nil, fmt.Errorf("GetSubscriptionBox decode: %w", err)
+	}
+	return &b, nil
+}
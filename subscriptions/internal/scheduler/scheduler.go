@@ -0,0 +1,117 @@
+// Package scheduler places the order for every subscription cycle that has
+// come due. Each cycle is claimed as a renewal row before the order is placed
+// and the order is keyed by the cycle, so overlapping runs, crashes and
+// retries never order the same cycle twice.
+package scheduler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"com.MixieMelts.subscriptions/internal/models"
+)
+
+const (
+	// batchSize bounds how many due subscriptions one run processes.
+	batchSize = 100
+	// maxAttempts is how many times a cycle is tried before the scheduler
+	// gives up on it and moves the subscription to its next cycle.
+	maxAttempts = 5
+	// staleAfter is how long a renewal may stay pending before another run
+	// assumes its owner died and reclaims it.
+	staleAfter = 10 * time.Minute
+)
+
+// Store persists subscriptions and their renewals.
+type Store interface {
+	DueSubscriptions(ctx context.Context, asOf time.Time, limit int) ([]models.Subscription, error)
+	BeginRenewal(ctx context.Context, subscriptionID int64, cycle, staleBefore time.Time) (*models.Renewal, error)
+	CompleteRenewal(ctx context.Context, renewalID, orderID int64) error
+	FailRenewal(ctx context.Context, renewalID int64, reason string, giveUp bool) error
+}
+
+// Orders places renewal orders.
+type Orders interface {
+	CreateRenewalOrder(ctx context.Context, sub models.Subscription, cycle time.Time) (int64, error)
+}
+
+// Scheduler generates renewal orders for due subscriptions.
+type Scheduler struct {
+	store  Store
+	orders Orders
+	now    func() time.Time
+}
+
+// New creates a Scheduler.
+func New(store Store, orders Orders) *Scheduler {
+	return &Scheduler{store: store, orders: orders, now: time.Now}
+}
+
+// Result summarises one scheduler run.
+type Result struct {
+	Ordered int
+	Failed  int
+}
+
+// RunOnce orders the current cycle of every due subscription. Failures of
+// individual renewals are recorded on the renewal and do not stop the run.
+func (s *Scheduler) RunOnce(ctx context.Context) (Result, error) {
+	var res Result
+	now := s.now()
+
+	due, err := s.store.DueSubscriptions(ctx, models.Date(now), batchSize)
+	if err != nil {
+		return res, err
+	}
+
+	for _, sub := range due {
+		cycle := sub.NextRenewalAt
+		renewal, err := s.store.BeginRenewal(ctx, sub.ID, cycle, now.Add(-staleAfter))
+		if err != nil {
+			return res, err
+		}
+		if renewal == nil {
+			continue
+		}
+
+		orderID, err := s.orders.CreateRenewalOrder(ctx, sub, cycle)
+		if err != nil {
+			res.Failed++
+			giveUp := renewal.Attempts >= maxAttempts
+			log.Printf("scheduler: renewal of subscription %d for %s failed (attempt %d, giving up: %v): %v",
+				sub.ID, cycle.Format("2006-01-02"), renewal.Attempts, giveUp, err)
+			if ferr := s.store.FailRenewal(ctx, renewal.ID, err.Error(), giveUp); ferr != nil {
+				return res, ferr
+			}
+			continue
+		}
+
+		if err := s.store.CompleteRenewal(ctx, renewal.ID, orderID); err != nil {
+			return res, err
+		}
+		res.Ordered++
+	}
+	return res, nil
+}
+
+// Run calls RunOnce every interval until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		res, err := s.RunOnce(ctx)
+		if err != nil {
+			log.Printf("scheduler: run failed: %v", err)
+		} else if res.Ordered > 0 || res.Failed > 0 {
+			log.Printf("scheduler: %d renewals ordered, %d failed", res.Ordered, res.Failed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
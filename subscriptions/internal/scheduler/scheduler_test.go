@@ -0,0 +1,215 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"com.MixieMelts.subscriptions/internal/models"
+)
+
+// fakeStore is an in-memory Store with the same claiming rules as the
+// database.
+type fakeStore struct {
+	subs     map[int64]*models.Subscription
+	renewals map[string]*models.Renewal
+	nextID   int64
+}
+
+func newFakeStore(subs ...models.Subscription) *fakeStore {
+	f := &fakeStore{subs: map[int64]*models.Subscription{}, renewals: map[string]*models.Renewal{}}
+	for i := range subs {
+		s := subs[i]
+		f.subs[s.ID] = &s
+	}
+	return f
+}
+
+func (f *fakeStore) DueSubscriptions(ctx context.Context, asOf time.Time, limit int) ([]models.Subscription, error) {
+	var due []models.Subscription
+	for _, s := range f.subs {
+		if s.Status == models.SubscriptionStatusActive && !s.NextRenewalAt.After(asOf) {
+			due = append(due, *s)
+		}
+	}
+	return due, nil
+}
+
+func (f *fakeStore) BeginRenewal(ctx context.Context, subscriptionID int64, cycle, staleBefore time.Time) (*models.Renewal, error) {
+	s := f.subs[subscriptionID]
+	if s == nil || s.Status != models.SubscriptionStatusActive || !s.NextRenewalAt.Equal(cycle) {
+		return nil, nil
+	}
+	key := models.RenewalReference(subscriptionID, cycle)
+	r, ok := f.renewals[key]
+	if !ok {
+		f.nextID++
+		r = &models.Renewal{ID: f.nextID, SubscriptionID: subscriptionID, CycleDate: cycle}
+		f.renewals[key] = r
+	} else if r.Status != models.RenewalStatusFailed {
+		return nil, nil
+	}
+	r.Status = models.RenewalStatusPending
+	r.Attempts++
+	claimed := *r
+	return &claimed, nil
+}
+
+func (f *fakeStore) renewal(id int64) *models.Renewal {
+	for _, r := range f.renewals {
+		if r.ID == id {
+			return r
+		}
+	}
+	return nil
+}
+
+func (f *fakeStore) advance(r *models.Renewal) {
+	s := f.subs[r.SubscriptionID]
+	if s.NextRenewalAt.Equal(r.CycleDate) {
+		s.Advance()
+	}
+}
+
+func (f *fakeStore) CompleteRenewal(ctx context.Context, renewalID, orderID int64) error {
+	r := f.renewal(renewalID)
+	r.Status = models.RenewalStatusOrdered
+	r.OrderID = &orderID
+	f.advance(r)
+	return nil
+}
+
+func (f *fakeStore) FailRenewal(ctx context.Context, renewalID int64, reason string, giveUp bool) error {
+	r := f.renewal(renewalID)
+	r.Status = models.RenewalStatusFailed
+	r.Error = reason
+	if giveUp {
+		f.advance(r)
+	}
+	return nil
+}
+
+type fakeOrders struct {
+	err    error
+	placed []string
+}
+
+func (f *fakeOrders) CreateRenewalOrder(ctx context.Context, sub models.Subscription, cycle time.Time) (int64, error) {
+	if f.err != nil {
+		return 0, f.err
+	}
+	f.placed = append(f.placed, models.RenewalReference(sub.ID, cycle))
+	return int64(len(f.placed)), nil
+}
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func monthly(id int64, next time.Time) models.Subscription {
+	return models.Subscription{
+		ID:            id,
+		UserID:        "42",
+		BoxID:         1,
+		Status:        models.SubscriptionStatusActive,
+		Cadence:       models.CadenceMonthly,
+		AnchorDay:     next.Day(),
+		NextRenewalAt: next,
+	}
+}
+
+func TestRunOnceOrdersDueCycles(t *testing.T) {
+	paused := monthly(3, date(2026, 3, 1))
+	paused.Status = models.SubscriptionStatusPaused
+
+	store := newFakeStore(
+		monthly(1, date(2026, 3, 10)),
+		monthly(2, date(2026, 3, 20)), // not due yet
+		paused,
+	)
+	orders := &fakeOrders{}
+	s := New(store, orders)
+	s.now = func() time.Time { return time.Date(2026, 3, 10, 15, 0, 0, 0, time.UTC) }
+
+	res, err := s.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Ordered != 1 || res.Failed != 0 {
+		t.Fatalf("expected 1 ordered, got %+v", res)
+	}
+	if len(orders.placed) != 1 || orders.placed[0] != "subscription-1-2026-03-10" {
+		t.Fatalf("unexpected orders %v", orders.placed)
+	}
+	if got := store.subs[1].NextRenewalAt; !got.Equal(date(2026, 4, 10)) {
+		t.Fatalf("expected next renewal 2026-04-10, got %s", got)
+	}
+
+	// A second run on the same day has nothing left to do.
+	res, err = s.RunOnce(context.Background())
+	if err != nil || res.Ordered != 0 {
+		t.Fatalf("expected idle second run, got %+v, %v", res, err)
+	}
+}
+
+func TestRunOnceRetriesThenGivesUp(t *testing.T) {
+	store := newFakeStore(monthly(1, date(2026, 3, 10)))
+	orders := &fakeOrders{err: errors.New("orders unavailable")}
+	s := New(store, orders)
+	s.now = func() time.Time { return date(2026, 3, 10) }
+
+	for attempt := 1; attempt < maxAttempts; attempt++ {
+		res, err := s.RunOnce(context.Background())
+		if err != nil || res.Failed != 1 {
+			t.Fatalf("attempt %d: expected a failed renewal, got %+v, %v", attempt, res, err)
+		}
+		if got := store.subs[1].NextRenewalAt; !got.Equal(date(2026, 3, 10)) {
+			t.Fatalf("attempt %d: subscription advanced before giving up: %s", attempt, got)
+		}
+	}
+
+	// Recovering before the last attempt orders the original cycle.
+	orders.err = nil
+	res, err := s.RunOnce(context.Background())
+	if err != nil || res.Ordered != 1 {
+		t.Fatalf("expected retry to succeed, got %+v, %v", res, err)
+	}
+	r := store.renewals["subscription-1-2026-03-10"]
+	if r.Status != models.RenewalStatusOrdered || r.Attempts != maxAttempts {
+		t.Fatalf("unexpected renewal %+v", r)
+	}
+
+	// A cycle that keeps failing is abandoned after maxAttempts.
+	orders.err = errors.New("orders unavailable")
+	s.now = func() time.Time { return date(2026, 4, 10) }
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if _, err := s.RunOnce(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if got := store.subs[1].NextRenewalAt; !got.Equal(date(2026, 5, 10)) {
+		t.Fatalf("expected subscription to move on to 2026-05-10, got %s", got)
+	}
+	if r := store.renewals["subscription-1-2026-04-10"]; r.Status != models.RenewalStatusFailed {
+		t.Fatalf("expected abandoned renewal to stay failed, got %+v", r)
+	}
+}
+
+func TestRenewalsStayOnAnchorDay(t *testing.T) {
+	store := newFakeStore(monthly(1, date(2026, 1, 31)))
+	s := New(store, &fakeOrders{})
+
+	want := []time.Time{date(2026, 2, 28), date(2026, 3, 31), date(2026, 4, 30)}
+	now := date(2026, 1, 31)
+	for _, next := range want {
+		s.now = func() time.Time { return now }
+		if _, err := s.RunOnce(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := store.subs[1].NextRenewalAt; !got.Equal(next) {
+			t.Fatalf("expected next renewal %s, got %s", next.Format("2006-01-02"), got.Format("2006-01-02"))
+		}
+		now = next
+	}
+}
@@ -1,17 +1,23 @@
 package main
 
 import (
+	"context"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
+	"com.MixieMelts.users/internal/auth"
+	"com.MixieMelts.users/internal/blob"
 	"com.MixieMelts.users/internal/database"
 	"com.MixieMelts.users/internal/handlers"
+	"com.MixieMelts.users/internal/mail"
+	"com.MixieMelts.users/jwtverify"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/joho/godotenv"
-	"github.com/rs/cors"
 )
 
 var db *database.DB
@@ -24,6 +30,18 @@ func main() {
 		log.Println("Error loading .env file, using environment variables")
 	}
 
+	// "migrate up|down|status" manages the schema and exits
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		m, err := database.Migrator()
+		if err == nil {
+			err = m.Command(context.Background(), os.Getenv("DATABASE_URL"), os.Args[2:], os.Stdout)
+		}
+		if err != nil {
+			log.Fatalf("migrate: %v", err)
+		}
+		return
+	}
+
 	// Database connection
 	db, err = database.New(os.Getenv("DATABASE_URL"))
 	if err != nil {
@@ -33,25 +51,97 @@ func main() {
 	r := chi.NewRouter()
 
 	// Initialize handlers
-	h := handlers.New(db, []byte(os.Getenv("JWT_SECRET_KEY")))
+	var ttls handlers.TokenTTLs
+	for _, e := range []struct {
+		name string
+		dst  *time.Duration
+	}{
+		{"ACCESS_TOKEN_TTL", &ttls.Access},
+		{"REFRESH_TOKEN_TTL", &ttls.Refresh},
+		{"VERIFY_EMAIL_TOKEN_TTL", &ttls.VerifyEmail},
+		{"PASSWORD_RESET_TOKEN_TTL", &ttls.PasswordReset},
+	} {
+		if v := os.Getenv(e.name); v != "" {
+			if *e.dst, err = time.ParseDuration(v); err != nil {
+				log.Fatalf("Invalid %s: %v", e.name, err)
+			}
+		}
+	}
+
+	// JWT_SIGNING_KEYS lists PEM private key files, the signing key first;
+	// without it tokens are signed with JWT_SECRET_KEY
+	var keys []auth.SigningKey
+	for _, path := range strings.Split(os.Getenv("JWT_SIGNING_KEYS"), ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		key, err := auth.LoadSigningKey(path)
+		if err != nil {
+			log.Fatalf("Failed to load signing key: %v", err)
+		}
+		log.Printf("Loaded %s signing key %s", key.Method.Alg(), key.JWK.Kid)
+		keys = append(keys, key)
+	}
+	signer, err := auth.NewSigner([]byte(os.Getenv("JWT_SECRET_KEY")), keys...)
+	if err != nil {
+		log.Fatalf("Failed to set up token signing: %v", err)
+	}
+
+	// Avatars are kept on local disk and served by this service
+	avatarDir := os.Getenv("AVATAR_DIR")
+	if avatarDir == "" {
+		avatarDir = "data/avatars"
+	}
+	avatars, err := blob.NewLocal(avatarDir, "/api/users/avatars")
+	if err != nil {
+		log.Fatalf("Failed to set up avatar storage: %v", err)
+	}
+
+	// Mail goes through SMTP_HOST when it is set; otherwise it is written
+	// to MAIL_DIR, or logged, for local development
+	mailFrom := os.Getenv("MAIL_FROM")
+	if mailFrom == "" {
+		mailFrom = "Mixie Melts <no-reply@mixiemelts.local>"
+	}
+	var mailer mail.Mailer = &mail.File{Dir: os.Getenv("MAIL_DIR"), From: mailFrom}
+	if host := os.Getenv("SMTP_HOST"); host != "" {
+		smtpPort := os.Getenv("SMTP_PORT")
+		if smtpPort == "" {
+			smtpPort = "587"
+		}
+		mailer = &mail.SMTP{
+			Addr:     net.JoinHostPort(host, smtpPort),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     mailFrom,
+		}
+		log.Printf("Sending mail through %s", host)
+	}
+	appURL := os.Getenv("APP_URL")
+	if appURL == "" {
+		appURL = "http://localhost:8081"
+	}
+
+	h := handlers.New(db, signer, avatars, handlers.Emails{Mailer: mailer, AppURL: appURL}, ttls)
 
 	// Middleware stack
 	r.Use(middleware.Logger)    // Logs requests to the console
 	r.Use(middleware.Recoverer) // Recovers from panics without crashing
 	r.Use(middleware.Timeout(60 * time.Second))
 
-	// CORS middleware
-	r.Use(cors.New(cors.Options{
-		AllowedOrigins:   []string{"frontend:"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Content-Type", "Authorization"},
-		AllowCredentials: true,
-		MaxAge:           300, // Maximum value not ignored by any major browsers
-	}).Handler)
+	// Public keys for other services to verify access tokens with
+	r.Get("/.well-known/jwks.json", h.GetJWKS)
+
+	// Uploaded avatars
+	r.Handle("/api/users/avatars/*", http.StripPrefix("/api/users/avatars", avatars.Handler()))
 
 	// Public routes for local auth
 	r.Post("/api/users/register", h.RegisterUser)
 	r.Post("/api/users/login", h.LoginUser)
+	r.Post("/api/users/token/refresh", h.RefreshToken)
+	r.Post("/api/users/verify-email", h.VerifyEmail)
+	r.Post("/api/users/password-reset/request", h.RequestPasswordReset)
+	r.Post("/api/users/password-reset", h.ResetPassword)
 
 	// Public routes for OAuth
 	r.Get("/api/users/oauth/google/login", h.HandleGoogleLogin)
@@ -63,7 +153,19 @@ func main() {
 	r.Group(func(r chi.Router) {
 		r.Use(h.AuthMiddleware)
 		r.Get("/api/users/me", h.GetUserProfile)
-		// Add other protected routes here (e.g., PUT /api/users/me)
+		r.Put("/api/users/me", h.UpdateUserProfile)
+		r.Put("/api/users/me/avatar", h.UploadAvatar)
+		r.Post("/api/users/logout", h.Logout)
+		r.Post("/api/users/logout-all", h.LogoutAll)
+		r.Post("/api/users/verify-email/request", h.RequestEmailVerification)
+
+		// Role management
+		r.Group(func(r chi.Router) {
+			r.Use(jwtverify.Require(jwtverify.PermRolesManage))
+			r.Get("/api/users/{id}/roles", h.GetUserRoles)
+			r.Put("/api/users/{id}/roles/{role}", h.GrantUserRole)
+			r.Delete("/api/users/{id}/roles/{role}", h.RevokeUserRole)
+		})
 	})
 
 	port := os.Getenv("PORT")
@@ -3,12 +3,10 @@ module com.MixieMelts.users
 go 1.24.2
 
 require (
-	github.com/davecgh/go-spew v1.1.1
 	github.com/go-chi/chi/v5 v5.2.3
 	github.com/golang-jwt/jwt/v4 v4.5.2
 	github.com/jackc/pgx/v5 v5.7.6
 	github.com/joho/godotenv v1.5.1
-	github.com/rs/cors v1.11.1
 	golang.org/x/crypto v0.43.0
 	golang.org/x/oauth2 v0.32.0
 )
@@ -17,5 +15,7 @@ require (
 	cloud.google.com/go/compute/metadata v0.3.0 // indirect
 	github.com/jackc/pgpassfile v1.0.0 // indirect
 	github.com/jackc/pgservicefile v0.0.0-20240606120523-5a60cdf6a761 // indirect
+	github.com/jackc/puddle/v2 v2.2.2 // indirect
+	golang.org/x/sync v0.17.0 // indirect
 	golang.org/x/text v0.30.0 // indirect
 )
@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"com.MixieMelts.users/jwtverify"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// minRSABits is the smallest RSA signing key accepted.
+const minRSABits = 2048
+
+// SigningKey is a private key access tokens can be signed with.
+type SigningKey struct {
+	JWK     jwtverify.JWK
+	Method  jwt.SigningMethod
+	Private crypto.Signer
+}
+
+// ParseSigningKey reads a PEM encoded RSA (PKCS #1 or #8) or Ed25519
+// (PKCS #8) private key.
+func ParseSigningKey(data []byte) (SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return SigningKey{}, errors.New("no PEM block found")
+	}
+
+	var key any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return SigningKey{}, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return SigningKey{}, err
+	}
+
+	var sk SigningKey
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		if key.N.BitLen() < minRSABits {
+			return SigningKey{}, fmt.Errorf("RSA key is %d bits; at least %d required", key.N.BitLen(), minRSABits)
+		}
+		sk = SigningKey{Method: jwt.SigningMethodRS256, Private: key}
+	case ed25519.PrivateKey:
+		sk = SigningKey{Method: jwt.SigningMethodEdDSA, Private: key}
+	default:
+		return SigningKey{}, fmt.Errorf("unsupported key type %T", key)
+	}
+	if sk.JWK, err = jwtverify.NewJWK(sk.Private.Public()); err != nil {
+		return SigningKey{}, err
+	}
+	return sk, nil
+}
+
+// LoadSigningKey reads a signing key from a PEM file.
+func LoadSigningKey(path string) (SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return SigningKey{}, err
+	}
+	sk, err := ParseSigningKey(data)
+	if err != nil {
+		return SigningKey{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return sk, nil
+}
+
+// Signer signs access tokens. With signing keys it signs with the first
+// and publishes all of them, so keys are rotated by putting the new key
+// first and dropping the old one once its tokens have expired. Without
+// keys it falls back to HS256 with the shared secret.
+type Signer struct {
+	secret []byte
+	keys   []SigningKey
+}
+
+// NewSigner returns a Signer for the given keys, falling back to secret
+// when there are none.
+func NewSigner(secret []byte, keys ...SigningKey) (*Signer, error) {
+	if len(keys) == 0 && len(secret) == 0 {
+		return nil, errors.New("no signing keys or secret")
+	}
+	seen := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		if seen[k.JWK.Kid] {
+			return nil, fmt.Errorf("signing key %s listed twice", k.JWK.Kid)
+		}
+		seen[k.JWK.Kid] = true
+	}
+	return &Signer{secret: secret, keys: keys}, nil
+}
+
+// Sign signs claims with the active key.
+func (s *Signer) Sign(claims jwt.Claims) (string, error) {
+	if len(s.keys) == 0 {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.secret)
+	}
+	k := s.keys[0]
+	token := jwt.NewWithClaims(k.Method, claims)
+	token.Header["kid"] = k.JWK.Kid
+	return token.SignedString(k.Private)
+}
+
+// JWKS lists the public half of every signing key.
+func (s *Signer) JWKS() jwtverify.JWKS {
+	set := jwtverify.JWKS{Keys: make([]jwtverify.JWK, 0, len(s.keys))}
+	for _, k := range s.keys {
+		set.Keys = append(set.Keys, k.JWK)
+	}
+	return set
+}
+
+// Key returns the public key with id kid, so the users service verifies
+// its own tokens without fetching its key set.
+func (s *Signer) Key(kid string) (crypto.PublicKey, error) {
+	for _, k := range s.keys {
+		if k.JWK.Kid == kid {
+			return k.Private.Public(), nil
+		}
+	}
+	return nil, jwtverify.ErrUnknownKey
+}
+
+// Verifier verifies tokens signed by s. HS256 tokens are still accepted
+// while the secret is set, so switching to signing keys does not log
+// everyone out.
+func (s *Signer) Verifier() *jwtverify.Verifier {
+	return jwtverify.New(s, s.secret)
+}
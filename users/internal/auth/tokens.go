@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// NewSessionID returns a random session id.
+func NewSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("session id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// NewRefreshToken returns a random refresh token and the hash to store for
+// it. The token itself is only ever given to the client.
+func NewRefreshToken() (token, hash string, err error) {
+	return newToken()
+}
+
+// HashRefreshToken returns the hash a refresh token is stored under.
+func HashRefreshToken(token string) string {
+	return hashToken(token)
+}
+
+// NewOneTimeToken returns a random token for an emailed link, such as an
+// email verification or password reset, and the hash to store for it.
+func NewOneTimeToken() (token, hash string, err error) {
+	return newToken()
+}
+
+// HashOneTimeToken returns the hash a one-time token is stored under.
+func HashOneTimeToken(token string) string {
+	return hashToken(token)
+}
+
+func newToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("random token: %w", err)
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, hashToken(token), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
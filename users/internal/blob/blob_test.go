@@ -0,0 +1,72 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocal(t *testing.T) {
+	ctx := context.Background()
+	l, err := NewLocal(filepath.Join(t.TempDir(), "avatars"), "/avatars/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.Put(ctx, "1-a.png", strings.NewReader("first")); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Put(ctx, "1-a.png", strings.NewReader("second")); err != nil {
+		t.Fatal(err)
+	}
+	if got := l.URL("1-a.png"); got != "/avatars/1-a.png" {
+		t.Fatalf("expected URL /avatars/1-a.png, got %q", got)
+	}
+
+	rr := httptest.NewRecorder()
+	l.Handler().ServeHTTP(rr, httptest.NewRequest("GET", "/1-a.png", nil))
+	body, _ := io.ReadAll(rr.Body)
+	if rr.Code != 200 || string(body) != "second" {
+		t.Fatalf("expected the replaced blob to be served, got %d %q", rr.Code, body)
+	}
+
+	// Only the blob is left behind, no temporary files
+	entries, _ := os.ReadDir(l.Dir)
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 file, found %d", len(entries))
+	}
+
+	if err := l.Delete(ctx, "1-a.png"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Delete(ctx, "1-a.png"); err != nil {
+		t.Fatalf("deleting a missing blob: %v", err)
+	}
+	rr = httptest.NewRecorder()
+	l.Handler().ServeHTTP(rr, httptest.NewRequest("GET", "/1-a.png", nil))
+	if rr.Code != 404 {
+		t.Fatalf("expected 404 for a deleted blob, got %d", rr.Code)
+	}
+}
+
+func TestLocalInvalidKeys(t *testing.T) {
+	ctx := context.Background()
+	l, err := NewLocal(t.TempDir(), "/avatars")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"", ".", "..", "../x", "a/b", `a\b`} {
+		if err := l.Put(ctx, key, strings.NewReader("x")); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Put(%q): expected ErrInvalidKey, got %v", key, err)
+		}
+		if err := l.Delete(ctx, key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Delete(%q): expected ErrInvalidKey, got %v", key, err)
+		}
+	}
+}
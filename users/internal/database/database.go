@@ -7,25 +7,35 @@ import (
 
 	"com.MixieMelts.users/internal/models"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-// DB represents the database connection.
+// DB represents the database connection pool.
 type DB struct {
-	*pgx.Conn
+	*pgxpool.Pool
 }
 
-// New creates a new database connection.
-func New(config string) (*DB, error) {
-	conn, err := pgx.Connect(context.Background(), config)
+// Open creates a connection pool without touching the schema.
+func Open(config string) (*DB, error) {
+	pool, err := pgxpool.New(context.Background(), config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
+		return nil, fmt.Errorf("failed to create connection pool: %w", err)
 	}
 
-	log.Println("Successfully connected to the database.")
+	log.Println("Successfully created database connection pool.")
+	return &DB{pool}, nil
+}
+
+// New connects to the database, applies pending migrations and seeds it.
+func New(config string) (*DB, error) {
+	dbWrapper, err := Open(config)
+	if err != nil {
+		return nil, err
+	}
 
-	dbWrapper := &DB{conn}
-	if err := dbWrapper.createTables(context.Background()); err != nil {
-		return nil, fmt.Errorf("failed to create tables: %w", err)
+	if _, err := dbWrapper.MigrateUp(context.Background()); err != nil {
+		dbWrapper.Close()
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
 
 	dbWrapper.Seed(context.Background())
@@ -33,42 +43,49 @@ func New(config string) (*DB, error) {
 	return dbWrapper, nil
 }
 
-func (db *DB) createTables(ctx context.Context) error {
-	query := `
-	CREATE TABLE IF NOT EXISTS users (
-		id SERIAL PRIMARY KEY,
-		username VARCHAR(255) NOT NULL,
-		email VARCHAR(255) UNIQUE NOT NULL,
-		password VARCHAR(255) NOT NULL,
-		is_admin BOOLEAN DEFAULT false,
-		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-	);
-	`
-	_, err := db.Exec(ctx, query)
-	return err
-}
+// CreateUser inserts a new user into the database, with profile when it is
+// not nil.
+func (db *DB) CreateUser(ctx context.Context, user *models.User, profile *models.Profile) (int64, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create user: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
 
-// CreateUser inserts a new user into the database.
-func (db *DB) CreateUser(ctx context.Context, user *models.User) (int64, error) {
 	query := `
-	INSERT INTO users (username, email, password, is_admin)
-	VALUES ($1, $2, $3, $4)
+	INSERT INTO users (username, email, password, is_admin, email_verified_at)
+	VALUES ($1, $2, $3, $4, $5)
 	RETURNING id;
 	`
 	var userID int64
-	err := db.QueryRow(ctx, query, user.Username, user.Email, user.Password, user.IsAdmin).Scan(&userID)
+	err = tx.QueryRow(ctx, query, user.Username, user.Email, user.Password, user.IsAdmin, user.EmailVerifiedAt).Scan(&userID)
 	if err != nil {
 		return 0, fmt.Errorf("failed to create user: %w", err)
 	}
+	if user.IsAdmin {
+		if _, err := tx.Exec(ctx, `INSERT INTO user_roles (user_id, role) VALUES ($1, 'admin')`, userID); err != nil {
+			return 0, fmt.Errorf("failed to grant admin role: %w", err)
+		}
+	}
+	if profile != nil {
+		_, err = tx.Exec(ctx, `INSERT INTO profiles (user_id, first_name, last_name, bio) VALUES ($1,$2,$3,$4)`,
+			userID, profile.FirstName, profile.LastName, profile.Bio)
+		if err != nil {
+			return 0, fmt.Errorf("failed to create profile: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to create user: %w", err)
+	}
 	return userID, nil
 }
 
 // GetUserByEmail retrieves a user from the database by their email.
 func (db *DB) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
 	user := &models.User{}
-	query := "SELECT id, username, email, password, is_admin, created_at, updated_at FROM users WHERE email = $1"
-	err := db.QueryRow(ctx, query, email).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.IsAdmin, &user.CreatedAt, &user.UpdatedAt)
+	query := "SELECT id, username, email, password, is_admin, email_verified_at, created_at, updated_at FROM users WHERE email = $1"
+	err := db.QueryRow(ctx, query, email).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.IsAdmin, &user.EmailVerifiedAt, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return user, fmt.Errorf("user not found in database with emal: %w", err)
@@ -81,8 +98,8 @@ func (db *DB) GetUserByEmail(ctx context.Context, email string) (*models.User, e
 // GetUserByID retrieves a user from the database by their ID.
 func (db *DB) GetUserByID(ctx context.Context, id int64) (*models.User, error) {
 	user := &models.User{}
-	query := "SELECT id, username, email, password, is_admin, created_at, updated_at FROM users WHERE id = $1"
-	err := db.QueryRow(ctx, query, id).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.IsAdmin, &user.CreatedAt, &user.UpdatedAt)
+	query := "SELECT id, username, email, password, is_admin, email_verified_at, created_at, updated_at FROM users WHERE id = $1"
+	err := db.QueryRow(ctx, query, id).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.IsAdmin, &user.EmailVerifiedAt, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, nil // No user found is not an error
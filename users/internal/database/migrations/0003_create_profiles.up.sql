@@ -0,0 +1,10 @@
+-- A user's optional profile. Users without a row have an empty profile.
+-- avatar_key names the avatar image in the blob store.
+CREATE TABLE profiles (
+	user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+	first_name VARCHAR(100) NOT NULL DEFAULT '',
+	last_name VARCHAR(100) NOT NULL DEFAULT '',
+	bio TEXT NOT NULL DEFAULT '',
+	avatar_key TEXT NOT NULL DEFAULT '',
+	updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
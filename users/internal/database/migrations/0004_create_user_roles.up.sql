@@ -0,0 +1,12 @@
+-- Roles granted to users beyond "customer", which everyone holds.
+-- users.is_admin is kept in step with the "admin" role for code that still
+-- reads it.
+CREATE TABLE user_roles (
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	role TEXT NOT NULL,
+	granted_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+	granted_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (user_id, role)
+);
+
+INSERT INTO user_roles (user_id, role) SELECT id, 'admin' FROM users WHERE is_admin;
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"com.MixieMelts.users/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrUserNotFound is returned when updating the profile of a user that does
+// not exist.
+var ErrUserNotFound = errors.New("user not found")
+
+// GetProfile returns a user's profile, which is empty if they never set one.
+// AvatarURL is left for the caller to fill in from AvatarKey.
+func (db *DB) GetProfile(ctx context.Context, userID int64) (*models.Profile, error) {
+	p := &models.Profile{UserID: userID}
+	err := db.QueryRow(ctx, `SELECT first_name, last_name, bio, avatar_key FROM profiles WHERE user_id = $1`, userID).
+		Scan(&p.FirstName, &p.LastName, &p.Bio, &p.AvatarKey)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("GetProfile: %w", err)
+	}
+	return p, nil
+}
+
+// UpdateProfile applies upd to a user and their profile in one transaction
+// and returns both as updated.
+func (db *DB) UpdateProfile(ctx context.Context, userID int64, upd models.ProfileUpdate) (*models.UserProfile, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("UpdateProfile begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var out models.UserProfile
+	u := &out.User
+	err = tx.QueryRow(ctx, `UPDATE users SET
+			username = COALESCE($2::text, username),
+			updated_at = CASE WHEN $2::text IS NULL THEN updated_at ELSE CURRENT_TIMESTAMP END
+		WHERE id = $1
+		RETURNING id, username, email, is_admin, email_verified_at, created_at, updated_at`, userID, upd.Username).
+		Scan(&u.ID, &u.Username, &u.Email, &u.IsAdmin, &u.EmailVerifiedAt, &u.CreatedAt, &u.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("UpdateProfile user: %w", err)
+	}
+
+	p := &out.Profile
+	p.UserID = userID
+	err = tx.QueryRow(ctx, `INSERT INTO profiles (user_id, first_name, last_name, bio)
+		VALUES ($1, COALESCE($2, ''), COALESCE($3, ''), COALESCE($4, ''))
+		ON CONFLICT (user_id) DO UPDATE SET
+			first_name = COALESCE($2, profiles.first_name),
+			last_name = COALESCE($3, profiles.last_name),
+			bio = COALESCE($4, profiles.bio),
+			updated_at = CURRENT_TIMESTAMP
+		RETURNING first_name, last_name, bio, avatar_key`, userID, upd.FirstName, upd.LastName, upd.Bio).
+		Scan(&p.FirstName, &p.LastName, &p.Bio, &p.AvatarKey)
+	if err != nil {
+		return nil, fmt.Errorf("UpdateProfile profile: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("UpdateProfile commit: %w", err)
+	}
+	return &out, nil
+}
+
+// SetAvatar points a user's profile at the avatar stored under key, or at
+// none when key is empty, and returns the key it replaced.
+func (db *DB) SetAvatar(ctx context.Context, userID int64, key string) (string, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return "", fmt.Errorf("SetAvatar begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var old string
+	err = tx.QueryRow(ctx, `SELECT avatar_key FROM profiles WHERE user_id = $1 FOR UPDATE`, userID).Scan(&old)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return "", fmt.Errorf("SetAvatar lock: %w", err)
+	}
+	_, err = tx.Exec(ctx, `INSERT INTO profiles (user_id, avatar_key) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET avatar_key = EXCLUDED.avatar_key, updated_at = CURRENT_TIMESTAMP`, userID, key)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return "", ErrUserNotFound
+		}
+		return "", fmt.Errorf("SetAvatar: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return "", fmt.Errorf("SetAvatar commit: %w", err)
+	}
+	return old, nil
+}
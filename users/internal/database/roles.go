@@ -0,0 +1,111 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"com.MixieMelts.users/jwtverify"
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrLastAdmin is returned when revoking the admin role from the only user
+// who holds it, which would leave nobody able to grant roles.
+var ErrLastAdmin = errors.New("cannot revoke the last admin")
+
+// GetUserRoles returns a user's roles, sorted, starting with the customer
+// role every user holds.
+func (db *DB) GetUserRoles(ctx context.Context, userID int64) ([]string, error) {
+	rows, err := db.Query(ctx, `SELECT role FROM user_roles WHERE user_id = $1 ORDER BY role`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("GetUserRoles: %w", err)
+	}
+	roles, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("GetUserRoles: %w", err)
+	}
+	return append([]string{jwtverify.RoleCustomer}, roles...), nil
+}
+
+// GrantRole gives a user a role on behalf of grantedBy and reports whether
+// they did not already hold it.
+func (db *DB) GrantRole(ctx context.Context, userID int64, role string, grantedBy int64) (bool, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("GrantRole begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := lockUserTx(ctx, tx, userID); err != nil {
+		return false, err
+	}
+	tag, err := tx.Exec(ctx, `INSERT INTO user_roles (user_id, role, granted_by) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, role) DO NOTHING`, userID, role, grantedBy)
+	if err != nil {
+		return false, fmt.Errorf("GrantRole insert: %w", err)
+	}
+	if role == jwtverify.RoleAdmin {
+		if _, err := tx.Exec(ctx, `UPDATE users SET is_admin = true, updated_at = CURRENT_TIMESTAMP WHERE id = $1`, userID); err != nil {
+			return false, fmt.Errorf("GrantRole is_admin: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("GrantRole commit: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// RevokeRole takes a role from a user and reports whether they held it.
+func (db *DB) RevokeRole(ctx context.Context, userID int64, role string) (bool, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("RevokeRole begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if role == jwtverify.RoleAdmin {
+		// Serialise admin revocations so two admins cannot revoke each
+		// other at once and leave none
+		if _, err := tx.Exec(ctx, `SELECT 1 FROM user_roles WHERE role = $1 FOR UPDATE`, role); err != nil {
+			return false, fmt.Errorf("RevokeRole lock: %w", err)
+		}
+	}
+	if err := lockUserTx(ctx, tx, userID); err != nil {
+		return false, err
+	}
+	tag, err := tx.Exec(ctx, `DELETE FROM user_roles WHERE user_id = $1 AND role = $2`, userID, role)
+	if err != nil {
+		return false, fmt.Errorf("RevokeRole delete: %w", err)
+	}
+	if role == jwtverify.RoleAdmin && tag.RowsAffected() == 1 {
+		var admins int
+		if err := tx.QueryRow(ctx, `SELECT COUNT(*) FROM user_roles WHERE role = $1`, role).Scan(&admins); err != nil {
+			return false, fmt.Errorf("RevokeRole count: %w", err)
+		}
+		if admins == 0 {
+			return false, ErrLastAdmin
+		}
+		if _, err := tx.Exec(ctx, `UPDATE users SET is_admin = false, updated_at = CURRENT_TIMESTAMP WHERE id = $1`, userID); err != nil {
+			return false, fmt.Errorf("RevokeRole is_admin: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("RevokeRole commit: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// lockUserTx locks a user's row, returning ErrUserNotFound if there is none.
+func lockUserTx(ctx context.Context, tx pgx.Tx, userID int64) error {
+	var id int64
+	err := tx.QueryRow(ctx, `SELECT id FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("lock user %d: %w", userID, err)
+	}
+	return nil
+}
@@ -46,7 +46,7 @@ func (db *DB) Seed(ctx context.Context) {
 		}
 		user.Password = string(hashedPassword)
 
-		_, err = db.CreateUser(ctx, &user)
+		_, err = db.CreateUser(ctx, &user, nil)
 		if err != nil {
 			log.Printf("failed to create user %s: %v", user.Username, err)
 		}
@@ -0,0 +1,172 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"com.MixieMelts.users/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	// ErrInvalidRefreshToken is returned for a refresh token that is
+	// unknown, expired or belongs to a revoked session.
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrRefreshTokenReused is returned when a refresh token that was
+	// already rotated is presented again. Its session has been revoked.
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
+// Revoke reasons recorded on sessions.
+const (
+	RevokeLogout        = "logout"
+	RevokeLogoutAll     = "logout_all"
+	RevokeReuse         = "refresh_token_reuse"
+	RevokePasswordReset = "password_reset"
+)
+
+const sessionColumns = `id, user_id, user_agent, created_at, last_used_at, expires_at, revoked_at, revoke_reason`
+
+func scanSession(row pgx.Row) (*models.Session, error) {
+	var s models.Session
+	if err := row.Scan(&s.ID, &s.UserID, &s.UserAgent, &s.CreatedAt, &s.LastUsedAt, &s.ExpiresAt, &s.RevokedAt, &s.RevokeReason); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// CreateSession starts a session for a user with its first refresh token,
+// stored by hash, valid until expiresAt.
+func (db *DB) CreateSession(ctx context.Context, s *models.Session, refreshHash string) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("CreateSession begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	err = tx.QueryRow(ctx, `INSERT INTO sessions (id, user_id, user_agent, expires_at) VALUES ($1,$2,$3,$4)
+		RETURNING created_at, last_used_at`, s.ID, s.UserID, s.UserAgent, s.ExpiresAt).Scan(&s.CreatedAt, &s.LastUsedAt)
+	if err != nil {
+		return fmt.Errorf("CreateSession insert: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `INSERT INTO refresh_tokens (token_hash, session_id, expires_at) VALUES ($1,$2,$3)`,
+		refreshHash, s.ID, s.ExpiresAt); err != nil {
+		return fmt.Errorf("CreateSession refresh token: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("CreateSession commit: %w", err)
+	}
+	return nil
+}
+
+// RotateRefreshToken exchanges the refresh token stored as oldHash for one
+// stored as newHash, valid until expiresAt, and returns its session. The old
+// token is kept as rotated rather than deleted, so presenting it again is
+// recognised as reuse: the session is revoked and ErrRefreshTokenReused
+// returned, since either the client or an attacker holds a stolen token.
+func (db *DB) RotateRefreshToken(ctx context.Context, oldHash, newHash string, expiresAt time.Time) (*models.Session, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("RotateRefreshToken begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var (
+		sessionID    string
+		tokenExpires time.Time
+		rotatedAt    *time.Time
+	)
+	err = tx.QueryRow(ctx, `SELECT session_id, expires_at, rotated_at FROM refresh_tokens WHERE token_hash = $1`, oldHash).
+		Scan(&sessionID, &tokenExpires, &rotatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("RotateRefreshToken lookup: %w", err)
+	}
+
+	// Lock the session so two refreshes with the same token serialize and
+	// the second sees the first's rotation
+	s, err := scanSession(tx.QueryRow(ctx, `SELECT `+sessionColumns+` FROM sessions WHERE id = $1 FOR UPDATE`, sessionID))
+	if err != nil {
+		return nil, fmt.Errorf("RotateRefreshToken session: %w", err)
+	}
+	if s.RevokedAt != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err := tx.QueryRow(ctx, `SELECT rotated_at FROM refresh_tokens WHERE token_hash = $1`, oldHash).Scan(&rotatedAt); err != nil {
+		return nil, fmt.Errorf("RotateRefreshToken recheck: %w", err)
+	}
+	if rotatedAt != nil {
+		if err := revokeSessionTx(ctx, tx, s.ID, RevokeReuse); err != nil {
+			return nil, fmt.Errorf("RotateRefreshToken: %w", err)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return nil, fmt.Errorf("RotateRefreshToken commit: %w", err)
+		}
+		return nil, ErrRefreshTokenReused
+	}
+	if !time.Now().Before(tokenExpires) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE refresh_tokens SET rotated_at = NOW() WHERE token_hash = $1`, oldHash); err != nil {
+		return nil, fmt.Errorf("RotateRefreshToken rotate: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `INSERT INTO refresh_tokens (token_hash, session_id, expires_at) VALUES ($1,$2,$3)`,
+		newHash, s.ID, expiresAt); err != nil {
+		return nil, fmt.Errorf("RotateRefreshToken insert: %w", err)
+	}
+	err = tx.QueryRow(ctx, `UPDATE sessions SET last_used_at = NOW(), expires_at = $1 WHERE id = $2 RETURNING last_used_at, expires_at`,
+		expiresAt, s.ID).Scan(&s.LastUsedAt, &s.ExpiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("RotateRefreshToken touch session: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("RotateRefreshToken commit: %w", err)
+	}
+	return s, nil
+}
+
+func revokeSessionTx(ctx context.Context, tx pgx.Tx, id, reason string) error {
+	_, err := tx.Exec(ctx, `UPDATE sessions SET revoked_at = NOW(), revoke_reason = $1 WHERE id = $2 AND revoked_at IS NULL`, reason, id)
+	return err
+}
+
+// RevokeSession ends one of a user's sessions. It reports false if the user
+// has no such active session.
+func (db *DB) RevokeSession(ctx context.Context, userID int64, id, reason string) (bool, error) {
+	tag, err := db.Exec(ctx, `UPDATE sessions SET revoked_at = NOW(), revoke_reason = $1
+		WHERE id = $2 AND user_id = $3 AND revoked_at IS NULL`, reason, id, userID)
+	if err != nil {
+		return false, fmt.Errorf("RevokeSession: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// RevokeUserSessions ends every active session of a user and returns how
+// many there were.
+func (db *DB) RevokeUserSessions(ctx context.Context, userID int64, reason string) (int, error) {
+	tag, err := db.Exec(ctx, `UPDATE sessions SET revoked_at = NOW(), revoke_reason = $1
+		WHERE user_id = $2 AND revoked_at IS NULL`, reason, userID)
+	if err != nil {
+		return 0, fmt.Errorf("RevokeUserSessions: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// SessionActive reports whether a session exists and is neither revoked nor
+// expired.
+func (db *DB) SessionActive(ctx context.Context, id string) (bool, error) {
+	var active bool
+	err := db.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM sessions WHERE id = $1 AND revoked_at IS NULL AND expires_at > NOW())`, id).
+		Scan(&active)
+	if err != nil {
+		return false, fmt.Errorf("SessionActive: %w", err)
+	}
+	return active, nil
+}
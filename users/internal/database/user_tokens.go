@@ -0,0 +1,131 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrInvalidToken is returned for an emailed token that is unknown,
+// expired, already used or meant for something else.
+var ErrInvalidToken = errors.New("invalid token")
+
+// Purposes of emailed tokens.
+const (
+	TokenVerifyEmail   = "verify_email"
+	TokenResetPassword = "reset_password"
+)
+
+// CreateUserToken stores a token for purpose, by hash, valid until
+// expiresAt. Tokens issued to the user for the same purpose before it stop
+// working, so only the latest email is good.
+func (db *DB) CreateUserToken(ctx context.Context, userID int64, purpose, hash string, expiresAt time.Time) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("CreateUserToken begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := expireUserTokensTx(ctx, tx, userID, purpose); err != nil {
+		return fmt.Errorf("CreateUserToken expire: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `INSERT INTO user_tokens (token_hash, user_id, purpose, expires_at) VALUES ($1,$2,$3,$4)`,
+		hash, userID, purpose, expiresAt); err != nil {
+		return fmt.Errorf("CreateUserToken insert: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("CreateUserToken commit: %w", err)
+	}
+	return nil
+}
+
+func expireUserTokensTx(ctx context.Context, tx pgx.Tx, userID int64, purpose string) error {
+	_, err := tx.Exec(ctx, `UPDATE user_tokens SET used_at = NOW() WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL`,
+		userID, purpose)
+	return err
+}
+
+// consumeUserTokenTx marks the token stored as hash used and returns its
+// user, or ErrInvalidToken. Marking it in the same statement that checks it
+// means two requests with one token cannot both succeed.
+func consumeUserTokenTx(ctx context.Context, tx pgx.Tx, purpose, hash string) (int64, error) {
+	var userID int64
+	err := tx.QueryRow(ctx, `UPDATE user_tokens SET used_at = NOW()
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id`, hash, purpose).Scan(&userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrInvalidToken
+	}
+	if err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+// VerifyEmail uses the email verification token stored as hash and marks
+// its user's email verified. It returns the user's id.
+func (db *DB) VerifyEmail(ctx context.Context, hash string) (int64, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("VerifyEmail begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	userID, err := consumeUserTokenTx(ctx, tx, TokenVerifyEmail, hash)
+	if errors.Is(err, ErrInvalidToken) {
+		return 0, err
+	}
+	if err != nil {
+		return 0, fmt.Errorf("VerifyEmail token: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `UPDATE users SET email_verified_at = COALESCE(email_verified_at, NOW()) WHERE id = $1`,
+		userID); err != nil {
+		return 0, fmt.Errorf("VerifyEmail user: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("VerifyEmail commit: %w", err)
+	}
+	return userID, nil
+}
+
+// ResetPassword uses the password reset token stored as hash to set its
+// user's password to passwordHash. Every session of the user is revoked,
+// and so are their other reset tokens. Receiving the email also proves
+// they own the address, so it is marked verified. It returns the user's id.
+func (db *DB) ResetPassword(ctx context.Context, hash, passwordHash string) (int64, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("ResetPassword begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	userID, err := consumeUserTokenTx(ctx, tx, TokenResetPassword, hash)
+	if errors.Is(err, ErrInvalidToken) {
+		return 0, err
+	}
+	if err != nil {
+		return 0, fmt.Errorf("ResetPassword token: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `UPDATE users SET password = $1, updated_at = CURRENT_TIMESTAMP,
+			email_verified_at = COALESCE(email_verified_at, NOW())
+		WHERE id = $2`, passwordHash, userID); err != nil {
+		return 0, fmt.Errorf("ResetPassword user: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `UPDATE sessions SET revoked_at = NOW(), revoke_reason = $1
+		WHERE user_id = $2 AND revoked_at IS NULL`, RevokePasswordReset, userID); err != nil {
+		return 0, fmt.Errorf("ResetPassword sessions: %w", err)
+	}
+	if err := expireUserTokensTx(ctx, tx, userID, TokenResetPassword); err != nil {
+		return 0, fmt.Errorf("ResetPassword expire: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("ResetPassword commit: %w", err)
+	}
+	return userID, nil
+}
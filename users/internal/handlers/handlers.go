@@ -5,16 +5,25 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	"com.MixieMelts.users/internal/auth"
+	"com.MixieMelts.users/internal/blob"
+	"com.MixieMelts.users/internal/database"
+	"com.MixieMelts.users/internal/mail"
 	"com.MixieMelts.users/internal/models"
-	"github.com/davecgh/go-spew/spew"
+	"com.MixieMelts.users/jwtverify"
+	"github.com/go-chi/chi/v5"
 	"github.com/golang-jwt/jwt/v4"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -23,59 +32,155 @@ const oauthStateCookieName = "oauthstate"
 
 type DBLayer interface {
 	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
-	CreateUser(ctx context.Context, user *models.User) (int64, error)
+	CreateUser(ctx context.Context, user *models.User, profile *models.Profile) (int64, error)
 	GetUserByID(ctx context.Context, id int64) (*models.User, error)
+
+	GetProfile(ctx context.Context, userID int64) (*models.Profile, error)
+	UpdateProfile(ctx context.Context, userID int64, upd models.ProfileUpdate) (*models.UserProfile, error)
+	SetAvatar(ctx context.Context, userID int64, key string) (string, error)
+
+	GetUserRoles(ctx context.Context, userID int64) ([]string, error)
+	GrantRole(ctx context.Context, userID int64, role string, grantedBy int64) (bool, error)
+	RevokeRole(ctx context.Context, userID int64, role string) (bool, error)
+
+	CreateSession(ctx context.Context, s *models.Session, refreshHash string) error
+	RotateRefreshToken(ctx context.Context, oldHash, newHash string, expiresAt time.Time) (*models.Session, error)
+	RevokeSession(ctx context.Context, userID int64, id, reason string) (bool, error)
+	RevokeUserSessions(ctx context.Context, userID int64, reason string) (int, error)
+	SessionActive(ctx context.Context, id string) (bool, error)
+
+	CreateUserToken(ctx context.Context, userID int64, purpose, hash string, expiresAt time.Time) error
+	VerifyEmail(ctx context.Context, hash string) (int64, error)
+	ResetPassword(ctx context.Context, hash, passwordHash string) (int64, error)
+}
+
+// Default token lifetimes.
+const (
+	DefaultAccessTokenTTL        = 15 * time.Minute
+	DefaultRefreshTokenTTL       = 30 * 24 * time.Hour
+	DefaultVerifyEmailTokenTTL   = 48 * time.Hour
+	DefaultPasswordResetTokenTTL = time.Hour
+)
+
+// TokenTTLs sets how long issued tokens last, including the ones sent by
+// email. Zero values take the defaults.
+type TokenTTLs struct {
+	Access        time.Duration
+	Refresh       time.Duration
+	VerifyEmail   time.Duration
+	PasswordReset time.Duration
+}
+
+// Emails configures the mail the service sends.
+type Emails struct {
+	Mailer mail.Mailer
+	// AppURL is the frontend's base URL. Emailed links open its
+	// /verify-email and /reset-password pages with the token in the query.
+	AppURL string
 }
 
 type Handler struct {
-	db           DBLayer
-	jwtSecretKey []byte
+	db       DBLayer
+	signer   *auth.Signer
+	verifier *jwtverify.Verifier
+	avatars  blob.Store
+	emails   Emails
+	ttls     TokenTTLs
+
+	// background tracks work a handler finishes after responding, such as
+	// sending password reset emails.
+	background sync.WaitGroup
 }
 
-func New(db DBLayer, jwtSecretKey []byte) *Handler {
-	return &Handler{db: db, jwtSecretKey: jwtSecretKey}
+func New(db DBLayer, signer *auth.Signer, avatars blob.Store, emails Emails, ttls TokenTTLs) *Handler {
+	if ttls.Access <= 0 {
+		ttls.Access = DefaultAccessTokenTTL
+	}
+	if ttls.Refresh <= 0 {
+		ttls.Refresh = DefaultRefreshTokenTTL
+	}
+	if ttls.VerifyEmail <= 0 {
+		ttls.VerifyEmail = DefaultVerifyEmailTokenTTL
+	}
+	if ttls.PasswordReset <= 0 {
+		ttls.PasswordReset = DefaultPasswordResetTokenTTL
+	}
+	emails.AppURL = strings.TrimSuffix(emails.AppURL, "/")
+	return &Handler{db: db, signer: signer, verifier: signer.Verifier(), avatars: avatars, emails: emails, ttls: ttls}
 }
 
 // --- HANDLERS ---
 // In a real app, these would be in an 'api' or 'handlers' package.
 
-// RegisterUser handles new user creation.
+// RegisterUser handles new user creation and emails the new user a link to
+// verify their address. It responds the same whether or not the email is
+// already registered, so it cannot be used to find out who has an account.
 func (h *Handler) RegisterUser(w http.ResponseWriter, r *http.Request) {
-	var creds models.Credentials
-	if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
+	var reg models.Registration
+	if err := json.NewDecoder(r.Body).Decode(&reg); err != nil {
 		respondWithError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
+	creds := reg.Credentials
+	if msg := validatePassword(creds.Password); msg != "" {
+		respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(creds.Password), bcrypt.DefaultCost)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Failed to hash password")
+	upd := models.ProfileUpdate{FirstName: &reg.FirstName, LastName: &reg.LastName}
+	if strings.TrimSpace(reg.Username) != "" {
+		upd.Username = &reg.Username
+	}
+	if msg := validateProfileUpdate(&upd); msg != "" {
+		respondWithError(w, http.StatusBadRequest, msg)
 		return
 	}
+	username, _, _ := strings.Cut(creds.Email, "@")
+	if upd.Username != nil {
+		username = *upd.Username
+	}
 
-	// Check if user already exists
-	_, err = h.db.GetUserByEmail(r.Context(), creds.Email)
-	if err == nil {
-		respondWithError(w, http.StatusConflict, "User with this email already exists")
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(creds.Password), bcrypt.DefaultCost)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to hash password")
 		return
 	}
 
 	newUser := &models.User{
 		Email:    creds.Email,
 		Password: string(hashedPassword),
-		Username: "New User", // In a real app, you'd get this from the request
+		Username: username,
 	}
-
-	userID, err := h.db.CreateUser(r.Context(), newUser)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Failed to create user")
-		return
+	var profile *models.Profile
+	if *upd.FirstName != "" || *upd.LastName != "" {
+		profile = &models.Profile{FirstName: *upd.FirstName, LastName: *upd.LastName}
 	}
 
-	newUser.ID = userID
-
-	log.Printf("User registered: %s", creds.Email)
-	respondWithJSON(w, http.StatusCreated, newUser)
+	// As with password resets, the lookup, the insert and the email happen
+	// after responding, so neither the answer nor its timing tells whether
+	// the email already has an account
+	ctx := context.WithoutCancel(r.Context())
+	h.background.Add(1)
+	go func() {
+		defer h.background.Done()
+		if _, err := h.db.GetUserByEmail(ctx, newUser.Email); err == nil {
+			return
+		}
+		userID, err := h.db.CreateUser(ctx, newUser, profile)
+		if err != nil {
+			log.Printf("RegisterUser error: %v", err)
+			return
+		}
+		newUser.ID = userID
+		log.Printf("User registered: %s", newUser.Email)
+		// The account is usable either way; the user can ask for another email
+		if err := h.sendVerificationEmail(ctx, newUser); err != nil {
+			log.Printf("RegisterUser verification email error: %v", err)
+		}
+	}()
+	respondWithJSON(w, http.StatusAccepted, map[string]string{
+		"message": "If the email is not already registered, a link to verify it has been sent",
+	})
 }
 
 // LoginUser handles user authentication and issues a JWT.
@@ -88,9 +193,7 @@ func (h *Handler) LoginUser(w http.ResponseWriter, r *http.Request) {
 
 	user, err := h.db.GetUserByEmail(r.Context(), creds.Email)
 	if err != nil || user == nil {
-		spew.Dump(user)
-		spew.Dump(creds)
-		respondWithError(w, http.StatusUnauthorized, "Invalid credentialzorz!")
+		respondWithError(w, http.StatusUnauthorized, "Invalid credentials")
 		return
 	}
 
@@ -99,7 +202,82 @@ func (h *Handler) LoginUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	h.issueJWT(w, *user)
+	h.issueTokens(w, r, *user)
+}
+
+// RefreshToken exchanges a refresh token for a new access and refresh token
+// pair. Each refresh token works once; presenting a used one again revokes
+// its session, logging out every holder of it.
+func (h *Handler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		respondWithError(w, http.StatusBadRequest, "refresh_token required")
+		return
+	}
+
+	refresh, refreshHash, err := auth.NewRefreshToken()
+	if err != nil {
+		log.Printf("RefreshToken error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to create token")
+		return
+	}
+	session, err := h.db.RotateRefreshToken(r.Context(), auth.HashRefreshToken(req.RefreshToken), refreshHash, time.Now().Add(h.ttls.Refresh))
+	switch {
+	case errors.Is(err, database.ErrRefreshTokenReused):
+		log.Printf("Refresh token reuse detected; session revoked")
+		respondWithError(w, http.StatusUnauthorized, "Refresh token already used; session revoked")
+		return
+	case errors.Is(err, database.ErrInvalidRefreshToken):
+		respondWithError(w, http.StatusUnauthorized, "Invalid refresh token")
+		return
+	case err != nil:
+		log.Printf("RefreshToken error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to refresh token")
+		return
+	}
+
+	user, err := h.db.GetUserByID(r.Context(), session.UserID)
+	if err != nil || user == nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid refresh token")
+		return
+	}
+	h.respondWithTokens(w, r, *user, session.ID, refresh)
+}
+
+// Logout revokes the session of the access token used to call it.
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.Context().Value("userID").(string), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	sessionID, _ := r.Context().Value("sessionID").(string)
+
+	if _, err := h.db.RevokeSession(r.Context(), userID, sessionID, database.RevokeLogout); err != nil {
+		log.Printf("Logout error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to log out")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LogoutAll revokes every session of the calling user, on every device.
+func (h *Handler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.Context().Value("userID").(string), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	n, err := h.db.RevokeUserSessions(r.Context(), userID, database.RevokeLogoutAll)
+	if err != nil {
+		log.Printf("LogoutAll error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to log out")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]int{"revoked": n})
 }
 
 func respondWithError(w http.ResponseWriter, code int, message string) {
@@ -127,13 +305,464 @@ func (h *Handler) GetUserProfile(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Fetching profile for user ID: %d", userID)
 
 	user, err := h.db.GetUserByID(r.Context(), userID)
-	if err != nil {
+	if err != nil || user == nil {
 		http.Error(w, "User not found", http.StatusNotFound)
 		return
 	}
+	profile, err := h.db.GetProfile(r.Context(), userID)
+	if err != nil {
+		log.Printf("GetUserProfile error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch profile")
+		return
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(user)
+	respondWithJSON(w, http.StatusOK, h.userProfile(models.UserProfile{User: *user, Profile: *profile}))
+}
+
+// --- PROFILE HANDLERS ---
+
+// Profile field limits.
+const (
+	minUsernameLen = 3
+	maxUsernameLen = 50
+	maxNameLen     = 100
+	maxBioLen      = 1000
+
+	maxAvatarBytes = 2 << 20
+)
+
+// minPasswordLen is the shortest password an account may be given.
+const minPasswordLen = 8
+
+// validatePassword returns a message if password is too weak to set, or "".
+func validatePassword(password string) string {
+	if utf8.RuneCountInString(password) < minPasswordLen {
+		return fmt.Sprintf("Password must be at least %d characters", minPasswordLen)
+	}
+	return ""
+}
+
+// avatarTypes are the image types accepted as avatars, by the extension
+// they are stored with.
+var avatarTypes = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// validateProfileUpdate trims the fields of upd in place and returns a
+// message for the first invalid one, or "".
+func validateProfileUpdate(upd *models.ProfileUpdate) string {
+	for _, f := range []*string{upd.Username, upd.FirstName, upd.LastName, upd.Bio} {
+		if f != nil {
+			*f = strings.TrimSpace(*f)
+		}
+	}
+	switch {
+	case upd.Username != nil && utf8.RuneCountInString(*upd.Username) < minUsernameLen:
+		return fmt.Sprintf("Username must be at least %d characters", minUsernameLen)
+	case upd.Username != nil && utf8.RuneCountInString(*upd.Username) > maxUsernameLen:
+		return fmt.Sprintf("Username must be at most %d characters", maxUsernameLen)
+	case upd.FirstName != nil && utf8.RuneCountInString(*upd.FirstName) > maxNameLen,
+		upd.LastName != nil && utf8.RuneCountInString(*upd.LastName) > maxNameLen:
+		return fmt.Sprintf("Names must be at most %d characters", maxNameLen)
+	case upd.Bio != nil && utf8.RuneCountInString(*upd.Bio) > maxBioLen:
+		return fmt.Sprintf("Bio must be at most %d characters", maxBioLen)
+	}
+	return ""
+}
+
+// userProfile fills in the avatar URL of up.
+func (h *Handler) userProfile(up models.UserProfile) models.UserProfile {
+	if up.Profile.AvatarKey != "" {
+		up.Profile.AvatarURL = h.avatars.URL(up.Profile.AvatarKey)
+	}
+	return up
+}
+
+// UpdateUserProfile edits the caller's username and profile fields.
+func (h *Handler) UpdateUserProfile(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.Context().Value("userID").(string), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	var upd models.ProfileUpdate
+	if err := json.NewDecoder(r.Body).Decode(&upd); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if msg := validateProfileUpdate(&upd); msg != "" {
+		respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	up, err := h.db.UpdateProfile(r.Context(), userID, upd)
+	if errors.Is(err, database.ErrUserNotFound) {
+		respondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		log.Printf("UpdateUserProfile error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to update profile")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, h.userProfile(*up))
+}
+
+// UploadAvatar replaces the caller's avatar with the image in the "avatar"
+// field of a multipart form, and deletes the one it replaced.
+func (h *Handler) UploadAvatar(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.Context().Value("userID").(string), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxAvatarBytes+1<<10)
+	file, _, err := r.FormFile("avatar")
+	if err != nil {
+		var tooBig *http.MaxBytesError
+		if errors.As(err, &tooBig) {
+			respondWithError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Avatar must be at most %d bytes", maxAvatarBytes))
+			return
+		}
+		respondWithError(w, http.StatusBadRequest, "avatar file required")
+		return
+	}
+	defer file.Close()
+
+	// The type is sniffed from the content; the client's claim is ignored
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		respondWithError(w, http.StatusBadRequest, "avatar file required")
+		return
+	}
+	ext, ok := avatarTypes[http.DetectContentType(head[:n])]
+	if !ok {
+		respondWithError(w, http.StatusUnsupportedMediaType, "Avatar must be a PNG, JPEG, GIF or WebP image")
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		log.Printf("UploadAvatar error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to store avatar")
+		return
+	}
+
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		log.Printf("UploadAvatar error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to store avatar")
+		return
+	}
+	// A new key per upload, so cached copies of the old avatar never linger
+	key := fmt.Sprintf("%d-%x%s", userID, suffix, ext)
+	if err := h.avatars.Put(r.Context(), key, file); err != nil {
+		log.Printf("UploadAvatar error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to store avatar")
+		return
+	}
+
+	old, err := h.db.SetAvatar(r.Context(), userID, key)
+	if err != nil {
+		if delErr := h.avatars.Delete(r.Context(), key); delErr != nil {
+			log.Printf("UploadAvatar cleanup error: %v", delErr)
+		}
+		if errors.Is(err, database.ErrUserNotFound) {
+			respondWithError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		log.Printf("UploadAvatar error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to store avatar")
+		return
+	}
+	if old != "" {
+		if err := h.avatars.Delete(r.Context(), old); err != nil {
+			log.Printf("UploadAvatar cleanup error: %v", err)
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"avatar_url": h.avatars.URL(key)})
+}
+
+// --- ROLE HANDLERS ---
+// These are for callers with the roles:manage permission.
+
+// userRoles responds with the roles of the user in the {id} URL parameter.
+func (h *Handler) userRoles(w http.ResponseWriter, r *http.Request, userID int64) {
+	roles, err := h.db.GetUserRoles(r.Context(), userID)
+	if err != nil {
+		log.Printf("userRoles error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch roles")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, models.UserRoles{
+		UserID:      userID,
+		Roles:       roles,
+		Permissions: jwtverify.PermissionsFor(roles),
+	})
+}
+
+// roleParams parses the {id} and {role} URL parameters, responding with an
+// error and returning ok false if either is invalid.
+func roleParams(w http.ResponseWriter, r *http.Request) (userID int64, role string, ok bool) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return 0, "", false
+	}
+	role = chi.URLParam(r, "role")
+	if !jwtverify.ValidRole(role) {
+		respondWithError(w, http.StatusBadRequest, "Unknown role: "+role)
+		return 0, "", false
+	}
+	if role == jwtverify.RoleCustomer {
+		respondWithError(w, http.StatusBadRequest, "Every user is a customer")
+		return 0, "", false
+	}
+	return userID, role, true
+}
+
+// GetUserRoles lists a user's roles.
+func (h *Handler) GetUserRoles(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	user, err := h.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		log.Printf("GetUserRoles error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch user")
+		return
+	}
+	if user == nil {
+		respondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	h.userRoles(w, r, userID)
+}
+
+// GrantUserRole gives a user a role. Their tokens carry it from their next
+// login or refresh.
+func (h *Handler) GrantUserRole(w http.ResponseWriter, r *http.Request) {
+	userID, role, ok := roleParams(w, r)
+	if !ok {
+		return
+	}
+	grantedBy, _ := strconv.ParseInt(r.Context().Value("userID").(string), 10, 64)
+
+	granted, err := h.db.GrantRole(r.Context(), userID, role, grantedBy)
+	if errors.Is(err, database.ErrUserNotFound) {
+		respondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		log.Printf("GrantUserRole error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to grant role")
+		return
+	}
+	if granted {
+		log.Printf("User %d granted role %s to user %d", grantedBy, role, userID)
+	}
+	h.userRoles(w, r, userID)
+}
+
+// RevokeUserRole takes a role from a user. Access tokens already issued
+// keep it until they expire.
+func (h *Handler) RevokeUserRole(w http.ResponseWriter, r *http.Request) {
+	userID, role, ok := roleParams(w, r)
+	if !ok {
+		return
+	}
+	revokedBy := r.Context().Value("userID").(string)
+
+	revoked, err := h.db.RevokeRole(r.Context(), userID, role)
+	switch {
+	case errors.Is(err, database.ErrUserNotFound):
+		respondWithError(w, http.StatusNotFound, "User not found")
+		return
+	case errors.Is(err, database.ErrLastAdmin):
+		respondWithError(w, http.StatusConflict, "Cannot revoke the last admin")
+		return
+	case err != nil:
+		log.Printf("RevokeUserRole error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to revoke role")
+		return
+	}
+	if revoked {
+		log.Printf("User %s revoked role %s from user %d", revokedBy, role, userID)
+	}
+	h.userRoles(w, r, userID)
+}
+
+// --- EMAIL TOKEN HANDLERS ---
+// Verification and password reset links carry a random single-use token.
+// Only its hash is stored, so the database alone cannot be used to forge a
+// link.
+
+// sendUserToken stores a new token for purpose, valid for ttl, and emails
+// the user a link to path on the frontend carrying it.
+func (h *Handler) sendUserToken(ctx context.Context, user *models.User, purpose string, ttl time.Duration, path, subject, body string) error {
+	token, hash, err := auth.NewOneTimeToken()
+	if err != nil {
+		return err
+	}
+	if err := h.db.CreateUserToken(ctx, user.ID, purpose, hash, time.Now().Add(ttl)); err != nil {
+		return err
+	}
+	link := h.emails.AppURL + path + "?token=" + token
+	return h.emails.Mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: subject,
+		Body:    fmt.Sprintf(body, user.Username, link, ttl),
+	})
+}
+
+func (h *Handler) sendVerificationEmail(ctx context.Context, user *models.User) error {
+	return h.sendUserToken(ctx, user, database.TokenVerifyEmail, h.ttls.VerifyEmail, "/verify-email",
+		"Verify your Mixie Melts email",
+		"Hi %s,\n\nPlease confirm your email address by opening this link:\n\n%s\n\nThe link expires in %v. If you did not sign up for Mixie Melts, you can ignore this email.\n")
+}
+
+func (h *Handler) sendPasswordReset(ctx context.Context, user *models.User) error {
+	return h.sendUserToken(ctx, user, database.TokenResetPassword, h.ttls.PasswordReset, "/reset-password",
+		"Reset your Mixie Melts password",
+		"Hi %s,\n\nSomeone asked to reset the password for your account. To choose a new one, open this link:\n\n%s\n\nThe link expires in %v. If it was not you, ignore this email; your password has not changed.\n")
+}
+
+// RequestEmailVerification emails the calling user a new verification link.
+// Links sent before it stop working.
+func (h *Handler) RequestEmailVerification(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.Context().Value("userID").(string), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	user, err := h.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		log.Printf("RequestEmailVerification error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch user")
+		return
+	}
+	if user == nil {
+		respondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if user.EmailVerifiedAt != nil {
+		respondWithError(w, http.StatusConflict, "Email already verified")
+		return
+	}
+
+	if err := h.sendVerificationEmail(r.Context(), user); err != nil {
+		log.Printf("RequestEmailVerification error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to send email")
+		return
+	}
+	respondWithJSON(w, http.StatusAccepted, map[string]string{"message": "Verification email sent"})
+}
+
+// VerifyEmail marks the email of the user a verification token was sent to
+// as verified, and responds with the user.
+func (h *Handler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		respondWithError(w, http.StatusBadRequest, "token required")
+		return
+	}
+
+	userID, err := h.db.VerifyEmail(r.Context(), auth.HashOneTimeToken(req.Token))
+	if errors.Is(err, database.ErrInvalidToken) {
+		respondWithError(w, http.StatusBadRequest, "Invalid or expired token")
+		return
+	}
+	if err != nil {
+		log.Printf("VerifyEmail error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to verify email")
+		return
+	}
+
+	user, err := h.db.GetUserByID(r.Context(), userID)
+	if err != nil || user == nil {
+		log.Printf("VerifyEmail error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch user")
+		return
+	}
+	log.Printf("User %d verified their email", userID)
+	respondWithJSON(w, http.StatusOK, user)
+}
+
+// RequestPasswordReset emails a password reset link to the user with the
+// given email. It responds the same whether or not there is such a user,
+// so it cannot be used to find out who has an account.
+func (h *Handler) RequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Email) == "" {
+		respondWithError(w, http.StatusBadRequest, "email required")
+		return
+	}
+
+	// The lookup and email happen after responding, so the response time
+	// does not tell whether the account exists
+	ctx := context.WithoutCancel(r.Context())
+	email := strings.TrimSpace(req.Email)
+	h.background.Add(1)
+	go func() {
+		defer h.background.Done()
+		user, err := h.db.GetUserByEmail(ctx, email)
+		if err != nil || user == nil {
+			return
+		}
+		if err := h.sendPasswordReset(ctx, user); err != nil {
+			log.Printf("RequestPasswordReset error: %v", err)
+		}
+	}()
+	respondWithJSON(w, http.StatusAccepted, map[string]string{
+		"message": "If an account uses that email, a password reset link has been sent to it",
+	})
+}
+
+// ResetPassword sets a new password for the user a reset token was sent
+// to. Every session of the user is logged out.
+func (h *Handler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" || req.Password == "" {
+		respondWithError(w, http.StatusBadRequest, "token and password required")
+		return
+	}
+	if msg := validatePassword(req.Password); msg != "" {
+		respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to hash password")
+		return
+	}
+
+	userID, err := h.db.ResetPassword(r.Context(), auth.HashOneTimeToken(req.Token), string(hashedPassword))
+	if errors.Is(err, database.ErrInvalidToken) {
+		respondWithError(w, http.StatusBadRequest, "Invalid or expired token")
+		return
+	}
+	if err != nil {
+		log.Printf("ResetPassword error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+	log.Printf("User %d reset their password", userID)
+	w.WriteHeader(http.StatusNoContent)
 }
 
 // --- OAUTH HANDLERS ---
@@ -184,12 +813,15 @@ func (h *Handler) HandleGoogleCallback(w http.ResponseWriter, r *http.Request) {
 	user, err := h.db.GetUserByEmail(r.Context(), userInfo.Email)
 	if err != nil {
 		// Handle error, but for now, assume it means user doesn't exist
+		now := time.Now()
 		newUser := &models.User{
 			Email:    userInfo.Email,
 			Username: userInfo.Name,
 			Password: "", // No password for OAuth users
+			// The provider has already verified the address
+			EmailVerifiedAt: &now,
 		}
-		userID, err := h.db.CreateUser(r.Context(), newUser)
+		userID, err := h.db.CreateUser(r.Context(), newUser, nil)
 		if err != nil {
 			http.Error(w, "Failed to create user", http.StatusInternalServerError)
 			return
@@ -199,7 +831,7 @@ func (h *Handler) HandleGoogleCallback(w http.ResponseWriter, r *http.Request) {
 		log.Printf("New user created via Google: %s", userInfo.Email)
 	}
 
-	h.issueJWT(w, *user)
+	h.issueTokens(w, r, *user)
 }
 
 func (h *Handler) HandleFacebookLogin(w http.ResponseWriter, r *http.Request) {
@@ -242,12 +874,15 @@ func (h *Handler) HandleFacebookCallback(w http.ResponseWriter, r *http.Request)
 	user, err := h.db.GetUserByEmail(r.Context(), userInfo.Email)
 	if err != nil {
 		// Handle error, but for now, assume it means user doesn't exist
+		now := time.Now()
 		newUser := &models.User{
 			Email:    userInfo.Email,
 			Username: userInfo.Name,
 			Password: "", // No password for OAuth users
+			// The provider has already verified the address
+			EmailVerifiedAt: &now,
 		}
-		userID, err := h.db.CreateUser(r.Context(), newUser)
+		userID, err := h.db.CreateUser(r.Context(), newUser, nil)
 		if err != nil {
 			http.Error(w, "Failed to create user", http.StatusInternalServerError)
 			return
@@ -257,7 +892,7 @@ func (h *Handler) HandleFacebookCallback(w http.ResponseWriter, r *http.Request)
 		log.Printf("New user created via Facebook: %s", userInfo.Email)
 	}
 
-	h.issueJWT(w, *user)
+	h.issueTokens(w, r, *user)
 }
 
 // --- UTILITY & MIDDLEWARE ---
@@ -275,22 +910,82 @@ func (h *Handler) generateStateOauthCookie(w http.ResponseWriter) string {
 	return state
 }
 
-func (h *Handler) issueJWT(w http.ResponseWriter, user models.User) {
-	expirationTime := time.Now().Add(24 * time.Hour)
-	claims := &jwt.RegisteredClaims{
-		Subject:   fmt.Sprint(user.ID),
-		ExpiresAt: jwt.NewNumericDate(expirationTime),
+// Claims are the claims of the tokens issued to users. Revoking the session
+// SessionID names rejects the token here.
+type Claims = jwtverify.Claims
+
+// GetJWKS publishes the public keys access tokens are signed with, for
+// other services to verify them.
+func (h *Handler) GetJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	respondWithJSON(w, http.StatusOK, h.signer.JWKS())
+}
+
+// issueTokens starts a new session for user and responds with its first
+// token pair.
+func (h *Handler) issueTokens(w http.ResponseWriter, r *http.Request, user models.User) {
+	sessionID, err := auth.NewSessionID()
+	if err != nil {
+		log.Printf("issueTokens error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to create token")
+		return
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(h.jwtSecretKey)
+	refresh, refreshHash, err := auth.NewRefreshToken()
 	if err != nil {
-		http.Error(w, "Failed to create token", http.StatusInternalServerError)
+		log.Printf("issueTokens error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to create token")
+		return
+	}
+	session := &models.Session{
+		ID:        sessionID,
+		UserID:    user.ID,
+		UserAgent: r.UserAgent(),
+		ExpiresAt: time.Now().Add(h.ttls.Refresh),
+	}
+	if err := h.db.CreateSession(r.Context(), session, refreshHash); err != nil {
+		log.Printf("issueTokens error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to create session")
 		return
 	}
 
-	log.Printf("Issued JWT for user: %s", user.Email)
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"token": tokenString})
+	log.Printf("Started session for user: %s", user.Email)
+	h.respondWithTokens(w, r, user, sessionID, refresh)
+}
+
+// respondWithTokens signs an access token for user in the given session and
+// responds with it and the refresh token. The token carries the user's
+// current roles, so role changes apply from the next refresh.
+func (h *Handler) respondWithTokens(w http.ResponseWriter, r *http.Request, user models.User, sessionID, refresh string) {
+	roles, err := h.db.GetUserRoles(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("respondWithTokens error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to create token")
+		return
+	}
+	claims := &Claims{
+		Admin:       slices.Contains(roles, jwtverify.RoleAdmin),
+		Roles:       roles,
+		Permissions: jwtverify.PermissionsFor(roles),
+		SessionID:   sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   fmt.Sprint(user.ID),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(h.ttls.Access)),
+		},
+	}
+	tokenString, err := h.signer.Sign(claims)
+	if err != nil {
+		log.Printf("respondWithTokens error: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to create token")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, models.TokenPair{
+		Token:        tokenString,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(h.ttls.Access.Seconds()),
+		RefreshToken: refresh,
+	})
 }
 
 func (h *Handler) AuthMiddleware(next http.Handler) http.Handler {
@@ -302,19 +997,27 @@ func (h *Handler) AuthMiddleware(next http.Handler) http.Handler {
 		}
 
 		tokenString := authHeader[len("Bearer "):]
-		claims := &jwt.RegisteredClaims{}
-
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
-			return h.jwtSecretKey, nil
-		})
-
-		if err != nil || !token.Valid {
+		claims := &Claims{}
+		if err := h.verifier.Parse(tokenString, claims); err != nil || claims.SessionID == "" {
 			http.Error(w, "Invalid token", http.StatusUnauthorized)
 			return
 		}
 
-		// Add user ID to the request context for protected handlers to use
-		ctx := context.WithValue(r.Context(), "userID", claims.Subject)
+		// A logged-out session rejects its access tokens before they expire
+		active, err := h.db.SessionActive(r.Context(), claims.SessionID)
+		if err != nil {
+			log.Printf("AuthMiddleware error: %v", err)
+			http.Error(w, "Failed to check session", http.StatusInternalServerError)
+			return
+		}
+		if !active {
+			http.Error(w, "Session revoked", http.StatusUnauthorized)
+			return
+		}
+
+		// Add user and session IDs to the request context for protected handlers to use
+		ctx := context.WithValue(jwtverify.WithClaims(r.Context(), claims), "userID", claims.Subject)
+		ctx = context.WithValue(ctx, "sessionID", claims.SessionID)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
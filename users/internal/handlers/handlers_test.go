@@ -3,21 +3,54 @@ package handlers
 import (
 	"bytes"
 	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
+	"com.MixieMelts.users/internal/auth"
+	"com.MixieMelts.users/internal/database"
+	"com.MixieMelts.users/internal/mail"
 	"com.MixieMelts.users/internal/models"
+	"com.MixieMelts.users/jwtverify"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v4"
 	"golang.org/x/crypto/bcrypt"
 )
 
 // MockDB is a mock implementation of the DBLayer defined in handlers.go.
 type MockDB struct {
 	GetUserByEmailFunc func(ctx context.Context, email string) (*models.User, error)
-	CreateUserFunc     func(ctx context.Context, user *models.User) (int64, error)
+	CreateUserFunc     func(ctx context.Context, user *models.User, profile *models.Profile) (int64, error)
 	GetUserByIDFunc    func(ctx context.Context, id int64) (*models.User, error)
+
+	GetProfileFunc    func(ctx context.Context, userID int64) (*models.Profile, error)
+	UpdateProfileFunc func(ctx context.Context, userID int64, upd models.ProfileUpdate) (*models.UserProfile, error)
+	SetAvatarFunc     func(ctx context.Context, userID int64, key string) (string, error)
+
+	GetUserRolesFunc func(ctx context.Context, userID int64) ([]string, error)
+	GrantRoleFunc    func(ctx context.Context, userID int64, role string, grantedBy int64) (bool, error)
+	RevokeRoleFunc   func(ctx context.Context, userID int64, role string) (bool, error)
+
+	CreateSessionFunc      func(ctx context.Context, s *models.Session, refreshHash string) error
+	RotateRefreshTokenFunc func(ctx context.Context, oldHash, newHash string, expiresAt time.Time) (*models.Session, error)
+	RevokeSessionFunc      func(ctx context.Context, userID int64, id, reason string) (bool, error)
+	RevokeUserSessionsFunc func(ctx context.Context, userID int64, reason string) (int, error)
+	SessionActiveFunc      func(ctx context.Context, id string) (bool, error)
+
+	CreateUserTokenFunc func(ctx context.Context, userID int64, purpose, hash string, expiresAt time.Time) error
+	VerifyEmailFunc     func(ctx context.Context, hash string) (int64, error)
+	ResetPasswordFunc   func(ctx context.Context, hash, passwordHash string) (int64, error)
 }
 
 func (m *MockDB) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
@@ -27,9 +60,9 @@ func (m *MockDB) GetUserByEmail(ctx context.Context, email string) (*models.User
 	return nil, errors.New("GetUserByEmailFunc not implemented")
 }
 
-func (m *MockDB) CreateUser(ctx context.Context, user *models.User) (int64, error) {
+func (m *MockDB) CreateUser(ctx context.Context, user *models.User, profile *models.Profile) (int64, error) {
 	if m.CreateUserFunc != nil {
-		return m.CreateUserFunc(ctx, user)
+		return m.CreateUserFunc(ctx, user, profile)
 	}
 	return 0, errors.New("CreateUserFunc not implemented")
 }
@@ -41,24 +74,172 @@ func (m *MockDB) GetUserByID(ctx context.Context, id int64) (*models.User, error
 	return nil, errors.New("GetUserByIDFunc not implemented")
 }
 
+func (m *MockDB) GetProfile(ctx context.Context, userID int64) (*models.Profile, error) {
+	if m.GetProfileFunc != nil {
+		return m.GetProfileFunc(ctx, userID)
+	}
+	return nil, errors.New("GetProfileFunc not implemented")
+}
+
+func (m *MockDB) UpdateProfile(ctx context.Context, userID int64, upd models.ProfileUpdate) (*models.UserProfile, error) {
+	if m.UpdateProfileFunc != nil {
+		return m.UpdateProfileFunc(ctx, userID, upd)
+	}
+	return nil, errors.New("UpdateProfileFunc not implemented")
+}
+
+func (m *MockDB) SetAvatar(ctx context.Context, userID int64, key string) (string, error) {
+	if m.SetAvatarFunc != nil {
+		return m.SetAvatarFunc(ctx, userID, key)
+	}
+	return "", errors.New("SetAvatarFunc not implemented")
+}
+
+func (m *MockDB) GetUserRoles(ctx context.Context, userID int64) ([]string, error) {
+	if m.GetUserRolesFunc != nil {
+		return m.GetUserRolesFunc(ctx, userID)
+	}
+	return nil, errors.New("GetUserRolesFunc not implemented")
+}
+
+func (m *MockDB) GrantRole(ctx context.Context, userID int64, role string, grantedBy int64) (bool, error) {
+	if m.GrantRoleFunc != nil {
+		return m.GrantRoleFunc(ctx, userID, role, grantedBy)
+	}
+	return false, errors.New("GrantRoleFunc not implemented")
+}
+
+func (m *MockDB) RevokeRole(ctx context.Context, userID int64, role string) (bool, error) {
+	if m.RevokeRoleFunc != nil {
+		return m.RevokeRoleFunc(ctx, userID, role)
+	}
+	return false, errors.New("RevokeRoleFunc not implemented")
+}
+
+func (m *MockDB) CreateSession(ctx context.Context, s *models.Session, refreshHash string) error {
+	if m.CreateSessionFunc != nil {
+		return m.CreateSessionFunc(ctx, s, refreshHash)
+	}
+	return errors.New("CreateSessionFunc not implemented")
+}
+
+func (m *MockDB) RotateRefreshToken(ctx context.Context, oldHash, newHash string, expiresAt time.Time) (*models.Session, error) {
+	if m.RotateRefreshTokenFunc != nil {
+		return m.RotateRefreshTokenFunc(ctx, oldHash, newHash, expiresAt)
+	}
+	return nil, errors.New("RotateRefreshTokenFunc not implemented")
+}
+
+func (m *MockDB) RevokeSession(ctx context.Context, userID int64, id, reason string) (bool, error) {
+	if m.RevokeSessionFunc != nil {
+		return m.RevokeSessionFunc(ctx, userID, id, reason)
+	}
+	return false, errors.New("RevokeSessionFunc not implemented")
+}
+
+func (m *MockDB) RevokeUserSessions(ctx context.Context, userID int64, reason string) (int, error) {
+	if m.RevokeUserSessionsFunc != nil {
+		return m.RevokeUserSessionsFunc(ctx, userID, reason)
+	}
+	return 0, errors.New("RevokeUserSessionsFunc not implemented")
+}
+
+func (m *MockDB) SessionActive(ctx context.Context, id string) (bool, error) {
+	if m.SessionActiveFunc != nil {
+		return m.SessionActiveFunc(ctx, id)
+	}
+	return false, errors.New("SessionActiveFunc not implemented")
+}
+
+func (m *MockDB) CreateUserToken(ctx context.Context, userID int64, purpose, hash string, expiresAt time.Time) error {
+	if m.CreateUserTokenFunc != nil {
+		return m.CreateUserTokenFunc(ctx, userID, purpose, hash, expiresAt)
+	}
+	return errors.New("CreateUserTokenFunc not implemented")
+}
+
+func (m *MockDB) VerifyEmail(ctx context.Context, hash string) (int64, error) {
+	if m.VerifyEmailFunc != nil {
+		return m.VerifyEmailFunc(ctx, hash)
+	}
+	return 0, errors.New("VerifyEmailFunc not implemented")
+}
+
+func (m *MockDB) ResetPassword(ctx context.Context, hash, passwordHash string) (int64, error) {
+	if m.ResetPasswordFunc != nil {
+		return m.ResetPasswordFunc(ctx, hash, passwordHash)
+	}
+	return 0, errors.New("ResetPasswordFunc not implemented")
+}
+
+// memMailer records the messages sent through it.
+type memMailer struct {
+	sent []mail.Message
+}
+
+func (m *memMailer) Send(ctx context.Context, msg mail.Message) error {
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// emailedToken returns the token in the link of msg.
+func emailedToken(t *testing.T, msg mail.Message) string {
+	t.Helper()
+	_, rest, ok := strings.Cut(msg.Body, "?token=")
+	if !ok {
+		t.Fatalf("no token link in email:\n%s", msg.Body)
+	}
+	token, _, _ := strings.Cut(rest, "\n")
+	return token
+}
+
+// memBlobs is an in-memory blob.Store.
+type memBlobs map[string][]byte
+
+func (m memBlobs) Put(ctx context.Context, key string, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	m[key] = data
+	return err
+}
+
+func (m memBlobs) Delete(ctx context.Context, key string) error {
+	delete(m, key)
+	return nil
+}
+
+func (m memBlobs) URL(key string) string { return "/avatars/" + key }
+
+func newSigner(t *testing.T, secret []byte, keys ...auth.SigningKey) *auth.Signer {
+	t.Helper()
+	signer, err := auth.NewSigner(secret, keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
 // Table-driven tests for RegisterUser
 func TestRegisterUser(t *testing.T) {
 	jwtSecret := []byte("test-secret")
 
 	tests := []struct {
-		name      string
-		existing  bool
-		createErr error
-		wantCode  int
+		name        string
+		existing    bool
+		createErr   error
+		wantCreated bool
 	}{
-		{name: "successful registration", existing: false, createErr: nil, wantCode: http.StatusCreated},
-		{name: "user already exists", existing: true, createErr: nil, wantCode: http.StatusConflict},
-		{name: "db error on create", existing: false, createErr: errors.New("db fail"), wantCode: http.StatusInternalServerError},
+		{name: "successful registration", wantCreated: true},
+		{name: "user already exists", existing: true},
+		{name: "db error on create", createErr: errors.New("db fail")},
 	}
 
+	var firstBody string
 	for _, tc := range tests {
 		tc := tc
 		t.Run(tc.name, func(t *testing.T) {
+			mailer := &memMailer{}
+			var storedHash string
+			created := false
 			mockDB := &MockDB{
 				GetUserByEmailFunc: func(ctx context.Context, email string) (*models.User, error) {
 					if tc.existing {
@@ -66,15 +247,23 @@ func TestRegisterUser(t *testing.T) {
 					}
 					return nil, errors.New("not found")
 				},
-				CreateUserFunc: func(ctx context.Context, user *models.User) (int64, error) {
+				CreateUserFunc: func(ctx context.Context, user *models.User, profile *models.Profile) (int64, error) {
 					if tc.createErr != nil {
 						return 0, tc.createErr
 					}
+					created = true
 					return 1, nil
 				},
+				CreateUserTokenFunc: func(ctx context.Context, userID int64, purpose, hash string, expiresAt time.Time) error {
+					storedHash = hash
+					if userID != 1 || purpose != database.TokenVerifyEmail {
+						return errors.New("unexpected token")
+					}
+					return nil
+				},
 			}
 
-			handler := New(mockDB, jwtSecret)
+			handler := New(mockDB, newSigner(t, jwtSecret), memBlobs{}, Emails{Mailer: mailer, AppURL: "http://app.test/"}, TokenTTLs{})
 
 			creds := models.Credentials{Email: "test@example.com", Password: "password123"}
 			body, _ := json.Marshal(creds)
@@ -86,22 +275,31 @@ func TestRegisterUser(t *testing.T) {
 
 			rr := httptest.NewRecorder()
 			handler.RegisterUser(rr, req)
+			handler.background.Wait()
 
-			if rr.Code != tc.wantCode {
-				t.Fatalf("[%s] expected status %d got %d; body: %s", tc.name, tc.wantCode, rr.Code, rr.Body.String())
+			// Every case answers alike, so the response cannot tell
+			// whether the email is registered.
+			if rr.Code != http.StatusAccepted {
+				t.Fatalf("[%s] expected status %d got %d; body: %s", tc.name, http.StatusAccepted, rr.Code, rr.Body.String())
+			}
+			if firstBody == "" {
+				firstBody = rr.Body.String()
+			} else if rr.Body.String() != firstBody {
+				t.Fatalf("[%s] expected the same body as other cases, got %s", tc.name, rr.Body.String())
+			}
+			if created != tc.wantCreated {
+				t.Fatalf("[%s] expected created=%v got %v", tc.name, tc.wantCreated, created)
 			}
 
-			if tc.wantCode == http.StatusCreated {
-				var created models.User
-				if err := json.NewDecoder(rr.Body).Decode(&created); err != nil {
-					t.Fatalf("[%s] failed to decode response: %v", tc.name, err)
+			if tc.wantCreated {
+				if len(mailer.sent) != 1 || mailer.sent[0].To != creds.Email || !strings.Contains(mailer.sent[0].Body, "http://app.test/verify-email?token=") {
+					t.Fatalf("[%s] expected a verification email, got %+v", tc.name, mailer.sent)
 				}
-				if created.Email != creds.Email {
-					t.Fatalf("[%s] expected email %q got %q", tc.name, creds.Email, created.Email)
-				}
-				if created.ID != 1 {
-					t.Fatalf("[%s] expected id %d got %d", tc.name, 1, created.ID)
+				if auth.HashOneTimeToken(emailedToken(t, mailer.sent[0])) != storedHash {
+					t.Fatalf("[%s] emailed token does not match the stored hash", tc.name)
 				}
+			} else if len(mailer.sent) != 0 {
+				t.Fatalf("[%s] expected no email, got %+v", tc.name, mailer.sent)
 			}
 		})
 	}
@@ -140,9 +338,18 @@ func TestLoginUser(t *testing.T) {
 						IsAdmin:  false,
 					}, nil
 				},
+				CreateSessionFunc: func(ctx context.Context, s *models.Session, refreshHash string) error {
+					if s.ID == "" || s.UserID != 1 || refreshHash == "" {
+						return errors.New("bad session")
+					}
+					return nil
+				},
+				GetUserRolesFunc: func(ctx context.Context, userID int64) ([]string, error) {
+					return []string{jwtverify.RoleCustomer, jwtverify.RoleInventoryManager}, nil
+				},
 			}
 
-			handler := New(mockDB, jwtSecret)
+			handler := New(mockDB, newSigner(t, jwtSecret), memBlobs{}, Emails{Mailer: &memMailer{}}, TokenTTLs{})
 
 			creds := models.Credentials{Email: "test@example.com", Password: tc.credPassword}
 			body, _ := json.Marshal(creds)
@@ -158,14 +365,28 @@ func TestLoginUser(t *testing.T) {
 			if rr.Code != tc.wantStatusCode {
 				t.Fatalf("[%s] expected status %d got %d; body: %s", tc.name, tc.wantStatusCode, rr.Code, rr.Body.String())
 			}
+			// An unknown email and a wrong password read the same.
+			if tc.wantStatusCode == http.StatusUnauthorized && !strings.Contains(rr.Body.String(), `"Invalid credentials"`) {
+				t.Fatalf("[%s] expected the generic message, got %s", tc.name, rr.Body.String())
+			}
 
 			if tc.wantStatusCode == http.StatusOK {
-				var resp map[string]string
+				var resp models.TokenPair
 				if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
 					t.Fatalf("[%s] failed to decode response: %v", tc.name, err)
 				}
-				if _, ok := resp["token"]; !ok {
-					t.Fatalf("[%s] expected token in response, got: %v", tc.name, resp)
+				if resp.Token == "" || resp.RefreshToken == "" {
+					t.Fatalf("[%s] expected token and refresh_token in response, got: %+v", tc.name, resp)
+				}
+				if resp.ExpiresIn != int(DefaultAccessTokenTTL.Seconds()) {
+					t.Fatalf("[%s] expected expires_in %d got %d", tc.name, int(DefaultAccessTokenTTL.Seconds()), resp.ExpiresIn)
+				}
+				claims := &Claims{}
+				if _, err := jwt.ParseWithClaims(resp.Token, claims, func(*jwt.Token) (any, error) { return jwtSecret, nil }); err != nil {
+					t.Fatalf("[%s] invalid access token: %v", tc.name, err)
+				}
+				if claims.Admin || !claims.Can(jwtverify.PermInventoryWrite) || claims.Can(jwtverify.PermProductsWrite) {
+					t.Fatalf("[%s] unexpected token roles %v and permissions %v", tc.name, claims.Roles, claims.Permissions)
 				}
 			}
 		})
@@ -198,9 +419,12 @@ func TestGetUserProfile(t *testing.T) {
 					}
 					return tc.mockUser, nil
 				},
+				GetProfileFunc: func(ctx context.Context, userID int64) (*models.Profile, error) {
+					return &models.Profile{UserID: userID, FirstName: "Pat", AvatarKey: "42-a.png"}, nil
+				},
 			}
 
-			handler := New(mockDB, jwtSecret)
+			handler := New(mockDB, newSigner(t, jwtSecret), memBlobs{}, Emails{Mailer: &memMailer{}}, TokenTTLs{})
 
 			req, err := http.NewRequest("GET", "/api/users/me", nil)
 			if err != nil {
@@ -218,13 +442,708 @@ func TestGetUserProfile(t *testing.T) {
 			}
 
 			if tc.wantCode == http.StatusOK {
-				var returned models.User
+				var returned models.UserProfile
 				if err := json.NewDecoder(rr.Body).Decode(&returned); err != nil {
 					t.Fatalf("[%s] failed to decode response: %v", tc.name, err)
 				}
 				if returned.ID != tc.mockUser.ID || returned.Email != tc.mockUser.Email {
-					t.Fatalf("[%s] returned user mismatch: got %+v want %+v", tc.name, returned, tc.mockUser)
+					t.Fatalf("[%s] returned user mismatch: got %+v want %+v", tc.name, returned.User, tc.mockUser)
+				}
+				if returned.Profile.FirstName != "Pat" || returned.Profile.AvatarURL != "/avatars/42-a.png" {
+					t.Fatalf("[%s] returned profile mismatch: got %+v", tc.name, returned.Profile)
+				}
+			}
+		})
+	}
+}
+
+// Table-driven tests for RefreshToken
+func TestRefreshToken(t *testing.T) {
+	jwtSecret := []byte("test-secret")
+
+	tests := []struct {
+		name      string
+		body      string
+		rotateErr error
+		wantCode  int
+	}{
+		{name: "successful refresh", body: `{"refresh_token":"abc"}`, wantCode: http.StatusOK},
+		{name: "missing token", body: `{}`, wantCode: http.StatusBadRequest},
+		{name: "unknown token", body: `{"refresh_token":"abc"}`, rotateErr: database.ErrInvalidRefreshToken, wantCode: http.StatusUnauthorized},
+		{name: "reused token", body: `{"refresh_token":"abc"}`, rotateErr: database.ErrRefreshTokenReused, wantCode: http.StatusUnauthorized},
+		{name: "db error", body: `{"refresh_token":"abc"}`, rotateErr: errors.New("db fail"), wantCode: http.StatusInternalServerError},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB := &MockDB{
+				RotateRefreshTokenFunc: func(ctx context.Context, oldHash, newHash string, expiresAt time.Time) (*models.Session, error) {
+					if oldHash != auth.HashRefreshToken("abc") || newHash == oldHash {
+						return nil, errors.New("unexpected hashes")
+					}
+					if tc.rotateErr != nil {
+						return nil, tc.rotateErr
+					}
+					return &models.Session{ID: "s1", UserID: 7}, nil
+				},
+				GetUserByIDFunc: func(ctx context.Context, id int64) (*models.User, error) {
+					return &models.User{ID: id, Email: "test@example.com"}, nil
+				},
+				GetUserRolesFunc: func(ctx context.Context, userID int64) ([]string, error) {
+					return []string{jwtverify.RoleCustomer}, nil
+				},
+			}
+
+			handler := New(mockDB, newSigner(t, jwtSecret), memBlobs{}, Emails{Mailer: &memMailer{}}, TokenTTLs{})
+
+			req, err := http.NewRequest("POST", "/api/users/token/refresh", bytes.NewBufferString(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			handler.RefreshToken(rr, req)
+
+			if rr.Code != tc.wantCode {
+				t.Fatalf("[%s] expected status %d got %d; body: %s", tc.name, tc.wantCode, rr.Code, rr.Body.String())
+			}
+
+			if tc.wantCode == http.StatusOK {
+				var resp models.TokenPair
+				if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+					t.Fatalf("[%s] failed to decode response: %v", tc.name, err)
+				}
+				claims := &Claims{}
+				if _, err := jwt.ParseWithClaims(resp.Token, claims, func(*jwt.Token) (any, error) { return jwtSecret, nil }); err != nil {
+					t.Fatalf("[%s] invalid access token: %v", tc.name, err)
+				}
+				if claims.SessionID != "s1" || claims.Subject != "7" {
+					t.Fatalf("[%s] expected sid s1 and sub 7, got %q and %q", tc.name, claims.SessionID, claims.Subject)
+				}
+				if resp.RefreshToken == "" || resp.RefreshToken == "abc" {
+					t.Fatalf("[%s] expected a new refresh token, got %q", tc.name, resp.RefreshToken)
+				}
+			}
+		})
+	}
+}
+
+// Table-driven tests for AuthMiddleware
+func TestAuthMiddleware(t *testing.T) {
+	jwtSecret := []byte("test-secret")
+
+	sign := func(sid string) string {
+		claims := &Claims{
+			SessionID: sid,
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   "42",
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			},
+		}
+		s, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtSecret)
+		return s
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		active   bool
+		wantCode int
+	}{
+		{name: "active session", token: sign("s1"), active: true, wantCode: http.StatusOK},
+		{name: "revoked session", token: sign("s1"), active: false, wantCode: http.StatusUnauthorized},
+		{name: "token without session", token: sign(""), active: true, wantCode: http.StatusUnauthorized},
+		{name: "bad token", token: "garbage", active: true, wantCode: http.StatusUnauthorized},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB := &MockDB{
+				SessionActiveFunc: func(ctx context.Context, id string) (bool, error) {
+					return tc.active && id == "s1", nil
+				},
+			}
+
+			handler := New(mockDB, newSigner(t, jwtSecret), memBlobs{}, Emails{Mailer: &memMailer{}}, TokenTTLs{})
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Context().Value("userID") != "42" || r.Context().Value("sessionID") != "s1" {
+					t.Errorf("[%s] unexpected context values", tc.name)
+				}
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req, err := http.NewRequest("GET", "/api/users/me", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+
+			rr := httptest.NewRecorder()
+			handler.AuthMiddleware(next).ServeHTTP(rr, req)
+
+			if rr.Code != tc.wantCode {
+				t.Fatalf("[%s] expected status %d got %d; body: %s", tc.name, tc.wantCode, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+// Table-driven tests for Logout
+func TestLogout(t *testing.T) {
+	jwtSecret := []byte("test-secret")
+
+	tests := []struct {
+		name      string
+		revokeErr error
+		wantCode  int
+	}{
+		{name: "success", wantCode: http.StatusNoContent},
+		{name: "db error", revokeErr: errors.New("db fail"), wantCode: http.StatusInternalServerError},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var revoked string
+			mockDB := &MockDB{
+				RevokeSessionFunc: func(ctx context.Context, userID int64, id, reason string) (bool, error) {
+					if tc.revokeErr != nil {
+						return false, tc.revokeErr
+					}
+					if userID == 42 && reason == database.RevokeLogout {
+						revoked = id
+					}
+					return true, nil
+				},
+			}
+
+			handler := New(mockDB, newSigner(t, jwtSecret), memBlobs{}, Emails{Mailer: &memMailer{}}, TokenTTLs{})
+
+			req, err := http.NewRequest("POST", "/api/users/logout", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := context.WithValue(req.Context(), "userID", "42")
+			ctx = context.WithValue(ctx, "sessionID", "s1")
+			req = req.WithContext(ctx)
+
+			rr := httptest.NewRecorder()
+			handler.Logout(rr, req)
+
+			if rr.Code != tc.wantCode {
+				t.Fatalf("[%s] expected status %d got %d; body: %s", tc.name, tc.wantCode, rr.Code, rr.Body.String())
+			}
+			if tc.wantCode == http.StatusNoContent && revoked != "s1" {
+				t.Fatalf("[%s] expected session s1 revoked, got %q", tc.name, revoked)
+			}
+		})
+	}
+}
+
+// Tests for GetJWKS and tokens signed with a published key
+func TestGetJWKS(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := auth.ParseSigningKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockDB := &MockDB{
+		SessionActiveFunc: func(ctx context.Context, id string) (bool, error) { return true, nil },
+	}
+	signer := newSigner(t, nil, key)
+	handler := New(mockDB, signer, memBlobs{}, Emails{Mailer: &memMailer{}}, TokenTTLs{})
+
+	req, err := http.NewRequest("GET", "/.well-known/jwks.json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	handler.GetJWKS(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d; body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var set jwtverify.JWKS
+	if err := json.NewDecoder(rr.Body).Decode(&set); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(set.Keys) != 1 || set.Keys[0].Kid != key.JWK.Kid || set.Keys[0].Alg != "EdDSA" {
+		t.Fatalf("unexpected key set: %+v", set)
+	}
+
+	// A token signed with the key verifies against the published set
+	token, err := signer.Sign(&Claims{
+		SessionID:        "s1",
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "42", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := jwtverify.New(set, nil).Parse(token, &Claims{}); err != nil {
+		t.Fatalf("token did not verify against the key set: %v", err)
+	}
+
+	// ...and passes the users service's own middleware
+	req, err = http.NewRequest("GET", "/api/users/me", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr = httptest.NewRecorder()
+	handler.AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d; body: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+}
+
+// Table-driven tests for the username and names accepted by RegisterUser
+func TestRegisterUserProfile(t *testing.T) {
+	jwtSecret := []byte("test-secret")
+
+	tests := []struct {
+		name         string
+		body         string
+		wantCode     int
+		wantUsername string
+		wantProfile  *models.Profile
+	}{
+		{name: "username and names", body: `{"email":"pat@example.com","password":"password123","username":" patty ","first_name":"Pat","last_name":"Doe"}`, wantCode: http.StatusAccepted, wantUsername: "patty", wantProfile: &models.Profile{FirstName: "Pat", LastName: "Doe"}},
+		{name: "username from email", body: `{"email":"pat@example.com","password":"password123"}`, wantCode: http.StatusAccepted, wantUsername: "pat"},
+		{name: "username too short", body: `{"email":"pat@example.com","password":"password123","username":"p"}`, wantCode: http.StatusBadRequest},
+		{name: "password too short", body: `{"email":"pat@example.com","password":"short"}`, wantCode: http.StatusBadRequest},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var gotUser *models.User
+			var gotProfile *models.Profile
+			mockDB := &MockDB{
+				GetUserByEmailFunc: func(ctx context.Context, email string) (*models.User, error) {
+					return nil, errors.New("not found")
+				},
+				CreateUserFunc: func(ctx context.Context, user *models.User, profile *models.Profile) (int64, error) {
+					gotUser, gotProfile = user, profile
+					return 1, nil
+				},
+			}
+
+			handler := New(mockDB, newSigner(t, jwtSecret), memBlobs{}, Emails{Mailer: &memMailer{}}, TokenTTLs{})
+
+			req, err := http.NewRequest("POST", "/api/users/register", bytes.NewBufferString(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rr := httptest.NewRecorder()
+			handler.RegisterUser(rr, req)
+			handler.background.Wait()
+
+			if rr.Code != tc.wantCode {
+				t.Fatalf("[%s] expected status %d got %d; body: %s", tc.name, tc.wantCode, rr.Code, rr.Body.String())
+			}
+			if tc.wantCode != http.StatusAccepted {
+				return
+			}
+			if gotUser.Username != tc.wantUsername {
+				t.Fatalf("[%s] expected username %q got %q", tc.name, tc.wantUsername, gotUser.Username)
+			}
+			if (gotProfile == nil) != (tc.wantProfile == nil) ||
+				gotProfile != nil && (gotProfile.FirstName != tc.wantProfile.FirstName || gotProfile.LastName != tc.wantProfile.LastName) {
+				t.Fatalf("[%s] expected profile %+v got %+v", tc.name, tc.wantProfile, gotProfile)
+			}
+		})
+	}
+}
+
+// Table-driven tests for UpdateUserProfile
+func TestUpdateUserProfile(t *testing.T) {
+	jwtSecret := []byte("test-secret")
+
+	tests := []struct {
+		name      string
+		body      string
+		updateErr error
+		wantCode  int
+	}{
+		{name: "success", body: `{"username":"newname","bio":"Loves wax melts"}`, wantCode: http.StatusOK},
+		{name: "username too short", body: `{"username":"ab"}`, wantCode: http.StatusBadRequest},
+		{name: "bio too long", body: `{"bio":"` + strings.Repeat("x", maxBioLen+1) + `"}`, wantCode: http.StatusBadRequest},
+		{name: "invalid body", body: `{`, wantCode: http.StatusBadRequest},
+		{name: "user gone", body: `{"bio":""}`, updateErr: database.ErrUserNotFound, wantCode: http.StatusNotFound},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB := &MockDB{
+				UpdateProfileFunc: func(ctx context.Context, userID int64, upd models.ProfileUpdate) (*models.UserProfile, error) {
+					if tc.updateErr != nil {
+						return nil, tc.updateErr
+					}
+					if upd.FirstName != nil {
+						t.Errorf("[%s] omitted first_name should stay nil", tc.name)
+					}
+					return &models.UserProfile{
+						User:    models.User{ID: userID, Username: *upd.Username},
+						Profile: models.Profile{UserID: userID, Bio: *upd.Bio},
+					}, nil
+				},
+			}
+
+			handler := New(mockDB, newSigner(t, jwtSecret), memBlobs{}, Emails{Mailer: &memMailer{}}, TokenTTLs{})
+
+			req, err := http.NewRequest("PUT", "/api/users/me", bytes.NewBufferString(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = req.WithContext(context.WithValue(req.Context(), "userID", "42"))
+
+			rr := httptest.NewRecorder()
+			handler.UpdateUserProfile(rr, req)
+
+			if rr.Code != tc.wantCode {
+				t.Fatalf("[%s] expected status %d got %d; body: %s", tc.name, tc.wantCode, rr.Code, rr.Body.String())
+			}
+			if tc.wantCode == http.StatusOK {
+				var returned models.UserProfile
+				if err := json.NewDecoder(rr.Body).Decode(&returned); err != nil {
+					t.Fatalf("[%s] failed to decode response: %v", tc.name, err)
 				}
+				if returned.Username != "newname" || returned.Profile.Bio != "Loves wax melts" {
+					t.Fatalf("[%s] unexpected response: %+v", tc.name, returned)
+				}
+			}
+		})
+	}
+}
+
+// Table-driven tests for UploadAvatar
+func TestUploadAvatar(t *testing.T) {
+	jwtSecret := []byte("test-secret")
+	png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 64)...)
+
+	tests := []struct {
+		name     string
+		content  []byte
+		wantCode int
+	}{
+		{name: "png", content: png, wantCode: http.StatusOK},
+		{name: "not an image", content: []byte("<html><script>alert(1)</script></html>"), wantCode: http.StatusUnsupportedMediaType},
+		{name: "too large", content: append(png, make([]byte, maxAvatarBytes+2<<10)...), wantCode: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			blobs := memBlobs{"42-old.png": png}
+			var setKey string
+			mockDB := &MockDB{
+				SetAvatarFunc: func(ctx context.Context, userID int64, key string) (string, error) {
+					setKey = key
+					return "42-old.png", nil
+				},
+			}
+
+			handler := New(mockDB, newSigner(t, jwtSecret), blobs, Emails{Mailer: &memMailer{}}, TokenTTLs{})
+
+			var body bytes.Buffer
+			mw := multipart.NewWriter(&body)
+			fw, _ := mw.CreateFormFile("avatar", "me.png")
+			fw.Write(tc.content)
+			mw.Close()
+
+			req, err := http.NewRequest("PUT", "/api/users/me/avatar", &body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", mw.FormDataContentType())
+			req = req.WithContext(context.WithValue(req.Context(), "userID", "42"))
+
+			rr := httptest.NewRecorder()
+			handler.UploadAvatar(rr, req)
+
+			if rr.Code != tc.wantCode {
+				t.Fatalf("[%s] expected status %d got %d; body: %s", tc.name, tc.wantCode, rr.Code, rr.Body.String())
+			}
+			if tc.wantCode != http.StatusOK {
+				if len(blobs) != 1 {
+					t.Fatalf("[%s] expected nothing stored, have %d blobs", tc.name, len(blobs))
+				}
+				return
+			}
+			if !strings.HasPrefix(setKey, "42-") || !strings.HasSuffix(setKey, ".png") {
+				t.Fatalf("[%s] unexpected key %q", tc.name, setKey)
+			}
+			if _, ok := blobs["42-old.png"]; ok {
+				t.Fatalf("[%s] expected old avatar deleted", tc.name)
+			}
+			if !bytes.Equal(blobs[setKey], tc.content) {
+				t.Fatalf("[%s] stored avatar does not match upload", tc.name)
+			}
+		})
+	}
+}
+
+// Table-driven tests for GrantUserRole and RevokeUserRole
+func TestUserRoles(t *testing.T) {
+	jwtSecret := []byte("test-secret")
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		dbErr    error
+		wantCode int
+	}{
+		{name: "grant", method: "PUT", target: "/api/users/7/roles/kitchen_staff", wantCode: http.StatusOK},
+		{name: "grant unknown role", method: "PUT", target: "/api/users/7/roles/wizard", wantCode: http.StatusBadRequest},
+		{name: "grant customer", method: "PUT", target: "/api/users/7/roles/customer", wantCode: http.StatusBadRequest},
+		{name: "grant to missing user", method: "PUT", target: "/api/users/7/roles/admin", dbErr: database.ErrUserNotFound, wantCode: http.StatusNotFound},
+		{name: "revoke", method: "DELETE", target: "/api/users/7/roles/kitchen_staff", wantCode: http.StatusOK},
+		{name: "revoke last admin", method: "DELETE", target: "/api/users/7/roles/admin", dbErr: database.ErrLastAdmin, wantCode: http.StatusConflict},
+		{name: "invalid user id", method: "DELETE", target: "/api/users/x/roles/admin", wantCode: http.StatusBadRequest},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB := &MockDB{
+				GrantRoleFunc: func(ctx context.Context, userID int64, role string, grantedBy int64) (bool, error) {
+					if userID != 7 || grantedBy != 1 {
+						t.Errorf("[%s] unexpected grant of %s to %d by %d", tc.name, role, userID, grantedBy)
+					}
+					return tc.dbErr == nil, tc.dbErr
+				},
+				RevokeRoleFunc: func(ctx context.Context, userID int64, role string) (bool, error) {
+					return tc.dbErr == nil, tc.dbErr
+				},
+				GetUserRolesFunc: func(ctx context.Context, userID int64) ([]string, error) {
+					return []string{jwtverify.RoleCustomer, jwtverify.RoleKitchenStaff}, nil
+				},
+			}
+
+			handler := New(mockDB, newSigner(t, jwtSecret), memBlobs{}, Emails{Mailer: &memMailer{}}, TokenTTLs{})
+			router := chi.NewRouter()
+			router.Put("/api/users/{id}/roles/{role}", handler.GrantUserRole)
+			router.Delete("/api/users/{id}/roles/{role}", handler.RevokeUserRole)
+
+			req, err := http.NewRequest(tc.method, tc.target, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = req.WithContext(context.WithValue(req.Context(), "userID", "1"))
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tc.wantCode {
+				t.Fatalf("[%s] expected status %d got %d; body: %s", tc.name, tc.wantCode, rr.Code, rr.Body.String())
+			}
+			if tc.wantCode == http.StatusOK {
+				var returned models.UserRoles
+				if err := json.NewDecoder(rr.Body).Decode(&returned); err != nil {
+					t.Fatalf("[%s] failed to decode response: %v", tc.name, err)
+				}
+				if returned.UserID != 7 || !slices.Contains(returned.Permissions, jwtverify.PermKitchen) {
+					t.Fatalf("[%s] unexpected response: %+v", tc.name, returned)
+				}
+			}
+		})
+	}
+}
+
+// Table-driven tests for RequestEmailVerification and VerifyEmail
+func TestVerifyEmail(t *testing.T) {
+	jwtSecret := []byte("test-secret")
+	verifiedAt := time.Now()
+
+	t.Run("request", func(t *testing.T) {
+		for _, tc := range []struct {
+			name     string
+			verified *time.Time
+			wantCode int
+			wantSent int
+		}{
+			{name: "unverified", verified: nil, wantCode: http.StatusAccepted, wantSent: 1},
+			{name: "already verified", verified: &verifiedAt, wantCode: http.StatusConflict, wantSent: 0},
+		} {
+			mockDB := &MockDB{
+				GetUserByIDFunc: func(ctx context.Context, id int64) (*models.User, error) {
+					return &models.User{ID: id, Email: "test@example.com", EmailVerifiedAt: tc.verified}, nil
+				},
+				CreateUserTokenFunc: func(ctx context.Context, userID int64, purpose, hash string, expiresAt time.Time) error {
+					if time.Until(expiresAt) > DefaultVerifyEmailTokenTTL {
+						t.Errorf("[%s] token expires too late: %v", tc.name, expiresAt)
+					}
+					return nil
+				},
+			}
+			mailer := &memMailer{}
+			handler := New(mockDB, newSigner(t, jwtSecret), memBlobs{}, Emails{Mailer: mailer}, TokenTTLs{})
+
+			req := httptest.NewRequest("POST", "/api/users/verify-email/request", nil)
+			req = req.WithContext(context.WithValue(req.Context(), "userID", "1"))
+			rr := httptest.NewRecorder()
+			handler.RequestEmailVerification(rr, req)
+
+			if rr.Code != tc.wantCode || len(mailer.sent) != tc.wantSent {
+				t.Fatalf("[%s] expected status %d and %d emails, got %d and %d", tc.name, tc.wantCode, tc.wantSent, rr.Code, len(mailer.sent))
+			}
+		}
+	})
+
+	tests := []struct {
+		name      string
+		body      string
+		verifyErr error
+		wantCode  int
+	}{
+		{name: "verified", body: `{"token":"abc"}`, wantCode: http.StatusOK},
+		{name: "missing token", body: `{}`, wantCode: http.StatusBadRequest},
+		{name: "invalid token", body: `{"token":"abc"}`, verifyErr: database.ErrInvalidToken, wantCode: http.StatusBadRequest},
+		{name: "db error", body: `{"token":"abc"}`, verifyErr: errors.New("db fail"), wantCode: http.StatusInternalServerError},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB := &MockDB{
+				VerifyEmailFunc: func(ctx context.Context, hash string) (int64, error) {
+					if hash != auth.HashOneTimeToken("abc") {
+						return 0, errors.New("unexpected hash")
+					}
+					return 1, tc.verifyErr
+				},
+				GetUserByIDFunc: func(ctx context.Context, id int64) (*models.User, error) {
+					return &models.User{ID: id, Email: "test@example.com", EmailVerifiedAt: &verifiedAt}, nil
+				},
+			}
+			handler := New(mockDB, newSigner(t, jwtSecret), memBlobs{}, Emails{Mailer: &memMailer{}}, TokenTTLs{})
+
+			req := httptest.NewRequest("POST", "/api/users/verify-email", strings.NewReader(tc.body))
+			rr := httptest.NewRecorder()
+			handler.VerifyEmail(rr, req)
+
+			if rr.Code != tc.wantCode {
+				t.Fatalf("[%s] expected status %d got %d; body: %s", tc.name, tc.wantCode, rr.Code, rr.Body.String())
+			}
+			if tc.wantCode == http.StatusOK {
+				var user models.User
+				if err := json.NewDecoder(rr.Body).Decode(&user); err != nil || user.EmailVerifiedAt == nil {
+					t.Fatalf("[%s] expected a verified user, got %+v (%v)", tc.name, user, err)
+				}
+			}
+		})
+	}
+}
+
+// Table-driven tests for RequestPasswordReset
+func TestRequestPasswordReset(t *testing.T) {
+	jwtSecret := []byte("test-secret")
+
+	tests := []struct {
+		name     string
+		body     string
+		tokenErr error
+		wantCode int
+		wantSent int
+	}{
+		{name: "known user", body: `{"email":"test@example.com"}`, wantCode: http.StatusAccepted, wantSent: 1},
+		{name: "unknown user", body: `{"email":"nobody@example.com"}`, wantCode: http.StatusAccepted, wantSent: 0},
+		{name: "db error is not revealed", body: `{"email":"test@example.com"}`, tokenErr: errors.New("db fail"), wantCode: http.StatusAccepted, wantSent: 0},
+		{name: "missing email", body: `{}`, wantCode: http.StatusBadRequest, wantSent: 0},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB := &MockDB{
+				GetUserByEmailFunc: func(ctx context.Context, email string) (*models.User, error) {
+					if email != "test@example.com" {
+						return &models.User{}, errors.New("not found")
+					}
+					return &models.User{ID: 1, Email: email}, nil
+				},
+				CreateUserTokenFunc: func(ctx context.Context, userID int64, purpose, hash string, expiresAt time.Time) error {
+					if purpose != database.TokenResetPassword || time.Until(expiresAt) > DefaultPasswordResetTokenTTL {
+						t.Errorf("[%s] unexpected %s token expiring at %v", tc.name, purpose, expiresAt)
+					}
+					return tc.tokenErr
+				},
+			}
+			mailer := &memMailer{}
+			handler := New(mockDB, newSigner(t, jwtSecret), memBlobs{}, Emails{Mailer: mailer, AppURL: "http://app.test"}, TokenTTLs{})
+
+			req := httptest.NewRequest("POST", "/api/users/password-reset/request", strings.NewReader(tc.body))
+			rr := httptest.NewRecorder()
+			handler.RequestPasswordReset(rr, req)
+			handler.background.Wait()
+
+			if rr.Code != tc.wantCode {
+				t.Fatalf("[%s] expected status %d got %d; body: %s", tc.name, tc.wantCode, rr.Code, rr.Body.String())
+			}
+			if len(mailer.sent) != tc.wantSent {
+				t.Fatalf("[%s] expected %d emails, got %+v", tc.name, tc.wantSent, mailer.sent)
+			}
+			if tc.wantSent > 0 && !strings.Contains(mailer.sent[0].Body, "http://app.test/reset-password?token=") {
+				t.Fatalf("[%s] expected a reset link, got:\n%s", tc.name, mailer.sent[0].Body)
+			}
+		})
+	}
+}
+
+// Table-driven tests for ResetPassword
+func TestResetPassword(t *testing.T) {
+	jwtSecret := []byte("test-secret")
+
+	tests := []struct {
+		name     string
+		body     string
+		resetErr error
+		wantCode int
+	}{
+		{name: "reset", body: `{"token":"abc","password":"newpassword"}`, wantCode: http.StatusNoContent},
+		{name: "missing password", body: `{"token":"abc"}`, wantCode: http.StatusBadRequest},
+		{name: "missing token", body: `{"password":"newpassword"}`, wantCode: http.StatusBadRequest},
+		{name: "password too short", body: `{"token":"abc","password":"short"}`, wantCode: http.StatusBadRequest},
+		{name: "invalid token", body: `{"token":"abc","password":"newpassword"}`, resetErr: database.ErrInvalidToken, wantCode: http.StatusBadRequest},
+		{name: "db error", body: `{"token":"abc","password":"newpassword"}`, resetErr: errors.New("db fail"), wantCode: http.StatusInternalServerError},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB := &MockDB{
+				ResetPasswordFunc: func(ctx context.Context, hash, passwordHash string) (int64, error) {
+					if hash != auth.HashOneTimeToken("abc") {
+						return 0, errors.New("unexpected hash")
+					}
+					if err := bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte("newpassword")); err != nil {
+						return 0, err
+					}
+					return 1, tc.resetErr
+				},
+			}
+			handler := New(mockDB, newSigner(t, jwtSecret), memBlobs{}, Emails{Mailer: &memMailer{}}, TokenTTLs{})
+
+			req := httptest.NewRequest("POST", "/api/users/password-reset", strings.NewReader(tc.body))
+			rr := httptest.NewRecorder()
+			handler.ResetPassword(rr, req)
+
+			if rr.Code != tc.wantCode {
+				t.Fatalf("[%s] expected status %d got %d; body: %s", tc.name, tc.wantCode, rr.Code, rr.Body.String())
 			}
 		})
 	}
@@ -0,0 +1,46 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// File is a Mailer for local development and tests. It writes each message
+// to Dir as a .eml file, or logs it when Dir is empty. Nothing is delivered,
+// so links in the messages, including tokens, end up on disk or in the log.
+type File struct {
+	Dir  string
+	From string
+}
+
+// Send writes or logs msg.
+func (f *File) Send(ctx context.Context, msg Message) error {
+	data, _, _, err := msg.format(f.From)
+	if err != nil {
+		return err
+	}
+	if f.Dir == "" {
+		log.Printf("mail: to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+		return nil
+	}
+
+	if err := os.MkdirAll(f.Dir, 0o755); err != nil {
+		return fmt.Errorf("mail: %w", err)
+	}
+	// Named by time so the newest message sorts last
+	out, err := os.CreateTemp(f.Dir, time.Now().UTC().Format("20060102T150405.000")+"-*.eml")
+	if err != nil {
+		return fmt.Errorf("mail: %w", err)
+	}
+	if _, err := out.Write(data); err != nil {
+		out.Close()
+		return fmt.Errorf("mail: write %s: %w", out.Name(), err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("mail: write %s: %w", out.Name(), err)
+	}
+	return nil
+}
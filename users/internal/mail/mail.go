@@ -0,0 +1,61 @@
+// Package mail sends the service's emails, such as verification and
+// password reset links, behind an interface so tests and local development
+// need no mail server.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// ErrInvalidMessage is returned for a message with an unparsable address
+// or a header that would smuggle in other headers.
+var ErrInvalidMessage = errors.New("invalid mail message")
+
+// Message is a plain text email to one recipient.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers messages.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// format renders msg as an RFC 5322 message from from, and returns it with
+// the bare sender and recipient addresses for the SMTP envelope.
+func (msg Message) format(from string) (data []byte, sender, recipient string, err error) {
+	fromAddr, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("%w: from %q: %v", ErrInvalidMessage, from, err)
+	}
+	toAddr, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("%w: to %q: %v", ErrInvalidMessage, msg.To, err)
+	}
+	if strings.ContainsAny(msg.Subject, "\r\n") {
+		return nil, "", "", fmt.Errorf("%w: subject contains a line break", ErrInvalidMessage)
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", fromAddr)
+	fmt.Fprintf(&b, "To: %s\r\n", toAddr)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	qp := quotedprintable.NewWriter(&b)
+	qp.Write([]byte(strings.ReplaceAll(msg.Body, "\n", "\r\n")))
+	qp.Close()
+	return b.Bytes(), fromAddr.Address, toAddr.Address, nil
+}
@@ -0,0 +1,133 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFile(t *testing.T) {
+	ctx := context.Background()
+	f := &File{Dir: filepath.Join(t.TempDir(), "mail"), From: "Mixie Melts <no-reply@mixiemelts.test>"}
+
+	err := f.Send(ctx, Message{To: "ann@example.com", Subject: "Reset your password", Body: "Follow this link:\nhttp://localhost/reset?token=abc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, _ := os.ReadDir(f.Dir)
+	if len(entries) != 1 || filepath.Ext(entries[0].Name()) != ".eml" {
+		t.Fatalf("expected one .eml file, found %v", entries)
+	}
+	data, _ := os.ReadFile(filepath.Join(f.Dir, entries[0].Name()))
+	for _, want := range []string{
+		"From: \"Mixie Melts\" <no-reply@mixiemelts.test>\r\n",
+		"To: <ann@example.com>\r\n",
+		"Subject: Reset your password\r\n",
+		"Follow this link:\r\nhttp://localhost/reset?token=3Dabc",
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected message to contain %q, got:\n%s", want, data)
+		}
+	}
+}
+
+func TestInvalidMessages(t *testing.T) {
+	f := &File{From: "no-reply@mixiemelts.test"}
+	for _, msg := range []Message{
+		{To: "", Subject: "Hi"},
+		{To: "not an address", Subject: "Hi"},
+		{To: "ann@example.com\r\nBcc: eve@example.com", Subject: "Hi"},
+		{To: "ann@example.com", Subject: "Hi\r\nBcc: eve@example.com"},
+	} {
+		if err := f.Send(context.Background(), msg); !errors.Is(err, ErrInvalidMessage) {
+			t.Errorf("Send(%+v): expected ErrInvalidMessage, got %v", msg, err)
+		}
+	}
+}
+
+// fakeSMTP accepts one connection, speaks just enough SMTP for Send and
+// returns the envelope and message it received.
+func fakeSMTP(t *testing.T) (addr string, received <-chan []string) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	ch := make(chan []string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+
+		var got []string
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				ch <- got
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			switch cmd {
+			case "EHLO", "HELO":
+				reply("250 localhost")
+			case "MAIL", "RCPT":
+				got = append(got, line)
+				reply("250 OK")
+			case "DATA":
+				reply("354 Go ahead")
+				var body strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil || l == ".\r\n" {
+						break
+					}
+					body.WriteString(l)
+				}
+				got = append(got, body.String())
+				reply("250 Queued")
+			case "QUIT":
+				reply("221 Bye")
+				ch <- got
+				return
+			default:
+				reply("502 Not implemented")
+			}
+		}
+	}()
+	return l.Addr().String(), ch
+}
+
+func TestSMTP(t *testing.T) {
+	addr, received := fakeSMTP(t)
+	s := &SMTP{Addr: addr, From: "Mixie Melts <no-reply@mixiemelts.test>"}
+
+	err := s.Send(context.Background(), Message{To: "Ann <ann@example.com>", Subject: "Verify your email", Body: "Hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := <-received
+	if len(got) != 3 {
+		t.Fatalf("expected MAIL, RCPT and DATA, got %q", got)
+	}
+	if got[0] != "MAIL FROM:<no-reply@mixiemelts.test>" || got[1] != "RCPT TO:<ann@example.com>" {
+		t.Fatalf("unexpected envelope %q", got[:2])
+	}
+	if !strings.Contains(got[2], "Subject: Verify your email\r\n") || !strings.HasSuffix(got[2], "\r\n\r\nHello\r\n") {
+		t.Fatalf("unexpected message:\n%s", got[2])
+	}
+}
@@ -0,0 +1,76 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+)
+
+// SMTP sends mail through an SMTP server at Addr (host:port), upgrading to
+// TLS when the server offers STARTTLS. Username and Password, when set,
+// authenticate with PLAIN, which net/smtp only allows over TLS or to
+// localhost.
+type SMTP struct {
+	Addr     string
+	Username string
+	Password string
+	From     string
+}
+
+// Send delivers msg. ctx bounds the whole conversation with the server.
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+	data, sender, recipient, err := msg.format(s.From)
+	if err != nil {
+		return err
+	}
+	host, _, err := net.SplitHostPort(s.Addr)
+	if err != nil {
+		return fmt.Errorf("mail: smtp address: %w", err)
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.Addr)
+	if err != nil {
+		return fmt.Errorf("mail: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("mail: %w", err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return fmt.Errorf("mail: starttls: %w", err)
+		}
+	}
+	if s.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.Username, s.Password, host)); err != nil {
+			return fmt.Errorf("mail: auth: %w", err)
+		}
+	}
+	if err := c.Mail(sender); err != nil {
+		return fmt.Errorf("mail: sender: %w", err)
+	}
+	if err := c.Rcpt(recipient); err != nil {
+		return fmt.Errorf("mail: recipient: %w", err)
+	}
+	wc, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("mail: data: %w", err)
+	}
+	if _, err := wc.Write(data); err != nil {
+		wc.Close()
+		return fmt.Errorf("mail: data: %w", err)
+	}
+	if err := wc.Close(); err != nil {
+		return fmt.Errorf("mail: data: %w", err)
+	}
+	return c.Quit()
+}
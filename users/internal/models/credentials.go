@@ -5,3 +5,12 @@ type Credentials struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
 }
+
+// Registration is the body of POST /api/users/register. Username defaults
+// to the part of the email before the "@".
+type Registration struct {
+	Credentials
+	Username  string `json:"username,omitempty"`
+	FirstName string `json:"first_name,omitempty"`
+	LastName  string `json:"last_name,omitempty"`
+}
@@ -7,4 +7,21 @@ type Profile struct {
 	LastName  string `json:"last_name,omitempty"`
 	Bio       string `json:"bio,omitempty"`
 	AvatarURL string `json:"avatar_url,omitempty"`
+	// AvatarKey names the avatar in the blob store AvatarURL serves it from.
+	AvatarKey string `json:"-"`
+}
+
+// UserProfile is a user with their profile, as returned by /api/users/me.
+type UserProfile struct {
+	User
+	Profile Profile `json:"profile"`
+}
+
+// ProfileUpdate is the body of PUT /api/users/me. Omitted fields are left
+// unchanged; an empty string clears a profile field.
+type ProfileUpdate struct {
+	Username  *string `json:"username,omitempty"`
+	FirstName *string `json:"first_name,omitempty"`
+	LastName  *string `json:"last_name,omitempty"`
+	Bio       *string `json:"bio,omitempty"`
 }
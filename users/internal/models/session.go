@@ -4,11 +4,25 @@ import (
 	"time"
 )
 
-// Session represents a user session.
+// Session represents one login of a user on one device. Access tokens name
+// it in their "sid" claim, and its refresh tokens rotate on every use. A
+// revoked or expired session no longer authenticates anything.
 type Session struct {
-	ID        string    `json:"id"`
-	UserID    int64     `json:"user_id"`
-	Token     string    `json:"token"`
-	ExpiresAt time.Time `json:"expires_at"`
-	CreatedAt time.Time `json:"created_at"`
+	ID           string     `json:"id"`
+	UserID       int64      `json:"user_id"`
+	UserAgent    string     `json:"user_agent,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	LastUsedAt   time.Time  `json:"last_used_at"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	RevokeReason string     `json:"revoke_reason,omitempty"`
+}
+
+// TokenPair is the response to a login or refresh. Token is the short-lived
+// access token; RefreshToken may be exchanged once for a new pair.
+type TokenPair struct {
+	Token        string `json:"token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"` // seconds until Token expires
+	RefreshToken string `json:"refresh_token"`
 }
@@ -0,0 +1,216 @@
+package jwtverify
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrUnknownKey is returned for a key id no key set contains.
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// JWK is one public key of a JSON Web Key Set (RFC 7517). Only RSA keys
+// (RS256) and Ed25519 keys (EdDSA) are supported.
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Kid string `json:"kid"`
+
+	// RSA modulus and exponent
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// Ed25519 curve and public key
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set, as served at /.well-known/jwks.json.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewJWK describes pub as a signing key. Its key id is the key's RFC 7638
+// thumbprint, so the same key always gets the same id.
+func NewJWK(pub crypto.PublicKey) (JWK, error) {
+	var k JWK
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		k = JWK{
+			Kty: "RSA",
+			Alg: "RS256",
+			N:   b64(pub.N.Bytes()),
+			E:   b64(big.NewInt(int64(pub.E)).Bytes()),
+		}
+	case ed25519.PublicKey:
+		k = JWK{Kty: "OKP", Alg: "EdDSA", Crv: "Ed25519", X: b64(pub)}
+	default:
+		return JWK{}, fmt.Errorf("unsupported key type %T", pub)
+	}
+	k.Use = "sig"
+	k.Kid = k.Thumbprint()
+	return k, nil
+}
+
+// Thumbprint is the RFC 7638 SHA-256 thumbprint of k: the hash of its
+// required members in lexicographic order.
+func (k JWK) Thumbprint() string {
+	var members string
+	switch k.Kty {
+	case "RSA":
+		members = fmt.Sprintf(`{"e":%q,"kty":"RSA","n":%q}`, k.E, k.N)
+	case "OKP":
+		members = fmt.Sprintf(`{"crv":%q,"kty":"OKP","x":%q}`, k.Crv, k.X)
+	}
+	sum := sha256.Sum256([]byte(members))
+	return b64(sum[:])
+}
+
+// PublicKey decodes k into an *rsa.PublicKey or ed25519.PublicKey.
+func (k JWK) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: modulus: %w", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: exponent: %w", k.Kid, err)
+		}
+		exp := new(big.Int).SetBytes(e)
+		if len(n) == 0 || !exp.IsInt64() || exp.Int64() < 3 || exp.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("key %s: invalid RSA key", k.Kid)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("key %s: unsupported curve %q", k.Kid, k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("key %s: invalid Ed25519 key", k.Kid)
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("key %s: unsupported key type %q", k.Kid, k.Kty)
+}
+
+// Key returns the public key with id kid, making a JWKS a KeySource.
+func (s JWKS) Key(kid string) (crypto.PublicKey, error) {
+	for _, k := range s.Keys {
+		if k.Kid == kid {
+			return k.PublicKey()
+		}
+	}
+	return nil, ErrUnknownKey
+}
+
+// Remote key set timings.
+const (
+	// DefaultMaxAge is how long a fetched key set is used before it is
+	// fetched again, so retired keys stop verifying.
+	DefaultMaxAge = 10 * time.Minute
+	// minRefreshInterval throttles fetches for unknown key ids, so tokens
+	// with made-up ids cannot hammer the users service.
+	minRefreshInterval = 10 * time.Second
+)
+
+// RemoteKeySet is a KeySource backed by a JWKS URL. It fetches the set on
+// first use, again when it is older than MaxAge, and again when a token
+// names a key it does not have yet, which is how a rotated-in key is
+// picked up.
+type RemoteKeySet struct {
+	URL    string
+	Client *http.Client
+	MaxAge time.Duration
+
+	mu      sync.Mutex
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+}
+
+// NewRemoteKeySet returns a key set fetched from url.
+func NewRemoteKeySet(url string) *RemoteKeySet {
+	return &RemoteKeySet{
+		URL:    url,
+		Client: &http.Client{Timeout: 5 * time.Second},
+		MaxAge: DefaultMaxAge,
+	}
+}
+
+// Key returns the public key with id kid.
+func (s *RemoteKeySet) Key(kid string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[kid]
+	if ok && time.Since(s.fetched) < s.MaxAge {
+		return key, nil
+	}
+	if time.Since(s.fetched) < minRefreshInterval {
+		if ok {
+			return key, nil
+		}
+		return nil, ErrUnknownKey
+	}
+
+	if err := s.refresh(); err != nil {
+		// Keep verifying with the keys we have while the users service is
+		// unreachable
+		if ok {
+			log.Printf("jwtverify: keeping cached keys: %v", err)
+			return key, nil
+		}
+		return nil, err
+	}
+	if key, ok := s.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+// refresh fetches the key set. It must be called with s.mu held.
+func (s *RemoteKeySet) refresh() error {
+	s.fetched = time.Now()
+
+	resp, err := s.Client.Get(s.URL)
+	if err != nil {
+		return fmt.Errorf("fetch %s: %w", s.URL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetch %s: status %d", s.URL, resp.StatusCode)
+	}
+
+	var set JWKS
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("decode %s: %w", s.URL, err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		pub, err := k.PublicKey()
+		if err != nil {
+			log.Printf("jwtverify: skipping key: %v", err)
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+	s.keys = keys
+	return nil
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,187 @@
+package jwtverify
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key any, sub string) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: sub, ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))},
+	})
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestJWKRoundTrip(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pub := range []any{&rsaKey.PublicKey, edPub} {
+		k, err := NewJWK(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		again, _ := NewJWK(pub)
+		if k.Kid == "" || k.Kid != again.Kid {
+			t.Fatalf("expected a stable key id, got %q and %q", k.Kid, again.Kid)
+		}
+
+		data, _ := json.Marshal(JWKS{Keys: []JWK{k}})
+		var set JWKS
+		if err := json.Unmarshal(data, &set); err != nil {
+			t.Fatal(err)
+		}
+		got, err := set.Key(k.Kid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, pub) {
+			t.Fatalf("%s key did not round trip", k.Kty)
+		}
+	}
+
+	if _, err := (JWKS{}).Key("nope"); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("expected ErrUnknownKey, got %v", err)
+	}
+}
+
+func TestVerifier(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, _ := NewJWK(pub)
+	set := JWKS{Keys: []JWK{k}}
+	secret := []byte("test-secret")
+
+	tests := []struct {
+		name    string
+		v       *Verifier
+		token   string
+		wantErr bool
+	}{
+		{name: "EdDSA with known key", v: New(set, nil), token: sign(t, jwt.SigningMethodEdDSA, k.Kid, priv, "1")},
+		{name: "EdDSA without key id", v: New(set, nil), token: sign(t, jwt.SigningMethodEdDSA, "", priv, "1"), wantErr: true},
+		{name: "EdDSA with unknown key", v: New(set, nil), token: sign(t, jwt.SigningMethodEdDSA, "other", priv, "1"), wantErr: true},
+		{name: "HS256 with secret", v: New(set, secret), token: sign(t, jwt.SigningMethodHS256, "", secret, "1")},
+		{name: "HS256 without secret", v: New(set, nil), token: sign(t, jwt.SigningMethodHS256, "", secret, "1"), wantErr: true},
+		{name: "HS256 with wrong secret", v: New(nil, []byte("other")), token: sign(t, jwt.SigningMethodHS256, "", secret, "1"), wantErr: true},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.v.Parse(tc.token, &Claims{})
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("[%s] expected error %v, got %v", tc.name, tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRemoteKeySet(t *testing.T) {
+	oldPub, oldPriv, _ := ed25519.GenerateKey(rand.Reader)
+	newPub, newPriv, _ := ed25519.GenerateKey(rand.Reader)
+	oldKey, _ := NewJWK(oldPub)
+	newKey, _ := NewJWK(newPub)
+
+	published := JWKS{Keys: []JWK{oldKey}}
+	fetches := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		json.NewEncoder(w).Encode(published)
+	}))
+	defer srv.Close()
+
+	keys := NewRemoteKeySet(srv.URL)
+	v := New(keys, nil)
+
+	if err := v.Parse(sign(t, jwt.SigningMethodEdDSA, oldKey.Kid, oldPriv, "1"), &Claims{}); err != nil {
+		t.Fatalf("expected old key to verify: %v", err)
+	}
+
+	// The new key is published; unknown ids only refetch after the throttle
+	published = JWKS{Keys: []JWK{newKey, oldKey}}
+	newToken := sign(t, jwt.SigningMethodEdDSA, newKey.Kid, newPriv, "1")
+	if err := v.Parse(newToken, &Claims{}); err == nil {
+		t.Fatal("expected unknown key to fail within the refresh throttle")
+	}
+	keys.fetched = time.Now().Add(-minRefreshInterval)
+	if err := v.Parse(newToken, &Claims{}); err != nil {
+		t.Fatalf("expected rotated-in key to verify: %v", err)
+	}
+	if fetches != 2 {
+		t.Fatalf("expected 2 fetches, got %d", fetches)
+	}
+
+	// The old key is retired and stops verifying once the set is stale
+	published = JWKS{Keys: []JWK{newKey}}
+	keys.fetched = time.Now().Add(-DefaultMaxAge)
+	if err := v.Parse(sign(t, jwt.SigningMethodEdDSA, oldKey.Kid, oldPriv, "1"), &Claims{}); err == nil {
+		t.Fatal("expected retired key to fail")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	secret := []byte("test-secret")
+	v := New(nil, secret)
+
+	tests := []struct {
+		name     string
+		header   string
+		wantCode int
+	}{
+		{name: "valid token", header: "Bearer " + sign(t, jwt.SigningMethodHS256, "", secret, "42"), wantCode: http.StatusOK},
+		{name: "no subject", header: "Bearer " + sign(t, jwt.SigningMethodHS256, "", secret, ""), wantCode: http.StatusUnauthorized},
+		{name: "no header", header: "", wantCode: http.StatusUnauthorized},
+		{name: "not bearer", header: "Basic abc", wantCode: http.StatusUnauthorized},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				claims, ok := ClaimsFromContext(r.Context())
+				if !ok || claims.Subject != "42" || r.Context().Value("userID") != "42" {
+					t.Errorf("[%s] claims not in context", tc.name)
+				}
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest("GET", "/", nil)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+			rr := httptest.NewRecorder()
+			v.Middleware(next).ServeHTTP(rr, req)
+
+			if rr.Code != tc.wantCode {
+				t.Fatalf("[%s] expected status %d got %d", tc.name, tc.wantCode, rr.Code)
+			}
+		})
+	}
+}
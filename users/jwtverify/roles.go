@@ -0,0 +1,77 @@
+package jwtverify
+
+import (
+	"net/http"
+	"slices"
+)
+
+// Roles a user can hold. Every user is a customer; the others are granted
+// by an admin.
+const (
+	RoleCustomer         = "customer"
+	RoleAdmin            = "admin"
+	RoleKitchenStaff     = "kitchen_staff"
+	RoleInventoryManager = "inventory_manager"
+)
+
+// Permissions carried in tokens and checked by services.
+const (
+	PermProductsWrite  = "products:write"
+	PermInventoryRead  = "inventory:read"
+	PermInventoryWrite = "inventory:write"
+	PermKitchen        = "kitchen"
+	PermRolesManage    = "roles:manage"
+)
+
+// rolePermissions is what each role may do. Admins may do everything.
+var rolePermissions = map[string][]string{
+	RoleCustomer:         {},
+	RoleAdmin:            {PermProductsWrite, PermInventoryRead, PermInventoryWrite, PermKitchen, PermRolesManage},
+	RoleKitchenStaff:     {PermKitchen, PermInventoryRead},
+	RoleInventoryManager: {PermInventoryRead, PermInventoryWrite},
+}
+
+// ValidRole reports whether role is a known role.
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// PermissionsFor returns the sorted permissions granted by roles.
+func PermissionsFor(roles []string) []string {
+	var perms []string
+	for _, role := range roles {
+		for _, p := range rolePermissions[role] {
+			if !slices.Contains(perms, p) {
+				perms = append(perms, p)
+			}
+		}
+	}
+	slices.Sort(perms)
+	return perms
+}
+
+// Can reports whether the token grants perm. Admin tokens grant every
+// permission, including ones issued before permissions were.
+func (c *Claims) Can(perm string) bool {
+	return c.Admin || slices.Contains(c.Permissions, perm)
+}
+
+// Require rejects requests whose token, as stored by Middleware, does not
+// grant perm. It must run after Middleware.
+func Require(perm string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := ClaimsFromContext(r.Context())
+			if !ok {
+				http.Error(w, "Authorization header required", http.StatusUnauthorized)
+				return
+			}
+			if !claims.Can(perm) {
+				http.Error(w, "Permission "+perm+" required", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package jwtverify
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestPermissionsFor(t *testing.T) {
+	tests := []struct {
+		roles []string
+		want  []string
+	}{
+		{roles: []string{RoleCustomer}, want: nil},
+		{roles: []string{RoleCustomer, RoleKitchenStaff, RoleInventoryManager}, want: []string{PermInventoryRead, PermInventoryWrite, PermKitchen}},
+		{roles: []string{RoleAdmin}, want: []string{PermInventoryRead, PermInventoryWrite, PermKitchen, PermProductsWrite, PermRolesManage}},
+		{roles: []string{"unknown"}, want: nil},
+	}
+	for _, tc := range tests {
+		if got := PermissionsFor(tc.roles); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("PermissionsFor(%v) = %v, want %v", tc.roles, got, tc.want)
+		}
+	}
+}
+
+func TestRequire(t *testing.T) {
+	tests := []struct {
+		name     string
+		claims   *Claims
+		wantCode int
+	}{
+		{name: "permitted", claims: &Claims{Permissions: []string{PermInventoryRead, PermInventoryWrite}}, wantCode: http.StatusOK},
+		{name: "admin", claims: &Claims{Admin: true}, wantCode: http.StatusOK},
+		{name: "not permitted", claims: &Claims{Permissions: []string{PermInventoryRead}}, wantCode: http.StatusForbidden},
+		{name: "no claims", wantCode: http.StatusUnauthorized},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/ingredients", nil)
+			if tc.claims != nil {
+				req = req.WithContext(WithClaims(req.Context(), tc.claims))
+			}
+			rr := httptest.NewRecorder()
+			Require(PermInventoryWrite)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})).ServeHTTP(rr, req)
+
+			if rr.Code != tc.wantCode {
+				t.Fatalf("[%s] expected status %d got %d", tc.name, tc.wantCode, rr.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const usage = "usage: migrate up | down [steps] | status"
+
+// UpPool applies every pending migration on one connection from pool, which
+// holds the advisory lock for the whole run.
+func (m *Migrator) UpPool(ctx context.Context, pool *pgxpool.Pool) ([]Migration, error) {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("migrate %s: acquire connection: %w", m.service, err)
+	}
+	defer conn.Release()
+	return m.Up(ctx, conn.Conn())
+}
+
+// Command implements a service's "migrate" subcommand against the database
+// at dbURL, writing its report to out: "up" applies pending migrations,
+// "down" rolls back the latest steps (default 1) and "status" lists every
+// migration and when it was applied.
+func (m *Migrator) Command(ctx context.Context, dbURL string, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	steps := 1
+	if args[0] == "down" && len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid steps %q: %s", args[1], usage)
+		}
+		steps = n
+	}
+	switch args[0] {
+	case "up", "down", "status":
+	default:
+		return fmt.Errorf("unknown command %q: %s", args[0], usage)
+	}
+
+	conn, err := pgx.Connect(ctx, dbURL)
+	if err != nil {
+		return fmt.Errorf("migrate %s: connect: %w", m.service, err)
+	}
+	defer conn.Close(context.WithoutCancel(ctx))
+
+	switch args[0] {
+	case "up":
+		applied, err := m.Up(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mg := range applied {
+			fmt.Fprintf(out, "applied %d_%s\n", mg.Version, mg.Name)
+		}
+		fmt.Fprintf(out, "applied %d migration(s)\n", len(applied))
+	case "down":
+		reverted, err := m.Down(ctx, conn, steps)
+		if err != nil {
+			return err
+		}
+		for _, mg := range reverted {
+			fmt.Fprintf(out, "rolled back %d_%s\n", mg.Version, mg.Name)
+		}
+		fmt.Fprintf(out, "rolled back %d migration(s)\n", len(reverted))
+	case "status":
+		list, err := m.Status(ctx, conn)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED")
+		for _, s := range list {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\n", s.Version, s.Name, applied)
+		}
+		return tw.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+// Package migrate applies versioned SQL migrations embedded in a service
+// binary. Each migration is a pair of files named
+// "<version>_<name>.up.sql" and "<version>_<name>.down.sql"; applied versions
+// are recorded in a schema_migrations table. A Postgres advisory lock keyed
+// by service serializes runs, so replicas starting together do not race to
+// migrate the same schema. Services keep only their own SQL files and build
+// this package from the users module.
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrNoDown is returned when rolling back a migration that has no down
+// script.
+var ErrNoDown = errors.New("migration has no down script")
+
+// Migration is one versioned schema change.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status describes a migration and when it was applied; AppliedAt is nil
+// for a pending migration.
+type Status struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time
+}
+
+// Migrator applies a service's migrations.
+type Migrator struct {
+	service    string
+	lockKey    int64
+	migrations []Migration
+}
+
+// New loads the migrations in the root of fsys for service. The service name
+// keys the advisory lock, so services sharing a database migrate
+// independently.
+func New(service string, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	h := fnv.New64a()
+	h.Write([]byte("schema_migrations:" + service))
+	return &Migrator{service: service, lockKey: int64(h.Sum64()), migrations: migrations}, nil
+}
+
+// Load reads the migrations in the root of fsys, sorted by version. Every
+// version needs an up script; down scripts are optional.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, e := range entries {
+		if e.IsDir() || path.Ext(e.Name()) != ".sql" {
+			continue
+		}
+		version, name, direction, err := parseName(e.Name())
+		if err != nil {
+			return nil, err
+		}
+		body, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", e.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if m.Name != name {
+			return nil, fmt.Errorf("migration %d has two names: %q and %q", version, m.Name, name)
+		}
+		switch direction {
+		case "up":
+			m.Up = string(body)
+		case "down":
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// parseName splits "0001_create_users.up.sql" into 1, "create_users", "up".
+func parseName(file string) (int64, string, string, error) {
+	base := strings.TrimSuffix(file, ".sql")
+	direction := path.Ext(base)
+	if direction != ".up" && direction != ".down" {
+		return 0, "", "", fmt.Errorf("migration %s: want .up.sql or .down.sql", file)
+	}
+	base = strings.TrimSuffix(base, direction)
+	num, name, ok := strings.Cut(base, "_")
+	if !ok || name == "" {
+		return 0, "", "", fmt.Errorf("migration %s: want <version>_<name>", file)
+	}
+	version, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, "", "", fmt.Errorf("migration %s: invalid version %q", file, num)
+	}
+	return version, name, strings.TrimPrefix(direction, "."), nil
+}
+
+// Up applies every pending migration in version order, each in its own
+// transaction, and returns the ones applied.
+func (m *Migrator) Up(ctx context.Context, conn *pgx.Conn) ([]Migration, error) {
+	var applied []Migration
+	err := m.locked(ctx, conn, func(done map[int64]time.Time) error {
+		for _, mg := range m.migrations {
+			if _, ok := done[mg.Version]; ok {
+				continue
+			}
+			if err := m.apply(ctx, conn, mg, mg.Up, true); err != nil {
+				return err
+			}
+			applied = append(applied, mg)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down rolls back the latest steps applied migrations, newest first, and
+// returns the ones rolled back.
+func (m *Migrator) Down(ctx context.Context, conn *pgx.Conn, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := m.locked(ctx, conn, func(done map[int64]time.Time) error {
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			mg := m.migrations[i]
+			if _, ok := done[mg.Version]; !ok {
+				continue
+			}
+			if mg.Down == "" {
+				return fmt.Errorf("%w: %d_%s", ErrNoDown, mg.Version, mg.Name)
+			}
+			if err := m.apply(ctx, conn, mg, mg.Down, false); err != nil {
+				return err
+			}
+			reverted = append(reverted, mg)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status lists every known migration and when it was applied.
+func (m *Migrator) Status(ctx context.Context, conn *pgx.Conn) ([]Status, error) {
+	var list []Status
+	err := m.locked(ctx, conn, func(done map[int64]time.Time) error {
+		for _, mg := range m.migrations {
+			s := Status{Version: mg.Version, Name: mg.Name}
+			if at, ok := done[mg.Version]; ok {
+				s.AppliedAt = &at
+			}
+			list = append(list, s)
+		}
+		return nil
+	})
+	return list, err
+}
+
+// locked takes the service's advisory lock on conn, makes sure the
+// schema_migrations table exists and calls fn with the applied versions.
+func (m *Migrator) locked(ctx context.Context, conn *pgx.Conn, fn func(done map[int64]time.Time) error) error {
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, m.lockKey); err != nil {
+		return fmt.Errorf("migrate %s: lock: %w", m.service, err)
+	}
+	// If unlocking fails, the lock is still released when the session ends.
+	defer func() { _, _ = conn.Exec(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, m.lockKey) }()
+
+	_, err := conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		return fmt.Errorf("migrate %s: create schema_migrations: %w", m.service, err)
+	}
+
+	rows, err := conn.Query(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return fmt.Errorf("migrate %s: read schema_migrations: %w", m.service, err)
+	}
+	done := map[int64]time.Time{}
+	for rows.Next() {
+		var v int64
+		var at time.Time
+		if err := rows.Scan(&v, &at); err != nil {
+			rows.Close()
+			return fmt.Errorf("migrate %s: scan schema_migrations: %w", m.service, err)
+		}
+		done[v] = at
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("migrate %s: read schema_migrations: %w", m.service, err)
+	}
+	return fn(done)
+}
+
+// apply runs script for mg and records (up) or forgets (down) its version in
+// one transaction.
+func (m *Migrator) apply(ctx context.Context, conn *pgx.Conn, mg Migration, script string, up bool) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("migrate %s: begin %d: %w", m.service, mg.Version, err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if _, err := tx.Exec(ctx, script); err != nil {
+		return fmt.Errorf("migrate %s: %d_%s: %w", m.service, mg.Version, mg.Name, err)
+	}
+	if up {
+		_, err = tx.Exec(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, mg.Version, mg.Name)
+	} else {
+		_, err = tx.Exec(ctx, `DELETE FROM schema_migrations WHERE version = $1`, mg.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("migrate %s: record %d: %w", m.service, mg.Version, err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("migrate %s: commit %d: %w", m.service, mg.Version, err)
+	}
+	return nil
+}
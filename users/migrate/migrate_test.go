@@ -0,0 +1,87 @@
+package migrate
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_add_index.up.sql":      {Data: []byte("CREATE INDEX i ON t (a);")},
+		"0001_create_table.up.sql":   {Data: []byte("CREATE TABLE t (a INT);")},
+		"0001_create_table.down.sql": {Data: []byte("DROP TABLE t;")},
+		"README.md":                  {Data: []byte("ignored")},
+	}
+	got, err := Load(fsys)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 migrations, got %d", len(got))
+	}
+	if got[0].Version != 1 || got[0].Name != "create_table" || got[0].Down != "DROP TABLE t;" {
+		t.Fatalf("unexpected first migration: %+v", got[0])
+	}
+	if got[1].Version != 2 || got[1].Down != "" {
+		t.Fatalf("unexpected second migration: %+v", got[1])
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+		want  string
+	}{
+		{
+			name:  "no direction",
+			files: fstest.MapFS{"0001_create.sql": {Data: []byte("x")}},
+			want:  "want .up.sql or .down.sql",
+		},
+		{
+			name:  "no name",
+			files: fstest.MapFS{"0001.up.sql": {Data: []byte("x")}},
+			want:  "want <version>_<name>",
+		},
+		{
+			name:  "bad version",
+			files: fstest.MapFS{"abc_create.up.sql": {Data: []byte("x")}},
+			want:  "invalid version",
+		},
+		{
+			name:  "down only",
+			files: fstest.MapFS{"0001_create.down.sql": {Data: []byte("x")}},
+			want:  "has no up script",
+		},
+		{
+			name: "conflicting names",
+			files: fstest.MapFS{
+				"0001_create.up.sql":  {Data: []byte("x")},
+				"0001_other.down.sql": {Data: []byte("x")},
+			},
+			want: "has two names",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Load(tc.files)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("Load error = %v, want %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestNewLockKeyPerService(t *testing.T) {
+	fsys := fstest.MapFS{"0001_create.up.sql": {Data: []byte("x")}}
+	a, err := New("users", fsys)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	b, _ := New("products", fsys)
+	a2, _ := New("users", fsys)
+	if a.lockKey == b.lockKey || a.lockKey != a2.lockKey {
+		t.Fatalf("lock keys: users=%d products=%d users again=%d", a.lockKey, b.lockKey, a2.lockKey)
+	}
+}
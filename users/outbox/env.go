@@ -0,0 +1,44 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// TransportFromEnv builds the transport named by OUTBOX_TRANSPORT:
+// "inprocess" (the default) hands events to a subscriber in this process
+// that logs them, "notify" sends them with Postgres NOTIFY on
+// OUTBOX_NOTIFY_CHANNEL (default channel) over db and "webhook" posts them
+// to every URL in OUTBOX_WEBHOOKS.
+func TransportFromEnv(db Execer, channel string) (Transport, error) {
+	switch kind := os.Getenv("OUTBOX_TRANSPORT"); kind {
+	case "", "inprocess":
+		p := NewInProcess()
+		p.Subscribe(func(ctx context.Context, e Event) error {
+			log.Printf("outbox: %s %s", e.Type, e.AggregateID)
+			return nil
+		})
+		return p, nil
+	case "notify":
+		if c := os.Getenv("OUTBOX_NOTIFY_CHANNEL"); c != "" {
+			channel = c
+		}
+		return NewNotify(db, channel), nil
+	case "webhook":
+		var urls []string
+		for _, u := range strings.Split(os.Getenv("OUTBOX_WEBHOOKS"), ",") {
+			if u = strings.TrimSpace(u); u != "" {
+				urls = append(urls, u)
+			}
+		}
+		if len(urls) == 0 {
+			return nil, fmt.Errorf("OUTBOX_WEBHOOKS must be set for the webhook transport")
+		}
+		return NewWebhook(urls), nil
+	default:
+		return nil, fmt.Errorf("unknown OUTBOX_TRANSPORT %q", kind)
+	}
+}
@@ -0,0 +1,46 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Handler consumes an event.
+type Handler func(ctx context.Context, e Event) error
+
+// InProcess delivers events to handlers in the same process. It suits
+// consumers that live in the publishing service, and tests.
+type InProcess struct {
+	mu       sync.RWMutex
+	handlers []Handler
+}
+
+// NewInProcess creates an in-process transport with no subscribers.
+func NewInProcess() *InProcess {
+	return &InProcess{}
+}
+
+// Subscribe registers h to receive every event published after it.
+func (p *InProcess) Subscribe(h Handler) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.handlers = append(p.handlers, h)
+}
+
+// Publish calls every handler in subscription order and returns their joined
+// errors. Every handler sees the event even if an earlier one fails, so
+// handlers must tolerate the redelivery that follows a failure.
+func (p *InProcess) Publish(ctx context.Context, e Event) error {
+	p.mu.RLock()
+	handlers := append([]Handler(nil), p.handlers...)
+	p.mu.RUnlock()
+
+	var errs []error
+	for _, h := range handlers {
+		if err := h(ctx, e); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
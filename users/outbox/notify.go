@@ -0,0 +1,79 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// maxNotifyPayload is the largest payload Postgres accepts in a NOTIFY.
+const maxNotifyPayload = 7999
+
+// Execer runs a statement; *pgxpool.Pool and *pgx.Conn satisfy it.
+type Execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// Notify delivers events with Postgres NOTIFY on a channel. Listeners that
+// are not connected when an event is sent never see it, so it suits
+// consumers that can resynchronise on reconnect.
+type Notify struct {
+	db      Execer
+	channel string
+}
+
+// NewNotify creates a transport that notifies channel through db.
+func NewNotify(db Execer, channel string) *Notify {
+	return &Notify{db: db, channel: channel}
+}
+
+// Publish sends e as the JSON payload of a notification.
+func (n *Notify) Publish(ctx context.Context, e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("Notify encode: %w", err)
+	}
+	if len(body) > maxNotifyPayload {
+		return fmt.Errorf("Notify: %s event %d is %d bytes, over the %d byte limit", e.Type, e.ID, len(body), maxNotifyPayload)
+	}
+	if _, err := n.db.Exec(ctx, `SELECT pg_notify($1, $2)`, n.channel, string(body)); err != nil {
+		return fmt.Errorf("Notify: %w", err)
+	}
+	return nil
+}
+
+// Listen subscribes conn to channel and calls h with every event notified on
+// it until ctx is cancelled or the connection fails. Notifications that are
+// not events are skipped.
+func Listen(ctx context.Context, conn *pgx.Conn, channel string, h Handler) error {
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+		return fmt.Errorf("Listen: %w", err)
+	}
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return fmt.Errorf("Listen: %w", err)
+		}
+		e, err := decodeNotification(n.Payload)
+		if err != nil {
+			continue
+		}
+		if err := h(ctx, e); err != nil {
+			return fmt.Errorf("Listen: handle %s event %d: %w", e.Type, e.ID, err)
+		}
+	}
+}
+
+func decodeNotification(payload string) (Event, error) {
+	var e Event
+	if err := json.Unmarshal([]byte(payload), &e); err != nil {
+		return Event{}, err
+	}
+	if e.Type == "" {
+		return Event{}, fmt.Errorf("notification is not an event")
+	}
+	return e, nil
+}
@@ -0,0 +1,112 @@
+// Package outbox publishes domain events that were written to an outbox table
+// in the same transaction as the change they describe, so an event is
+// published if and only if its change committed. A Relay reads pending events
+// in order and hands them to a pluggable Transport: in-process subscribers,
+// Postgres LISTEN/NOTIFY or HTTP webhooks.
+//
+// Delivery is at least once. An event whose delivery fails is retried on the
+// next run, and events after it wait so consumers see them in order; a relay
+// that crashes between delivering and marking an event delivers it again.
+// Consumers should ignore events whose (source, id) they have already seen.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// batchSize bounds how many events one relay run publishes.
+const batchSize = 100
+
+// Event is one domain event, as stored in the outbox and as delivered.
+type Event struct {
+	ID          int64           `json:"id"`
+	Source      string          `json:"source"`       // service that published the event
+	Type        string          `json:"type"`         // e.g. "product.created"
+	AggregateID string          `json:"aggregate_id"` // id of the entity the event is about
+	Payload     json.RawMessage `json:"payload"`
+	OccurredAt  time.Time       `json:"occurred_at"`
+}
+
+// Transport delivers events to consumers.
+type Transport interface {
+	Publish(ctx context.Context, e Event) error
+}
+
+// Store reads pending events from the outbox and records their delivery.
+type Store interface {
+	PendingEvents(ctx context.Context, limit int) ([]Event, error)
+	MarkEventPublished(ctx context.Context, id int64) error
+	MarkEventFailed(ctx context.Context, id int64, reason string) error
+}
+
+// Relay moves events from a Store to a Transport.
+type Relay struct {
+	source    string
+	store     Store
+	transport Transport
+}
+
+// NewRelay creates a relay that publishes the events of the source service.
+func NewRelay(source string, store Store, transport Transport) *Relay {
+	return &Relay{source: source, store: store, transport: transport}
+}
+
+// Result summarises one relay run.
+type Result struct {
+	Published int
+	Failed    int
+}
+
+// RunOnce publishes pending events oldest first. It stops at the first event
+// the transport rejects, recording the failure on it, so later events are
+// never delivered ahead of it.
+func (r *Relay) RunOnce(ctx context.Context) (Result, error) {
+	var res Result
+	events, err := r.store.PendingEvents(ctx, batchSize)
+	if err != nil {
+		return res, err
+	}
+
+	for _, e := range events {
+		e.Source = r.source
+		if err := r.transport.Publish(ctx, e); err != nil {
+			res.Failed++
+			log.Printf("outbox: publishing %s event %d failed: %v", e.Type, e.ID, err)
+			if ferr := r.store.MarkEventFailed(ctx, e.ID, err.Error()); ferr != nil {
+				return res, ferr
+			}
+			return res, nil
+		}
+		if err := r.store.MarkEventPublished(ctx, e.ID); err != nil {
+			return res, err
+		}
+		res.Published++
+	}
+	return res, nil
+}
+
+// Run calls RunOnce every interval until ctx is cancelled. A full batch is
+// followed straight away by the next one.
+func (r *Relay) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		res, err := r.RunOnce(ctx)
+		if err != nil {
+			log.Printf("outbox: run failed: %v", err)
+		}
+		if err == nil && res.Failed == 0 && res.Published == batchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
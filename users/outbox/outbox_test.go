@@ -0,0 +1,122 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeStore is an in-memory outbox.
+type fakeStore struct {
+	events    []Event
+	published map[int64]bool
+	failures  map[int64]string
+}
+
+func newFakeStore(events ...Event) *fakeStore {
+	return &fakeStore{events: events, published: map[int64]bool{}, failures: map[int64]string{}}
+}
+
+func (f *fakeStore) PendingEvents(ctx context.Context, limit int) ([]Event, error) {
+	var pending []Event
+	for _, e := range f.events {
+		if !f.published[e.ID] && len(pending) < limit {
+			pending = append(pending, e)
+		}
+	}
+	return pending, nil
+}
+
+func (f *fakeStore) MarkEventPublished(ctx context.Context, id int64) error {
+	f.published[id] = true
+	return nil
+}
+
+func (f *fakeStore) MarkEventFailed(ctx context.Context, id int64, reason string) error {
+	f.failures[id] = reason
+	return nil
+}
+
+// failingTransport rejects events whose id is in fail and records the rest.
+type failingTransport struct {
+	fail      map[int64]bool
+	delivered []Event
+}
+
+func (t *failingTransport) Publish(ctx context.Context, e Event) error {
+	if t.fail[e.ID] {
+		return errors.New("consumer down")
+	}
+	t.delivered = append(t.delivered, e)
+	return nil
+}
+
+func testEvent(id int64, typ string) Event {
+	return Event{ID: id, Type: typ, AggregateID: "1", Payload: json.RawMessage(`{}`), OccurredAt: time.Now()}
+}
+
+func deliveredIDs(events []Event) []int64 {
+	var ids []int64
+	for _, e := range events {
+		ids = append(ids, e.ID)
+	}
+	return ids
+}
+
+func TestRelayPublishesInOrder(t *testing.T) {
+	store := newFakeStore(testEvent(1, "product.created"), testEvent(2, "product.updated"), testEvent(3, "product.updated"))
+	transport := &failingTransport{}
+	relay := NewRelay("products", store, transport)
+
+	res, err := relay.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if res.Published != 3 || res.Failed != 0 {
+		t.Fatalf("result: got %+v", res)
+	}
+	if got := deliveredIDs(transport.delivered); !reflect.DeepEqual(got, []int64{1, 2, 3}) {
+		t.Fatalf("delivered: got %v", got)
+	}
+	for _, e := range transport.delivered {
+		if e.Source != "products" {
+			t.Fatalf("event %d source: got %q", e.ID, e.Source)
+		}
+	}
+
+	res, err = relay.RunOnce(context.Background())
+	if err != nil || res.Published != 0 {
+		t.Fatalf("second run: got %+v, %v; want nothing published", res, err)
+	}
+}
+
+func TestRelayStopsAtFailure(t *testing.T) {
+	store := newFakeStore(testEvent(1, "product.created"), testEvent(2, "product.updated"), testEvent(3, "product.updated"))
+	transport := &failingTransport{fail: map[int64]bool{2: true}}
+	relay := NewRelay("products", store, transport)
+
+	res, err := relay.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if res.Published != 1 || res.Failed != 1 {
+		t.Fatalf("result: got %+v", res)
+	}
+	if store.failures[2] == "" {
+		t.Fatalf("failure of event 2 was not recorded")
+	}
+	if got := deliveredIDs(transport.delivered); !reflect.DeepEqual(got, []int64{1}) {
+		t.Fatalf("delivered: got %v; event 3 must wait for event 2", got)
+	}
+
+	transport.fail = nil
+	if _, err := relay.RunOnce(context.Background()); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if got := deliveredIDs(transport.delivered); !reflect.DeepEqual(got, []int64{1, 2, 3}) {
+		t.Fatalf("delivered after retry: got %v", got)
+	}
+}
@@ -0,0 +1,107 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestInProcess(t *testing.T) {
+	p := NewInProcess()
+	var first, second []string
+	p.Subscribe(func(ctx context.Context, e Event) error {
+		first = append(first, e.Type)
+		return errors.New("first failed")
+	})
+	p.Subscribe(func(ctx context.Context, e Event) error {
+		second = append(second, e.Type)
+		return nil
+	})
+
+	err := p.Publish(context.Background(), testEvent(1, "product.created"))
+	if err == nil || !strings.Contains(err.Error(), "first failed") {
+		t.Fatalf("Publish: got %v, want the first handler's error", err)
+	}
+	if len(first) != 1 || len(second) != 1 {
+		t.Fatalf("handlers: got %v and %v, want both called once", first, second)
+	}
+}
+
+// fakeExecer records the statements it is asked to run.
+type fakeExecer struct {
+	sql  string
+	args []any
+}
+
+func (f *fakeExecer) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	f.sql, f.args = sql, args
+	return pgconn.CommandTag{}, nil
+}
+
+func TestNotify(t *testing.T) {
+	db := &fakeExecer{}
+	n := NewNotify(db, "products_events")
+
+	e := testEvent(7, "product.updated")
+	e.Source = "products"
+	if err := n.Publish(context.Background(), e); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if !strings.Contains(db.sql, "pg_notify") || len(db.args) != 2 || db.args[0] != "products_events" {
+		t.Fatalf("statement: got %q %v", db.sql, db.args)
+	}
+
+	got, err := decodeNotification(db.args[1].(string))
+	if err != nil {
+		t.Fatalf("decodeNotification: %v", err)
+	}
+	if got.ID != 7 || got.Type != "product.updated" || got.Source != "products" {
+		t.Fatalf("decoded event: got %+v", got)
+	}
+
+	big := testEvent(8, "product.updated")
+	big.Payload = json.RawMessage(`"` + strings.Repeat("x", maxNotifyPayload) + `"`)
+	if err := n.Publish(context.Background(), big); err == nil {
+		t.Fatalf("Publish: expected an error for a payload over the NOTIFY limit")
+	}
+
+	if _, err := decodeNotification(`{"hello":"world"}`); err == nil {
+		t.Fatalf("decodeNotification: expected an error for a non-event payload")
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var gotType string
+	var gotEvent Event
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotType = r.Header.Get("X-Event-Type")
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &gotEvent)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ok.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	e := testEvent(3, "product.created")
+	if err := NewWebhook([]string{ok.URL}).Publish(context.Background(), e); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if gotType != "product.created" || gotEvent.ID != 3 {
+		t.Fatalf("delivered: header %q, event %+v", gotType, gotEvent)
+	}
+
+	err := NewWebhook([]string{ok.URL, failing.URL}).Publish(context.Background(), e)
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Fatalf("Publish: got %v, want the failing URL's status", err)
+	}
+}
@@ -0,0 +1,60 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Webhook delivers events by posting them as JSON to a fixed list of URLs.
+type Webhook struct {
+	urls       []string
+	httpClient *http.Client
+}
+
+// NewWebhook creates a transport that posts to every url.
+func NewWebhook(urls []string) *Webhook {
+	return &Webhook{urls: urls, httpClient: &http.Client{Timeout: 5 * time.Second}}
+}
+
+// Publish posts e to every URL and returns the joined errors of those that
+// did not accept it with a 2xx status. The event type is also sent in the
+// X-Event-Type header.
+func (w *Webhook) Publish(ctx context.Context, e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("Webhook encode: %w", err)
+	}
+
+	var errs []error
+	for _, url := range w.urls {
+		if err := w.post(ctx, url, e.Type, body); err != nil {
+			errs = append(errs, fmt.Errorf("Webhook %s to %s: %w", e.Type, url, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (w *Webhook) post(ctx context.Context, url, eventType string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-Type", eventType)
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}